@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// altTextPrompt is the default instructions of the alt-text command, for the
+// providers that prompt models.
+const altTextPrompt = `Write the alt text of this image for a web page: one concise sentence (at most 125 characters) describing what it shows, for people who cannot see it, including any short text in it that is essential, and not starting with "Image of" or "Picture of".`
+
+// altTextMain implements the alt-text subcommand, which writes a concise
+// one-sentence description of each image, for the alt attributes of images
+// of web pages, and optionally a file mapping each image to it.
+func altTextMain(args []string) {
+	fs := newFlagSet("alt-text", "<filename or URL>...")
+	verbose := fs.Bool("v", false, "Verbose output")
+	var (
+		pf providerFlags
+		cf cacheFlags
+		rf rateFlags
+		lf loadFlags
+	)
+	pf.register(fs, "gemini")
+	cf.register(fs)
+	rf.register(fs)
+	lf.register(fs)
+	prompt := fs.String("prompt", altTextPrompt, "Instructions of --api=gemini, claude, ollama or cloudflare for the alt text of each image (other APIs caption images as usual)")
+	mapping := fs.String("mapping", "", "File to write the alt text of each image into, keyed by its path relative to --root, as JSON (.json), YAML (.yaml or .yml) or HTML <img> elements (.html), e.g. alt.json")
+	root := fs.String("root", ".", "Directory the keys of --mapping are relative to, e.g. the root of the static site")
+	recursive := fs.Bool("recursive", false, "Recursively walk directories for image files")
+	retries := fs.Int("retries", 3, "Number of times to retry requests that fail with transient errors")
+	concurrency := fs.Int("concurrency", 1, "Number of files to load and requests to send in parallel")
+	parseFlags(fs, args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return
+	}
+	write, err := newAltTextMapping(*mapping)
+	if err != nil {
+		fatal(err)
+	}
+	limiter, err := rf.limiter()
+	if err != nil {
+		fatal(err)
+	}
+	opts := vision.Options{
+		Prompt:      *prompt,
+		Concurrency: *concurrency,
+		Retries:     *retries,
+		RetryDelay:  time.Second,
+		Verbose:     *verbose,
+		RateLimit:   limiter,
+	}
+	ctx := context.Background()
+	p, err := pf.newProvider(ctx)
+	if err != nil {
+		fatal(err)
+	}
+	if p, err = cf.wrap(p); err != nil {
+		fatal(err)
+	}
+	lo := lf.options()
+	lo.applyDefaults(p.Name())
+	var summary runSummary
+	alts := make(map[string]string) // by key of the mapping
+	annotateChunks(ctx, p, expandPatterns(fs.Args(), *recursive, nil, false), lo, opts, &summary, func(_ []vision.Image, results []vision.Result) {
+		for _, r := range results {
+			if r.Err == nil && len(r.Description) == 0 {
+				r.Err = fmt.Errorf("no description, %s may not caption images", p.Name())
+			}
+			if r.Err != nil {
+				slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+				summary.failed++
+				continue
+			}
+			summary.succeeded++
+			alt := altText(r.Description)
+			fmt.Printf("%s: %s\n", r.Name, alt)
+			alts[altTextKey(r.Name, *root)] = alt
+		}
+	})
+	if write != nil {
+		if err := write(*mapping, alts); err != nil {
+			fatal(err)
+		}
+	}
+	if code := summary.exitCode(); code != 0 {
+		exitWith(code)
+	}
+}
+
+// altText returns the description of an image as alt text: a sentence
+// without the quotes or prefixes models sometimes wrap it in.
+func altText(description string) string {
+	alt := strings.TrimSpace(description)
+	for _, prefix := range []string{"Alt text:", "Alt:"} {
+		if len(alt) > len(prefix) && strings.EqualFold(alt[:len(prefix)], prefix) {
+			alt = strings.TrimSpace(alt[len(prefix):])
+		}
+	}
+	return strings.Trim(alt, "\"“”")
+}
+
+// altTextKey returns the key of the image filename in the mapping: its path
+// relative to root, with forward slashes as in URLs. URLs are kept as is.
+func altTextKey(filename, root string) string {
+	if !isLocalFile(filename) {
+		return filename
+	}
+	if rel, err := filepath.Rel(root, filename); err == nil {
+		filename = rel
+	}
+	return filepath.ToSlash(filename)
+}
+
+// newAltTextMapping returns the function writing the alt text of images, by
+// key, into the file path, in the format of its extension, or nil if path is
+// empty.
+func newAltTextMapping(path string) (func(path string, alts map[string]string) error, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return writeAltTextJSON, nil
+	case ".yaml", ".yml":
+		return writeAltTextYAML, nil
+	case ".html", ".htm":
+		return writeAltTextHTML, nil
+	}
+	return nil, fmt.Errorf("invalid --mapping(%s), must be a .json, .yaml, .yml or .html file", path)
+}
+
+func writeAltTextJSON(path string, alts map[string]string) error {
+	data, err := json.MarshalIndent(alts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// writeAltTextYAML writes a YAML mapping, of keys and values quoted as Go
+// strings, whose escapes those of YAML's double-quoted strings include.
+func writeAltTextYAML(path string, alts map[string]string) error {
+	var buf bytes.Buffer
+	for _, key := range sortedKeys(alts) {
+		fmt.Fprintf(&buf, "%s: %s\n", strconv.Quote(key), strconv.Quote(alts[key]))
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// writeAltTextHTML writes an <img> element per image, to paste into pages.
+func writeAltTextHTML(path string, alts map[string]string) error {
+	var buf bytes.Buffer
+	for _, key := range sortedKeys(alts) {
+		fmt.Fprintf(&buf, "<img src=\"%s\" alt=\"%s\">\n", html.EscapeString(key), html.EscapeString(alts[key]))
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
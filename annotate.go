@@ -0,0 +1,635 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/asimshankar/visionapi/pkg/metadata"
+	"github.com/asimshankar/visionapi/pkg/render"
+	"github.com/asimshankar/visionapi/pkg/vision"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// annotateMain implements the annotate command, the ocr and faces commands
+// which only differ in the default of --features, and the watch command which
+// annotates the images that appear in directories instead.
+func annotateMain(name, defaultFeatures string, args []string) {
+	usage := "<filename, URL or - for the standard input>..."
+	if name == "watch" {
+		usage = "<directory>..."
+	}
+	fs := newFlagSet(name, usage)
+	verbose := fs.Bool("v", false, "Verbose output")
+	var (
+		pf providerFlags
+		cf cacheFlags
+		rf rateFlags
+		lf loadFlags
+	)
+	pf.register(fs, "auto")
+	cf.register(fs)
+	rf.register(fs)
+	lf.register(fs)
+	features := fs.String("features", defaultFeatures, "Comma-separated list of features to detect: "+featureNames())
+	cropAspectRatio := fs.String("crop-aspect-ratio", "", "Aspect ratio (W:H or a number) of the crop hints requested with --features=crop-hints")
+	question := fs.String("question", "", "Question about each image answered in its description, instead of a caption (--api=gemini, claude, ollama or cloudflare only), e.g. \"How many people are in the photo?\"")
+	prompt := fs.String("prompt", "", "Instructions replacing the default prompt of --api=gemini, claude, ollama or cloudflare, whose response is the description of each image instead of a caption, e.g. \"Describe any safety hazards visible\" (the format of the response is still requested)")
+	classes := fs.String("classes", "", "Comma-separated list of the labels that --api=clip scores each image against, and that --api=gemini, claude or ollama choose labels from, e.g. cat,dog,car")
+	classesFile := fs.String("classes-file", "", "File of labels to use as --classes, one per line (e.g. a custom taxonomy), skipping blank lines and lines starting with #")
+	ocrLanguages := fs.String("ocr-languages", "", "Comma-separated list of the languages (BCP-47 codes, e.g. en,de,hi) of the text detected with --features=text or document, by default detected by the API (Microsoft only uses the first)")
+	translateTo := fs.String("translate-to", "", "Language (a BCP-47 code, e.g. fr) to translate the labels, objects, captions and text detected into, with --translator")
+	translator := fs.String("translator", "google", "Which translator to use with --translate-to: google (the Cloud Translation API, authenticated as --api=google) or the name of a plugin")
+	writeText := fs.Bool("write-text", false, "Write the text detected in each image to <filename>.txt (implies --features=text)")
+	output := fs.String("output", "text", "Output format: text, json, csv (one row per annotation), csv-wide (one row per file with the top --csv-labels labels), hocr or alto (the layout of --features=document), pdf (writes a searchable <filename>.pdf of each image), sqlite:FILE (writes into tables of a SQLite database, e.g. sqlite:annotations.db), html:FILE (writes a report with thumbnails, e.g. html:report.html), coco:FILE (writes the objects detected as a COCO dataset, e.g. coco:annotations.json), lightroom:FILE or digikam:FILE (writes the labels, objects, landmarks, celebrities and logos detected as a Lightroom keyword list or digiKam tag hierarchy, e.g. lightroom:keywords.txt), voc or yolo (write the objects detected in each image to a Pascal VOC <name>.xml or YOLO <name>.txt file alongside it)")
+	tmpl := fs.String("template", "", "Go text/template (see https://pkg.go.dev/text/template) to write each result with, instead of --output, e.g. '{{.File}}: {{range .Labels}}{{.Description}} {{end}}'")
+	pdfPerDir := fs.Bool("pdf-per-dir", false, "With --output=pdf, combine the images of each directory into one PDF named after the directory")
+	csvLabels := fs.Int("csv-labels", 5, "Number of labels per row with --output=csv-wide")
+	retries := fs.Int("retries", 3, "Number of times to retry requests that fail with transient errors")
+	retryDelay := fs.Duration("retry-delay", time.Second, "Initial delay between retries, which grows exponentially")
+	concurrency := fs.Int("concurrency", 1, "Number of files to load and requests to send in parallel")
+	batchBytes := fs.Int("batch-bytes", 0, "Maximum size of the images of each request of APIs that batch them (google), in bytes (default: the limit of the API, 8 MB)")
+	batchCount := fs.Int("batch-count", 0, "Maximum number of images per request of APIs that batch them (google) (default: the limit of the API, 16)")
+	download := fs.Bool("download", false, "Download http(s) URLs and gs:// and s3:// objects and send their content, instead of having the API fetch them")
+	var (
+		recursive bool
+		exclude   stringList
+	)
+	fs.BoolVar(&recursive, "recursive", false, "Recursively walk directories for image files")
+	fs.BoolVar(&recursive, "R", false, "Shorthand for --recursive")
+	fs.Var(&exclude, "exclude", "Glob of files or directories to skip, matched against the path and the name (can be repeated)")
+	writeMetadata := fs.Bool("write-metadata", false, "Add the detected labels to the XMP keywords (dc:subject) of each JPEG or PNG image, and to the IPTC-IIM keywords of each JPEG image")
+	sidecar := fs.Bool("sidecar", false, "With --write-metadata, write keywords to an XMP sidecar (e.g. photo.xmp) instead of modifying images")
+	renameTemplate := fs.String("rename-template", "", "Go text/template to rename each local image with, in its directory, with the fields of --template and .Date (taken, e.g. 2024-06-01), .Caption, .Label (the top label), .Base and .Ext, e.g. '{{.Date}}-{{.Caption | slug}}{{.Ext}}'")
+	sidecarJSON := fs.Bool("sidecar-json", false, "Write the result of each image, as with --output=json, to <filename>.vision.json alongside it")
+	gcsBucket := fs.String("gcs-bucket", "", "Google Cloud Storage bucket (and optional prefix, e.g. my-bucket/tmp) to stage PDF and TIFF files in, which are annotated asynchronously (only --features=text and document are supported)")
+	renderDir := fs.String("render-dir", "", "Write a copy of each image, with the bounding boxes of detected objects, faces, logos and text drawn onto it, as a PNG into this directory")
+	redactFaces := fs.String("redact-faces", "", "Write a copy of each image, with the faces detected in it blurred or pixelated (see --redact-style) and without metadata, into this directory (implies --features=faces)")
+	redactPlates := fs.String("redact-plates", "", "Write a copy of each image, with the license plates detected in it (as objects, or text formatted like plates) blurred or pixelated and without metadata, into this directory (implies --features=objects,text)")
+	redactStyle := fs.String("redact-style", render.Blur, "How --redact-faces and --redact-plates redact areas: "+render.Blur+" or "+render.Pixelate)
+	extractDir := fs.String("extract-faces", "", "Write each face detected in each image, cropped with --face-margin, as a JPEG named after the image and the index of the face (e.g. photo-face-0.jpg) into this directory (implies --features=faces)")
+	faceMargin := fs.Float64("face-margin", 0.2, "Margin around the faces written by --extract-faces, as a fraction of their size on every side")
+	geotag := fs.Bool("geotag", false, "Write the location of the most likely landmark into the Exif GPS metadata of each JPEG image (implies --features=landmarks)")
+	quarantineDir := fs.String("quarantine-dir", "", "Move images flagged as adult, violent or racy into this directory (implies --features=safe-search)")
+	quarantineThreshold := fs.Float64("quarantine-threshold", 0.75, "Likelihood in [0, 1] above which --quarantine-dir considers an image flagged")
+	minConfidence := fs.Float64("min-confidence", 0, "Drop labels, landmarks, logos and objects with a confidence (in [0, 1]) below this")
+	maxResults := fs.Int("max-results", 0, "Maximum number of labels, landmarks, logos and objects per image (0 for no limit)")
+	progress := fs.Bool("progress", isTerminal(os.Stderr), "Show a progress bar on stderr (default: if stderr is a terminal)")
+	frameInterval := fs.Duration("frame-interval", 5*time.Second, "Interval between the frames of videos (mp4 and mov files, which requires ffmpeg) to annotate, whose labels are merged")
+	gifFrames := fs.Int("gif-frames", 0, "Annotate every Nth frame of animated GIFs (e.g. 1 for all of them), whose labels are merged, instead of only the first frame")
+	fallback := fs.String("fallback", "", "Comma-separated list of APIs (e.g. aws,microsoft) to annotate images with, in turn, if --api fails for them with quota, authentication or transient errors")
+	minProviders := fs.Int("consensus-min-providers", 2, "With --api=all, the number of APIs that must report a label for it to be in the consensus (or all of those that succeeded, if fewer)")
+	dedupThreshold := fs.Int("dedup-threshold", -1, "Annotate only one of images that look alike (e.g. burst shots and resized copies), copying its result to the others, if their perceptual hashes differ in at most this many bits (of 64, e.g. 5; negative to annotate every image)")
+	sinceLastRun := fs.Bool("since-last-run", false, "Only annotate the files that are new, or whose content changed, since the last run of the same command and files (e.g. a nightly job over a growing library)")
+	resume := fs.Bool("resume", false, "Resume an interrupted run of the same command and files, annotating only the files it had not")
+	stateFile := fs.String("state-file", "", "File to checkpoint the progress of the run to, for --resume (default: a file in the cache directory specific to the command, files and working directory)")
+	var metricsAddr *string
+	if name == "watch" {
+		metricsAddr = fs.String("metrics-addr", "", "Address to serve Prometheus metrics on, at /metrics, while watching")
+	}
+	filelist := fs.String("filelist", "", "JSON Lines manifest of files (or URLs) to annotate, in addition to those in the arguments, each a JSON object with a \"path\" and optionally \"features\" (e.g. \"labels,text\"), \"languageHints\" (e.g. [\"en\"]) and an \"output\" file to write its result to as JSON")
+	stdinFilelist := fs.Bool("stdin-filelist", false, "Read the files (or URLs) to annotate, one per line, from the standard input, in addition to those in the arguments")
+	dryRun := fs.Bool("dry-run", false, "Load and validate the images, and print the number of requests and the estimated cost of annotating them, without calling the API")
+	parseFlags(fs, args)
+	if name == "watch" && (*dryRun || *resume || *stdinFilelist || len(*filelist) > 0 || *sinceLastRun) {
+		fatal(fmt.Errorf("--dry-run, --resume, --stdin-filelist, --filelist and --since-last-run are not supported by watch"))
+	}
+	patterns := fs.Args()
+	if *stdinFilelist {
+		for _, p := range patterns {
+			if p == stdinName {
+				fatal(fmt.Errorf("%s (an image on the standard input) cannot be used with --stdin-filelist", stdinName))
+			}
+		}
+		list, err := readFileList(os.Stdin)
+		if err != nil {
+			fatal(fmt.Errorf("unable to read --stdin-filelist: %v", err))
+		}
+		patterns = append(patterns, list...)
+	}
+	var (
+		m             *manifest
+		manifestPaths []string
+	)
+	if len(*filelist) > 0 {
+		var err error
+		if m, manifestPaths, err = readManifest(*filelist); err != nil {
+			fatal(fmt.Errorf("unable to read --filelist: %v", err))
+		}
+	}
+	if len(patterns) < 1 && len(manifestPaths) == 0 {
+		fs.Usage()
+		return
+	}
+	if *frameInterval <= 0 {
+		fatal(fmt.Errorf("invalid --frame-interval(%v), must be positive", *frameInterval))
+	}
+	vo := videoOptions{interval: *frameInterval, gifFrames: *gifFrames}
+	opts := vision.Options{
+		Concurrency:   *concurrency,
+		Retries:       *retries,
+		RetryDelay:    *retryDelay,
+		MinConfidence: *minConfidence,
+		MaxResults:    *maxResults,
+		Verbose:       *verbose,
+		Stats:         &vision.Stats{},
+		BatchBytes:    *batchBytes,
+		BatchCount:    *batchCount,
+	}
+	var err error
+	if opts.Features, err = vision.ParseFeatures(*features); err != nil {
+		fatal(err)
+	}
+	if opts.RateLimit, err = rf.limiter(); err != nil {
+		fatal(err)
+	}
+	if len(*cropAspectRatio) > 0 {
+		ratio, err := parseAspectRatio(*cropAspectRatio)
+		if err != nil {
+			fatal(err)
+		}
+		opts.CropAspectRatios = []float64{ratio}
+	}
+	opts.LanguageHints = splitList(*ocrLanguages)
+	opts.Question = *question
+	opts.Prompt = *prompt
+	opts.Classes = splitList(*classes)
+	if len(*classesFile) > 0 {
+		more, err := readClasses(*classesFile)
+		if err != nil {
+			fatal(err)
+		}
+		opts.Classes = append(opts.Classes, more...)
+	}
+	if (*output == "hocr" || *output == "alto" || *output == "pdf") && !opts.Has(vision.FeatureDocument) {
+		opts.Features = append(opts.RequestedFeatures(), vision.FeatureDocument)
+	}
+	if (strings.HasPrefix(*output, "coco:") || *output == "voc" || *output == "yolo") && !opts.Has(vision.FeatureObjects) {
+		opts.Features = append(opts.RequestedFeatures(), vision.FeatureObjects)
+	}
+	if *writeText && !opts.Has(vision.FeatureText) {
+		opts.Features = append(opts.RequestedFeatures(), vision.FeatureText)
+	}
+	// Faces and plates are redacted in the same copy of each image.
+	redactDir := *redactFaces
+	if len(*redactPlates) > 0 {
+		if len(redactDir) > 0 && filepath.Clean(redactDir) != filepath.Clean(*redactPlates) {
+			fatal(fmt.Errorf("--redact-faces and --redact-plates must be the same directory"))
+		}
+		redactDir = *redactPlates
+	}
+	if len(redactDir) > 0 && *redactStyle != render.Blur && *redactStyle != render.Pixelate {
+		fatal(fmt.Errorf("invalid --redact-style(%s), must be %q or %q", *redactStyle, render.Blur, render.Pixelate))
+	}
+	if *faceMargin < 0 {
+		fatal(fmt.Errorf("invalid --face-margin(%v), must not be negative", *faceMargin))
+	}
+	if (len(*redactFaces) > 0 || len(*extractDir) > 0) && !opts.Has(vision.FeatureFaces) {
+		opts.Features = append(opts.RequestedFeatures(), vision.FeatureFaces)
+	}
+	if len(*redactPlates) > 0 {
+		for _, f := range []vision.Feature{vision.FeatureObjects, vision.FeatureText} {
+			if !opts.Has(f) {
+				opts.Features = append(opts.RequestedFeatures(), f)
+			}
+		}
+	}
+	if *geotag && !opts.Has(vision.FeatureLandmarks) {
+		opts.Features = append(opts.RequestedFeatures(), vision.FeatureLandmarks)
+	}
+	if len(*quarantineDir) > 0 && !opts.Has(vision.FeatureSafeSearch) {
+		opts.Features = append(opts.RequestedFeatures(), vision.FeatureSafeSearch)
+	}
+	ctx := context.Background()
+	lo := lf.options()
+	lo.download = *download
+	if *dryRun {
+		cfg := pf.resolve()
+		names := []string{cfg.apiName()}
+		if cfg.api == "all" {
+			multi, err := newMultiProvider(ctx, cfg, cacheFlags{disabled: true}, *minProviders)
+			if err != nil {
+				fatal(err)
+			}
+			names = multi.names()
+		}
+		if len(names) == 1 {
+			lo.applyDefaults(names[0])
+		}
+		// TIFF files are only annotated as documents by a vision.FileProvider,
+		// with --gcs-bucket.
+		var tiffs bool
+		if len(*gcsBucket) > 0 && len(names) == 1 {
+			if p, err := newProvider(ctx, names[0], cfg); err == nil {
+				_, tiffs = p.(vision.FileProvider)
+			}
+		}
+		filenames, documents := splitDocuments(append(expandPatterns(patterns, recursive, exclude, true), manifestPaths...), tiffs)
+		filenames, videos := splitVideos(filenames, vo)
+		if len(videos) > 0 {
+			slog.Warn("Videos are not included in the estimate", "files", len(videos))
+		}
+		images, failed := loadImages(ctx, filenames, lo, opts.Concurrency)
+		for _, r := range failed {
+			slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+		}
+		for _, name := range names {
+			identity := name
+			if p, err := newProvider(ctx, name, cfg); err == nil {
+				identity = vision.Identity(p)
+			}
+			if err := printEstimate(os.Stdout, name, identity, cfg.azure.APIVersion, images, documents, opts, cf); err != nil {
+				fatal(err)
+			}
+		}
+		return
+	}
+	if metricsAddr != nil && len(*metricsAddr) > 0 {
+		cf.metrics = newMetrics()
+		cf.metrics.serve(*metricsAddr)
+	}
+	var (
+		cfg       = pf.resolve()
+		p, base   vision.Provider
+		multi     *multiProvider
+		fallbacks []string
+	)
+	if len(*fallback) > 0 {
+		fallbacks = strings.Split(strings.ToLower(*fallback), ",")
+	}
+	if cfg.api == "all" {
+		multi, err = newMultiProvider(ctx, cfg, cf, *minProviders)
+		p, base = multi, multi
+	} else if base, err = newProvider(ctx, cfg.apiName(), cfg); err == nil {
+		if base, fallbacks, err = routeUnsupported(ctx, base, fallbacks, cfg, &opts); err == nil {
+			p, err = cf.wrap(base)
+		}
+	}
+	if err == nil && len(fallbacks) > 0 {
+		p, err = newFallbackProvider(ctx, p, fallbacks, cfg, cf)
+	}
+	if err == nil && len(*translateTo) > 0 {
+		var t vision.Translator
+		if t, err = newTranslator(ctx, *translator, cfg); err == nil {
+			p = newTranslatingProvider(p, t, *translateTo)
+		}
+	}
+	if err != nil {
+		fatal(err)
+	}
+	out, err := newResultWriter(*output, os.Stdout, p.Name(), opts, outputOptions{
+		csvLabels:   *csvLabels,
+		pdfPerDir:   *pdfPerDir,
+		jpegQuality: lo.jpegQuality,
+		compare:     multi,
+		template:    *tmpl,
+	})
+	if err != nil {
+		fatal(err)
+	}
+	lo.applyDefaults(p.Name())
+	if m != nil {
+		m.provider = p.Name()
+	}
+	st, err := newRunState(*stateFile, name, fs.Args())
+	if err != nil {
+		fatal(err)
+	}
+	var idx *fileIndex
+	if *sinceLastRun {
+		if idx, err = loadFileIndex(st); err != nil {
+			fatal(err)
+		}
+	}
+	var rn *renamer
+	if len(*renameTemplate) > 0 {
+		if rn, err = newRenamer(*renameTemplate); err != nil {
+			fatal(err)
+		}
+	}
+	var filenames []string
+	switch {
+	case name == "watch":
+		// The arguments are the directories to watch.
+	case *resume:
+		if filenames, err = st.load(); err != nil {
+			fatal(err)
+		}
+		slog.Info("Resuming an interrupted run", "remaining", len(filenames), "done", st.Done)
+	default:
+		filenames = append(expandPatterns(patterns, recursive, exclude, len(*gcsBucket) > 0), manifestPaths...)
+		if idx != nil {
+			filenames = idx.changed(filenames)
+		}
+	}
+	var (
+		start   = time.Now()
+		bar     *progressBar
+		summary runSummary
+	)
+	if *progress && name != "watch" {
+		bar = newProgressBar(os.Stderr, len(filenames), opts.Stats)
+		if multi != nil {
+			bar.perFile = len(multi.providers)
+		}
+		logOutput.set(bar)
+	}
+	fail := func(err error) {
+		if bar != nil {
+			bar.Close()
+			logOutput.set(os.Stderr)
+		}
+		fatal(err)
+	}
+	// write outputs results, of images (if not documents), and acts on them.
+	write := func(ctx context.Context, results []vision.Result, images []vision.Image) {
+		ctx, span := tracer.Start(ctx, "Write", trace.WithAttributes(attribute.Int("results", len(results))))
+		defer span.End()
+		for i, r := range results {
+			var img *vision.Image
+			if i < len(images) {
+				img = &images[i]
+			}
+			if err := m.write(out, r, img); err != nil {
+				fail(err)
+			}
+			if r.Err != nil {
+				summary.failed++
+			} else {
+				summary.succeeded++
+			}
+			if r.Err == nil && *writeText && isLocalFile(r.Name) {
+				if err := ioutil.WriteFile(r.Name+".txt", []byte(r.Text), 0644); err != nil {
+					slog.Error("Unable to write text", "file", r.Name, "err", err)
+				}
+			}
+			if r.Err == nil && *writeMetadata && isLocalFile(r.Name) {
+				if err := writeKeywords(r, *sidecar); err != nil {
+					slog.Error("Unable to write metadata", "file", r.Name, "err", err)
+				}
+			}
+			if r.Err == nil && *sidecarJSON && isLocalFile(r.Name) {
+				if err := writeSidecarJSON(r, p.Name(), multi); err != nil {
+					slog.Error("Unable to write JSON sidecar", "file", r.Name, "err", err)
+				}
+			}
+			if r.Err == nil && *geotag && isLocalFile(r.Name) {
+				if err := writeLocation(r); err != nil {
+					slog.Error("Unable to geotag", "file", r.Name, "err", err)
+				}
+			}
+			if r.Err == nil && len(*renderDir) > 0 && i < len(images) {
+				if dest, err := renderResult(ctx, images[i], r, *renderDir); err != nil {
+					slog.Error("Unable to render", "file", r.Name, "err", err)
+				} else {
+					slog.Info("Rendered", "file", r.Name, "dest", dest)
+				}
+			}
+			if r.Err == nil && len(redactDir) > 0 && i < len(images) {
+				areas := redactedAreas(r, len(*redactFaces) > 0, len(*redactPlates) > 0)
+				if dest, err := redactImage(ctx, images[i], r, areas, *redactStyle, lo.jpegQuality, lo.orient, redactDir); err != nil {
+					slog.Error("Unable to redact", "file", r.Name, "err", err)
+				} else {
+					slog.Info("Redacted", "file", r.Name, "areas", len(areas), "dest", dest)
+				}
+			}
+			if r.Err == nil && len(*extractDir) > 0 && i < len(images) {
+				if paths, err := extractFaces(ctx, images[i], r, *faceMargin, lo.jpegQuality, lo.orient, *extractDir); err != nil {
+					slog.Error("Unable to extract faces", "file", r.Name, "err", err)
+				} else if len(paths) > 0 {
+					slog.Info("Extracted faces", "file", r.Name, "faces", len(paths), "dir", *extractDir)
+				}
+			}
+			// Moving the image must come last, as its path changes.
+			quarantined := false
+			if r.Err == nil && len(*quarantineDir) > 0 && isLocalFile(r.Name) && isFlagged(r.SafeSearch, *quarantineThreshold) {
+				if dest, err := quarantine(r.Name, *quarantineDir); err != nil {
+					slog.Error("Unable to quarantine", "file", r.Name, "err", err)
+				} else {
+					slog.Info("Quarantined", "file", r.Name, "dest", dest)
+					quarantined = true
+				}
+			}
+			path := r.Name
+			if r.Err == nil && rn != nil && !quarantined && isLocalFile(r.Name) {
+				if dest, err := rn.rename(r); err != nil {
+					slog.Error("Unable to rename", "file", r.Name, "err", err)
+				} else if dest != r.Name {
+					slog.Info("Renamed", "file", r.Name, "dest", dest)
+					path = dest
+				}
+			}
+			// Only once the image is no longer changed (e.g. by
+			// --write-metadata and --geotag), so that it is not annotated
+			// again by --since-last-run.
+			if r.Err == nil && idx != nil && !quarantined {
+				idx.record(path)
+			}
+			if multi != nil {
+				multi.forget(r.Name)
+			}
+		}
+	}
+	// skip outputs the failures of files that were not sent to the provider.
+	skip := func(failed []vision.Result) {
+		if bar != nil {
+			bar.Skip(len(failed))
+		}
+		summary.skipped += len(failed)
+		for _, r := range failed {
+			if err := m.write(out, r, nil); err != nil {
+				fail(err)
+			}
+		}
+	}
+	if name == "watch" {
+		// Images written by annotating others must not be annotated too.
+		skipDirs := exclude
+		for _, dir := range []string{*renderDir, redactDir, *extractDir, *quarantineDir} {
+			if len(dir) > 0 {
+				skipDirs = append(skipDirs, filepath.Clean(dir))
+			}
+		}
+		w, err := newWatcher(fs.Args(), recursive, skipDirs)
+		if err != nil {
+			fatal(err)
+		}
+		// Until interrupted, after which the output is closed as usual.
+		wctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		slog.Info("Watching for images", "dirs", strings.Join(fs.Args(), ","))
+		err = w.run(wctx, func(filenames []string) {
+			ctx, span := tracer.Start(ctx, "Batch", trace.WithAttributes(attribute.Int("files", len(filenames))))
+			defer span.End()
+			images, failed := loadImages(ctx, filenames, lo, opts.Concurrency)
+			skip(failed)
+			results, err := p.Annotate(ctx, images, opts)
+			if err != nil {
+				// E.g., the API is unreachable, which it may not be for
+				// later images.
+				slog.Error("Unable to annotate", "files", len(images), "err", err)
+				spanError(span, err)
+				return
+			}
+			write(ctx, results, images)
+		})
+		stop()
+		if err != nil {
+			fatal(err)
+		}
+	}
+	var dd *deduper
+	if *dedupThreshold >= 0 {
+		dd = &deduper{threshold: *dedupThreshold}
+	}
+	_, isFileProvider := base.(vision.FileProvider)
+	filenames, documents := splitDocuments(filenames, len(*gcsBucket) > 0 && isFileProvider)
+	filenames, videos := splitVideos(filenames, vo)
+	// Videos and documents are annotated after images.
+	pending := func(filenames []string) []string {
+		return append(append(filenames[:len(filenames):len(filenames)], videos...), documents...)
+	}
+	// Images are loaded and annotated a chunk at a time (see chunkFiles),
+	// checkpointing the files that remain after each.
+	perChunk := lo.chunkFiles(opts.Concurrency)
+	for len(filenames) > 0 {
+		n := min(len(filenames), checkpointFiles, perChunk)
+		bctx, span := tracer.Start(ctx, "Batch", trace.WithAttributes(attribute.Int("files", n)))
+		images, failed := loadImages(bctx, filenames[:n], lo, opts.Concurrency)
+		skip(failed)
+		results, err := dd.annotate(images, func(unique []vision.Image) ([]vision.Result, error) {
+			if bar != nil {
+				bar.Skip(len(images) - len(unique))
+			}
+			return m.annotate(bctx, p, unique, opts)
+		})
+		if err != nil {
+			fail(err)
+		}
+		write(bctx, results, images)
+		span.End()
+		filenames = filenames[n:]
+		if err := st.checkpoint(n, pending(filenames)); err != nil {
+			fail(err)
+		}
+	}
+	for len(videos) > 0 {
+		r, err := annotateVideo(ctx, p, videos[0], vo, lo, m.options(videos[0], opts))
+		if err != nil {
+			fail(err)
+		}
+		write(ctx, []vision.Result{r}, nil)
+		videos = videos[1:]
+		if err := st.checkpoint(1, pending(nil)); err != nil {
+			fail(err)
+		}
+	}
+	if len(documents) > 0 {
+		files, failed := loadDocuments(ctx, documents)
+		fileProvider, ok := base.(vision.FileProvider)
+		if len(files) > 0 && (len(*gcsBucket) == 0 || !ok) {
+			err := fmt.Errorf("PDF files require --gcs-bucket")
+			if !ok {
+				err = fmt.Errorf("PDF files are not supported by %s", base.Name())
+			}
+			for _, f := range files {
+				failed = append(failed, vision.Result{Name: f.Name, Err: err})
+			}
+			files = nil
+		}
+		skip(failed)
+		var results []vision.Result
+		if len(files) > 0 {
+			// Multi-page files are not cached.
+			if results, err = fileProvider.AnnotateFiles(ctx, files, *gcsBucket, opts); err != nil {
+				fail(err)
+			}
+		}
+		write(ctx, results, nil)
+	}
+	if bar != nil {
+		bar.Close()
+		logOutput.set(os.Stderr)
+	}
+	if err := out.Close(); err != nil {
+		fatal(err)
+	}
+	if err := st.remove(); err != nil {
+		fatal(err)
+	}
+	if idx != nil {
+		if err := idx.save(); err != nil {
+			fatal(err)
+		}
+	}
+	summary.log(opts.Stats.Snapshot(), time.Since(start))
+	if code := summary.exitCode(); code != 0 {
+		exitWith(code)
+	}
+}
+
+// readClasses returns the labels of the file of --classes-file, one per line,
+// skipping blank lines and comments (starting with #).
+func readClasses(filename string) ([]string, error) {
+	byts, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var classes []string
+	for _, line := range strings.Split(string(byts), "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 && !strings.HasPrefix(line, "#") {
+			classes = append(classes, line)
+		}
+	}
+	if len(classes) == 0 {
+		return nil, fmt.Errorf("no classes in %s", filename)
+	}
+	return classes, nil
+}
+
+// writeLocation sets the GPS coordinates of the image to those of its most
+// likely landmark, if any.
+func writeLocation(r vision.Result) error {
+	for _, l := range r.Landmarks {
+		if l.Location != nil {
+			slog.Info("Geotagging", "file", r.Name, "location", l.Location.String(), "landmark", l.Description)
+			return metadata.SetGPS(r.Name, l.Location.Latitude, l.Location.Longitude)
+		}
+	}
+	return nil
+}
+
+// writeKeywords adds the labels of r to the XMP metadata of the image (or of
+// its sidecar).
+func writeKeywords(r vision.Result, sidecar bool) error {
+	keywords := labelDescriptions(r.Labels)
+	if len(keywords) == 0 {
+		return nil
+	}
+	if sidecar {
+		return metadata.AddSidecarKeywords(r.Name, keywords)
+	}
+	return metadata.AddKeywords(r.Name, keywords)
+}
+
+// writeSidecarJSON writes r, as written by --output=json (with the results of
+// each provider of compare, if any), to <filename>.vision.json, so that the
+// result travels with the image.
+func writeSidecarJSON(r vision.Result, provider string, compare *multiProvider) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := (&jsonWriter{enc, provider, compare}).Write(r); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.Name+".vision.json", buf.Bytes(), 0644)
+}
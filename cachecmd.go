@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+)
+
+// cacheMain implements the cache command, which prints the location
+// ("dir") or size ("stats") of the cache of results, or removes all of its
+// entries ("clear").
+func cacheMain(args []string) {
+	fs := newFlagSet("cache", "dir|stats|clear")
+	var cf cacheFlags
+	cf.register(fs)
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return
+	}
+	// The cache is inspected even if it is disabled by --no-cache.
+	cf.disabled = false
+	c, err := cf.open()
+	if err != nil {
+		fatal(err)
+	}
+	switch fs.Arg(0) {
+	case "dir":
+		fmt.Println(c.Dir())
+	case "stats":
+		entries, bytes, err := c.Size()
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Printf("%s: %d results, %s\n", c.Dir(), entries, formatBytes(bytes))
+	case "clear":
+		entries, _, err := c.Size()
+		if err != nil {
+			fatal(err)
+		}
+		if err := c.Clear(); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("Removed %d results from %s\n", entries, c.Dir())
+	default:
+		fatal(fmt.Errorf("invalid cache command %q, must be 'dir', 'stats' or 'clear'", fs.Arg(0)))
+	}
+}
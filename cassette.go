@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// cassetteFlags record the HTTP requests of all commands (to the APIs, and
+// downloads of images) and their responses into a file, a cassette, or replay
+// them from one instead of making the requests.
+type cassetteFlags struct {
+	record string
+	replay string
+	tr     *cassetteTransport
+}
+
+var cassette cassetteFlags
+
+func (cf *cassetteFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&cf.record, "record", "", "Record the requests to the APIs and their responses into this file (a cassette, e.g. cassette.json), for --replay")
+	fs.StringVar(&cf.replay, "replay", "", "Replay the responses recorded by --record in this file instead of calling the APIs, which then need no credentials")
+}
+
+// active returns true if recording or replaying, in which case results are not
+// cached, so that every request is recorded or replayed.
+func (cf *cassetteFlags) active() bool { return cf.tr != nil }
+
+// setup records or replays the requests made with the default transport,
+// which is used by all the providers.
+func (cf *cassetteFlags) setup() error {
+	switch {
+	case len(cf.record) > 0 && len(cf.replay) > 0:
+		return fmt.Errorf("only one of --record and --replay can be set")
+	case len(cf.record) > 0:
+		cf.tr = &cassetteTransport{base: http.DefaultTransport}
+	case len(cf.replay) > 0:
+		data, err := ioutil.ReadFile(cf.replay)
+		if err != nil {
+			return err
+		}
+		cf.tr = &cassetteTransport{}
+		if err := json.Unmarshal(data, &cf.tr.interactions); err != nil {
+			return fmt.Errorf("unable to parse %s: %v", cf.replay, err)
+		}
+		// The AWS SDK signs requests, which are not sent, with credentials
+		// from the environment.
+		for k, v := range map[string]string{"AWS_ACCESS_KEY_ID": "replay", "AWS_SECRET_ACCESS_KEY": "replay", "AWS_REGION": "us-east-1"} {
+			if len(os.Getenv(k)) == 0 {
+				os.Setenv(k, v)
+			}
+		}
+	default:
+		return nil
+	}
+	http.DefaultTransport = cf.tr
+	return nil
+}
+
+// credentials sets placeholders for the keys and endpoints that are not set in
+// pf, when replaying.
+func (cf *cassetteFlags) credentials(pf *providerFlags) {
+	if len(cf.replay) == 0 {
+		return
+	}
+	if len(pf.google.APIKey) == 0 && len(pf.google.CredentialsFile) == 0 {
+		pf.google.APIKey = "replay"
+	}
+	if len(pf.microsoftKey) == 0 {
+		pf.microsoftKey = "replay"
+	}
+	if len(pf.azure.Endpoint) == 0 && len(pf.azure.Region) == 0 {
+		pf.azure.Endpoint = "https://replay.invalid"
+	}
+	if len(pf.gemini.APIKey) == 0 {
+		pf.gemini.APIKey = "replay"
+	}
+	if len(pf.claude.APIKey) == 0 {
+		pf.claude.APIKey = "replay"
+	}
+	if len(pf.huggingFace.Token) == 0 {
+		pf.huggingFace.Token = "replay"
+	}
+	if len(pf.clarifai.PAT) == 0 {
+		pf.clarifai.PAT = "replay"
+	}
+	if len(pf.imagga.Key) == 0 {
+		pf.imagga.Key, pf.imagga.Secret = "replay", "replay"
+	}
+	if len(pf.cloudflare.AccountID) == 0 || len(pf.cloudflare.Token) == 0 {
+		pf.cloudflare.AccountID, pf.cloudflare.Token = "replay", "replay"
+	}
+	if len(pf.replicate.Token) == 0 {
+		pf.replicate.Token = "replay"
+	}
+}
+
+// close writes the cassette being recorded.
+func (cf *cassetteFlags) close() error {
+	if len(cf.record) == 0 || cf.tr == nil {
+		return nil
+	}
+	cf.tr.mu.Lock()
+	defer cf.tr.mu.Unlock()
+	data, err := json.MarshalIndent(cf.tr.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(cf.record, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("unable to write %s: %v", cf.record, err)
+	}
+	return nil
+}
+
+// interaction is a recorded request and its response. Requests are identified
+// by their method, path and body, and not the host or headers (e.g., with
+// keys), so that they can be replayed with other credentials.
+type interaction struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// RequestSHA256 is the hash of the body of the request.
+	RequestSHA256 string      `json:"requestSHA256"`
+	Status        int         `json:"status"`
+	Header        http.Header `json:"header,omitempty"`
+	// Body of the response if it is text (e.g. JSON), and otherwise
+	// BinaryBody (e.g. of downloaded images).
+	Body       string `json:"body,omitempty"`
+	BinaryBody []byte `json:"binaryBody,omitempty"`
+	used       bool
+}
+
+// cassetteHiddenQuery are the query parameters that are not recorded, as they
+// are credentials.
+var cassetteHiddenQuery = []string{"key", "subscription-key"}
+
+// cassetteHeader are the response headers that are recorded.
+var cassetteHeader = []string{"Content-Type", "Retry-After"}
+
+// cassetteTransport records the requests made with base, or if base is nil
+// replays the responses to interactions.
+type cassetteTransport struct {
+	base         http.RoundTripper
+	mu           sync.Mutex
+	interactions []*interaction
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	h := sha256.Sum256(body)
+	hash := hex.EncodeToString(h[:])
+	if t.base == nil {
+		return t.replay(req, hash)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || isTokenRequest(req) {
+		return resp, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	u := *req.URL
+	q := u.Query()
+	for _, k := range cassetteHiddenQuery {
+		q.Del(k)
+	}
+	u.RawQuery = q.Encode()
+	in := &interaction{Method: req.Method, URL: u.String(), RequestSHA256: hash, Status: resp.StatusCode, Header: make(http.Header)}
+	for _, k := range cassetteHeader {
+		if v := resp.Header.Get(k); len(v) > 0 {
+			in.Header.Set(k, v)
+		}
+	}
+	if utf8.Valid(respBody) {
+		in.Body = string(respBody)
+	} else {
+		in.BinaryBody = respBody
+	}
+	t.mu.Lock()
+	t.interactions = append(t.interactions, in)
+	t.mu.Unlock()
+	return resp, nil
+}
+
+// replay returns the response to the first recorded request like req that was
+// not yet replayed, or the last of them if all were (e.g., when retrying).
+func (t *cassetteTransport) replay(req *http.Request, hash string) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var match *interaction
+	for _, in := range t.interactions {
+		if in.Method != req.Method || in.RequestSHA256 != hash || !samePath(in.URL, req) {
+			continue
+		}
+		match = in
+		if !in.used {
+			break
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no response to %s %s recorded in %s", req.Method, req.URL.Path, cassette.replay)
+	}
+	match.used = true
+	body := match.BinaryBody
+	if len(body) == 0 {
+		body = []byte(match.Body)
+	}
+	header := match.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", match.Status, http.StatusText(match.Status)),
+		StatusCode:    match.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// isTokenRequest returns true for requests for OAuth tokens (e.g., by
+// Application Default Credentials), which are not recorded as the responses
+// are credentials, and are not needed to replay.
+func isTokenRequest(req *http.Request) bool {
+	return strings.HasSuffix(req.URL.Path, "/token") || req.URL.Host == "metadata.google.internal" || req.URL.Host == "169.254.169.254"
+}
+
+// samePath returns true if the recorded url has the path of req. Hosts are
+// not compared, as they depend on the endpoint or region configured.
+func samePath(recorded string, req *http.Request) bool {
+	r, err := req.URL.Parse(recorded)
+	return err == nil && r.Path == req.URL.Path
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// allProviders are the providers used by --api=all, if configured.
+var allProviders = []string{"google", "microsoft", "aws"}
+
+// multiProvider annotates images with several providers concurrently,
+// returning the consensus of their results, and recording the result of each
+// for comparison.
+type multiProvider struct {
+	providers []vision.Provider
+	// minProviders is the number of providers that must report a label for
+	// it to be in the consensus (or all of them that succeeded, if fewer).
+	minProviders int
+	mu           sync.Mutex
+	results      map[string][]vision.Result // by image name, one per provider
+}
+
+// newMultiProvider returns a multiProvider of those of allProviders that are
+// configured (e.g., with credentials set), with results cached as per cf.
+func newMultiProvider(ctx context.Context, cfg providerFlags, cf cacheFlags, minProviders int) (*multiProvider, error) {
+	m := &multiProvider{minProviders: minProviders, results: make(map[string][]vision.Result)}
+	for _, name := range allProviders {
+		p, err := newProvider(ctx, name, cfg)
+		if err != nil {
+			slog.Warn("Not using API", "api", name, "err", err)
+			continue
+		}
+		if p, err = cf.wrap(p); err != nil {
+			return nil, err
+		}
+		m.providers = append(m.providers, p)
+	}
+	if len(m.providers) == 0 {
+		return nil, fmt.Errorf("none of %v are configured", allProviders)
+	}
+	slog.Info("Comparing APIs", "apis", strings.Join(m.names(), ","))
+	return m, nil
+}
+
+func (m *multiProvider) Name() string { return "all" }
+
+// Capabilities are those of any of the providers, as the others fail the
+// images of the features they do not support, which are still compared.
+func (m *multiProvider) Capabilities() []vision.Feature {
+	var features []vision.Feature
+	for _, f := range vision.AllFeatures {
+		for _, p := range m.providers {
+			if len(vision.Unsupported(p, []vision.Feature{f})) == 0 {
+				features = append(features, f)
+				break
+			}
+		}
+	}
+	return features
+}
+
+func (m *multiProvider) names() []string {
+	names := make([]string, len(m.providers))
+	for i, p := range m.providers {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+func (m *multiProvider) Annotate(ctx context.Context, images []vision.Image, opts vision.Options) ([]vision.Result, error) {
+	var (
+		all  = make([][]vision.Result, len(m.providers))
+		errs = make([]error, len(m.providers))
+	)
+	parallel.For(len(m.providers), len(m.providers), func(i int) {
+		all[i], errs[i] = m.providers[i].Annotate(ctx, images, opts)
+	})
+	for i, err := range errs {
+		// A provider that cannot annotate any image (e.g., as it does not
+		// support a feature) fails each of them, and the others are still
+		// compared.
+		if err != nil {
+			all[i] = make([]vision.Result, len(images))
+			for j, img := range images {
+				all[i][j] = vision.Result{Name: img.Name, Err: err}
+			}
+		}
+	}
+	results := make([]vision.Result, len(images))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for j, img := range images {
+		per := make([]vision.Result, len(m.providers))
+		for i := range m.providers {
+			per[i] = all[i][j]
+		}
+		m.results[img.Name] = per
+		results[j] = m.merge(img.Name, per)
+	}
+	return results, nil
+}
+
+// merge returns the consensus of the results of each provider: the labels
+// reported by at least minProviders of them, and the other annotations of the
+// first provider that succeeded.
+func (m *multiProvider) merge(name string, per []vision.Result) vision.Result {
+	var (
+		merged  vision.Result
+		labels  [][]vision.Label
+		failed  []string
+		succeed bool
+	)
+	for i, r := range per {
+		if r.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", m.providers[i].Name(), r.Err))
+			continue
+		}
+		if !succeed {
+			merged, succeed = r, true
+		}
+		labels = append(labels, r.Labels)
+	}
+	merged.Name = name
+	if !succeed {
+		merged.Err = fmt.Errorf("%s", strings.Join(failed, "; "))
+		return merged
+	}
+	merged.Labels = vision.MergeLabels(min(m.minProviders, len(labels)), labels...)
+	return merged
+}
+
+// compared returns the names of the providers and their results for the image
+// named name.
+func (m *multiProvider) compared(name string) ([]string, []vision.Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.names(), m.results[name]
+}
+
+// forget drops the results of each provider for the image named name, once
+// its result is written, so that they are not kept for the rest of the run.
+func (m *multiProvider) forget(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.results, name)
+}
+
+// writeComparison writes a table of the labels of r (the consensus) and their
+// confidence as reported by each provider.
+func (m *multiProvider) writeComparison(w io.Writer, r vision.Result) error {
+	names, per := m.compared(r.Name)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s: labels:\t%s\tconsensus\n", r.Name, strings.Join(names, "\t"))
+	for _, l := range r.Labels {
+		row := []string{"  " + l.Description}
+		for _, pr := range per {
+			confidence := "-"
+			if pr.Err != nil {
+				confidence = "error"
+			}
+			// The label may be a synonym of the one reported by the provider,
+			// and the highest confidence of its synonyms counts.
+			var best float64
+			for _, pl := range pr.Labels {
+				if vision.NormalizeLabel(pl.Description) == vision.NormalizeLabel(l.Description) && pl.Confidence >= best {
+					best = pl.Confidence
+					confidence = fmt.Sprintf("%.2f", pl.Confidence)
+				}
+			}
+			row = append(row, confidence)
+		}
+		row = append(row, fmt.Sprintf("%.2f", l.Confidence))
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for i, pr := range per {
+		if pr.Err != nil {
+			slog.Error("Unable to annotate", "file", r.Name, "api", names[i], "err", pr.Err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configEnvVar overrides the location of the configuration file.
+const configEnvVar = "VISIONAPI_CONFIG"
+
+// configEntry is a line of the configuration file, "key = value", where key is
+// the name of a flag (applied to every command with that flag) or
+// command.flag (applied only to that command).
+type configEntry struct {
+	key, value string
+}
+
+// configPath returns the location of the configuration file, e.g.
+// ~/.config/visionapi/config on Linux.
+func configPath() (string, error) {
+	if path := os.Getenv(configEnvVar); len(path) > 0 {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "visionapi", "config"), nil
+}
+
+// readConfig returns the entries of the configuration file at path, which
+// need not exist. Blank lines and lines starting with # are ignored.
+func readConfig(path string) ([]configEntry, error) {
+	byts, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []configEntry
+	scanner := bufio.NewScanner(bytes.NewReader(byts))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		entries = append(entries, configEntry{strings.TrimSpace(key), strings.TrimSpace(value)})
+	}
+	return entries, scanner.Err()
+}
+
+func writeConfig(path string, entries []configEntry) error {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintf(&buf, "%s = %s\n", e.key, e.value)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// The configuration may contain keys.
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// applyConfig sets the flags of fs from the configuration file, with entries
+// for the command (fs.Name()) taking precedence over those for all commands.
+// Keys for flags that fs does not have are ignored.
+func applyConfig(fs *flag.FlagSet) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	entries, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	for _, scoped := range []bool{false, true} {
+		for _, e := range entries {
+			name := e.key
+			if command, f, ok := strings.Cut(e.key, "."); ok {
+				if !scoped || command != fs.Name() {
+					continue
+				}
+				name = f
+			} else if scoped {
+				continue
+			}
+			if fs.Lookup(name) == nil {
+				continue
+			}
+			if err := fs.Set(name, e.value); err != nil {
+				return fmt.Errorf("%s: invalid %s: %v", path, e.key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configMain implements the config command, which prints ("list", "get") or
+// changes ("set", "unset") the entries of the configuration file.
+func configMain(args []string) {
+	fs := newFlagSet("config", "list | path | get <key> | set <key> <value> | unset <key>")
+	parseArgs(fs, args)
+	path, err := configPath()
+	if err != nil {
+		fatal(err)
+	}
+	entries, err := readConfig(path)
+	if err != nil {
+		fatal(err)
+	}
+	find := func(key string) int {
+		for i, e := range entries {
+			if e.key == key {
+				return i
+			}
+		}
+		return -1
+	}
+	nargs := map[string]int{"list": 1, "path": 1, "get": 2, "set": 3, "unset": 2}
+	if n, ok := nargs[fs.Arg(0)]; !ok || fs.NArg() != n {
+		fs.Usage()
+		os.Exit(exitFatal)
+	}
+	switch fs.Arg(0) {
+	case "list":
+		for _, e := range entries {
+			fmt.Printf("%s = %s\n", e.key, e.value)
+		}
+	case "path":
+		fmt.Println(path)
+	case "get":
+		i := find(fs.Arg(1))
+		if i < 0 {
+			fatal(fmt.Errorf("%s is not set in %s", fs.Arg(1), path))
+		}
+		fmt.Println(entries[i].value)
+	case "set":
+		key := strings.TrimPrefix(fs.Arg(1), "--")
+		if len(key) == 0 || strings.ContainsAny(key, "= \t") {
+			fatal(fmt.Errorf("invalid key %q, must be the name of a flag, optionally prefixed by a command (e.g. annotate.features)", fs.Arg(1)))
+		}
+		if i := find(key); i >= 0 {
+			entries[i].value = fs.Arg(2)
+		} else {
+			entries = append(entries, configEntry{key, fs.Arg(2)})
+		}
+		if err := writeConfig(path, entries); err != nil {
+			fatal(err)
+		}
+	case "unset":
+		if i := find(fs.Arg(1)); i >= 0 {
+			entries = append(entries[:i], entries[i+1:]...)
+			if err := writeConfig(path, entries); err != nil {
+				fatal(err)
+			}
+		}
+	}
+}
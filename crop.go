@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asimshankar/visionapi/pkg/metadata"
+	"github.com/asimshankar/visionapi/pkg/preprocess"
+	"github.com/asimshankar/visionapi/pkg/vision"
+	"golang.org/x/image/draw"
+)
+
+// cropMain implements the crop subcommand, which writes a thumbnail of each
+// image cropped around its best crop hint.
+func cropMain(args []string) {
+	fs := newFlagSet("crop", "<filename or URL>...")
+	verbose := fs.Bool("v", false, "Verbose output")
+	var (
+		pf providerFlags
+		cf cacheFlags
+		rf rateFlags
+	)
+	pf.register(fs, "google")
+	cf.register(fs)
+	rf.register(fs)
+	aspectRatio := fs.String("aspect-ratio", "1:1", "Aspect ratio of the thumbnails, as W:H (e.g. 16:9) or a number (e.g. 1.78)")
+	width := fs.Int("width", 0, "Width in pixels to scale thumbnails down to (0 to keep the size of the crop)")
+	outputDir := fs.String("output-dir", "thumbnails", "Directory to write the thumbnails (JPEGs) into")
+	jpegQuality := fs.Int("jpeg-quality", 85, "JPEG quality (1-100) of the thumbnails")
+	noOrient := fs.Bool("no-orient", false, "Do not rotate images (and thumbnails) upright as per their Exif orientation")
+	retries := fs.Int("retries", 3, "Number of times to retry requests that fail with transient errors")
+	concurrency := fs.Int("concurrency", 1, "Number of files to load and requests to send in parallel")
+	parseFlags(fs, args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return
+	}
+	ratio, err := parseAspectRatio(*aspectRatio)
+	if err != nil {
+		fatal(err)
+	}
+	limiter, err := rf.limiter()
+	if err != nil {
+		fatal(err)
+	}
+	opts := vision.Options{
+		Features:         []vision.Feature{vision.FeatureCropHints},
+		CropAspectRatios: []float64{ratio},
+		Concurrency:      *concurrency,
+		Retries:          *retries,
+		RetryDelay:       time.Second,
+		Verbose:          *verbose,
+		RateLimit:        limiter,
+	}
+	ctx := context.Background()
+	p, err := pf.newProvider(ctx)
+	if err != nil {
+		fatal(err)
+	}
+	if p, err = cf.wrap(p); err != nil {
+		fatal(err)
+	}
+	// Small images can still be cropped, so only the maximum size applies.
+	lo := loadOptions{force: true, resize: true, jpegQuality: *jpegQuality, orient: !*noOrient}
+	lo.applyDefaults(p.Name())
+	images, failed := loadImages(ctx, expandPatterns(fs.Args(), false, nil, false), lo, opts.Concurrency)
+	for _, r := range failed {
+		slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+	}
+	summary := runSummary{skipped: len(failed)}
+	results, err := p.Annotate(ctx, images, opts)
+	if err != nil {
+		fatal(err)
+	}
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		fatal(err)
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+			summary.failed++
+			continue
+		}
+		dest, err := writeThumbnail(ctx, images[i], r, ratio, *width, *jpegQuality, lo.orient, *outputDir)
+		if err != nil {
+			slog.Error("Unable to crop", "file", r.Name, "err", err)
+			summary.failed++
+			continue
+		}
+		summary.succeeded++
+		fmt.Printf("%s: %s\n", r.Name, dest)
+	}
+	if code := summary.exitCode(); code != 0 {
+		exitWith(code)
+	}
+}
+
+// parseAspectRatio parses "W:H" or a number.
+func parseAspectRatio(s string) (float64, error) {
+	var ratio float64
+	if w, h, ok := strings.Cut(s, ":"); ok {
+		fw, err1 := strconv.ParseFloat(w, 64)
+		fh, err2 := strconv.ParseFloat(h, 64)
+		if err1 == nil && err2 == nil && fh > 0 {
+			ratio = fw / fh
+		}
+	} else {
+		ratio, _ = strconv.ParseFloat(s, 64)
+	}
+	if ratio <= 0 {
+		return 0, fmt.Errorf("invalid --aspect-ratio(%s), must be W:H or a positive number", s)
+	}
+	return ratio, nil
+}
+
+// loadOriginal returns the original image of r (rather than the image sent,
+// img, which may have been downscaled, and rotated upright if orient is set,
+// but the embedded preview of RAW files, as was sent), rotated as img was, and
+// the factor to scale bounds in pixels of img by to map them onto it.
+func loadOriginal(ctx context.Context, img vision.Image, r vision.Result, orient bool) (image.Image, float64, error) {
+	sent := img.Content
+	original := sent
+	var err error
+	if isLocalFile(r.Name) {
+		original, err = ioutil.ReadFile(r.Name)
+	} else if len(sent) == 0 {
+		original, err = vision.Download(ctx, img.URI)
+		sent = original
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	orientation := metadata.Orientation(original)
+	if preprocess.IsRAW(r.Name) {
+		if original, orientation, err = preprocess.RAWPreview(original); err != nil {
+			return nil, 0, err
+		}
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode image: %v", err)
+	}
+	if orient && isLocalFile(r.Name) {
+		// As the image sent was.
+		decoded = preprocess.Orient(decoded, orientation)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(sent))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode image: %v", err)
+	}
+	return decoded, float64(decoded.Bounds().Dx()) / float64(cfg.Width), nil
+}
+
+// writeThumbnail crops the original image (see loadOriginal) around the best
+// crop hint of r and returns the path of the thumbnail written into dir.
+func writeThumbnail(ctx context.Context, img vision.Image, r vision.Result, ratio float64, width, quality int, orient bool, dir string) (string, error) {
+	decoded, scale, err := loadOriginal(ctx, img, r, orient)
+	if err != nil {
+		return "", err
+	}
+	b := decoded.Bounds()
+	hint := image.Rect(0, 0, b.Dx(), b.Dy())
+	if len(r.CropHints) > 0 {
+		// Bounds are in pixels of the image sent.
+		h := r.CropHints[0].Bounds
+		hint = image.Rect(int(float64(h.X)*scale), int(float64(h.Y)*scale), int(float64(h.X+h.Width)*scale), int(float64(h.Y+h.Height)*scale))
+	} else {
+		slog.Warn("No crop hints, cropping around the center", "file", r.Name)
+	}
+	crop := fitAspectRatio(hint, b.Dx(), b.Dy(), ratio).Add(b.Min)
+	cropped := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+	draw.Draw(cropped, cropped.Bounds(), decoded, crop.Min, draw.Src)
+	var thumbnail image.Image = cropped
+	if width > 0 && width < crop.Dx() {
+		thumbnail = preprocess.Resize(cropped, width, max(1, int(float64(width)/ratio)))
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: quality}); err != nil {
+		return "", err
+	}
+	base := filepath.Base(r.Name)
+	if isURL(r.Name) {
+		base = path.Base(strings.SplitN(r.Name, "?", 2)[0])
+	}
+	dest := uniquePath(dir, strings.TrimSuffix(base, filepath.Ext(base))+".jpg")
+	return dest, ioutil.WriteFile(dest, buf.Bytes(), 0644)
+}
+
+// extractFaces writes each face detected in r, cropped from the original
+// image (see loadOriginal) with a margin of its size on every side, as a JPEG
+// named after the image and the index of the face (e.g. photo-face-0.jpg)
+// into dir, and returns their paths.
+func extractFaces(ctx context.Context, img vision.Image, r vision.Result, margin float64, quality int, orient bool, dir string) ([]string, error) {
+	if len(r.Faces) == 0 {
+		return nil, nil
+	}
+	decoded, scale, err := loadOriginal(ctx, img, r, orient)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	base := filepath.Base(r.Name)
+	if isURL(r.Name) {
+		base = path.Base(strings.SplitN(r.Name, "?", 2)[0])
+	}
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	b := decoded.Bounds()
+	var paths []string
+	for i, f := range r.Faces {
+		// Bounds are in pixels of the image sent.
+		fb := f.Bounds
+		dx, dy := float64(fb.Width)*margin, float64(fb.Height)*margin
+		crop := image.Rect(
+			int((float64(fb.X)-dx)*scale), int((float64(fb.Y)-dy)*scale),
+			int((float64(fb.X+fb.Width)+dx)*scale), int((float64(fb.Y+fb.Height)+dy)*scale),
+		).Add(b.Min).Intersect(b)
+		if crop.Empty() {
+			continue
+		}
+		cropped := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+		draw.Draw(cropped, cropped.Bounds(), decoded, crop.Min, draw.Src)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, cropped, &jpeg.Options{Quality: quality}); err != nil {
+			return paths, err
+		}
+		dest := uniquePath(dir, fmt.Sprintf("%s-face-%d.jpg", base, i))
+		if err := ioutil.WriteFile(dest, buf.Bytes(), 0644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, dest)
+	}
+	return paths, nil
+}
+
+// fitAspectRatio returns the rectangle of the given aspect ratio, within an
+// image of width x height, that is centered on hint and contains as much of it
+// as possible.
+func fitAspectRatio(hint image.Rectangle, width, height int, ratio float64) image.Rectangle {
+	w, h := float64(hint.Dx()), float64(hint.Dy())
+	if w < 1 || h < 1 {
+		w, h = float64(width), float64(height)
+	}
+	if w/h < ratio {
+		w = h * ratio
+	} else {
+		h = w / ratio
+	}
+	if w > float64(width) {
+		w, h = float64(width), float64(width)/ratio
+	}
+	if h > float64(height) {
+		w, h = float64(height)*ratio, float64(height)
+	}
+	cx, cy := float64(hint.Min.X+hint.Max.X)/2, float64(hint.Min.Y+hint.Max.Y)/2
+	x := min(max(cx-w/2, 0), float64(width)-w)
+	y := min(max(cy-h/2, 0), float64(height)-h)
+	return image.Rect(int(x), int(y), int(x+w), int(y+h))
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asimshankar/visionapi/pkg/preprocess"
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// datasetObjects returns the objects of r that are localized, with their
+// bounds scaled from the image sent (img) to the original file, which may
+// have been downscaled, and the size of the original. The size of images
+// left to the provider to fetch is unknown, so they fail.
+func datasetObjects(r vision.Result, img *vision.Image) (objects []vision.Label, width, height int, err error) {
+	if img == nil || len(img.Content) == 0 {
+		return nil, 0, 0, fmt.Errorf("the size of the image is unknown (use --download for URLs)")
+	}
+	sent, _, err := image.DecodeConfig(bytes.NewReader(img.Content))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to decode image: %v", err)
+	}
+	width, height = sent.Width, sent.Height
+	if cfg, err := fileImageConfig(r.Name); err == nil {
+		// Images rotated upright before being sent are transposed.
+		if (cfg.Width > cfg.Height) != (sent.Width > sent.Height) {
+			cfg.Width, cfg.Height = cfg.Height, cfg.Width
+		}
+		width, height = cfg.Width, cfg.Height
+	}
+	scale := float64(width) / float64(sent.Width)
+	for _, o := range r.Objects {
+		if o.Bounds == nil {
+			continue
+		}
+		b := *o.Bounds
+		o.Bounds = &vision.BoundingBox{
+			X:      int(float64(b.X) * scale),
+			Y:      int(float64(b.Y) * scale),
+			Width:  int(float64(b.Width) * scale),
+			Height: int(float64(b.Height) * scale),
+		}
+		objects = append(objects, o)
+	}
+	return objects, width, height, nil
+}
+
+// fileImageConfig returns the dimensions of the local image file filename.
+// Those of RAW files are unknown, as their preview was sent.
+func fileImageConfig(filename string) (image.Config, error) {
+	if !isLocalFile(filename) || preprocess.IsRAW(filename) {
+		return image.Config{}, fmt.Errorf("not a local image file")
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	return cfg, err
+}
+
+// cocoDataset is a dataset in the COCO object detection format, as per
+// https://cocodataset.org/#format-data.
+type cocoDataset struct {
+	Images      []cocoImage      `json:"images"`
+	Categories  []cocoCategory   `json:"categories"`
+	Annotations []cocoAnnotation `json:"annotations"`
+}
+
+type cocoImage struct {
+	ID       int    `json:"id"`
+	FileName string `json:"file_name"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+type cocoCategory struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type cocoAnnotation struct {
+	ID         int     `json:"id"`
+	ImageID    int     `json:"image_id"`
+	CategoryID int     `json:"category_id"`
+	BBox       [4]int  `json:"bbox"` // x, y, width, height
+	Area       int     `json:"area"`
+	IsCrowd    int     `json:"iscrowd"`
+	Score      float64 `json:"score"`
+}
+
+// cocoWriter writes the objects localized in images (--features=objects) as a
+// COCO dataset into a file, when closed. Categories are the labels of the
+// objects, merged by their vision.NormalizeLabel keys. Errors are logged.
+type cocoWriter struct {
+	path       string
+	dataset    cocoDataset
+	categories map[string]int // IDs, by key.
+}
+
+func newCOCOWriter(path string) *cocoWriter {
+	return &cocoWriter{path: path, categories: make(map[string]int)}
+}
+
+func (c *cocoWriter) Write(r vision.Result) error {
+	return c.WriteImage(r, nil)
+}
+
+func (c *cocoWriter) WriteImage(r vision.Result, img *vision.Image) error {
+	if r.Err != nil {
+		slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+		return nil
+	}
+	objects, width, height, err := datasetObjects(r, img)
+	if err != nil {
+		slog.Error("Unable to export", "file", r.Name, "err", err)
+		return nil
+	}
+	d := &c.dataset
+	id := len(d.Images) + 1
+	d.Images = append(d.Images, cocoImage{ID: id, FileName: r.Name, Width: width, Height: height})
+	for _, o := range objects {
+		key := vision.NormalizeLabel(o.Description)
+		category, ok := c.categories[key]
+		if !ok {
+			category = len(d.Categories) + 1
+			c.categories[key] = category
+			d.Categories = append(d.Categories, cocoCategory{ID: category, Name: key})
+		}
+		b := o.Bounds
+		d.Annotations = append(d.Annotations, cocoAnnotation{
+			ID:         len(d.Annotations) + 1,
+			ImageID:    id,
+			CategoryID: category,
+			BBox:       [4]int{b.X, b.Y, b.Width, b.Height},
+			Area:       b.Width * b.Height,
+			Score:      o.Confidence,
+		})
+	}
+	return nil
+}
+
+func (c *cocoWriter) Close() error {
+	// Empty lists, rather than null, for tools that expect them.
+	d := c.dataset
+	if d.Images == nil {
+		d.Images = []cocoImage{}
+	}
+	if d.Categories == nil {
+		d.Categories = []cocoCategory{}
+	}
+	if d.Annotations == nil {
+		d.Annotations = []cocoAnnotation{}
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, append(data, '\n'), 0644)
+}
+
+// datasetPath returns the path of the annotation file of the local image
+// filename with the extension ext, alongside it.
+func datasetPath(filename, ext string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ext
+}
+
+// vocAnnotation is the annotation of an image in the Pascal VOC format, as per
+// http://host.robots.ox.ac.uk/pascal/VOC/voc2012/devkit_doc.pdf.
+type vocAnnotation struct {
+	XMLName  xml.Name    `xml:"annotation"`
+	Folder   string      `xml:"folder"`
+	Filename string      `xml:"filename"`
+	Path     string      `xml:"path"`
+	Width    int         `xml:"size>width"`
+	Height   int         `xml:"size>height"`
+	Depth    int         `xml:"size>depth"`
+	Objects  []vocObject `xml:"object"`
+}
+
+type vocObject struct {
+	Name      string `xml:"name"`
+	Pose      string `xml:"pose"`
+	Truncated int    `xml:"truncated"`
+	Difficult int    `xml:"difficult"`
+	XMin      int    `xml:"bndbox>xmin"`
+	YMin      int    `xml:"bndbox>ymin"`
+	XMax      int    `xml:"bndbox>xmax"`
+	YMax      int    `xml:"bndbox>ymax"`
+}
+
+// vocWriter writes the objects localized in each local image as a Pascal VOC
+// XML file alongside it (e.g. photo.xml for photo.jpg). Errors are logged.
+type vocWriter struct{}
+
+func (v vocWriter) Write(r vision.Result) error {
+	return v.WriteImage(r, nil)
+}
+
+func (v vocWriter) WriteImage(r vision.Result, img *vision.Image) error {
+	objects, width, height, ok := localDatasetObjects(r, img)
+	if !ok {
+		return nil
+	}
+	abs, err := filepath.Abs(r.Name)
+	if err != nil {
+		abs = r.Name
+	}
+	doc := vocAnnotation{
+		Folder:   filepath.Base(filepath.Dir(abs)),
+		Filename: filepath.Base(r.Name),
+		Path:     abs,
+		Width:    width,
+		Height:   height,
+		Depth:    3,
+	}
+	for _, o := range objects {
+		b := o.Bounds
+		doc.Objects = append(doc.Objects, vocObject{
+			Name: vision.NormalizeLabel(o.Description),
+			Pose: "Unspecified",
+			// VOC coordinates are 1-based and inclusive.
+			XMin: b.X + 1,
+			YMin: b.Y + 1,
+			XMax: b.X + b.Width,
+			YMax: b.Y + b.Height,
+		})
+	}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	dest := datasetPath(r.Name, ".xml")
+	if err := ioutil.WriteFile(dest, append(data, '\n'), 0644); err != nil {
+		slog.Error("Unable to export", "file", r.Name, "err", err)
+	}
+	return nil
+}
+
+func (v vocWriter) Close() error { return nil }
+
+// yoloWriter writes the objects localized in each local image in the YOLO
+// format, as a text file alongside it (e.g. photo.txt for photo.jpg) with a
+// line per object of its class and its normalized center and size. The
+// classes (the labels of the objects, merged by their vision.NormalizeLabel
+// keys) are written, one per line, to classes.txt in the directory of each
+// image when closed. Errors are logged.
+type yoloWriter struct {
+	classes []string
+	index   map[string]int // Into classes, by key.
+	dirs    map[string]bool
+}
+
+func newYOLOWriter() *yoloWriter {
+	return &yoloWriter{index: make(map[string]int), dirs: make(map[string]bool)}
+}
+
+func (y *yoloWriter) Write(r vision.Result) error {
+	return y.WriteImage(r, nil)
+}
+
+func (y *yoloWriter) WriteImage(r vision.Result, img *vision.Image) error {
+	objects, width, height, ok := localDatasetObjects(r, img)
+	if !ok {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, o := range objects {
+		key := vision.NormalizeLabel(o.Description)
+		class, ok := y.index[key]
+		if !ok {
+			class = len(y.classes)
+			y.index[key] = class
+			y.classes = append(y.classes, key)
+		}
+		b := o.Bounds
+		fmt.Fprintf(&buf, "%d %.6f %.6f %.6f %.6f\n", class,
+			(float64(b.X)+float64(b.Width)/2)/float64(width),
+			(float64(b.Y)+float64(b.Height)/2)/float64(height),
+			float64(b.Width)/float64(width),
+			float64(b.Height)/float64(height))
+	}
+	if err := ioutil.WriteFile(datasetPath(r.Name, ".txt"), buf.Bytes(), 0644); err != nil {
+		slog.Error("Unable to export", "file", r.Name, "err", err)
+		return nil
+	}
+	y.dirs[filepath.Dir(r.Name)] = true
+	return nil
+}
+
+func (y *yoloWriter) Close() error {
+	classes := strings.Join(y.classes, "\n")
+	if len(classes) > 0 {
+		classes += "\n"
+	}
+	for dir := range y.dirs {
+		if err := ioutil.WriteFile(filepath.Join(dir, "classes.txt"), []byte(classes), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localDatasetObjects returns the result of datasetObjects for local images
+// that were annotated, logging the failures of others.
+func localDatasetObjects(r vision.Result, img *vision.Image) ([]vision.Label, int, int, bool) {
+	if r.Err != nil {
+		slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+		return nil, 0, 0, false
+	}
+	if !isLocalFile(r.Name) {
+		slog.Error("Unable to export", "file", r.Name, "err", "only local files can be annotated alongside")
+		return nil, 0, 0, false
+	}
+	objects, width, height, err := datasetObjects(r, img)
+	if err != nil {
+		slog.Error("Unable to export", "file", r.Name, "err", err)
+		return nil, 0, 0, false
+	}
+	return objects, width, height, true
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"log/slog"
+
+	"github.com/asimshankar/visionapi/pkg/preprocess"
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// deduper skips annotating images that are perceptual duplicates of images
+// annotated before them in the run, i.e. whose difference hashes (see
+// preprocess.DHash) differ in at most threshold bits, copying the result of
+// the earlier image instead. A nil deduper annotates every image.
+type deduper struct {
+	threshold int
+	annotated []dedupedImage
+}
+
+type dedupedImage struct {
+	hash   uint64
+	result vision.Result
+}
+
+// annotate annotates the images that are not duplicates with annotate.
+// Duplicates of images in the same batch share their results, even if
+// annotating them failed.
+func (d *deduper) annotate(images []vision.Image, annotate func([]vision.Image) ([]vision.Result, error)) ([]vision.Result, error) {
+	if d == nil {
+		return annotate(images)
+	}
+	var (
+		results = make([]vision.Result, len(images))
+		hashes  = make([]uint64, len(images))
+		hashed  = make([]bool, len(images))
+		copyOf  = make([]int, len(images)) // Index into unique, or -1.
+		unique  []vision.Image
+		indices []int // Of unique in images.
+	)
+	for i, img := range images {
+		copyOf[i] = -1
+		// Images left to the provider to fetch are not deduplicated.
+		if decoded, _, err := image.Decode(bytes.NewReader(img.Content)); err == nil {
+			hashes[i], hashed[i] = preprocess.DHash(decoded), true
+		}
+		if o := d.find(hashes[i], hashed[i]); o != nil {
+			slog.Info("Copying the result of a duplicate", "file", img.Name, "of", o.Name)
+			results[i] = *o
+			results[i].Name = img.Name
+			continue
+		}
+		for j, k := range indices {
+			if hashed[i] && hashed[k] && preprocess.Distance(hashes[i], hashes[k]) <= d.threshold {
+				copyOf[i] = j
+				break
+			}
+		}
+		if copyOf[i] < 0 {
+			unique, indices = append(unique, img), append(indices, i)
+		}
+	}
+	annotated, err := annotate(unique)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range indices {
+		results[i] = annotated[j]
+		if hashed[i] && annotated[j].Err == nil {
+			d.annotated = append(d.annotated, dedupedImage{hashes[i], annotated[j]})
+		}
+	}
+	for i, j := range copyOf {
+		if j < 0 {
+			continue
+		}
+		slog.Info("Copying the result of a duplicate", "file", images[i].Name, "of", unique[j].Name)
+		results[i] = annotated[j]
+		results[i].Name = images[i].Name
+	}
+	return results, nil
+}
+
+// find returns the result of an image annotated earlier, that hash is a
+// duplicate of, if any.
+func (d *deduper) find(hash uint64, hashed bool) *vision.Result {
+	if !hashed {
+		return nil
+	}
+	for i, a := range d.annotated {
+		if preprocess.Distance(hash, a.hash) <= d.threshold {
+			return &d.annotated[i].result
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// diffMain implements the diff command, which compares the labels of two sets
+// of results written with --output=json (e.g. by different providers, or
+// before and after a re-run) image by image.
+func diffMain(args []string) {
+	fs := newFlagSet("diff", "<before.json> <after.json>")
+	minDelta := fs.Float64("min-delta", 0.05, "Report labels in both results whose confidence changed by at least this much")
+	parseFlags(fs, args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return
+	}
+	before, order, err := readResults(fs.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	after, afterOrder, err := readResults(fs.Arg(1))
+	if err != nil {
+		fatal(err)
+	}
+	for _, name := range afterOrder {
+		if _, ok := before[name]; !ok {
+			order = append(order, name)
+		}
+	}
+	var added, removed, changed, differing int
+	for _, name := range order {
+		a, inBefore := before[name]
+		b, inAfter := after[name]
+		switch {
+		case !inAfter:
+			fmt.Printf("%s: only in %s\n", name, fs.Arg(0))
+			differing++
+			continue
+		case !inBefore:
+			fmt.Printf("%s: only in %s\n", name, fs.Arg(1))
+			differing++
+			continue
+		case len(a.Error) > 0 || len(b.Error) > 0:
+			if a.Error != b.Error {
+				fmt.Printf("%s: error %q -> %q\n", name, a.Error, b.Error)
+				differing++
+			}
+			continue
+		}
+		d := diffLabels(a.Labels, b.Labels, *minDelta)
+		for _, l := range d.added {
+			fmt.Printf("%s: + %s (%.2f)\n", name, l.Description, l.Confidence)
+		}
+		for _, l := range d.removed {
+			fmt.Printf("%s: - %s (%.2f)\n", name, l.Description, l.Confidence)
+		}
+		for _, c := range d.changed {
+			fmt.Printf("%s: ~ %s %.2f -> %.2f (%+.2f)\n", name, c.before.Description, c.before.Confidence, c.after.Confidence, c.after.Confidence-c.before.Confidence)
+		}
+		added += len(d.added)
+		removed += len(d.removed)
+		changed += len(d.changed)
+		if len(d.added)+len(d.removed)+len(d.changed) > 0 {
+			differing++
+		}
+	}
+	fmt.Printf("%d of %d images differ: %d labels added, %d removed, %d changed\n", differing, len(order), added, removed, changed)
+}
+
+// readResults reads the documents written with --output=json to filename, by
+// image name, and the names in order.
+func readResults(filename string) (map[string]jsonResult, []string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	var (
+		results = make(map[string]jsonResult)
+		order   []string
+		dec     = json.NewDecoder(f)
+	)
+	for {
+		var r jsonResult
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("unable to parse %s: %v", filename, err)
+		}
+		if _, ok := results[r.Name]; !ok {
+			order = append(order, r.Name)
+		}
+		// The last result of an image (e.g. of a re-run appended to the
+		// same file) wins.
+		results[r.Name] = r
+	}
+	return results, order, nil
+}
+
+type labelChange struct {
+	before, after vision.Label
+}
+
+type labelDiff struct {
+	added, removed []vision.Label
+	changed        []labelChange
+}
+
+// diffLabels compares labels by their vision.NormalizeLabel keys, reporting
+// those whose confidence changed by at least minDelta. Labels are in the order
+// of after (or of before, for removed labels).
+func diffLabels(before, after []vision.Label, minDelta float64) labelDiff {
+	var (
+		d                  labelDiff
+		beforeKeys, byKeyB = labelsByKey(before)
+		afterKeys, byKeyA  = labelsByKey(after)
+	)
+	for _, key := range afterKeys {
+		l := byKeyA[key]
+		prev, ok := byKeyB[key]
+		switch {
+		case !ok:
+			d.added = append(d.added, l)
+		case math.Abs(l.Confidence-prev.Confidence) >= minDelta:
+			d.changed = append(d.changed, labelChange{prev, l})
+		}
+	}
+	for _, key := range beforeKeys {
+		if _, ok := byKeyA[key]; !ok {
+			d.removed = append(d.removed, byKeyB[key])
+		}
+	}
+	return d
+}
+
+// labelsByKey returns the first label with each vision.NormalizeLabel key, by
+// key, and the keys in order.
+func labelsByKey(labels []vision.Label) ([]string, map[string]vision.Label) {
+	var (
+		keys  []string
+		byKey = make(map[string]vision.Label)
+	)
+	for _, l := range labels {
+		key := vision.NormalizeLabel(l.Description)
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+			byKey[key] = l
+		}
+	}
+	return keys, byKey
+}
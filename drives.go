@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// Environment variables of the OAuth access tokens of cloud drives, if not
+// set by flags.
+const (
+	dropboxTokenEnvVar  = "DROPBOX_ACCESS_TOKEN"
+	oneDriveTokenEnvVar = "ONEDRIVE_ACCESS_TOKEN"
+)
+
+// driveFlags configure the access of all commands to files in cloud drives,
+// named by dropbox://path and onedrive://path (e.g. dropbox://Photos/2024),
+// which are downloaded rather than synced locally.
+type driveFlags struct {
+	dropboxToken  string
+	oneDriveToken string
+}
+
+var drives driveFlags
+
+func (df *driveFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&df.dropboxToken, "dropbox-token", "", "OAuth access token (with the files.content.read scope) to read dropbox:// files and folders with (default: the "+dropboxTokenEnvVar+" environment variable)")
+	fs.StringVar(&df.oneDriveToken, "onedrive-token", "", "OAuth access token (with the Files.Read scope) of Microsoft Graph to read onedrive:// files and folders with (default: the "+oneDriveTokenEnvVar+" environment variable)")
+}
+
+// isDrivePath returns true if name is a file or folder in a cloud drive.
+func isDrivePath(name string) bool {
+	return strings.HasPrefix(name, "dropbox://") || strings.HasPrefix(name, "onedrive://")
+}
+
+// splitDrivePath returns the scheme of the drive path name, and the path in
+// the drive, e.g. "/Photos/2024" ("" for the root of a Dropbox).
+func splitDrivePath(name string) (scheme, path string) {
+	scheme, path, _ = strings.Cut(name, "://")
+	path = strings.Trim(path, "/")
+	if len(path) > 0 || scheme == "onedrive" {
+		path = "/" + path
+	}
+	return scheme, path
+}
+
+// token returns the access token of the drive of scheme.
+func (df *driveFlags) token(scheme string) (string, error) {
+	name, env, token := "--dropbox-token", dropboxTokenEnvVar, df.dropboxToken
+	if scheme == "onedrive" {
+		name, env, token = "--onedrive-token", oneDriveTokenEnvVar, df.oneDriveToken
+	}
+	if len(token) == 0 {
+		token = os.Getenv(env)
+	}
+	if len(token) == 0 {
+		return "", fmt.Errorf("no access token for %s:// paths, set %s or %s", scheme, name, env)
+	}
+	return token, nil
+}
+
+// list returns the image files (and documents, if documents is true) of the
+// folder name, and of its subfolders if recursive is true, or name itself if
+// it is a file.
+func (df *driveFlags) list(ctx context.Context, name string, recursive, documents bool) ([]string, error) {
+	scheme, path := splitDrivePath(name)
+	token, err := df.token(scheme)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	include := func(file string) {
+		ext := strings.ToLower(filepath.Ext(file))
+		if imageExtensions[ext] || documents && len(vision.FileMIMEType(file)) > 0 {
+			files = append(files, scheme+":/"+file)
+		}
+	}
+	if scheme == "onedrive" {
+		err = listOneDrive(ctx, token, path, recursive, include)
+	} else {
+		err = listDropbox(ctx, token, path, recursive, include)
+	}
+	if err == errNotFolder {
+		return []string{name}, nil
+	}
+	return files, err
+}
+
+// download returns the content of the file name.
+func (df *driveFlags) download(ctx context.Context, name string) ([]byte, error) {
+	scheme, path := splitDrivePath(name)
+	token, err := df.token(scheme)
+	if err != nil {
+		return nil, err
+	}
+	var req *http.Request
+	if scheme == "onedrive" {
+		// Redirected to a pre-authenticated URL of the content, to which the
+		// token is not sent.
+		req, err = http.NewRequest("GET", oneDriveItemURL(path, "/content"), nil)
+	} else {
+		// As per https://www.dropbox.com/developers/documentation/http/documentation#files-download
+		var arg string
+		if arg, err = dropboxArg(map[string]string{"path": path}); err == nil {
+			req, err = http.NewRequest("POST", "https://content.dropboxapi.com/2/files/download", nil)
+			if err == nil {
+				req.Header.Set("Dropbox-API-Arg", arg)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sendDriveRequest(ctx, req, token)
+}
+
+// errNotFolder is returned by listDropbox and listOneDrive if the path is a
+// file.
+var errNotFolder = fmt.Errorf("not a folder")
+
+// listDropbox calls include with the path of each file of the Dropbox folder
+// path, as per
+// https://www.dropbox.com/developers/documentation/http/documentation#files-list_folder
+func listDropbox(ctx context.Context, token, path string, recursive bool, include func(string)) error {
+	if len(path) > 0 {
+		var metadata struct {
+			Tag string `json:".tag"`
+		}
+		if err := postDropbox(ctx, token, "get_metadata", map[string]interface{}{"path": path}, &metadata); err != nil {
+			return err
+		}
+		if metadata.Tag == "file" {
+			return errNotFolder
+		}
+	}
+	var (
+		page struct {
+			Entries []struct {
+				Tag         string `json:".tag"`
+				PathDisplay string `json:"path_display"`
+			} `json:"entries"`
+			Cursor  string `json:"cursor"`
+			HasMore bool   `json:"has_more"`
+		}
+		endpoint = "list_folder"
+		request  = map[string]interface{}{"path": path, "recursive": recursive}
+	)
+	for {
+		if err := postDropbox(ctx, token, endpoint, request, &page); err != nil {
+			return err
+		}
+		for _, e := range page.Entries {
+			if e.Tag == "file" {
+				include(e.PathDisplay)
+			}
+		}
+		if !page.HasMore {
+			return nil
+		}
+		endpoint, request = "list_folder/continue", map[string]interface{}{"cursor": page.Cursor}
+		page.Entries = nil
+	}
+}
+
+// postDropbox calls the endpoint of the files namespace of the Dropbox API
+// with request, decoding the response into response.
+func postDropbox(ctx context.Context, token, endpoint string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", "https://api.dropboxapi.com/2/files/"+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if body, err = sendDriveRequest(ctx, req, token); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, response)
+}
+
+// dropboxArg returns v as JSON for the Dropbox-API-Arg header, in which
+// characters other than ASCII must be escaped.
+func dropboxArg(v interface{}) (string, error) {
+	byts, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, r := range string(byts) {
+		if r < 0x80 {
+			b.WriteRune(r)
+			continue
+		}
+		for _, u := range utf16.Encode([]rune{r}) {
+			fmt.Fprintf(&b, `\u%04x`, u)
+		}
+	}
+	return b.String(), nil
+}
+
+// oneDriveItem is a file or folder of a OneDrive, as per
+// https://learn.microsoft.com/graph/api/resources/driveitem
+type oneDriveItem struct {
+	Name   string    `json:"name"`
+	Folder *struct{} `json:"folder"`
+}
+
+// oneDriveItemURL returns the URL of the item at path (e.g. /Photos) of the
+// OneDrive of the user of the token, followed by suffix (e.g. /children).
+func oneDriveItemURL(path, suffix string) string {
+	if path == "/" {
+		return "https://graph.microsoft.com/v1.0/me/drive/root" + suffix
+	}
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	u := "https://graph.microsoft.com/v1.0/me/drive/root:/" + strings.Join(segments, "/")
+	if len(suffix) > 0 {
+		u += ":" + suffix
+	}
+	return u
+}
+
+// listOneDrive calls include with the path of each file of the OneDrive
+// folder path, as per
+// https://learn.microsoft.com/graph/api/driveitem-list-children
+func listOneDrive(ctx context.Context, token, path string, recursive bool, include func(string)) error {
+	var item oneDriveItem
+	if err := getOneDrive(ctx, token, oneDriveItemURL(path, ""), &item); err != nil {
+		return err
+	}
+	if item.Folder == nil {
+		return errNotFolder
+	}
+	next := oneDriveItemURL(path, "/children")
+	for len(next) > 0 {
+		var page struct {
+			Value    []oneDriveItem `json:"value"`
+			NextLink string         `json:"@odata.nextLink"`
+		}
+		if err := getOneDrive(ctx, token, next, &page); err != nil {
+			return err
+		}
+		for _, child := range page.Value {
+			childPath := strings.TrimSuffix(path, "/") + "/" + child.Name
+			if child.Folder == nil {
+				include(childPath)
+			} else if recursive {
+				if err := listOneDrive(ctx, token, childPath, recursive, include); err != nil {
+					return err
+				}
+			}
+		}
+		next = page.NextLink
+	}
+	return nil
+}
+
+// getOneDrive gets the endpoint (a URL) of Microsoft Graph, decoding the
+// response into response.
+func getOneDrive(ctx context.Context, token, endpoint string, response interface{}) error {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	body, err := sendDriveRequest(ctx, req, token)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, response)
+}
+
+// sendDriveRequest sends req authenticated by token, returning the body of a
+// successful response.
+func sendDriveRequest(ctx context.Context, req *http.Request, token string) ([]byte, error) {
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %v", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Dropbox errors have an error_summary (e.g. "path/not_found/..."),
+		// and those of Microsoft Graph an error.message.
+		var e struct {
+			Summary string `json:"error_summary"`
+			Error   struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(body, &e)
+		msg := e.Summary
+		if len(msg) == 0 {
+			msg = e.Error.Message
+		}
+		if len(msg) == 0 {
+			return nil, fmt.Errorf("HTTP request failed: %s", resp.Status)
+		}
+		return nil, fmt.Errorf("HTTP request failed: %s: %s", resp.Status, msg)
+	}
+	return body, nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/asimshankar/visionapi/pkg/cache"
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// billedUnit is an operation that a provider bills once per image.
+type billedUnit struct {
+	name  string
+	price float64 // USD per 1000 units
+}
+
+// List prices of the first paid tier (ignoring free tiers and volume
+// discounts), as published at:
+// https://cloud.google.com/vision/pricing
+// https://azure.microsoft.com/pricing/details/cognitive-services/computer-vision/
+// https://azure.microsoft.com/pricing/details/cognitive-services/custom-vision-service/
+// https://aws.amazon.com/rekognition/pricing/
+var (
+	googlePrices = map[vision.Feature]float64{
+		vision.FeatureLabels:     1.50,
+		vision.FeatureText:       1.50,
+		vision.FeatureFaces:      1.50,
+		vision.FeatureLandmarks:  1.50,
+		vision.FeatureLogos:      1.50,
+		vision.FeatureSafeSearch: 1.50,
+		vision.FeatureWeb:        3.50,
+		vision.FeatureObjects:    2.25,
+		vision.FeatureCropHints:  1.50,
+		vision.FeatureColors:     1.50,
+		vision.FeatureDocument:   1.50,
+	}
+	microsoftPrice    = 1.00 // per transaction of each visual feature
+	microsoftOCRPrice = 1.50
+	customVisionPrice = 2.00 // per prediction
+	awsPrice          = 1.00 // per image of each API
+)
+
+// googleMaxBatchBytes and googleMaxBatchImages are the request limits that the
+// google provider batches images by, unless --batch-bytes or --batch-count
+// are set.
+const (
+	googleMaxBatchBytes  = 8 << 20
+	googleMaxBatchImages = 16
+)
+
+// billedUnits returns the operations billed per image by provider for the
+// features of opts, and the number of requests made per image. ok is false
+// for providers whose prices are not known.
+func billedUnits(provider, azureVersion string, opts vision.Options) (units []billedUnit, requests int, ok bool) {
+	switch provider {
+	case "google":
+		// Images are batched, so requests are counted by the caller.
+		for _, f := range opts.RequestedFeatures() {
+			units = append(units, billedUnit{string(f), googlePrices[f]})
+		}
+		return units, 0, true
+	case "microsoft":
+		add := func(name string, price float64) { units = append(units, billedUnit{name, price}) }
+		if azureVersion == vision.MicrosoftV4 {
+			// A single request per image, billed per feature.
+			if opts.Has(vision.FeatureLabels) {
+				add("tags", microsoftPrice)
+				add("caption", microsoftOCRPrice)
+			}
+			if opts.Has(vision.FeatureText) {
+				add("read", microsoftOCRPrice)
+			}
+			if opts.Has(vision.FeatureObjects) {
+				add("objects", microsoftPrice)
+			}
+			if opts.Has(vision.FeatureCropHints) {
+				add("smartCrops", microsoftPrice)
+			}
+			return units, 1, true
+		}
+		if opts.Has(vision.FeatureLabels) {
+			add("tags", microsoftPrice)
+			add("description", microsoftPrice)
+		}
+		for _, v := range []struct {
+			f    vision.Feature
+			name string
+		}{{vision.FeatureFaces, "faces"}, {vision.FeatureSafeSearch, "adult"}, {vision.FeatureObjects, "objects"}, {vision.FeatureLogos, "brands"}, {vision.FeatureColors, "color"}, {vision.FeatureCelebrities, "celebrities"}, {vision.FeatureLandmarks, "landmarks"}} {
+			if opts.Has(v.f) {
+				add(v.name, microsoftPrice)
+			}
+		}
+		if len(units) > 0 {
+			requests++
+		}
+		if opts.Has(vision.FeatureText) || opts.Has(vision.FeatureDocument) {
+			add("ocr", microsoftOCRPrice)
+			requests++
+		}
+		if opts.Has(vision.FeatureCropHints) {
+			add("areaOfInterest", microsoftPrice)
+			requests++
+		}
+		return units, requests, true
+	case "aws":
+		// Labels and objects share the DetectLabels API, whose image
+		// properties (colors) are billed separately but in the same request.
+		labels, colors := opts.Has(vision.FeatureLabels) || opts.Has(vision.FeatureObjects), opts.Has(vision.FeatureColors)
+		for _, v := range []struct {
+			name string
+			ok   bool
+		}{
+			{"DetectLabels", labels},
+			{"DetectLabels image properties", colors},
+			{"DetectText", opts.Has(vision.FeatureText)},
+			{"DetectFaces", opts.Has(vision.FeatureFaces)},
+			{"DetectModerationLabels", opts.Has(vision.FeatureSafeSearch)},
+		} {
+			if v.ok {
+				units = append(units, billedUnit{v.name, awsPrice})
+			}
+		}
+		requests = len(units)
+		if labels && colors {
+			requests--
+		}
+		return units, requests, true
+	case "customvision":
+		// Classification and detection are separate predictions.
+		for _, f := range []vision.Feature{vision.FeatureLabels, vision.FeatureObjects} {
+			if opts.Has(f) {
+				units = append(units, billedUnit{string(f), customVisionPrice})
+			}
+		}
+		return units, len(units), true
+	case "ollama", "local", "clip":
+		// Models run locally, at no cost.
+		return nil, 1, true
+	case "deepstack":
+		// Self-hosted, at no cost, with a request for each of objects and
+		// faces.
+		for _, f := range []vision.Feature{vision.FeatureObjects, vision.FeatureFaces} {
+			if opts.Has(f) {
+				requests++
+			}
+		}
+		return nil, requests, true
+	default:
+		return nil, 1, false
+	}
+}
+
+// printEstimate writes the number of images and requests that would be sent to
+// provider, and their estimated cost. Images with results in the cache (unless
+// disabled by cf) of the provider with the identity (see vision.Identity) are
+// not counted.
+func printEstimate(w io.Writer, provider, identity, azureVersion string, images []vision.Image, documents []string, opts vision.Options, cf cacheFlags) error {
+	c, err := cf.open()
+	if err != nil {
+		return err
+	}
+	var (
+		sent     []vision.Image
+		cached   int
+		bytes    int64
+		requests int
+	)
+	for _, img := range images {
+		if c != nil && len(img.Content) > 0 {
+			if _, ok := c.Get(cache.Key(identity, img.Content, opts)); ok {
+				cached++
+				continue
+			}
+		}
+		sent = append(sent, img)
+		bytes += int64(len(img.Content))
+	}
+	units, perImage, ok := billedUnits(provider, azureVersion, opts)
+	if provider == "google" {
+		maxBytes, maxCount := googleMaxBatchBytes, googleMaxBatchImages
+		if opts.BatchBytes > 0 {
+			maxBytes = opts.BatchBytes
+		}
+		if opts.BatchCount > 0 {
+			maxCount = opts.BatchCount
+		}
+		var size, count int
+		for i, img := range sent {
+			if i == 0 || size+len(img.Content) > maxBytes || count == maxCount {
+				requests++
+				size, count = 0, 0
+			}
+			size += len(img.Content)
+			count++
+		}
+	} else {
+		requests = perImage * len(sent)
+	}
+	fmt.Fprintf(w, "Dry run: %d images (%s, %d cached) would be sent to %s in %d requests\n", len(sent), formatBytes(bytes), cached, provider, requests)
+	if len(documents) > 0 {
+		fmt.Fprintf(w, "%d PDF and TIFF files are not included, as they are billed per page\n", len(documents))
+	}
+	if !ok {
+		fmt.Fprintf(w, "The prices of %s are not known\n", provider)
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	var total float64
+	for _, u := range units {
+		cost := u.price * float64(len(sent)) / 1000
+		total += cost
+		fmt.Fprintf(tw, "%s\t%d units\t$%.4f\t\n", u.name, len(sent), cost)
+	}
+	fmt.Fprintf(tw, "total\t\t$%.4f\t\n", total)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "(estimated at list prices, excluding free tiers and volume discounts)")
+	return nil
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+	"github.com/asimshankar/visionapi/pkg/face"
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// facesMain implements the faces command, which annotates images with
+// --features=faces, unless its first argument is a subcommand: cluster or
+// compare.
+func facesMain(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "cluster":
+			facesClusterMain(args[1:])
+			return
+		case "compare":
+			facesCompareMain(args[1:])
+			return
+		}
+	}
+	annotateMain("faces", "faces", args)
+}
+
+// faceCluster is a group of faces of the same person, in the output of faces
+// cluster.
+type faceCluster struct {
+	ID    int           `json:"id"`
+	Faces []clusterFace `json:"faces"`
+}
+
+type clusterFace struct {
+	File string `json:"file"`
+	// Index of the face in the faces detected in File.
+	Index  int                `json:"index"`
+	Bounds vision.BoundingBox `json:"bounds"`
+}
+
+// facesClusterMain implements the faces cluster command, which detects the
+// faces in images with a provider, but groups them by person locally, with the
+// descriptors of the face package.
+func facesClusterMain(args []string) {
+	fs := newFlagSet("faces cluster", "<filename or URL>...")
+	verbose := fs.Bool("v", false, "Verbose output")
+	var (
+		pf providerFlags
+		cf cacheFlags
+		rf rateFlags
+		lf loadFlags
+	)
+	pf.register(fs, "auto")
+	cf.register(fs)
+	rf.register(fs)
+	lf.register(fs)
+	threshold := fs.Float64("threshold", 0.9, "Similarity in [0, 1] of faces to the faces of a cluster above which they are of the same person (higher to split people that are merged, lower to merge clusters of the same person)")
+	minFaces := fs.Int("min-faces", 2, "Minimum number of faces of a cluster, so that people who appear once are not reported")
+	outputDir := fs.String("output-dir", "", "Write a directory per cluster (person-1, person-2, ...) of links to the images of its faces into this directory, instead of printing the clusters as JSON")
+	recursive := fs.Bool("recursive", false, "Recursively walk directories for image files")
+	retries := fs.Int("retries", 3, "Number of times to retry requests that fail with transient errors")
+	concurrency := fs.Int("concurrency", 1, "Number of files to load and requests to send in parallel")
+	parseFlags(fs, args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return
+	}
+	limiter, err := rf.limiter()
+	if err != nil {
+		fatal(err)
+	}
+	opts := vision.Options{
+		Features:    []vision.Feature{vision.FeatureFaces},
+		Concurrency: *concurrency,
+		Retries:     *retries,
+		RetryDelay:  time.Second,
+		Verbose:     *verbose,
+		RateLimit:   limiter,
+	}
+	ctx := context.Background()
+	p, err := pf.newProvider(ctx)
+	if err != nil {
+		fatal(err)
+	}
+	if p, err = cf.wrap(p); err != nil {
+		fatal(err)
+	}
+	lo := lf.options()
+	lo.applyDefaults(p.Name())
+	var (
+		summary     runSummary
+		faces       []clusterFace
+		descriptors [][]float64
+	)
+	annotateChunks(ctx, p, expandPatterns(fs.Args(), *recursive, nil, false), lo, opts, &summary, func(images []vision.Image, results []vision.Result) {
+		for i, r := range results {
+			if r.Err != nil {
+				slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+				summary.failed++
+				continue
+			}
+			described, err := describeFaces(ctx, images[i], r)
+			if err != nil {
+				slog.Error("Unable to describe faces", "file", r.Name, "err", err)
+				summary.failed++
+				continue
+			}
+			for j, d := range described {
+				faces = append(faces, clusterFace{File: r.Name, Index: j, Bounds: r.Faces[j].Bounds})
+				descriptors = append(descriptors, d)
+			}
+			summary.succeeded++
+		}
+	})
+	var clusters []faceCluster
+	groups := face.Cluster(descriptors, *threshold)
+	byGroup := make(map[int]int) // Index into clusters, by group.
+	for i, g := range groups {
+		c, ok := byGroup[g]
+		if !ok {
+			c = len(clusters)
+			byGroup[g] = c
+			clusters = append(clusters, faceCluster{})
+		}
+		clusters[c].Faces = append(clusters[c].Faces, faces[i])
+	}
+	var people []faceCluster
+	for _, c := range clusters {
+		if len(c.Faces) >= *minFaces {
+			c.ID = len(people) + 1
+			people = append(people, c)
+		}
+	}
+	slog.Info("Clustered faces", "faces", len(faces), "people", len(people))
+	if len(*outputDir) > 0 {
+		if err := writeClusters(people, *outputDir); err != nil {
+			fatal(err)
+		}
+	} else {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			Clusters []faceCluster `json:"clusters"`
+		}{people}); err != nil {
+			fatal(err)
+		}
+	}
+	if code := summary.exitCode(); code != 0 {
+		exitWith(code)
+	}
+}
+
+// faceComparison is the comparison of a candidate image with the reference
+// image, in the output of faces compare.
+type faceComparison struct {
+	File    string             `json:"file"`
+	Matches []vision.FaceMatch `json:"matches,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// facesCompareMain implements the faces compare command, which compares the
+// face of a reference image with the faces of candidate images, with a
+// provider that verifies faces (vision.FaceComparer).
+func facesCompareMain(args []string) {
+	fs := newFlagSet("faces compare", "<reference> <candidate>...")
+	verbose := fs.Bool("v", false, "Verbose output")
+	var (
+		pf providerFlags
+		rf rateFlags
+		lf loadFlags
+	)
+	pf.register(fs, "aws")
+	rf.register(fs)
+	lf.register(fs)
+	minSimilarity := fs.Float64("min-similarity", 0.8, "Similarity in [0, 1] of faces to the face of the reference above which they are reported as matches")
+	output := fs.String("output", "text", "Output format: text or json")
+	retries := fs.Int("retries", 3, "Number of times to retry requests that fail with transient errors")
+	concurrency := fs.Int("concurrency", 1, "Number of files to load and requests to send in parallel")
+	parseFlags(fs, args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return
+	}
+	if *output != "text" && *output != "json" {
+		fatal(fmt.Errorf("unknown --output=%q, must be text or json", *output))
+	}
+	limiter, err := rf.limiter()
+	if err != nil {
+		fatal(err)
+	}
+	opts := vision.Options{
+		Features:    []vision.Feature{vision.FeatureFaces},
+		Concurrency: *concurrency,
+		Retries:     *retries,
+		RetryDelay:  time.Second,
+		Verbose:     *verbose,
+		RateLimit:   limiter,
+	}
+	ctx := context.Background()
+	// Comparisons are not cached, so the provider is not wrapped.
+	p, err := pf.newProvider(ctx)
+	if err != nil {
+		fatal(err)
+	}
+	comparer, ok := p.(vision.FaceComparer)
+	if !ok {
+		fatal(fmt.Errorf("comparing faces is not supported by %s", p.Name()))
+	}
+	lo := lf.options()
+	lo.applyDefaults(p.Name())
+	reference, failed := loadImages(ctx, fs.Args()[:1], lo, 1)
+	if len(failed) > 0 {
+		fatal(fmt.Errorf("unable to load %s: %v", failed[0].Name, failed[0].Err))
+	}
+	var summary runSummary
+	comparisons := make([]faceComparison, 0, fs.NArg()-1)
+	loadChunks(ctx, fs.Args()[1:], lo, opts.Concurrency, func(candidates []vision.Image, failed []vision.Result) {
+		for _, r := range failed {
+			slog.Error("Unable to compare", "file", r.Name, "err", r.Err)
+		}
+		summary.skipped += len(failed)
+		compared := make([]faceComparison, len(candidates))
+		parallel.For(len(candidates), opts.Concurrency, func(i int) {
+			compared[i].File = candidates[i].Name
+			matches, err := comparer.CompareFaces(ctx, reference[0], candidates[i], *minSimilarity, opts)
+			if err != nil {
+				compared[i].Error = err.Error()
+				return
+			}
+			compared[i].Matches = matches
+		})
+		comparisons = append(comparisons, compared...)
+	})
+	for _, c := range comparisons {
+		if len(c.Error) > 0 {
+			slog.Error("Unable to compare", "file", c.File, "err", c.Error)
+			summary.failed++
+			continue
+		}
+		summary.succeeded++
+		if *output == "json" {
+			continue
+		}
+		if len(c.Matches) == 0 {
+			fmt.Printf("%s: no match\n", c.File)
+		}
+		for _, m := range c.Matches {
+			b := m.Bounds
+			fmt.Printf("%s: %.2f at (%d, %d) %dx%d\n", c.File, m.Similarity, b.X, b.Y, b.Width, b.Height)
+		}
+	}
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			Reference   string           `json:"reference"`
+			Comparisons []faceComparison `json:"comparisons"`
+		}{reference[0].Name, comparisons}); err != nil {
+			fatal(err)
+		}
+	}
+	if code := summary.exitCode(); code != 0 {
+		exitWith(code)
+	}
+}
+
+// describeFaces returns the descriptor of each face of r, in the image sent
+// (img), which their bounds are in pixels of.
+func describeFaces(ctx context.Context, img vision.Image, r vision.Result) ([][]float64, error) {
+	if len(r.Faces) == 0 {
+		return nil, nil
+	}
+	content := img.Content
+	if len(content) == 0 {
+		var err error
+		if content, err = vision.Download(ctx, img.URI); err != nil {
+			return nil, err
+		}
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	origin := decoded.Bounds().Min
+	descriptors := make([][]float64, len(r.Faces))
+	for i, f := range r.Faces {
+		b := f.Bounds
+		descriptors[i] = face.Describe(decoded, image.Rect(b.X, b.Y, b.X+b.Width, b.Y+b.Height).Add(origin))
+	}
+	return descriptors, nil
+}
+
+// writeClusters writes a directory per cluster into dir, of symbolic links to
+// the local images of its faces (or copies, where links are not supported),
+// and of the URLs of remote images in urls.txt.
+func writeClusters(clusters []faceCluster, dir string) error {
+	for _, c := range clusters {
+		cdir := filepath.Join(dir, fmt.Sprintf("person-%d", c.ID))
+		if err := os.MkdirAll(cdir, 0755); err != nil {
+			return err
+		}
+		var urls []byte
+		linked := make(map[string]bool)
+		for _, f := range c.Faces {
+			if !isLocalFile(f.File) {
+				urls = append(urls, f.File+"\n"...)
+				continue
+			}
+			if linked[f.File] {
+				// Of several faces of the same person.
+				continue
+			}
+			linked[f.File] = true
+			src, err := filepath.Abs(f.File)
+			if err != nil {
+				return err
+			}
+			dest := uniquePath(cdir, filepath.Base(f.File))
+			if err := os.Symlink(src, dest); err != nil {
+				data, err := ioutil.ReadFile(src)
+				if err != nil {
+					return err
+				}
+				if err := ioutil.WriteFile(dest, data, 0644); err != nil {
+					return err
+				}
+			}
+		}
+		if len(urls) > 0 {
+			if err := ioutil.WriteFile(filepath.Join(cdir, "urls.txt"), urls, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// fallbackProvider annotates images with the first of its providers, and
+// those that fail with a vision.ServiceError (e.g., as the quota is exhausted)
+// with each of the others in turn.
+type fallbackProvider struct {
+	providers []vision.Provider
+}
+
+// newFallbackProvider returns a fallbackProvider of p followed by the providers
+// named, with results cached as per cf.
+func newFallbackProvider(ctx context.Context, p vision.Provider, names []string, cfg providerFlags, cf cacheFlags) (*fallbackProvider, error) {
+	f := &fallbackProvider{[]vision.Provider{p}}
+	for _, name := range names {
+		next, err := newProvider(ctx, name, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if next, err = cf.wrap(next); err != nil {
+			return nil, err
+		}
+		f.providers = append(f.providers, next)
+	}
+	return f, nil
+}
+
+// routeUnsupported returns the provider to annotate images with, and the
+// fallbacks to use with it, if base does not support all the features of opts:
+// the first of fallbacks that does, so that images do not fail mid-batch. If
+// none does, the features base does not support are dropped from opts with a
+// warning, and the others are still annotated.
+func routeUnsupported(ctx context.Context, base vision.Provider, fallbacks []string, cfg providerFlags, opts *vision.Options) (vision.Provider, []string, error) {
+	requested := opts.RequestedFeatures()
+	unsupported := vision.Unsupported(base, requested)
+	if len(unsupported) == 0 {
+		return base, fallbacks, nil
+	}
+	for i, name := range fallbacks {
+		next, err := newProvider(ctx, name, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(vision.Unsupported(next, requested)) == 0 {
+			slog.Warn("Features not supported by the API, annotating with a fallback API instead", "api", base.Name(), "features", unsupported, "fallback", next.Name())
+			return next, append(fallbacks[:i:i], fallbacks[i+1:]...), nil
+		}
+	}
+	if len(unsupported) == len(requested) {
+		return nil, nil, fmt.Errorf("none of the features requested (%v) are supported by %s", requested, base.Name())
+	}
+	slog.Warn("Features not supported by the API are not annotated", "api", base.Name(), "features", unsupported)
+	opts.Features = nil
+	for _, f := range requested {
+		if len(vision.Unsupported(base, []vision.Feature{f})) == 0 {
+			opts.Features = append(opts.Features, f)
+		}
+	}
+	return base, fallbacks, nil
+}
+
+func (f *fallbackProvider) Name() string { return f.providers[0].Name() }
+
+// Capabilities are those of the first provider, as the others only annotate
+// the images it fails to.
+func (f *fallbackProvider) Capabilities() []vision.Feature { return f.providers[0].Capabilities() }
+
+func (f *fallbackProvider) Annotate(ctx context.Context, images []vision.Image, opts vision.Options) ([]vision.Result, error) {
+	results, err := f.providers[0].Annotate(ctx, images, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, next := range f.providers[1:] {
+		var (
+			retry   []vision.Image
+			indices []int // into images, of each of retry
+		)
+		for i, r := range results {
+			if vision.IsServiceError(r.Err) {
+				retry = append(retry, images[i])
+				indices = append(indices, i)
+			}
+		}
+		if len(retry) == 0 {
+			break
+		}
+		slog.Info("Annotating with a fallback API", "images", len(retry), "api", next.Name())
+		retried, err := next.Annotate(ctx, retry, opts)
+		if err != nil {
+			// E.g., the features are not supported by next, so the images
+			// keep their errors unless a later provider succeeds.
+			slog.Warn("Unable to annotate with a fallback API", "api", next.Name(), "err", err)
+			continue
+		}
+		for j, r := range retried {
+			if r.Err == nil {
+				slog.Info("Annotated by a fallback API", "file", r.Name, "api", next.Name(), "after", results[indices[j]].Err)
+			}
+			results[indices[j]] = r
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+	"github.com/asimshankar/visionapi/pkg/visionpb"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcStreamConcurrency is the number of images on a stream that are annotated
+// at once.
+const grpcStreamConcurrency = 8
+
+// grpcServer implements the visionpb.Vision service of serve --grpc.
+type grpcServer struct {
+	visionpb.UnimplementedVisionServer
+	*server
+}
+
+// serveGRPC serves the visionpb.Vision service of s on addr.
+func serveGRPC(s *server, addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	gs := grpc.NewServer(grpc.MaxRecvMsgSize(serveMaxBodyBytes), grpc.StatsHandler(otelgrpc.NewServerHandler()))
+	visionpb.RegisterVisionServer(gs, &grpcServer{server: s})
+	slog.Info("Serving gRPC", "api", s.p.Name(), "addr", lis.Addr().String())
+	return gs.Serve(lis)
+}
+
+func (g *grpcServer) Annotate(ctx context.Context, req *visionpb.AnnotateRequest) (*visionpb.AnnotateResponse, error) {
+	return g.annotateRequest(ctx, req)
+}
+
+func (g *grpcServer) AnnotateStream(stream visionpb.Vision_AnnotateStreamServer) error {
+	var (
+		ctx  = stream.Context()
+		mu   sync.Mutex // guards stream.Send and err
+		err  error
+		wg   sync.WaitGroup
+		sema = make(chan struct{}, grpcStreamConcurrency)
+	)
+	for {
+		req, rerr := stream.Recv()
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			wg.Wait()
+			return rerr
+		}
+		mu.Lock()
+		failed := err != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		sema <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sema; wg.Done() }()
+			resp, aerr := g.annotateRequest(ctx, req)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				return
+			}
+			if aerr != nil {
+				// Invalid requests end the stream, as they would fail the
+				// unary RPC.
+				err = aerr
+				return
+			}
+			err = stream.Send(resp)
+		}()
+	}
+	wg.Wait()
+	return err
+}
+
+// annotateRequest annotates the image of req, returning an error only for
+// invalid requests. Images that could not be annotated have the error in
+// the result.
+func (g *grpcServer) annotateRequest(ctx context.Context, req *visionpb.AnnotateRequest) (*visionpb.AnnotateResponse, error) {
+	var (
+		opts = g.opts
+		img  = vision.Image{Name: req.GetName()}
+		err  error
+	)
+	if len(req.GetFeatures()) > 0 {
+		opts.Features = nil
+		for _, name := range req.GetFeatures() {
+			features, err := vision.ParseFeatures(name)
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+			opts.Features = append(opts.Features, features...)
+		}
+	}
+	if u := req.GetUrl(); len(u) > 0 {
+		if !isURL(u) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid url %q", u)
+		}
+		img.URI = u
+		if len(img.Name) == 0 {
+			img.Name = u
+		}
+	} else {
+		if len(req.GetContent()) == 0 {
+			return nil, status.Error(codes.InvalidArgument, "one of content or url must be set")
+		}
+		if len(img.Name) == 0 {
+			img.Name = "image"
+		}
+		if img.Content, err = prepareImage(ctx, img.Name, req.GetContent(), g.lo); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	results, err := g.p.Annotate(ctx, []vision.Image{img}, opts)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := results[0].Err; err != nil {
+		slog.Error("Unable to annotate", "file", img.Name, "err", err)
+	}
+	return &visionpb.AnnotateResponse{Id: req.GetId(), Provider: g.p.Name(), Result: newPBResult(results[0])}, nil
+}
+
+// newPBResult returns r as a visionpb.Result.
+func newPBResult(r vision.Result) *visionpb.Result {
+	pr := &visionpb.Result{
+		Name:        r.Name,
+		Labels:      pbLabels(r.Labels),
+		Description: r.Description,
+		Text:        r.Text,
+		TextBlocks:  pbLabels(r.TextBlocks),
+		Landmarks:   pbLabels(r.Landmarks),
+		Logos:       pbLabels(r.Logos),
+		Objects:     pbLabels(r.Objects),
+	}
+	if r.Err != nil {
+		pr.Error = r.Err.Error()
+	}
+	for _, f := range r.Faces {
+		pf := &visionpb.Face{
+			Bounds:     pbBounds(&f.Bounds),
+			Confidence: f.Confidence,
+			Emotions:   f.Emotions,
+			Age:        f.Age,
+			Gender:     f.Gender,
+		}
+		for _, l := range f.Landmarks {
+			pf.Landmarks = append(pf.Landmarks, &visionpb.FaceLandmark{Type: l.Type, X: int32(l.X), Y: int32(l.Y)})
+		}
+		pr.Faces = append(pr.Faces, pf)
+	}
+	if d := r.Document; d != nil {
+		pr.Document = &visionpb.Document{}
+		for _, p := range d.Pages {
+			pp := &visionpb.Page{Width: int32(p.Width), Height: int32(p.Height)}
+			for _, b := range p.Blocks {
+				pb := &visionpb.Block{Bounds: pbBounds(&b.Bounds), Confidence: b.Confidence}
+				for _, para := range b.Paragraphs {
+					ppara := &visionpb.Paragraph{Bounds: pbBounds(&para.Bounds), Confidence: para.Confidence}
+					for _, w := range para.Words {
+						ppara.Words = append(ppara.Words, &visionpb.Word{Text: w.Text, Bounds: pbBounds(&w.Bounds), Confidence: w.Confidence})
+					}
+					pb.Paragraphs = append(pb.Paragraphs, ppara)
+				}
+				pp.Blocks = append(pp.Blocks, pb)
+			}
+			pr.Document.Pages = append(pr.Document.Pages, pp)
+		}
+	}
+	for _, h := range r.CropHints {
+		pr.CropHints = append(pr.CropHints, &visionpb.CropHint{Bounds: pbBounds(&h.Bounds), Confidence: h.Confidence, ImportanceFraction: h.ImportanceFraction})
+	}
+	if ss := r.SafeSearch; ss != nil {
+		pr.SafeSearch = &visionpb.SafeSearch{Adult: ss.Adult, Racy: ss.Racy, Violence: ss.Violence, Medical: ss.Medical, Spoof: ss.Spoof}
+	}
+	if w := r.Web; w != nil {
+		pr.Web = &visionpb.WebDetection{
+			BestGuessLabels:       w.BestGuessLabels,
+			Entities:              pbLabels(w.Entities),
+			FullMatchingImages:    w.FullMatchingImages,
+			PartialMatchingImages: w.PartialMatchingImages,
+			SimilarImages:         w.SimilarImages,
+		}
+		for _, p := range w.Pages {
+			pr.Web.Pages = append(pr.Web.Pages, &visionpb.WebPage{Url: p.URL, Title: p.Title})
+		}
+	}
+	for _, c := range r.Colors {
+		pr.Colors = append(pr.Colors, &visionpb.Color{Hex: c.Hex, Name: c.Name, Fraction: c.Fraction, Score: c.Score})
+	}
+	return pr
+}
+
+func pbLabels(labels []vision.Label) []*visionpb.Label {
+	var pls []*visionpb.Label
+	for _, l := range labels {
+		pl := &visionpb.Label{Description: l.Description, Confidence: l.Confidence, Bounds: pbBounds(l.Bounds)}
+		if l.Location != nil {
+			pl.Location = &visionpb.LatLng{Latitude: l.Location.Latitude, Longitude: l.Location.Longitude}
+		}
+		pls = append(pls, pl)
+	}
+	return pls
+}
+
+func pbBounds(b *vision.BoundingBox) *visionpb.BoundingBox {
+	if b == nil {
+		return nil
+	}
+	return &visionpb.BoundingBox{X: int32(b.X), Y: int32(b.Y), Width: int32(b.Width), Height: int32(b.Height)}
+}
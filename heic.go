@@ -0,0 +1,18 @@
+//go:build heic
+
+package main
+
+import (
+	// Registers a decoder of HEIC/HEIF images (as taken by iPhones), which
+	// requires cgo and libheif.
+	_ "github.com/strukturag/libheif/go/heif"
+)
+
+// heicSupported is whether this binary was built (with -tags heic) to decode
+// HEIC images.
+const heicSupported = true
+
+func init() {
+	imageExtensions[".heic"] = true
+	imageExtensions[".heif"] = true
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"image"
+	"image/jpeg"
+	"log/slog"
+	"os"
+
+	"github.com/asimshankar/visionapi/pkg/preprocess"
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// htmlThumbnailWidth is the width, in pixels, of the thumbnails embedded in
+// HTML reports.
+const htmlThumbnailWidth = 320
+
+// htmlSnippetLength is the maximum number of characters of the text of each
+// image included in HTML reports.
+const htmlSnippetLength = 300
+
+var htmlTemplate = template.Must(template.New("report").Parse(`
+{{- define "header" -}}
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>visionapi report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; background: #fafafa; }
+.image { display: flex; gap: 1.5em; padding: 1em; margin-bottom: 1em; background: #fff; border: 1px solid #ddd; }
+.thumbnail { position: relative; flex: none; width: {{.}}px; align-self: flex-start; }
+.thumbnail img { display: block; width: 100%; }
+.face { position: absolute; border: 2px solid #e33; box-sizing: border-box; }
+h2 { font-size: 1em; margin: 0 0 .5em; word-break: break-all; }
+table { border-collapse: collapse; }
+td { padding: .1em 1em .1em 0; }
+.confidence { color: #666; text-align: right; }
+.text { white-space: pre-wrap; color: #333; background: #f4f4f4; padding: .5em; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+{{end}}
+{{- define "image" -}}
+<div class="image">
+<div class="thumbnail">
+{{- if .Thumbnail}}<img src="{{.Thumbnail}}" alt="">{{end}}
+{{- range .Faces}}<div class="face" style="left: {{.Left}}%; top: {{.Top}}%; width: {{.Width}}%; height: {{.Height}}%"></div>{{end -}}
+</div>
+<div>
+<h2>{{.Name}}</h2>
+{{- if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{- if .Description}}<p>{{.Description}}</p>{{end}}
+{{- if .Labels}}
+<table>
+{{- range .Labels}}<tr><td>{{.Description}}</td><td class="confidence">{{printf "%.2f" .Confidence}}</td></tr>{{end}}
+</table>
+{{- end}}
+{{- if .Faces}}<p>{{len .Faces}} face(s)</p>{{end}}
+{{- if .Text}}<p class="text">{{.Text}}</p>{{end}}
+</div>
+</div>
+{{end}}
+{{- define "footer" -}}
+</body>
+</html>
+{{end}}
+`))
+
+// htmlImage is the data of the "image" template of each result.
+type htmlImage struct {
+	Name, Description, Text, Error string
+	// Thumbnail is a data: URI of the image sent, or its URL.
+	Thumbnail template.URL
+	Labels    []vision.Label
+	// Faces are the percentages of the thumbnail covered by each face.
+	Faces []htmlBox
+}
+
+type htmlBox struct {
+	Left, Top, Width, Height float64
+}
+
+// htmlWriter writes a standalone HTML report into a file, with a thumbnail
+// (of the image sent, with the boxes of detected faces), labels, caption and
+// a snippet of the text of each image, for reviewing results in a browser.
+type htmlWriter struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+func newHTMLWriter(path string) (*htmlWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	h := &htmlWriter{f, bufio.NewWriter(f)}
+	if err := htmlTemplate.ExecuteTemplate(h.w, "header", htmlThumbnailWidth); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *htmlWriter) Write(r vision.Result) error {
+	return h.WriteImage(r, nil)
+}
+
+// WriteImage writes r, with a thumbnail of img if not nil.
+func (h *htmlWriter) WriteImage(r vision.Result, img *vision.Image) error {
+	data := htmlImage{Name: r.Name, Description: r.Description, Labels: r.Labels, Text: r.Text}
+	if r.Err != nil {
+		data.Error = r.Err.Error()
+	}
+	if text := []rune(data.Text); len(text) > htmlSnippetLength {
+		data.Text = string(text[:htmlSnippetLength]) + "…"
+	}
+	switch {
+	case img != nil && len(img.Content) > 0:
+		thumbnail, size, err := htmlThumbnail(img.Content)
+		if err != nil {
+			// The result is reported without the image.
+			slog.Error("Unable to write thumbnail", "file", r.Name, "err", err)
+			break
+		}
+		data.Thumbnail = template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(thumbnail))
+		for _, f := range r.Faces {
+			b := f.Bounds
+			data.Faces = append(data.Faces, htmlBox{
+				Left:   100 * float64(b.X) / float64(size.X),
+				Top:    100 * float64(b.Y) / float64(size.Y),
+				Width:  100 * float64(b.Width) / float64(size.X),
+				Height: 100 * float64(b.Height) / float64(size.Y),
+			})
+		}
+	case img != nil && len(img.URI) > 0 && !vision.IsCloudStorageURI(img.URI):
+		// Faces are not drawn, as the size of the image is unknown.
+		data.Thumbnail = template.URL(img.URI)
+	}
+	return htmlTemplate.ExecuteTemplate(h.w, "image", data)
+}
+
+func (h *htmlWriter) Close() error {
+	err := htmlTemplate.ExecuteTemplate(h.w, "footer", nil)
+	if err == nil {
+		err = h.w.Flush()
+	}
+	if cerr := h.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// htmlThumbnail returns a JPEG of the image content, downscaled to
+// htmlThumbnailWidth if wider, and the size of content.
+func htmlThumbnail(content []byte) ([]byte, image.Point, error) {
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+	size := img.Bounds().Size()
+	if size.X > htmlThumbnailWidth {
+		img = preprocess.Resize(img, htmlThumbnailWidth, max(1, size.Y*htmlThumbnailWidth/size.X))
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, image.Point{}, err
+	}
+	return buf.Bytes(), size, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fileIndex records the local files annotated by previous runs of the same
+// command, so that --since-last-run only annotates those that are new or
+// changed.
+type fileIndex struct {
+	path  string
+	Files map[string]indexedFile `json:"files"`
+}
+
+// indexedFile is the state of a file when it was last annotated.
+type indexedFile struct {
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+	SHA256  string    `json:"sha256"`
+}
+
+// loadFileIndex reads the index of the run st, next to its state file, which
+// is empty if there was no previous run.
+func loadFileIndex(st *runState) (*fileIndex, error) {
+	idx := &fileIndex{
+		path:  strings.TrimSuffix(st.path, filepath.Ext(st.path)) + ".index",
+		Files: make(map[string]indexedFile),
+	}
+	data, err := ioutil.ReadFile(idx.path)
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", idx.path, err)
+	}
+	return idx, nil
+}
+
+// changed returns the filenames that are not local files annotated by a
+// previous run, or whose content changed since. Files whose modification time
+// or size changed are hashed to tell.
+func (idx *fileIndex) changed(filenames []string) []string {
+	var changed []string
+	for _, f := range filenames {
+		prev, ok := idx.Files[f]
+		if !isLocalFile(f) || !ok {
+			changed = append(changed, f)
+			continue
+		}
+		stat, err := os.Stat(f)
+		if err == nil && stat.ModTime().Equal(prev.ModTime) && stat.Size() == prev.Size {
+			continue
+		}
+		if file, err := indexFile(f); err != nil || file.SHA256 != prev.SHA256 {
+			changed = append(changed, f)
+		} else {
+			// Touched, but not changed.
+			idx.Files[f] = file
+		}
+	}
+	slog.Info("Skipping files unchanged since the last run", "unchanged", len(filenames)-len(changed), "changed", len(changed))
+	return changed
+}
+
+// record adds the local file filename, which was annotated, to the index.
+func (idx *fileIndex) record(filename string) {
+	if !isLocalFile(filename) {
+		return
+	}
+	file, err := indexFile(filename)
+	if err != nil {
+		slog.Error("Unable to index", "file", filename, "err", err)
+		return
+	}
+	idx.Files[filename] = file
+}
+
+// save writes the index, atomically.
+func (idx *fileIndex) save() error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0755); err != nil {
+		return fmt.Errorf("unable to create state directory: %v", err)
+	}
+	tmp := idx.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("unable to write index: %v", err)
+	}
+	return os.Rename(tmp, idx.path)
+}
+
+func indexFile(filename string) (indexedFile, error) {
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return indexedFile{}, err
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return indexedFile{}, err
+	}
+	sum := sha256.Sum256(data)
+	return indexedFile{ModTime: stat.ModTime(), Size: stat.Size(), SHA256: hex.EncodeToString(sum[:])}, nil
+}
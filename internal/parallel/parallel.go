@@ -0,0 +1,33 @@
+// Package parallel implements a simple bounded worker pool.
+package parallel
+
+import "sync"
+
+// For calls fn(i) for every i in [0, n), using at most concurrency goroutines
+// (at least one), and returns once all calls have completed.
+func For(n, concurrency int, fn func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+	var (
+		wg   sync.WaitGroup
+		work = make(chan int)
+	)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// keywordRoot is the top of the hierarchy of keywords written by
+// keywordWriter, under which there is a category per feature.
+const keywordRoot = "Vision"
+
+// keywordCategory is a category of the hierarchy of keywords, e.g. the labels.
+type keywordCategory struct {
+	name     string
+	keywords map[string]*keyword // by vision.NormalizeLabel key
+}
+
+// keyword is a keyword of a category, with the other descriptions of it that
+// were merged into it (e.g. "canine" for "dog").
+type keyword struct {
+	name     string
+	synonyms map[string]bool
+}
+
+// keywordWriter writes the labels, objects, landmarks, celebrities and logos
+// detected in images as a hierarchy of keywords into a file, when closed, to
+// be imported into digital asset management tools: a Lightroom keyword list
+// ("lightroom") or digiKam tag paths ("digikam"). Keywords are merged by
+// their vision.NormalizeLabel keys. Errors are logged.
+type keywordWriter struct {
+	path       string
+	format     string
+	categories []keywordCategory
+}
+
+func newKeywordWriter(path, format string) *keywordWriter {
+	k := &keywordWriter{path: path, format: format}
+	for _, name := range []string{"Labels", "Objects", "Landmarks", "Celebrities", "Logos"} {
+		k.categories = append(k.categories, keywordCategory{name, make(map[string]*keyword)})
+	}
+	return k
+}
+
+func (k *keywordWriter) Write(r vision.Result) error {
+	if r.Err != nil {
+		slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+		return nil
+	}
+	for i, labels := range [][]vision.Label{r.Labels, r.Objects, r.Landmarks, r.Celebrities, r.Logos} {
+		c := k.categories[i]
+		for _, l := range labels {
+			name := strings.TrimSpace(l.Description)
+			key := vision.NormalizeLabel(name)
+			if len(key) == 0 {
+				continue
+			}
+			kw, ok := c.keywords[key]
+			if !ok {
+				kw = &keyword{name: name, synonyms: make(map[string]bool)}
+				c.keywords[key] = kw
+			}
+			if !strings.EqualFold(name, kw.name) {
+				kw.synonyms[strings.ToLower(name)] = true
+			}
+		}
+	}
+	return nil
+}
+
+func (k *keywordWriter) Close() error {
+	var buf bytes.Buffer
+	if k.format == "lightroom" {
+		// As per the format of Lightroom's Metadata > Import Keywords: a
+		// keyword per line, indented by a tab per level, with categories in
+		// brackets (so they are not exported with images) and synonyms in
+		// braces.
+		fmt.Fprintf(&buf, "[%s]\n", keywordRoot)
+	}
+	for _, c := range k.categories {
+		if len(c.keywords) == 0 {
+			continue
+		}
+		if k.format == "lightroom" {
+			fmt.Fprintf(&buf, "\t[%s]\n", c.name)
+		}
+		for _, kw := range c.sorted() {
+			if k.format == "digikam" {
+				// digiKam's tag paths, as in its digiKam:TagsList XMP
+				// metadata, in which "/" separates the levels.
+				fmt.Fprintf(&buf, "%s/%s/%s\n", keywordRoot, c.name, strings.ReplaceAll(kw.name, "/", " "))
+				continue
+			}
+			fmt.Fprintf(&buf, "\t\t%s\n", kw.name)
+			var synonyms []string
+			for s := range kw.synonyms {
+				synonyms = append(synonyms, s)
+			}
+			sort.Strings(synonyms)
+			for _, s := range synonyms {
+				fmt.Fprintf(&buf, "\t\t\t{%s}\n", s)
+			}
+		}
+	}
+	return ioutil.WriteFile(k.path, buf.Bytes(), 0644)
+}
+
+// sorted returns the keywords of c, in alphabetical order.
+func (c keywordCategory) sorted() []*keyword {
+	keywords := make([]*keyword, 0, len(c.keywords))
+	for _, kw := range c.keywords {
+		keywords = append(keywords, kw)
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		return strings.ToLower(keywords[i].name) < strings.ToLower(keywords[j].name)
+	})
+	return keywords
+}
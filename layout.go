@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/asimshankar/visionapi/pkg/pdf"
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// hocrWriter writes the document layout of every image as a page of a single
+// hOCR document, as per http://kba.github.io/hocr-spec/1.2/.
+type hocrWriter struct {
+	w     io.Writer
+	pages int
+}
+
+const hocrHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" lang="en">
+ <head>
+  <title></title>
+  <meta http-equiv="Content-Type" content="text/html;charset=utf-8"/>
+  <meta name="ocr-system" content="visionapi"/>
+  <meta name="ocr-capabilities" content="ocr_page ocr_carea ocr_par ocr_line ocrx_word"/>
+ </head>
+ <body>
+`
+
+func (h *hocrWriter) Write(r vision.Result) error {
+	doc := layoutOf(r)
+	if doc == nil {
+		return nil
+	}
+	if h.pages == 0 {
+		if _, err := io.WriteString(h.w, hocrHeader); err != nil {
+			return err
+		}
+	}
+	var buf strings.Builder
+	for _, p := range doc.Pages {
+		h.pages++
+		page := h.pages
+		fmt.Fprintf(&buf, "  <div class=\"ocr_page\" id=\"page_%d\" title=\"image %s; bbox 0 0 %d %d; ppageno %d\">\n", page, xmlText(fmt.Sprintf("%q", r.Name)), p.Width, p.Height, page-1)
+		for i, b := range p.Blocks {
+			fmt.Fprintf(&buf, "   <div class=\"ocr_carea\" id=\"block_%d_%d\" title=\"%s\">\n", page, i+1, hocrBBox(b.Bounds))
+			for j, par := range b.Paragraphs {
+				// Paragraphs are not split into lines by all providers, so
+				// each is a single line.
+				fmt.Fprintf(&buf, "    <p class=\"ocr_par\" id=\"par_%d_%d_%d\" title=\"%s\">\n", page, i+1, j+1, hocrBBox(par.Bounds))
+				fmt.Fprintf(&buf, "     <span class=\"ocr_line\" id=\"line_%d_%d_%d\" title=\"%s\">", page, i+1, j+1, hocrBBox(par.Bounds))
+				for k, w := range par.Words {
+					if k > 0 {
+						buf.WriteString(" ")
+					}
+					title := hocrBBox(w.Bounds)
+					if w.Confidence > 0 {
+						title += fmt.Sprintf("; x_wconf %.0f", w.Confidence*100)
+					}
+					fmt.Fprintf(&buf, "<span class=\"ocrx_word\" id=\"word_%d_%d_%d_%d\" title=\"%s\">%s</span>", page, i+1, j+1, k+1, title, xmlText(w.Text))
+				}
+				buf.WriteString("</span>\n    </p>\n")
+			}
+			buf.WriteString("   </div>\n")
+		}
+		buf.WriteString("  </div>\n")
+	}
+	_, err := io.WriteString(h.w, buf.String())
+	return err
+}
+
+func (h *hocrWriter) Close() error {
+	if h.pages == 0 {
+		return nil
+	}
+	_, err := io.WriteString(h.w, " </body>\n</html>\n")
+	return err
+}
+
+func hocrBBox(b vision.BoundingBox) string {
+	return fmt.Sprintf("bbox %d %d %d %d", b.X, b.Y, b.X+b.Width, b.Y+b.Height)
+}
+
+// altoWriter writes the document layout of every image as a page of a single
+// ALTO v4 document, as per https://www.loc.gov/standards/alto/.
+type altoWriter struct {
+	w     io.Writer
+	pages int
+}
+
+const altoHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<alto xmlns="http://www.loc.gov/standards/alto/ns-v4#" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.loc.gov/standards/alto/ns-v4# http://www.loc.gov/standards/alto/v4/alto-4-2.xsd">
+ <Description>
+  <MeasurementUnit>pixel</MeasurementUnit>
+ </Description>
+ <Layout>
+`
+
+func (a *altoWriter) Write(r vision.Result) error {
+	doc := layoutOf(r)
+	if doc == nil {
+		return nil
+	}
+	if a.pages == 0 {
+		if _, err := io.WriteString(a.w, altoHeader); err != nil {
+			return err
+		}
+	}
+	var buf strings.Builder
+	for _, p := range doc.Pages {
+		a.pages++
+		page := a.pages
+		// ALTO has no attribute for the image of a page, so it is noted in a
+		// comment.
+		fmt.Fprintf(&buf, "  <!-- %s -->\n", strings.ReplaceAll(r.Name, "--", "- -"))
+		fmt.Fprintf(&buf, "  <Page ID=\"page_%d\" PHYSICAL_IMG_NR=\"%d\" WIDTH=\"%d\" HEIGHT=\"%d\">\n", page, page, p.Width, p.Height)
+		fmt.Fprintf(&buf, "   <PrintSpace HPOS=\"0\" VPOS=\"0\" WIDTH=\"%d\" HEIGHT=\"%d\">\n", p.Width, p.Height)
+		for i, b := range p.Blocks {
+			fmt.Fprintf(&buf, "    <TextBlock ID=\"block_%d_%d\" %s>\n", page, i+1, altoPosition(b.Bounds))
+			for j, par := range b.Paragraphs {
+				fmt.Fprintf(&buf, "     <TextLine ID=\"line_%d_%d_%d\" %s>\n", page, i+1, j+1, altoPosition(par.Bounds))
+				for k, w := range par.Words {
+					if k > 0 {
+						buf.WriteString("      <SP/>\n")
+					}
+					fmt.Fprintf(&buf, "      <String ID=\"string_%d_%d_%d_%d\" CONTENT=\"%s\" %s", page, i+1, j+1, k+1, xmlText(w.Text), altoPosition(w.Bounds))
+					if w.Confidence > 0 {
+						fmt.Fprintf(&buf, " WC=\"%.2f\"", w.Confidence)
+					}
+					buf.WriteString("/>\n")
+				}
+				buf.WriteString("     </TextLine>\n")
+			}
+			buf.WriteString("    </TextBlock>\n")
+		}
+		buf.WriteString("   </PrintSpace>\n  </Page>\n")
+	}
+	_, err := io.WriteString(a.w, buf.String())
+	return err
+}
+
+func (a *altoWriter) Close() error {
+	if a.pages == 0 {
+		return nil
+	}
+	_, err := io.WriteString(a.w, " </Layout>\n</alto>\n")
+	return err
+}
+
+func altoPosition(b vision.BoundingBox) string {
+	return fmt.Sprintf("HPOS=\"%d\" VPOS=\"%d\" WIDTH=\"%d\" HEIGHT=\"%d\"", b.X, b.Y, b.Width, b.Height)
+}
+
+// pdfWriter writes a searchable PDF of each image, with the detected text as
+// an invisible layer over the original image, to <filename>.pdf. If perDir is
+// set, the images of each directory are instead combined into a single PDF
+// named after the directory (e.g. scans/scans.pdf), in the order of the input.
+type pdfWriter struct {
+	perDir      bool
+	jpegQuality int
+	dirs        []string              // in the order first seen
+	pages       map[string][]pdf.Page // by directory, if perDir
+}
+
+func (p *pdfWriter) Write(r vision.Result) error {
+	doc := layoutOf(r)
+	if doc == nil {
+		return nil
+	}
+	if !isLocalFile(r.Name) {
+		slog.Error("Searchable PDFs can only be written for local files", "file", r.Name)
+		return nil
+	}
+	// The original image is embedded, which may have a higher resolution
+	// than the image sent.
+	content, err := ioutil.ReadFile(r.Name)
+	if err == nil {
+		content, err = pdf.ToJPEG(content, p.jpegQuality)
+	}
+	if err != nil {
+		slog.Error("Unable to write PDF", "file", r.Name, "err", err)
+		return nil
+	}
+	var pages []pdf.Page
+	for _, layout := range doc.Pages {
+		pages = append(pages, pdf.Page{JPEG: content, Layout: layout})
+	}
+	if !p.perDir {
+		p.writeFile(r.Name+".pdf", pages)
+		return nil
+	}
+	dir := filepath.Dir(r.Name)
+	if p.pages == nil {
+		p.pages = make(map[string][]pdf.Page)
+	}
+	if _, ok := p.pages[dir]; !ok {
+		p.dirs = append(p.dirs, dir)
+	}
+	p.pages[dir] = append(p.pages[dir], pages...)
+	return nil
+}
+
+func (p *pdfWriter) Close() error {
+	for _, dir := range p.dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		p.writeFile(filepath.Join(dir, filepath.Base(abs)+".pdf"), p.pages[dir])
+	}
+	return nil
+}
+
+func (p *pdfWriter) writeFile(filename string, pages []pdf.Page) {
+	var buf bytes.Buffer
+	err := pdf.Write(&buf, pages)
+	if err == nil {
+		err = ioutil.WriteFile(filename, buf.Bytes(), 0644)
+	}
+	if err != nil {
+		slog.Error("Unable to write PDF", "file", filename, "err", err)
+		return
+	}
+	slog.Info("Wrote PDF", "file", filename, "pages", len(pages))
+}
+
+// layoutOf returns the document layout of r, logging errors (and skipping
+// images without a layout).
+func layoutOf(r vision.Result) *vision.Document {
+	if r.Err != nil {
+		slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+	} else if r.Document == nil {
+		slog.Warn("No document layout", "file", r.Name)
+	}
+	return r.Document
+}
+
+// xmlText escapes s for use in XML text and attributes.
+func xmlText(s string) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+	"github.com/asimshankar/visionapi/pkg/metadata"
+	"github.com/asimshankar/visionapi/pkg/preprocess"
+	"github.com/asimshankar/visionapi/pkg/vision"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// expandPatterns returns the files matching each of the provided patterns.
+// URLs (and gs:// and s3:// objects), and stdinName, are returned as is, while
+// folders of cloud drives (see driveFlags) are listed. If recursive is true,
+// matching directories are walked for image files (and multi-page PDF and TIFF
+// files, if documents is true). Files or directories whose path or name match
+// any of the exclude patterns are skipped. Videos are found by walking
+// directories as well.
+func expandPatterns(patterns []string, recursive bool, exclude []string, documents bool) []string {
+	var filenames []string
+	for _, pattern := range patterns {
+		if isDrivePath(pattern) {
+			files, err := drives.list(context.Background(), pattern, recursive, documents)
+			if err != nil {
+				slog.Error("Unable to list", "file", pattern, "err", err)
+			}
+			filenames = append(filenames, files...)
+			continue
+		}
+		if isURL(pattern) || pattern == stdinName {
+			filenames = append(filenames, pattern)
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			slog.Error("Invalid file pattern", "pattern", pattern, "err", err)
+			continue
+		}
+		for _, match := range matches {
+			if isExcluded(match, exclude) {
+				continue
+			}
+			if stat, err := os.Stat(match); !recursive || err != nil || !stat.IsDir() {
+				filenames = append(filenames, match)
+				continue
+			}
+			err := filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					slog.Error("Unable to read", "file", path, "err", err)
+					return nil
+				}
+				if isExcluded(path, exclude) {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				ext := strings.ToLower(filepath.Ext(path))
+				if !info.IsDir() && (imageExtensions[ext] || videoExtensions[ext] || documents && len(vision.FileMIMEType(path)) > 0) {
+					filenames = append(filenames, path)
+				}
+				return nil
+			})
+			if err != nil {
+				slog.Error("Unable to walk", "file", match, "err", err)
+			}
+		}
+	}
+	return filenames
+}
+
+// imageExtensions are the extensions of files considered when walking
+// directories.
+var imageExtensions = map[string]bool{
+	".arw":  true,
+	".bmp":  true,
+	".cr2":  true,
+	".dng":  true,
+	".gif":  true,
+	".jpeg": true,
+	".jpg":  true,
+	".nef":  true,
+	".png":  true,
+	".tif":  true,
+	".tiff": true,
+	".webp": true,
+}
+
+// Image formats (as registered with the image package) accepted by each
+// provider, as per:
+// https://cloud.google.com/vision/docs/supported-files
+// https://docs.microsoft.com/azure/cognitive-services/computer-vision/overview-image-analysis#image-requirements
+// https://docs.aws.amazon.com/rekognition/latest/dg/limits.html
+// https://ai.google.dev/gemini-api/docs/image-understanding#supported-formats
+// https://docs.anthropic.com/en/docs/build-with-claude/vision
+// https://github.com/ollama/ollama/blob/main/docs/api.md
+// https://cloud.google.com/vertex-ai/docs/image-data/classification/prepare-data
+// Images in other formats are transcoded to JPEGs before being sent.
+var providerFormats = map[string]map[string]bool{
+	"google":    {"bmp": true, "gif": true, "jpeg": true, "png": true, "tiff": true, "webp": true},
+	"microsoft": {"bmp": true, "gif": true, "jpeg": true, "png": true},
+	"aws":       {"jpeg": true, "png": true},
+	"gemini":    {"jpeg": true, "png": true, "webp": true},
+	"claude":    {"gif": true, "jpeg": true, "png": true, "webp": true},
+	"ollama":    {"jpeg": true, "png": true},
+	"vertex":    {"bmp": true, "gif": true, "jpeg": true, "png": true},
+}
+
+// defaultFormats are the image formats sent as is to other providers (or when
+// the provider is not known).
+var defaultFormats = map[string]bool{"gif": true, "jpeg": true, "png": true}
+
+// isHEIC returns true if byts starts like a HEIC/HEIF image, i.e. an ISO base
+// media file of one of the HEIF brands.
+func isHEIC(byts []byte) bool {
+	if len(byts) < 12 || string(byts[4:8]) != "ftyp" {
+		return false
+	}
+	switch string(byts[8:12]) {
+	case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+		return true
+	}
+	return false
+}
+
+// splitDocuments separates local multi-page files (PDF, and TIFF if tiffs is
+// true), and those of cloud drives, which are annotated by a
+// vision.FileProvider, from images. Otherwise TIFF files are images, of which
+// the first page is annotated.
+func splitDocuments(filenames []string, tiffs bool) (images, documents []string) {
+	for _, f := range filenames {
+		mimeType := vision.FileMIMEType(f)
+		if (!isURL(f) || isDrivePath(f)) && len(mimeType) > 0 && (tiffs || mimeType != "image/tiff") {
+			documents = append(documents, f)
+		} else {
+			images = append(images, f)
+		}
+	}
+	return images, documents
+}
+
+// loadDocuments reads multi-page files, downloading those of cloud drives.
+// Files that cannot be read are returned as failed results.
+func loadDocuments(ctx context.Context, filenames []string) (files []vision.File, failed []vision.Result) {
+	for _, f := range filenames {
+		var (
+			byts []byte
+			err  error
+		)
+		if isDrivePath(f) {
+			byts, err = drives.download(ctx, f)
+		} else {
+			byts, err = ioutil.ReadFile(f)
+		}
+		if err != nil {
+			failed = append(failed, vision.Result{Name: f, Err: fmt.Errorf("unable to load: %v", err)})
+			continue
+		}
+		files = append(files, vision.File{Name: f, Content: byts, MIMEType: vision.FileMIMEType(f)})
+	}
+	return files, failed
+}
+
+func isExcluded(path string, exclude []string) bool {
+	for _, pattern := range exclude {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadOptions control the loading and validation of images.
+type loadOptions struct {
+	// download URLs instead of leaving them to the provider to fetch.
+	download bool
+	// Recommended limits, which images must be within unless force is set.
+	minWidth, minHeight int
+	maxBytes            int
+	force               bool
+	// resize images larger than maxBytes, by re-encoding them as JPEGs of
+	// jpegQuality.
+	resize      bool
+	jpegQuality int
+	// orient images upright as per their Exif orientation, by re-encoding
+	// them (as for resize) if they are not.
+	orient bool
+	// formats sent as is, see providerFormats.
+	formats map[string]bool
+}
+
+// Recommended minimum image dimensions of each provider, as per:
+// https://cloud.google.com/vision/docs/best-practices#image_sizing
+// https://docs.microsoft.com/azure/cognitive-services/computer-vision/overview-image-analysis#image-requirements
+// https://docs.aws.amazon.com/rekognition/latest/dg/limits.html
+var recommendedMinSize = map[string][2]int{
+	"google":    {640, 480},
+	"microsoft": {50, 50},
+	"aws":       {80, 80},
+}
+
+// 4 MB as per https://cloud.google.com/vision/docs/best-practices#file_sizes
+const recommendedMaxBytes = 4 << 20
+
+// applyDefaults replaces zero limits by the recommendations for provider, and
+// sets the formats it accepts.
+func (lo *loadOptions) applyDefaults(provider string) {
+	min := recommendedMinSize[provider]
+	if lo.minWidth <= 0 {
+		lo.minWidth = min[0]
+	}
+	if lo.minHeight <= 0 {
+		lo.minHeight = min[1]
+	}
+	if lo.maxBytes <= 0 {
+		lo.maxBytes = recommendedMaxBytes
+	}
+	if lo.formats = providerFormats[provider]; lo.formats == nil {
+		lo.formats = defaultFormats
+	}
+}
+
+// chunkFiles returns the number of files loaded at once by loadChunks. As
+// images are at most lo.maxBytes once loaded (unless --force is set), chunks
+// of chunkBytes bound the memory used by a run, however many files it loads.
+func (lo *loadOptions) chunkFiles(concurrency int) int {
+	return max(concurrency, chunkBytes/lo.maxBytes, 1)
+}
+
+// loadFlags are the flags of the loadOptions of a command.
+type loadFlags struct {
+	minWidth, minHeight int
+	maxBytes            int
+	force               bool
+	noResize            bool
+	jpegQuality         int
+	noOrient            bool
+}
+
+func (lf *loadFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&lf.minWidth, "min-width", 0, "Minimum width of images to send (default: the recommendation of the API, e.g. 640 for google)")
+	fs.IntVar(&lf.minHeight, "min-height", 0, "Minimum height of images to send (default: the recommendation of the API, e.g. 480 for google)")
+	fs.BoolVar(&lf.force, "force", false, "Send images that are outside the recommended size limits anyway")
+	fs.IntVar(&lf.maxBytes, "max-bytes", recommendedMaxBytes, "Maximum size of images to send, larger images are re-encoded to fit")
+	fs.BoolVar(&lf.noResize, "no-resize", false, "Do not re-encode images larger than --max-bytes (they are skipped instead, unless --force is set)")
+	fs.IntVar(&lf.jpegQuality, "jpeg-quality", 85, "JPEG quality (1-100) of re-encoded images")
+	fs.BoolVar(&lf.noOrient, "no-orient", false, "Do not rotate images upright as per their Exif orientation (by re-encoding them) before sending them")
+}
+
+// options returns the loadOptions of the flags, before applyDefaults.
+func (lf *loadFlags) options() loadOptions {
+	return loadOptions{
+		minWidth:    lf.minWidth,
+		minHeight:   lf.minHeight,
+		maxBytes:    lf.maxBytes,
+		force:       lf.force,
+		resize:      !lf.noResize,
+		jpegQuality: lf.jpegQuality,
+		orient:      !lf.noOrient,
+	}
+}
+
+// loadImages loads files concurrently, preserving their order. URLs are only
+// downloaded if lo.download is true, and are otherwise left to the provider to
+// fetch. Files that cannot be loaded are returned as failed results.
+func loadImages(ctx context.Context, filenames []string, lo loadOptions, concurrency int) (images []vision.Image, failed []vision.Result) {
+	var (
+		loaded = make([][]byte, len(filenames))
+		errs   = make([]error, len(filenames))
+	)
+	parallel.For(len(filenames), concurrency, func(i int) {
+		switch {
+		case !isURL(filenames[i]):
+			loaded[i], errs[i] = loadFile(ctx, filenames[i], lo)
+		case lo.download || isDrivePath(filenames[i]):
+			// The APIs cannot fetch files of cloud drives.
+			loaded[i], errs[i] = downloadFile(ctx, filenames[i], lo)
+		}
+	})
+	for i, filename := range filenames {
+		if errs[i] != nil {
+			failed = append(failed, vision.Result{Name: filename, Err: fmt.Errorf("unable to load: %v", errs[i])})
+			continue
+		}
+		img := vision.Image{Name: filename, Content: loaded[i]}
+		if isURL(filename) && !lo.download && !isDrivePath(filename) {
+			img.URI = filename
+		}
+		images = append(images, img)
+	}
+	return images, failed
+}
+
+// loadChunks loads files a chunk at a time (see chunkFiles), calling fn with
+// the images of each chunk and the files of it that could not be loaded.
+func loadChunks(ctx context.Context, filenames []string, lo loadOptions, concurrency int, fn func(images []vision.Image, failed []vision.Result)) {
+	n := lo.chunkFiles(concurrency)
+	for len(filenames) > 0 {
+		chunk := filenames[:min(n, len(filenames))]
+		fn(loadImages(ctx, chunk, lo, concurrency))
+		filenames = filenames[len(chunk):]
+	}
+}
+
+// annotateChunks annotates files with p a chunk at a time (see loadChunks),
+// calling fn with the images of each chunk and their results. Files that
+// cannot be loaded are logged and counted as skipped in summary.
+func annotateChunks(ctx context.Context, p vision.Provider, filenames []string, lo loadOptions, opts vision.Options, summary *runSummary, fn func(images []vision.Image, results []vision.Result)) {
+	loadChunks(ctx, filenames, lo, opts.Concurrency, func(images []vision.Image, failed []vision.Result) {
+		for _, r := range failed {
+			slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+		}
+		summary.skipped += len(failed)
+		results, err := p.Annotate(ctx, images, opts)
+		if err != nil {
+			fatal(err)
+		}
+		fn(images, results)
+	})
+}
+
+// stdinName is the name of the image read from the standard input.
+const stdinName = "-"
+
+func loadFile(ctx context.Context, filename string, lo loadOptions) ([]byte, error) {
+	var (
+		byts []byte
+		err  error
+	)
+	if filename == stdinName {
+		byts, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		byts, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read failed: %v", err)
+	}
+	return prepareImage(ctx, filename, byts, lo)
+}
+
+func downloadFile(ctx context.Context, url string, lo loadOptions) ([]byte, error) {
+	download := vision.Download
+	if isDrivePath(url) {
+		download = drives.download
+	}
+	byts, err := download(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return prepareImage(ctx, url, byts, lo)
+}
+
+// prepareImage validates that byts is an image within the recommended limits
+// of lo, returning the content to send. Images that are too large are
+// re-encoded if lo.resize is set, and with lo.force images outside the limits
+// are only warned about. Images in formats that are not in lo.formats (e.g.
+// HEIC, or WebP for AWS) are transcoded, and of camera RAW files only the
+// embedded JPEG preview is sent.
+func prepareImage(ctx context.Context, name string, byts []byte, lo loadOptions) (_ []byte, err error) {
+	_, span := tracer.Start(ctx, "Prepare", trace.WithAttributes(attribute.String("file", name), attribute.Int("bytes", len(byts))))
+	defer func() {
+		spanError(span, err)
+		span.End()
+	}()
+	orientation := metadata.Orientation(byts)
+	if preprocess.IsRAW(name) {
+		if byts, orientation, err = preprocess.RAWPreview(byts); err != nil {
+			return nil, err
+		}
+		span.SetAttributes(attribute.Int("preview_bytes", len(byts)))
+	}
+	if !lo.orient {
+		orientation = 1
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(byts))
+	if err != nil && isHEIC(byts) && !heicSupported {
+		return nil, fmt.Errorf("HEIC images are not supported by this binary (build it with -tags heic)")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	x, y := cfg.Width, cfg.Height
+	formats := lo.formats
+	if formats == nil {
+		formats = defaultFormats
+	}
+	transcode := !formats[format]
+	if fit := len(byts) > lo.maxBytes && lo.resize; fit || transcode || orientation > 1 {
+		img, _, err := image.Decode(bytes.NewReader(byts))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %v", err)
+		}
+		// Some APIs ignore the Exif orientation (and it is lost when
+		// re-encoding), so the pixels are rotated instead.
+		img = preprocess.Orient(img, orientation)
+		// Transcoded images (HEIC in particular) may well be larger
+		// than the original, so they are fit as well.
+		maxBytes := lo.maxBytes
+		if !lo.resize {
+			maxBytes = math.MaxInt
+		}
+		resized, err := preprocess.Fit(img, maxBytes, lo.jpegQuality)
+		if err != nil {
+			return nil, err
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(resized))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode resized image: %v", err)
+		}
+		span.SetAttributes(attribute.Int("resized_bytes", len(resized)))
+		if transcode {
+			slog.Debug("Transcoded", "file", name, "from", format, "to", "jpeg")
+		}
+		if orientation > 1 {
+			slog.Debug("Rotated upright", "file", name, "orientation", orientation)
+		}
+		if fit {
+			slog.Info("Re-encoded to fit", "file", name, "from", fmt.Sprintf("%d bytes, %dx%d", len(byts), x, y), "to", fmt.Sprintf("%d bytes, %dx%d", len(resized), cfg.Width, cfg.Height))
+		}
+		byts, x, y = resized, cfg.Width, cfg.Height
+	}
+	var problem error
+	if len(byts) > lo.maxBytes {
+		problem = fmt.Errorf("file size (%.1f MB) is larger than recommended size of %.1f MB", float64(len(byts))/(1<<20), float64(lo.maxBytes)/(1<<20))
+	} else if x < lo.minWidth || y < lo.minHeight {
+		problem = fmt.Errorf("image size (%dx%d) is smaller than recommended minimum of %dx%d", x, y, lo.minWidth, lo.minHeight)
+	}
+	if problem != nil && !lo.force {
+		return nil, fmt.Errorf("%v (use --force to send anyway)", problem)
+	}
+	if problem != nil {
+		slog.Warn("Sending anyway", "file", name, "problem", problem)
+	}
+	slog.Debug("Loaded", "file", name, "bytes", len(byts), "width", x, "height", y)
+	return byts, nil
+}
+
+// isLocalFile returns true if name is a local file, rather than a URL or the
+// standard input.
+func isLocalFile(name string) bool {
+	return !isURL(name) && name != stdinName
+}
+
+// readFileList returns the non-empty lines of r, which are files or URLs.
+func readFileList(r io.Reader) ([]string, error) {
+	var filenames []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if line := strings.TrimRight(s.Text(), "\r"); len(line) > 0 {
+			filenames = append(filenames, line)
+		}
+	}
+	return filenames, s.Err()
+}
+
+// isURL returns true if name is an http(s) URL, a gs:// or s3:// object or a
+// file of a cloud drive (e.g. dropbox://), rather than a local file.
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") || vision.IsCloudStorageURI(name) || isDrivePath(name)
+}
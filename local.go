@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/asimshankar/visionapi/pkg/cache"
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// localClassifier returns cfg with the default classifier (see
+// vision.LocalModelURL) if no model is set, downloading it into the models
+// directory of the cache the first time, so that later runs need no network.
+func localClassifier(ctx context.Context, cfg vision.LocalConfig) (vision.LocalConfig, error) {
+	if len(cfg.Model) > 0 {
+		if len(cfg.Labels) == 0 {
+			return cfg, fmt.Errorf("--local-model requires --local-labels")
+		}
+		return cfg, nil
+	}
+	dir, err := modelsDir()
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.Model, err = downloadOnce(ctx, vision.LocalModelURL, dir); err != nil {
+		return cfg, err
+	}
+	if len(cfg.Labels) == 0 {
+		if cfg.Labels, err = downloadOnce(ctx, vision.LocalLabelsURL, dir); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// clipModel returns the files of the CLIP model in dir, or of the default
+// model (see vision.CLIPModelURLs) if dir is not set, downloading them into
+// the models directory of the cache the first time.
+func clipModel(ctx context.Context, dir string) (vision.CLIPConfig, error) {
+	if len(dir) > 0 {
+		return vision.CLIPConfig{
+			ImageModel: filepath.Join(dir, "vision_model.onnx"),
+			TextModel:  filepath.Join(dir, "text_model.onnx"),
+			Vocab:      filepath.Join(dir, "vocab.json"),
+			Merges:     filepath.Join(dir, "merges.txt"),
+		}, nil
+	}
+	models, err := modelsDir()
+	if err != nil {
+		return vision.CLIPConfig{}, err
+	}
+	dir = filepath.Join(models, "clip")
+	var cfg vision.CLIPConfig
+	for _, f := range []struct {
+		path *string
+		url  string
+	}{
+		{&cfg.ImageModel, vision.CLIPModelURLs.ImageModel},
+		{&cfg.TextModel, vision.CLIPModelURLs.TextModel},
+		{&cfg.Vocab, vision.CLIPModelURLs.Vocab},
+		{&cfg.Merges, vision.CLIPModelURLs.Merges},
+	} {
+		if *f.path, err = downloadOnce(ctx, f.url, dir); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// modelsDir returns the directory of the models downloaded by local
+// providers, in the cache directory.
+func modelsDir() (string, error) {
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "models"), nil
+}
+
+// downloadOnce returns the path of the file of url in dir, downloading it
+// unless it already is.
+func downloadOnce(ctx context.Context, url, dir string) (string, error) {
+	dest := filepath.Join(dir, path.Base(url))
+	if _, err := os.Stat(dest); err == nil {
+		return dest, nil
+	}
+	slog.Info("Downloading", "url", url, "dir", dir)
+	data, err := vision.Download(ctx, url)
+	if err != nil {
+		return "", fmt.Errorf("unable to download %s: %v", url, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	// Written to a temporary file first so that an interrupted download is
+	// not mistaken for a complete one.
+	tmp := fmt.Sprintf("%s.%d.tmp", dest, os.Getpid())
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return "", err
+	}
+	return dest, os.Rename(tmp, dest)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// logOutput is the destination of log records, which the progress bar
+// replaces while it is drawn.
+var logOutput = &switchWriter{w: os.Stderr}
+
+// logFlags configure the logging of all commands.
+type logFlags struct {
+	level  string
+	format string
+}
+
+var logging logFlags
+
+func (lf *logFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&lf.level, "log-level", "info", "Minimum level of log messages: debug, info, warn or error")
+	fs.StringVar(&lf.format, "log-format", "text", "Format of log messages: text, or json (one object per line, with the time, level, message and attributes such as the file)")
+}
+
+// setup directs log messages (including those of the log package, e.g. from
+// the providers) to logOutput, in the configured format.
+func (lf *logFlags) setup() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(lf.level)); err != nil {
+		return fmt.Errorf("invalid --log-level(%s), must be 'debug', 'info', 'warn' or 'error'", lf.level)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch lf.format {
+	case "text":
+		h = slog.NewTextHandler(logOutput, opts)
+	case "json":
+		h = slog.NewJSONHandler(logOutput, opts)
+	default:
+		return fmt.Errorf("invalid --log-format(%s), must be 'text' or 'json'", lf.format)
+	}
+	// Messages of the log package are logged by h too, at the info level, so
+	// that they are filtered and formatted as the others.
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// Exit codes of the commands, other than 0 if every image succeeded.
+const (
+	// exitFatal is for errors that stop a command, e.g. of its flags,
+	// configuration or credentials.
+	exitFatal = 1
+	// exitPartial is for runs in which some, but not all, images failed.
+	exitPartial = 2
+	// exitFailed is for runs in which every image failed.
+	exitFailed = 3
+)
+
+// fatal logs err and exits.
+func fatal(err error) {
+	slog.Error(err.Error())
+	exitWith(exitFatal)
+}
+
+// exitWith exits with code, once exit has run.
+func exitWith(code int) {
+	exit()
+	os.Exit(code)
+}
+
+// exit writes the requests recorded and exports the spans that were ended,
+// before the process exits.
+func exit() {
+	if err := cassette.close(); err != nil {
+		slog.Error(err.Error())
+	}
+	tracing.shutdown()
+}
+
+// switchWriter is an io.Writer whose destination can be changed concurrently
+// with writes.
+type switchWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// set changes the destination of s to w.
+func (s *switchWriter) set(w io.Writer) {
+	s.mu.Lock()
+	s.w = w
+	s.mu.Unlock()
+}
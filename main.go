@@ -1,216 +1,491 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"image"
-	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
-	"io/ioutil"
-	"log"
-	"net/http"
 	"os"
-	"path/filepath"
-	"sort"
 	"strings"
 
-	"golang.org/x/oauth2/google"
-	"google.golang.org/api/vision/v1"
+	"github.com/asimshankar/visionapi/pkg/cache"
+	"github.com/asimshankar/visionapi/pkg/vision"
 )
 
 const (
-	microsoftApiKeyEnvVar = "MICROSOFT_API_KEY"
+	microsoftApiKeyEnvVar   = "MICROSOFT_API_KEY"
+	azureEndpointEnvVar     = "AZURE_VISION_ENDPOINT"
+	geminiApiKeyEnvVar      = "GEMINI_API_KEY"
+	anthropicApiKeyEnvVar   = "ANTHROPIC_API_KEY"
+	ollamaHostEnvVar        = "OLLAMA_HOST"
+	huggingFaceEnvVar       = "HF_TOKEN"
+	clarifaiPATEnvVar       = "CLARIFAI_PAT"
+	imaggaKeyEnvVar         = "IMAGGA_API_KEY"
+	imaggaSecretEnvVar      = "IMAGGA_API_SECRET"
+	cloudflareAccountEnvVar = "CLOUDFLARE_ACCOUNT_ID"
+	cloudflareTokenEnvVar   = "CLOUDFLARE_API_TOKEN"
+	replicateTokenEnvVar    = "REPLICATE_API_TOKEN"
+	deepStackHostEnvVar     = "DEEPSTACK_HOST"
+	deepStackAPIKeyEnvVar   = "DEEPSTACK_API_KEY"
 )
 
+// command is a subcommand of the CLI, run with the arguments that follow its
+// name.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+var commands = []command{
+	{"annotate", "Annotate images with the requested features (the default command)", func(args []string) { annotateMain("annotate", "labels", args) }},
+	{"ocr", "Detect the text in images (annotate with --features=text)", func(args []string) { annotateMain("ocr", "text", args) }},
+	{"faces", "Detect the faces in images (annotate with --features=faces), or group them by person (faces cluster)", facesMain},
+	{"watch", "Annotate the images that are created or modified in directories, until interrupted", func(args []string) { annotateMain("watch", "labels", args) }},
+	{"alt-text", "Write a concise one-sentence description of images, for the alt text of web pages", altTextMain},
+	{"crop", "Write thumbnails cropped around the most interesting part of images", cropMain},
+	{"serve", "Annotate images posted to an HTTP server", serveMain},
+	{"receipts", "Extract the merchant, date, total and line items of photos of receipts", receiptsMain},
+	{"products", "Find the products of a catalog (a Product Search product set) similar to images", productsMain},
+	{"organize", "Move, copy or link images into a directory per label", organizeMain},
+	{"search", "Find images by the labels stored in the cache or a SQLite database", searchMain},
+	{"diff", "Compare the labels of two sets of results written with --output=json", diffMain},
+	{"model", "Start or stop the Rekognition Custom Labels model of --aws-model-arn, which is billed while it runs, or show its status", modelMain},
+	{"cache", "Show the location and size of the cache of results, or clear it", cacheMain},
+	{"config", "Show or change the defaults of flags in the configuration file", configMain},
+}
+
 func main() {
-	flag.Usage = usage
-	verbose := flag.Bool("v", false, "Verbose output")
-	provider := flag.String("api", "auto", "Which API to use: google, microsoft or auto-detect (and possibly both)")
-	flag.Parse()
-	if flag.NArg() < 1 {
-		flag.Usage()
+	if len(os.Args) < 2 {
+		usage()
 		return
 	}
-	*provider = strings.ToLower(*provider)
-	switch *provider {
-	case "google":
-		mainGoogle(*verbose)
-	case "microsoft":
-		mainMicrosoft(*verbose)
-	case "auto":
-		if len(os.Getenv(microsoftApiKeyEnvVar)) > 0 {
-			mainMicrosoft(*verbose)
-		} else {
-			mainGoogle(*verbose)
+	switch os.Args[1] {
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+	}
+	defer exit()
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			c.run(os.Args[2:])
+			return
 		}
-	default:
-		log.Fatalf("Invalid --provider(%s), must be 'auto', 'google' or 'microsoft'", *provider)
 	}
+	// Flags and files without a command are annotated, as before commands
+	// were introduced.
+	annotateMain("annotate", "labels", os.Args[1:])
 }
 
-func mainMicrosoft(verbose bool) {
-	client := http.DefaultClient
-	key := os.Getenv(microsoftApiKeyEnvVar)
-	if len(key) == 0 {
-		log.Fatal("Must set %s environment variable to a valid obtained from https://www.microsoft.com/cognitive-services/en-US/subscriptions", microsoftApiKeyEnvVar)
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags] <filename or URL>...\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", c.name, c.summary)
 	}
-	for _, pattern := range flag.Args() {
-		matches, err := filepath.Glob(pattern)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Invalid file pattern %s: %v", pattern, err)
-			continue
-		}
-		for _, filename := range matches {
-			byts, err := loadFile(filename)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Unable to load %s: %v\n", filename, err)
-				continue
-			}
-			// From:
-			// https://www.microsoft.com/cognitive-services/en-us/computer-vision-api/documentation/howtocallvisionapi
-			// and
-			// https://dev.projectoxford.ai/docs/services/56f91f2d778daf23d8ec6739/operations/56f91f2e778daf14a499e1fa
-			req, err := http.NewRequest("POST", "https://api.projectoxford.ai/vision/v1.0/analyze?visualFeatures=Description,Tags", bytes.NewReader(byts))
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Unable to create request for %s: %v\n", filename, err)
-				continue
-			}
-			req.Header.Add("Content-Type", "application/octet-stream")
-			req.Header.Add("Ocp-Apim-Subscription-Key", key)
-			resp, err := client.Do(req)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "HTTP request for %s failed: %v", filename, err)
-				continue
-			}
-			respJson := make(map[string]interface{})
-			err = json.NewDecoder(resp.Body).Decode(&respJson)
-			resp.Body.Close()
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "HTTP request for %s failed: %v", filename, err)
-				continue
-			}
-			txt, err := json.MarshalIndent(respJson, "", "  ")
-			if err != nil {
-				fmt.Printf("%s: %s\n", filename, respJson)
-			} else {
-				fmt.Printf("%s: %s\n", filename, txt)
-			}
-		}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> --help for the flags of each command.\n", os.Args[0])
+}
+
+// newFlagSet returns the flags of a command, whose usage is described by args
+// (e.g. "<filename or URL>...").
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [flags] %s\n", os.Args[0], name, args)
+		fs.PrintDefaults()
 	}
+	logging.register(fs)
+	tracing.register(fs)
+	cassette.register(fs)
+	drives.register(fs)
+	return fs
 }
 
-func mainGoogle(verbose bool) {
-	ctx := context.Background()
-	client, err := google.DefaultClient(ctx, vision.CloudPlatformScope)
-	if err != nil {
-		log.Fatal(err)
+// parseFlags parses args into fs, after applying the defaults from the
+// configuration file, and sets up logging, recording or replaying and tracing.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	if err := applyConfig(fs); err != nil {
+		fatal(err)
 	}
-	service, err := vision.New(client)
-	if err != nil {
-		log.Fatal(err)
-	}
-	var (
-		request      = &vision.BatchAnnotateImagesRequest{}
-		requestSize  = 0
-		requestFiles []string
-	)
-	for _, pattern := range flag.Args() {
-		matches, err := filepath.Glob(pattern)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Invalid file pattern %s: %v", pattern, err)
-			continue
-		}
-		for _, filename := range matches {
-			byts, err := loadFile(filename)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Unable to load %s: %v\n", filename, err)
-				continue
-			}
-			// 8 MB per request size limit as per:
-			// https://cloud.google.com/vision/docs/best-practices#file_sizes
-			if requestSize+len(byts) > 8<<20 {
-				executeRequest(service, request, requestFiles, verbose)
-				request.Requests = nil
-				requestSize = 0
-				requestFiles = nil
-			}
-			request.Requests = append(request.Requests, &vision.AnnotateImageRequest{
-				Image: &vision.Image{
-					Content: base64.StdEncoding.EncodeToString(byts),
-				},
-				Features: []*vision.Feature{{Type: "LABEL_DETECTION"}},
-			})
-			requestSize += len(byts)
-			requestFiles = append(requestFiles, filename)
-		}
+	parseArgs(fs, args)
+	if err := logging.setup(); err != nil {
+		fatal(err)
+	}
+	if err := cassette.setup(); err != nil {
+		fatal(err)
+	}
+	if err := tracing.setup(); err != nil {
+		fatal(err)
 	}
-	executeRequest(service, request, requestFiles, verbose)
 }
 
-func executeRequest(service *vision.Service, request *vision.BatchAnnotateImagesRequest, requestFiles []string, verbose bool) {
-	response, err := service.Images.Annotate(request).Do()
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Cloud Vision API request failed: %v", err)
-		return
+// parseArgs parses args into fs, exiting if they are invalid (once fs has
+// printed the error and the usage) or if help was requested.
+func parseArgs(fs *flag.FlagSet, args []string) {
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(exitFatal)
 	}
-	if verbose {
-		txt, err := json.MarshalIndent(response, "", "  ")
-		if err != nil {
-			log.Printf("%+v\n", response)
-		} else {
-			log.Printf("%s\n", txt)
+}
+
+// providerFlags select and configure the API used by a command.
+type providerFlags struct {
+	api          string
+	google       vision.GoogleConfig
+	microsoftKey string
+	azure        vision.MicrosoftConfig
+	awsModelARN  string
+	gemini       vision.GeminiConfig
+	claude       vision.ClaudeConfig
+	ollama       vision.OllamaConfig
+	local        vision.LocalConfig
+	clipDir      string
+	huggingFace  vision.HuggingFaceConfig
+	clarifai     vision.ClarifaiConfig
+	imagga       vision.ImaggaConfig
+	cloudflare   vision.CloudflareConfig
+	replicate    vision.ReplicateConfig
+	deepStack    vision.DeepStackConfig
+	// replicateInputs are the key=value inputs of --api=replicate.
+	replicateInputs stringList
+	// model of --api=huggingface, clarifai or replicate.
+	model        string
+	mockFixtures string
+}
+
+func (pf *providerFlags) register(fs *flag.FlagSet, api string) {
+	fs.StringVar(&pf.api, "api", api, "Which API to use: google, microsoft, aws, gemini, claude, ollama, local (an image classifier run locally, in binaries built with -tags onnx), clip (scoring images against --classes locally, as for local), huggingface (the --model of the Hugging Face Hub), clarifai, imagga, cloudflare (Workers AI), replicate (the --model hosted by Replicate), deepstack (a self-hosted DeepStack or CodeProject.AI server), auto (microsoft if a key is set, otherwise google), all (each of them that is configured, comparing their results), mock (the results in --mock-fixtures) or the name of a plugin")
+	fs.StringVar(&pf.google.APIKey, "google-api-key", "", "API key for --api=google, instead of Application Default Credentials")
+	fs.StringVar(&pf.google.CredentialsFile, "google-credentials", "", "Service account JSON file for --api=google, instead of Application Default Credentials")
+	fs.StringVar(&pf.google.Model, "google-model", "", "Vertex AI endpoint of a custom-trained image classification or object detection model (projects/PROJECT/locations/LOCATION/endpoints/ENDPOINT) that --api=google sends images to, instead of the Cloud Vision API")
+	fs.StringVar(&pf.microsoftKey, "microsoft-key", "", "Key of the Azure AI Vision resource for --api=microsoft (default: $"+microsoftApiKeyEnvVar+")")
+	fs.StringVar(&pf.azure.Endpoint, "azure-endpoint", "", "Endpoint of the Azure AI Vision resource for --api=microsoft, e.g. https://myvision.cognitiveservices.azure.com (default: $"+azureEndpointEnvVar+")")
+	fs.StringVar(&pf.azure.Region, "azure-region", "", "Region of the Azure AI Vision resource for --api=microsoft (e.g. westus), used if no endpoint is set")
+	fs.StringVar(&pf.awsModelARN, "aws-model-arn", "", "ARN of a running Rekognition Custom Labels model that --api=aws sends images to instead (see the model command to start and stop it)")
+	fs.StringVar(&pf.gemini.APIKey, "gemini-api-key", "", "API key of the Generative Language API for --api=gemini (default: $"+geminiApiKeyEnvVar+")")
+	fs.StringVar(&pf.gemini.Model, "gemini-model", vision.DefaultGeminiModel, "Gemini model for --api=gemini")
+	fs.StringVar(&pf.claude.APIKey, "anthropic-api-key", "", "API key of the Anthropic API for --api=claude (default: $"+anthropicApiKeyEnvVar+")")
+	fs.StringVar(&pf.claude.Model, "claude-model", vision.DefaultClaudeModel, "Claude model for --api=claude")
+	fs.StringVar(&pf.ollama.Host, "ollama-host", "", "URL of the Ollama server for --api=ollama (default: $"+ollamaHostEnvVar+" or "+vision.DefaultOllamaHost+")")
+	fs.StringVar(&pf.ollama.Model, "ollama-model", vision.DefaultOllamaModel, "Vision model served by Ollama for --api=ollama, e.g. llava or moondream")
+	fs.StringVar(&pf.local.Model, "local-model", "", "ONNX file of the image classifier of --api=local (default: MobileNet v2, downloaded once into the cache directory)")
+	fs.StringVar(&pf.local.Labels, "local-labels", "", "File of the names of the classes of --local-model, one per line")
+	fs.StringVar(&pf.clipDir, "clip-dir", "", "Directory of the CLIP model of --api=clip: vision_model.onnx, text_model.onnx, vocab.json and merges.txt (default: ViT-B/32, downloaded once into the cache directory)")
+	fs.StringVar(&pf.local.Library, "onnxruntime", "", "Path of the ONNX Runtime shared library for --api=local and clip, e.g. /usr/local/lib/libonnxruntime.so (default: as found by the system)")
+	fs.StringVar(&pf.huggingFace.Token, "huggingface-token", "", "Access token of Hugging Face for --api=huggingface (default: $"+huggingFaceEnvVar+")")
+	fs.StringVar(&pf.clarifai.PAT, "clarifai-pat", "", "Personal access token of Clarifai for --api=clarifai (default: $"+clarifaiPATEnvVar+")")
+	fs.StringVar(&pf.imagga.Key, "imagga-key", "", "API key of Imagga for --api=imagga, or key:secret (default: $"+imaggaKeyEnvVar+")")
+	fs.StringVar(&pf.imagga.Secret, "imagga-secret", "", "API secret of Imagga for --api=imagga (default: $"+imaggaSecretEnvVar+")")
+	fs.StringVar(&pf.imagga.Categorizer, "imagga-categorizer", "", "Categorizer of --api=imagga whose categories are also labels, e.g. personal_photos")
+	fs.StringVar(&pf.cloudflare.AccountID, "cloudflare-account", "", "Account ID of Cloudflare for --api=cloudflare (default: $"+cloudflareAccountEnvVar+")")
+	fs.StringVar(&pf.cloudflare.Token, "cloudflare-token", "", "API token of Cloudflare with permission to use Workers AI for --api=cloudflare (default: $"+cloudflareTokenEnvVar+")")
+	fs.StringVar(&pf.cloudflare.Model, "cloudflare-model", vision.DefaultCloudflareModel, "Image classification model of Workers AI labeling images for --api=cloudflare")
+	fs.StringVar(&pf.cloudflare.CaptionModel, "cloudflare-caption-model", vision.DefaultCloudflareCaptionModel, "Image-to-text model of Workers AI captioning images (or answering --question) for --api=cloudflare, or empty for no captions")
+	fs.StringVar(&pf.replicate.Token, "replicate-token", "", "API token of Replicate for --api=replicate (default: $"+replicateTokenEnvVar+")")
+	fs.StringVar(&pf.replicate.ImageInput, "replicate-image-input", "image", "Input of the --model of --api=replicate that images are sent as")
+	fs.Var(&pf.replicateInputs, "replicate-input", "Other input of the --model of --api=replicate, as key=value (e.g. query=\"cat . dog\" for GroundingDINO); may be repeated")
+	fs.StringVar(&pf.deepStack.Host, "deepstack-host", "", "URL of the DeepStack or CodeProject.AI server for --api=deepstack (default: $"+deepStackHostEnvVar+" or "+vision.DefaultDeepStackHost+")")
+	fs.StringVar(&pf.deepStack.APIKey, "deepstack-api-key", "", "API key the server of --api=deepstack was started with, if any (default: $"+deepStackAPIKeyEnvVar+")")
+	fs.StringVar(&pf.model, "model", "", "Model of --api=huggingface (an image classification, object detection or image-to-text model of the Hub, e.g. facebook/detr-resnet-50, default: "+vision.DefaultHuggingFaceModel+"), clarifai (general, food, travel, nsfw, apparel or the user/app/model ID of another model, default: general) or replicate (owner/name:version, e.g. of BLIP, RAM or GroundingDINO)")
+	fs.StringVar(&pf.mockFixtures, "mock-fixtures", "", "File of the results returned by --api=mock, in the format of --output=json, whose names are patterns matched against the files (e.g. *.jpg, or no name to match any file)")
+	fs.StringVar(&pf.azure.APIVersion, "azure-api-version", vision.MicrosoftV32, "Azure AI Vision API version: "+vision.MicrosoftV32+" or "+vision.MicrosoftV4+" (Image Analysis 4.0, which does not support faces or safe-search)")
+	fs.StringVar(&pf.azure.CustomVisionProject, "azure-custom-vision-project", "", "ID of an Azure Custom Vision project whose --azure-custom-vision-iteration --api=microsoft sends images to instead, with the key and endpoint of its prediction resource")
+	fs.StringVar(&pf.azure.CustomVisionIteration, "azure-custom-vision-iteration", "", "Name the iteration of --azure-custom-vision-project was published as")
+	fs.StringVar(&pf.azure.OCR, "azure-ocr", vision.MicrosoftOCR, "Azure AI Vision v3.2 API to detect text with: "+vision.MicrosoftOCR+" or "+vision.MicrosoftRead+" (the asynchronous Read API, better at handwriting and dense documents)")
+}
+
+// resolve returns the flags with keys and endpoints that are not set taken
+// from the environment.
+func (pf *providerFlags) resolve() providerFlags {
+	cfg := *pf
+	cfg.api = strings.ToLower(cfg.api)
+	if len(cfg.azure.Endpoint) == 0 {
+		cfg.azure.Endpoint = os.Getenv(azureEndpointEnvVar)
+	}
+	if len(cfg.microsoftKey) == 0 {
+		cfg.microsoftKey = os.Getenv(microsoftApiKeyEnvVar)
+	}
+	if len(cfg.gemini.APIKey) == 0 {
+		cfg.gemini.APIKey = os.Getenv(geminiApiKeyEnvVar)
+	}
+	if len(cfg.claude.APIKey) == 0 {
+		cfg.claude.APIKey = os.Getenv(anthropicApiKeyEnvVar)
+	}
+	if len(cfg.ollama.Host) == 0 {
+		cfg.ollama.Host = os.Getenv(ollamaHostEnvVar)
+	}
+	if len(cfg.huggingFace.Token) == 0 {
+		cfg.huggingFace.Token = os.Getenv(huggingFaceEnvVar)
+	}
+	if len(cfg.clarifai.PAT) == 0 {
+		cfg.clarifai.PAT = os.Getenv(clarifaiPATEnvVar)
+	}
+	if len(cfg.imagga.Key) == 0 {
+		cfg.imagga.Key = os.Getenv(imaggaKeyEnvVar)
+	}
+	if len(cfg.imagga.Secret) == 0 {
+		cfg.imagga.Secret = os.Getenv(imaggaSecretEnvVar)
+	}
+	if len(cfg.cloudflare.AccountID) == 0 {
+		cfg.cloudflare.AccountID = os.Getenv(cloudflareAccountEnvVar)
+	}
+	if len(cfg.cloudflare.Token) == 0 {
+		cfg.cloudflare.Token = os.Getenv(cloudflareTokenEnvVar)
+	}
+	if len(cfg.replicate.Token) == 0 {
+		cfg.replicate.Token = os.Getenv(replicateTokenEnvVar)
+	}
+	if len(cfg.deepStack.Host) == 0 {
+		cfg.deepStack.Host = os.Getenv(deepStackHostEnvVar)
+	}
+	if len(cfg.deepStack.APIKey) == 0 {
+		cfg.deepStack.APIKey = os.Getenv(deepStackAPIKeyEnvVar)
+	}
+	cfg.huggingFace.Model, cfg.clarifai.Model, cfg.replicate.Model = cfg.model, cfg.model, cfg.model
+	cassette.credentials(&cfg)
+	return cfg
+}
+
+// apiName returns the name of the provider selected by --api, which for "auto"
+// depends on the keys that are set, and is "vertex" for google with
+// --google-model, "customvision" for microsoft with
+// --azure-custom-vision-project and "awscustom" for aws with --aws-model-arn.
+func (pf providerFlags) apiName() string {
+	name := pf.api
+	if name == "auto" {
+		name = "google"
+		if len(pf.microsoftKey) > 0 {
+			name = "microsoft"
 		}
 	}
-	for i, r := range response.Responses {
-		labels := entityAnnotationsByConfidence(r.LabelAnnotations)
-		sort.Sort(labels)
-		fmt.Printf("%s: %v\n", requestFiles[i], labels)
+	if name == "google" && len(pf.google.Model) > 0 {
+		return "vertex"
+	}
+	if name == "microsoft" && len(pf.azure.CustomVisionProject) > 0 {
+		return "customvision"
+	}
+	if name == "aws" && len(pf.awsModelARN) > 0 {
+		return "awscustom"
 	}
+	return name
 }
 
-type entityAnnotationsByConfidence []*vision.EntityAnnotation
+// newProvider returns the provider selected by --api.
+func (pf *providerFlags) newProvider(ctx context.Context) (vision.Provider, error) {
+	cfg := pf.resolve()
+	return newProvider(ctx, cfg.apiName(), cfg)
+}
+
+// cacheFlags configure the cache of results used by a command.
+type cacheFlags struct {
+	disabled bool
+	dir      string
+	// metrics, if not nil, record the metrics of the providers wrapped.
+	metrics *metrics
+}
 
-func (l entityAnnotationsByConfidence) Len() int           { return len(l) }
-func (l entityAnnotationsByConfidence) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
-func (l entityAnnotationsByConfidence) Less(i, j int) bool { return l[i].Confidence < l[j].Confidence }
-func (l entityAnnotationsByConfidence) String() string {
-	strs := make([]string, l.Len())
-	for i, a := range l {
-		strs[i] = a.Description
+func (cf *cacheFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&cf.disabled, "no-cache", false, "Do not use or update the cache of results")
+	fs.StringVar(&cf.dir, "cache-dir", "", "Directory to cache results in, keyed by image content and features (default: the user cache directory, e.g. ~/.cache/visionapi)")
+}
+
+// open returns the cache, or nil if it is disabled (including while recording
+// or replaying requests).
+func (cf *cacheFlags) open() (*cache.Cache, error) {
+	if cf.disabled || cassette.active() {
+		return nil, nil
+	}
+	dir := cf.dir
+	if len(dir) == 0 {
+		var err error
+		if dir, err = cache.DefaultDir(); err != nil {
+			return nil, err
+		}
 	}
-	return fmt.Sprintf("%v", strs)
+	return cache.Open(dir)
 }
 
-func loadFile(filename string) ([]byte, error) {
-	stat, err := os.Stat(filename)
+// wrap returns p with results cached, unless the cache is disabled, and with
+// its metrics recorded in cf.metrics and its calls traced.
+func (cf *cacheFlags) wrap(p vision.Provider) (vision.Provider, error) {
+	c, err := cf.open()
 	if err != nil {
-		return nil, fmt.Errorf("stat failed: %v", err)
+		return nil, err
 	}
-	if stat.Size() > (4 << 20) {
-		return nil, fmt.Errorf("file size (%v MB) is larger than recommended size of 4 MB as per https://cloud.google.com/vision/docs/best-practices#file_sizes", (stat.Size()*1.)/(1<<20))
+	// Results of --api=mock are not cached, so that changes to the fixtures
+	// apply.
+	if c != nil && p.Name() != "mock" {
+		p = c.Wrap(p)
 	}
-	byts, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, fmt.Errorf("read failed: %v", err)
+	return cf.metrics.wrap(tracing.wrap(p)), nil
+}
+
+// rateFlags limit the rate of requests sent by a command.
+type rateFlags struct {
+	qps       float64
+	perMinute int
+}
+
+func (rf *rateFlags) register(fs *flag.FlagSet) {
+	fs.Float64Var(&rf.qps, "qps", 0, "Maximum number of API requests per second, including retries (0 for no limit)")
+	fs.IntVar(&rf.perMinute, "max-requests-per-minute", 0, "Maximum number of API requests per minute, including retries (0 for no limit)")
+}
+
+// limiter returns the lower of the limits set, or nil if neither is.
+func (rf *rateFlags) limiter() (*vision.RateLimiter, error) {
+	if rf.qps < 0 || rf.perMinute < 0 {
+		return nil, fmt.Errorf("--qps and --max-requests-per-minute must not be negative")
 	}
-	img, _, err := image.Decode(bytes.NewReader(byts))
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %v", err)
+	perSecond := rf.qps
+	if m := float64(rf.perMinute) / 60; m > 0 && (perSecond == 0 || m < perSecond) {
+		perSecond = m
 	}
-	x, y := img.Bounds().Dx(), img.Bounds().Dy()
-	if x < 640 || x < 480 {
-		return nil, fmt.Errorf("image size (%dx%d) is smaller than recommended minimum of 640x480 as per https://cloud.google.com/vision/docs/best-practices#image_sizing", x, y)
+	if perSecond == 0 {
+		return nil, nil
 	}
-	log.Printf("%s is %d bytes and %dx%d pixels", filename, stat.Size(), x, y)
-	return byts, nil
+	return vision.NewRateLimiter(perSecond), nil
 }
 
-func usage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s <filename>\n", os.Args[0])
-	flag.PrintDefaults()
+func featureNames() string {
+	names := make([]string, len(vision.AllFeatures))
+	for i, f := range vision.AllFeatures {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ",")
+}
+
+func newProvider(ctx context.Context, name string, cfg providerFlags) (vision.Provider, error) {
+	switch name {
+	case "google":
+		return vision.NewGoogleWithConfig(ctx, cfg.google)
+	case "vertex":
+		if len(cfg.google.Model) == 0 {
+			return nil, fmt.Errorf("must set --google-model to the Vertex AI endpoint of a model")
+		}
+		return vision.NewVertex(ctx, cfg.google)
+	case "microsoft":
+		if len(cfg.microsoftKey) == 0 {
+			return nil, fmt.Errorf("must set --microsoft-key or the %s environment variable to a key of an Azure AI Vision resource, see https://learn.microsoft.com/en-us/azure/ai-services/computer-vision/", microsoftApiKeyEnvVar)
+		}
+		if len(cfg.azure.Endpoint) == 0 && len(cfg.azure.Region) == 0 {
+			return nil, fmt.Errorf("must set --azure-endpoint, the %s environment variable or --azure-region", azureEndpointEnvVar)
+		}
+		azure := cfg.azure
+		azure.Key = cfg.microsoftKey
+		return vision.NewMicrosoft(azure)
+	case "customvision":
+		if len(cfg.microsoftKey) == 0 {
+			return nil, fmt.Errorf("must set --microsoft-key or the %s environment variable to the key of the prediction resource of the Custom Vision project", microsoftApiKeyEnvVar)
+		}
+		if len(cfg.azure.Endpoint) == 0 && len(cfg.azure.Region) == 0 {
+			return nil, fmt.Errorf("must set --azure-endpoint, the %s environment variable or --azure-region", azureEndpointEnvVar)
+		}
+		if len(cfg.azure.CustomVisionProject) == 0 || len(cfg.azure.CustomVisionIteration) == 0 {
+			return nil, fmt.Errorf("must set --azure-custom-vision-project and --azure-custom-vision-iteration")
+		}
+		azure := cfg.azure
+		azure.Key = cfg.microsoftKey
+		return vision.NewCustomVision(azure)
+	case "aws":
+		return vision.NewAWS()
+	case "awscustom":
+		if len(cfg.awsModelARN) == 0 {
+			return nil, fmt.Errorf("must set --aws-model-arn to the ARN of a Rekognition Custom Labels model")
+		}
+		return vision.NewAWSCustomLabels(cfg.awsModelARN)
+	case "gemini":
+		if len(cfg.gemini.APIKey) == 0 {
+			return nil, fmt.Errorf("must set --gemini-api-key or the %s environment variable to a key of the Generative Language API, see https://ai.google.dev/gemini-api/docs/api-key", geminiApiKeyEnvVar)
+		}
+		return vision.NewGemini(cfg.gemini)
+	case "claude":
+		if len(cfg.claude.APIKey) == 0 {
+			return nil, fmt.Errorf("must set --anthropic-api-key or the %s environment variable to a key of the Anthropic API, see https://docs.anthropic.com/en/api/getting-started", anthropicApiKeyEnvVar)
+		}
+		return vision.NewClaude(cfg.claude)
+	case "ollama":
+		return vision.NewOllama(cfg.ollama)
+	case "local":
+		local, err := localClassifier(ctx, cfg.local)
+		if err != nil {
+			return nil, err
+		}
+		return vision.NewLocal(local)
+	case "clip":
+		model, err := clipModel(ctx, cfg.clipDir)
+		if err != nil {
+			return nil, err
+		}
+		model.Library = cfg.local.Library
+		return vision.NewCLIP(model)
+	case "huggingface":
+		if len(cfg.huggingFace.Token) == 0 {
+			return nil, fmt.Errorf("must set --huggingface-token or the %s environment variable to an access token of Hugging Face, see https://huggingface.co/settings/tokens", huggingFaceEnvVar)
+		}
+		return vision.NewHuggingFace(cfg.huggingFace)
+	case "clarifai":
+		if len(cfg.clarifai.PAT) == 0 {
+			return nil, fmt.Errorf("must set --clarifai-pat or the %s environment variable to a personal access token of Clarifai, see https://docs.clarifai.com/clarifai-basics/authentication/personal-access-tokens", clarifaiPATEnvVar)
+		}
+		return vision.NewClarifai(cfg.clarifai)
+	case "imagga":
+		if len(cfg.imagga.Key) == 0 {
+			return nil, fmt.Errorf("must set --imagga-key and --imagga-secret or the %s and %s environment variables to the API key and secret of Imagga, see https://imagga.com/profile/dashboard", imaggaKeyEnvVar, imaggaSecretEnvVar)
+		}
+		return vision.NewImagga(cfg.imagga)
+	case "cloudflare":
+		if len(cfg.cloudflare.AccountID) == 0 || len(cfg.cloudflare.Token) == 0 {
+			return nil, fmt.Errorf("must set --cloudflare-account and --cloudflare-token or the %s and %s environment variables to an account of Cloudflare and an API token of it, see https://developers.cloudflare.com/workers-ai/get-started/rest-api/", cloudflareAccountEnvVar, cloudflareTokenEnvVar)
+		}
+		return vision.NewCloudflare(cfg.cloudflare)
+	case "replicate":
+		if len(cfg.replicate.Token) == 0 {
+			return nil, fmt.Errorf("must set --replicate-token or the %s environment variable to an API token of Replicate, see https://replicate.com/account/api-tokens", replicateTokenEnvVar)
+		}
+		if len(cfg.replicate.Model) == 0 {
+			return nil, fmt.Errorf("must set --model with --api=replicate, e.g. salesforce/blip:<version>, see https://replicate.com/explore")
+		}
+		replicate := cfg.replicate
+		replicate.Inputs = make(map[string]string)
+		for _, input := range cfg.replicateInputs {
+			kv := strings.SplitN(input, "=", 2)
+			if len(kv) != 2 || len(kv[0]) == 0 {
+				return nil, fmt.Errorf("invalid --replicate-input(%s), must be key=value", input)
+			}
+			replicate.Inputs[kv[0]] = kv[1]
+		}
+		return vision.NewReplicate(replicate)
+	case "deepstack":
+		return vision.NewDeepStack(cfg.deepStack)
+	case "mock":
+		if len(cfg.mockFixtures) == 0 {
+			return nil, fmt.Errorf("must set --mock-fixtures with --api=mock")
+		}
+		return vision.NewMock(cfg.mockFixtures)
+	default:
+		if p, err := vision.NewPlugin(name); err == nil {
+			return p, nil
+		}
+		return nil, fmt.Errorf("invalid --api(%s), must be 'auto', 'all', 'google', 'microsoft', 'aws', 'gemini', 'claude', 'ollama', 'local', 'clip', 'huggingface', 'clarifai', 'imagga', 'cloudflare', 'replicate', 'deepstack', 'mock' or the name of a plugin (%s%s in $PATH)", name, vision.PluginPrefix, name)
+	}
+}
+
+// splitList returns the non-empty elements of the comma-separated list s.
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); len(e) > 0 {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// stringList is a flag.Value for flags that can be repeated.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
 }
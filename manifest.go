@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// manifestEntry is a line of a --filelist manifest: a file or URL to annotate,
+// with options that override those of the command line for it.
+type manifestEntry struct {
+	Path string `json:"path"`
+	// Features is a comma-separated list of features, as per --features.
+	Features      string   `json:"features,omitempty"`
+	LanguageHints []string `json:"languageHints,omitempty"`
+	// Output is the file to write the result to (as a JSON document, as
+	// per --output=json), instead of the output of the command.
+	Output string `json:"output,omitempty"`
+
+	features []vision.Feature
+}
+
+// manifest is a --filelist manifest, whose methods may be called on a nil
+// manifest (i.e. when there is none), for the options of the command line.
+type manifest struct {
+	entries map[string]*manifestEntry // By path.
+	// provider is the name of the provider in results written to files.
+	provider string
+}
+
+// readManifest reads a JSON Lines manifest, returning its paths in order.
+func readManifest(filename string) (*manifest, []string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	var (
+		m     = &manifest{entries: make(map[string]*manifestEntry)}
+		paths []string
+		s     = bufio.NewScanner(f)
+		line  int
+	)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line++
+		if len(strings.TrimSpace(s.Text())) == 0 {
+			continue
+		}
+		var e manifestEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %v", filename, line, err)
+		}
+		if len(e.Path) == 0 {
+			return nil, nil, fmt.Errorf("%s:%d: missing path", filename, line)
+		}
+		if e.features, err = vision.ParseFeatures(e.Features); err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %v", filename, line, err)
+		}
+		if _, ok := m.entries[e.Path]; ok {
+			return nil, nil, fmt.Errorf("%s:%d: duplicate path %s", filename, line, e.Path)
+		}
+		m.entries[e.Path] = &e
+		paths = append(paths, e.Path)
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, err
+	}
+	return m, paths, nil
+}
+
+// options returns opts, as overridden by the entry of name.
+func (m *manifest) options(name string, opts vision.Options) vision.Options {
+	if m == nil || m.entries[name] == nil {
+		return opts
+	}
+	e := m.entries[name]
+	if len(e.features) > 0 {
+		opts.Features = e.features
+	}
+	if len(e.LanguageHints) > 0 {
+		opts.LanguageHints = e.LanguageHints
+	}
+	return opts
+}
+
+// annotate annotates images with p, as per their options. Images with the same
+// options are annotated together.
+func (m *manifest) annotate(ctx context.Context, p vision.Provider, images []vision.Image, opts vision.Options) ([]vision.Result, error) {
+	if m == nil {
+		return p.Annotate(ctx, images, opts)
+	}
+	var (
+		results = make([]vision.Result, len(images))
+		groups  = make(map[string][]int) // Indices of images, by options.
+		keys    []string
+	)
+	for i, img := range images {
+		var key string
+		if e := m.entries[img.Name]; e != nil {
+			key = fmt.Sprintf("%v\x00%q", e.features, e.LanguageHints)
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+	for _, key := range keys {
+		group := groups[key]
+		batch := make([]vision.Image, len(group))
+		for j, i := range group {
+			batch[j] = images[i]
+		}
+		annotated, err := p.Annotate(ctx, batch, m.options(batch[0].Name, opts))
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range group {
+			results[i] = annotated[j]
+		}
+	}
+	return results, nil
+}
+
+// write writes r, of img (if known), to the output file of its entry, if any,
+// and otherwise to out.
+func (m *manifest) write(out resultWriter, r vision.Result, img *vision.Image) error {
+	var dest string
+	if m != nil && m.entries[r.Name] != nil {
+		dest = m.entries[r.Name].Output
+	}
+	if iw, ok := out.(imageResultWriter); ok && len(dest) == 0 {
+		return iw.WriteImage(r, img)
+	}
+	if len(dest) == 0 {
+		return out.Write(r)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(newJSONResult(r, m.provider))
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dest, append(data, '\n'), 0644)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics are the Prometheus metrics of the serve and watch commands, labeled
+// by provider.
+type metrics struct {
+	registry *prometheus.Registry
+	images   *prometheus.CounterVec
+	cached   *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	requests *prometheus.CounterVec
+	bytes    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		images: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "visionapi_images_total",
+			Help: "Number of images annotated, successfully or not, including those whose results were cached.",
+		}, []string{"provider"}),
+		cached: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "visionapi_cache_hits_total",
+			Help: "Number of images whose results were cached.",
+		}, []string{"provider"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "visionapi_errors_total",
+			Help: "Number of failures, by class: service (e.g., quota or authentication), canceled, image (e.g., an invalid image) or request (a whole batch failed, e.g. as a feature is not supported).",
+		}, []string{"provider", "class"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "visionapi_api_requests_total",
+			Help: "Number of API requests made, including retries.",
+		}, []string{"provider"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "visionapi_uploaded_bytes_total",
+			Help: "Number of bytes uploaded in API requests.",
+		}, []string{"provider"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "visionapi_annotate_duration_seconds",
+			Help:    "Time taken to annotate each batch of images (of one image, for serve).",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+		}, []string{"provider"}),
+	}
+	m.registry.MustRegister(m.images, m.cached, m.errors, m.requests, m.bytes, m.duration,
+		prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	return m
+}
+
+// handler returns the handler of /metrics.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// serve serves /metrics on addr, in the background.
+func (m *metrics) serve(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.handler())
+	slog.Info("Serving metrics", "url", "http://"+addr+"/metrics")
+	go func() { fatal(http.ListenAndServe(addr, mux)) }()
+}
+
+// wrap returns p, recording its metrics. m may be nil, in which case p is
+// returned as is.
+func (m *metrics) wrap(p vision.Provider) vision.Provider {
+	if m == nil {
+		return p
+	}
+	return &metricsProvider{p, m}
+}
+
+// metricsProvider records the metrics of the images annotated by p.
+type metricsProvider struct {
+	p vision.Provider
+	m *metrics
+}
+
+func (mp *metricsProvider) Name() string { return mp.p.Name() }
+
+func (mp *metricsProvider) Capabilities() []vision.Feature { return mp.p.Capabilities() }
+
+func (mp *metricsProvider) Annotate(ctx context.Context, images []vision.Image, opts vision.Options) ([]vision.Result, error) {
+	// The requests and cache hits of p are counted by its own Stats, which
+	// are added to those of the caller once done.
+	var (
+		name   = mp.p.Name()
+		stats  vision.Stats
+		caller = opts.Stats
+		start  = time.Now()
+	)
+	opts.Stats = &stats
+	results, err := mp.p.Annotate(ctx, images, opts)
+	mp.m.duration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	s := stats.Snapshot()
+	caller.Add(s)
+	mp.m.images.WithLabelValues(name).Add(float64(s.Images))
+	mp.m.cached.WithLabelValues(name).Add(float64(s.Cached))
+	mp.m.requests.WithLabelValues(name).Add(float64(s.Requests))
+	mp.m.bytes.WithLabelValues(name).Add(float64(s.Bytes))
+	if err != nil {
+		mp.m.errors.WithLabelValues(name, "request").Inc()
+		return nil, err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			mp.m.errors.WithLabelValues(name, errorClass(r.Err)).Inc()
+		}
+	}
+	return results, nil
+}
+
+// errorClass returns the class of the failure of an image, for
+// visionapi_errors_total.
+func errorClass(err error) string {
+	switch {
+	case vision.IsServiceError(err):
+		return "service"
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	}
+	return "image"
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// modelMain implements the model command, which starts ("start") or stops
+// ("stop") the Rekognition Custom Labels model of --aws-model-arn, waiting
+// until it is running or stopped, or prints its status ("status"). Models are
+// billed for as long as they run, so are best stopped once images have been
+// annotated.
+func modelMain(args []string) {
+	fs := newFlagSet("model", "start|stop|status")
+	arn := fs.String("aws-model-arn", "", "ARN of the Rekognition Custom Labels model")
+	inferenceUnits := fs.Int("inference-units", 1, "Number of inference units to start the model with, each billed by the hour")
+	parseFlags(fs, args)
+	if fs.NArg() != 1 || len(*arn) == 0 {
+		fs.Usage()
+		return
+	}
+	model, err := vision.NewAWSModel(*arn)
+	if err != nil {
+		fatal(err)
+	}
+	ctx := context.Background()
+	switch fs.Arg(0) {
+	case "start":
+		slog.Info("Starting model, which takes a few minutes", "arn", *arn, "inference_units", *inferenceUnits)
+		if err := model.Start(ctx, *inferenceUnits); err != nil {
+			fatal(err)
+		}
+		fmt.Println("RUNNING")
+	case "stop":
+		slog.Info("Stopping model", "arn", *arn)
+		if err := model.Stop(ctx); err != nil {
+			fatal(err)
+		}
+		fmt.Println("STOPPED")
+	case "status":
+		status, message, err := model.Status(ctx)
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Printf("%s: %s\n", status, message)
+	default:
+		fatal(fmt.Errorf("invalid model command %q, must be 'start', 'stop' or 'status'", fs.Arg(0)))
+	}
+}
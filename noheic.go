@@ -0,0 +1,7 @@
+//go:build !heic
+
+package main
+
+// heicSupported is whether this binary was built (with -tags heic) to decode
+// HEIC images.
+const heicSupported = false
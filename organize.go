@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// organizeKeys are the values of --by of the organize command, and the
+// feature whose most confident annotation names the directory of each image.
+var organizeKeys = map[string]vision.Feature{
+	"top-label":    vision.FeatureLabels,
+	"top-object":   vision.FeatureObjects,
+	"top-landmark": vision.FeatureLandmarks,
+}
+
+// organizeMain implements the organize command, which moves (or copies, or
+// links) local images into a directory per label, e.g. dog/ and beach/.
+func organizeMain(args []string) {
+	fs := newFlagSet("organize", "<filename or directory>...")
+	verbose := fs.Bool("v", false, "Verbose output")
+	var (
+		pf providerFlags
+		cf cacheFlags
+		rf rateFlags
+		lf loadFlags
+	)
+	pf.register(fs, "auto")
+	cf.register(fs)
+	rf.register(fs)
+	lf.register(fs)
+	by := fs.String("by", "top-label", "Which annotation names the directory of each image: top-label, top-object or top-landmark (the most confident of them)")
+	dest := fs.String("dest", "", "Directory to organize images into, with a directory per label")
+	mode := fs.String("mode", "move", "How to organize images: move, copy or symlink (leaving the images in place)")
+	unlabeled := fs.String("unlabeled", "unlabeled", "Directory (in --dest) for the images without any label, or empty to leave them in place")
+	minConfidence := fs.Float64("min-confidence", 0.5, "Ignore labels with a confidence (in [0, 1]) below this")
+	dryRun := fs.Bool("dry-run", false, "Print where each image would be organized into, without changing any file")
+	recursive := fs.Bool("recursive", false, "Recursively walk directories for image files")
+	retries := fs.Int("retries", 3, "Number of times to retry requests that fail with transient errors")
+	concurrency := fs.Int("concurrency", 1, "Number of files to load and requests to send in parallel")
+	parseFlags(fs, args)
+	if fs.NArg() < 1 || len(*dest) == 0 {
+		fs.Usage()
+		return
+	}
+	feature, ok := organizeKeys[*by]
+	if !ok {
+		fatal(fmt.Errorf("invalid --by(%s), must be 'top-label', 'top-object' or 'top-landmark'", *by))
+	}
+	if *mode != "move" && *mode != "copy" && *mode != "symlink" {
+		fatal(fmt.Errorf("invalid --mode(%s), must be 'move', 'copy' or 'symlink'", *mode))
+	}
+	limiter, err := rf.limiter()
+	if err != nil {
+		fatal(err)
+	}
+	opts := vision.Options{
+		Features:      []vision.Feature{feature},
+		MinConfidence: *minConfidence,
+		Concurrency:   *concurrency,
+		Retries:       *retries,
+		RetryDelay:    time.Second,
+		Verbose:       *verbose,
+		RateLimit:     limiter,
+	}
+	ctx := context.Background()
+	p, err := pf.newProvider(ctx)
+	if err != nil {
+		fatal(err)
+	}
+	if p, err = cf.wrap(p); err != nil {
+		fatal(err)
+	}
+	var (
+		filenames []string
+		summary   runSummary
+	)
+	for _, f := range expandPatterns(fs.Args(), *recursive, nil, false) {
+		if !isLocalFile(f) {
+			slog.Error("Unable to organize", "file", f, "err", "not a local file")
+			summary.skipped++
+			continue
+		}
+		filenames = append(filenames, f)
+	}
+	lo := lf.options()
+	lo.applyDefaults(p.Name())
+	planned := make(map[string]bool)
+	annotateChunks(ctx, p, filenames, lo, opts, &summary, func(_ []vision.Image, results []vision.Result) {
+		for _, r := range results {
+			if r.Err != nil {
+				slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+				summary.failed++
+				continue
+			}
+			dir := *unlabeled
+			if l, ok := topAnnotation(r, feature); ok {
+				dir = labelDirectory(l.Description)
+			}
+			// Images left in place as intended (those without labels, with an
+			// empty --unlabeled, and those already in their directory, e.g.
+			// when organizing --dest again) are organized rather than skipped.
+			if len(dir) == 0 {
+				slog.Info("Not organizing an image without labels", "file", r.Name)
+				summary.succeeded++
+				continue
+			}
+			if filepath.Join(*dest, dir, filepath.Base(r.Name)) == filepath.Clean(r.Name) {
+				summary.succeeded++
+				continue
+			}
+			to := plannedPath(filepath.Join(*dest, dir), filepath.Base(r.Name), planned)
+			if *dryRun {
+				fmt.Printf("%s: %s\n", r.Name, to)
+				summary.succeeded++
+				continue
+			}
+			if err := organizeFile(r.Name, to, *mode); err != nil {
+				slog.Error("Unable to organize", "file", r.Name, "err", err)
+				summary.failed++
+				continue
+			}
+			summary.succeeded++
+			fmt.Printf("%s: %s\n", r.Name, to)
+		}
+	})
+	if *dryRun {
+		slog.Info("Dry run, no files were changed", "mode", *mode)
+	}
+	if code := summary.exitCode(); code != 0 {
+		exitWith(code)
+	}
+}
+
+// topAnnotation returns the most confident annotation of r of the feature.
+func topAnnotation(r vision.Result, feature vision.Feature) (vision.Label, bool) {
+	var labels []vision.Label
+	switch feature {
+	case vision.FeatureLabels:
+		labels = r.Labels
+	case vision.FeatureObjects:
+		labels = r.Objects
+	case vision.FeatureLandmarks:
+		labels = r.Landmarks
+	}
+	var (
+		top   vision.Label
+		found bool
+	)
+	for _, l := range labels {
+		if !found || l.Confidence > top.Confidence {
+			top, found = l, true
+		}
+	}
+	return top, found
+}
+
+// labelDirectory returns the name of the directory of the images labeled
+// description: its vision.NormalizeLabel key (so that synonyms share one),
+// without path separators.
+func labelDirectory(description string) string {
+	name := strings.NewReplacer("/", "-", `\`, "-").Replace(vision.NormalizeLabel(description))
+	if name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// plannedPath returns the path of a file named base in dir, as uniquePath,
+// that is not already among planned (as files are not organized in a dry
+// run), and adds it to them.
+func plannedPath(dir, base string, planned map[string]bool) string {
+	var (
+		ext  = filepath.Ext(base)
+		dest = filepath.Join(dir, base)
+	)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(dest); os.IsNotExist(err) && !planned[dest] {
+			planned[dest] = true
+			return dest
+		}
+		dest = filepath.Join(dir, fmt.Sprintf("%s.%d%s", strings.TrimSuffix(base, ext), i, ext))
+	}
+}
+
+// organizeFile moves, copies or links (as per mode) the file filename to
+// dest, creating its directory.
+func organizeFile(filename, dest, mode string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	switch mode {
+	case "copy":
+		return copyFile(filename, dest)
+	case "symlink":
+		src, err := filepath.Abs(filename)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(src, dest)
+	}
+	return moveFile(filename, dest)
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// resultWriter writes annotation results in some output format.
+type resultWriter interface {
+	Write(r vision.Result) error
+	Close() error
+}
+
+// imageResultWriter is implemented by resultWriters that also use the image
+// sent for each result (e.g. to embed thumbnails), which is nil if unknown.
+type imageResultWriter interface {
+	WriteImage(r vision.Result, img *vision.Image) error
+}
+
+// outputOptions configure the formats of newResultWriter.
+type outputOptions struct {
+	// csvLabels is the number of labels per row of the "csv-wide" format.
+	csvLabels int
+	// pdfPerDir combines the images of each directory into a single PDF.
+	pdfPerDir bool
+	// jpegQuality of images embedded in PDFs that are not JPEGs.
+	jpegQuality int
+	// compare, if set, is the provider of --api=all, whose results per
+	// provider are included in the "text" and "json" formats.
+	compare *multiProvider
+	// template, if set, is used instead of format (see templateWriter).
+	template string
+}
+
+func newResultWriter(format string, w io.Writer, provider string, opts vision.Options, oo outputOptions) (resultWriter, error) {
+	if len(oo.template) > 0 {
+		return newTemplateWriter(w, oo.template, provider)
+	}
+	if path := strings.TrimPrefix(format, "sqlite:"); path != format && len(path) > 0 {
+		return newSQLiteWriter(path, provider)
+	}
+	if path := strings.TrimPrefix(format, "html:"); path != format && len(path) > 0 {
+		return newHTMLWriter(path)
+	}
+	if path := strings.TrimPrefix(format, "coco:"); path != format && len(path) > 0 {
+		return newCOCOWriter(path), nil
+	}
+	for _, kind := range []string{"lightroom", "digikam"} {
+		if path := strings.TrimPrefix(format, kind+":"); path != format && len(path) > 0 {
+			return newKeywordWriter(path, kind), nil
+		}
+	}
+	switch format {
+	case "text":
+		return &textWriter{w, opts, oo.compare}, nil
+	case "json":
+		return &jsonWriter{json.NewEncoder(w), provider, oo.compare}, nil
+	case "csv":
+		return newCSVWriter(w, opts, 0)
+	case "hocr":
+		return &hocrWriter{w: w}, nil
+	case "alto":
+		return &altoWriter{w: w}, nil
+	case "pdf":
+		return &pdfWriter{perDir: oo.pdfPerDir, jpegQuality: oo.jpegQuality}, nil
+	case "voc":
+		return vocWriter{}, nil
+	case "yolo":
+		return newYOLOWriter(), nil
+	case "csv-wide":
+		if oo.csvLabels <= 0 {
+			return nil, fmt.Errorf("invalid --csv-labels(%d), must be positive", oo.csvLabels)
+		}
+		return newCSVWriter(w, opts, oo.csvLabels)
+	default:
+		return nil, fmt.Errorf("invalid --output(%s), must be 'text', 'json', 'csv', 'csv-wide', 'hocr', 'alto', 'pdf', 'voc', 'yolo', 'sqlite:FILE', 'html:FILE', 'coco:FILE', 'lightroom:FILE' or 'digikam:FILE'", format)
+	}
+}
+
+// textWriter prints one line per requested feature, each prefixed by the
+// image name and the feature, except for labels compared across providers,
+// which are printed as a table. Errors are logged.
+type textWriter struct {
+	w       io.Writer
+	opts    vision.Options
+	compare *multiProvider
+}
+
+func (t *textWriter) Write(r vision.Result) error {
+	if r.Err != nil {
+		slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+		return nil
+	}
+	w := t.w
+	for _, f := range t.opts.RequestedFeatures() {
+		prefix := fmt.Sprintf("%s: %s:", r.Name, f)
+		switch f {
+		case vision.FeatureLabels:
+			if t.compare != nil {
+				if err := t.compare.writeComparison(w, r); err != nil {
+					return err
+				}
+			} else {
+				fmt.Fprintln(w, prefix, labelDescriptions(r.Labels))
+			}
+			for _, frame := range r.Frames {
+				offset := time.Duration(frame.Seconds * float64(time.Second))
+				fmt.Fprintf(w, "%s@%v: %s: %v\n", r.Name, offset, f, labelDescriptions(frame.Labels))
+			}
+			if len(r.Description) > 0 {
+				fmt.Fprintf(w, "%s: description: %q\n", r.Name, r.Description)
+			}
+		case vision.FeatureText:
+			fmt.Fprintf(w, "%s\n%s\n", prefix, r.Text)
+		case vision.FeatureFaces:
+			if len(r.Faces) == 0 {
+				fmt.Fprintln(w, prefix, "[]")
+			}
+			for i, face := range r.Faces {
+				fmt.Fprintf(w, "%s #%d %v%s\n", prefix, i, face.Bounds, faceAttributes(face))
+			}
+		case vision.FeatureLandmarks:
+			landmarks := make([]string, len(r.Landmarks))
+			for i, l := range r.Landmarks {
+				landmarks[i] = l.Description
+				if l.Location != nil {
+					landmarks[i] += "@" + l.Location.String()
+				}
+			}
+			fmt.Fprintln(w, prefix, landmarks)
+		case vision.FeatureCelebrities:
+			fmt.Fprintln(w, prefix, boundedDescriptions(r.Celebrities))
+		case vision.FeatureLogos:
+			fmt.Fprintln(w, prefix, boundedDescriptions(r.Logos))
+		case vision.FeatureSafeSearch:
+			if s := r.SafeSearch; s != nil {
+				fmt.Fprintf(w, "%s adult=%.2f racy=%.2f violence=%.2f medical=%.2f spoof=%.2f\n", prefix, s.Adult, s.Racy, s.Violence, s.Medical, s.Spoof)
+			}
+		case vision.FeatureWeb:
+			if web := r.Web; web != nil {
+				fmt.Fprintln(w, prefix, web.BestGuessLabels, labelDescriptions(web.Entities))
+				for _, u := range web.FullMatchingImages {
+					fmt.Fprintln(w, prefix, "full match:", u)
+				}
+				for _, u := range web.PartialMatchingImages {
+					fmt.Fprintln(w, prefix, "partial match:", u)
+				}
+				for _, u := range web.SimilarImages {
+					fmt.Fprintln(w, prefix, "similar:", u)
+				}
+				for _, p := range web.Pages {
+					fmt.Fprintf(w, "%s page: %s %q\n", prefix, p.URL, p.Title)
+				}
+			}
+		case vision.FeatureObjects:
+			fmt.Fprintln(w, prefix, boundedDescriptions(r.Objects))
+		case vision.FeatureDocument:
+			if d := r.Document; d != nil {
+				var blocks, words int
+				for _, p := range d.Pages {
+					blocks += len(p.Blocks)
+					for _, b := range p.Blocks {
+						for _, p := range b.Paragraphs {
+							words += len(p.Words)
+						}
+					}
+				}
+				fmt.Fprintf(w, "%s %d pages, %d blocks, %d words\n", prefix, len(d.Pages), blocks, words)
+			}
+			if !t.opts.Has(vision.FeatureText) {
+				fmt.Fprintln(w, r.Text)
+			}
+		case vision.FeatureCropHints:
+			hints := make([]string, len(r.CropHints))
+			for i, h := range r.CropHints {
+				hints[i] = h.Bounds.String()
+			}
+			fmt.Fprintln(w, prefix, hints)
+		case vision.FeatureColors:
+			fmt.Fprintln(w, prefix, r.Colors)
+		}
+	}
+	return nil
+}
+
+func (t *textWriter) Close() error { return nil }
+
+// jsonResult is the document written per image by jsonWriter.
+type jsonResult struct {
+	vision.Result
+	Provider string `json:"provider"`
+	Error    string `json:"error,omitempty"`
+	// Providers are the results of each provider, of which Result is the
+	// consensus, with --api=all.
+	Providers []jsonResult `json:"providers,omitempty"`
+}
+
+func newJSONResult(r vision.Result, provider string) jsonResult {
+	doc := jsonResult{Result: r, Provider: provider}
+	if r.Err != nil {
+		doc.Error = r.Err.Error()
+	}
+	return doc
+}
+
+// jsonWriter writes one JSON document per line for each image.
+type jsonWriter struct {
+	enc      *json.Encoder
+	provider string
+	compare  *multiProvider
+}
+
+func (j *jsonWriter) Write(r vision.Result) error {
+	doc := newJSONResult(r, j.provider)
+	if j.compare != nil {
+		names, per := j.compare.compared(r.Name)
+		for i, pr := range per {
+			doc.Providers = append(doc.Providers, newJSONResult(pr, names[i]))
+		}
+	}
+	return j.enc.Encode(doc)
+}
+
+func (j *jsonWriter) Close() error { return nil }
+
+// templateResult is the data of the template of templateWriter, which has the
+// fields of vision.Result.
+type templateResult struct {
+	vision.Result
+	// File is the name of the image, as Name.
+	File     string
+	Provider string
+	// Error is the message of Err, if the image could not be annotated.
+	Error string
+}
+
+// templateFuncs are the functions available to templates, in addition to
+// those of text/template.
+var templateFuncs = template.FuncMap{
+	// descriptions of labels, e.g. {{join (descriptions .Labels) ","}}.
+	"descriptions": labelDescriptions,
+	"join":         strings.Join,
+	// slug of a description for names of files, e.g. {{slug .Description}}.
+	"slug": slug,
+}
+
+// templateWriter executes a text/template for each result, e.g.
+// '{{.File}}: {{range .Labels}}{{.Description}} {{end}}', followed by a
+// newline unless the template ends with one.
+type templateWriter struct {
+	w        io.Writer
+	tmpl     *template.Template
+	provider string
+	newline  bool
+}
+
+func newTemplateWriter(w io.Writer, text, provider string) (*templateWriter, error) {
+	tmpl, err := template.New("output").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --template: %v", err)
+	}
+	return &templateWriter{w, tmpl, provider, !strings.HasSuffix(text, "\n")}, nil
+}
+
+func (t *templateWriter) Write(r vision.Result) error {
+	data := templateResult{Result: r, File: r.Name, Provider: t.provider}
+	if r.Err != nil {
+		data.Error = r.Err.Error()
+	}
+	if err := t.tmpl.Execute(t.w, data); err != nil {
+		return err
+	}
+	if t.newline {
+		_, err := fmt.Fprintln(t.w)
+		return err
+	}
+	return nil
+}
+
+func (t *templateWriter) Close() error { return nil }
+
+// csvWriter writes either one row per annotation (file, feature, description,
+// confidence, bounds), or, if wide is positive, one row per file with the top
+// wide labels and their confidences. Errors are logged.
+type csvWriter struct {
+	w    *csv.Writer
+	opts vision.Options
+	wide int
+}
+
+func newCSVWriter(w io.Writer, opts vision.Options, wide int) (*csvWriter, error) {
+	c := &csvWriter{csv.NewWriter(w), opts, wide}
+	header := []string{"file", "feature", "description", "confidence", "bounds"}
+	if wide > 0 {
+		header = []string{"file"}
+		for i := 1; i <= wide; i++ {
+			header = append(header, fmt.Sprintf("label%d", i), fmt.Sprintf("confidence%d", i))
+		}
+	}
+	if err := c.w.Write(header); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *csvWriter) Write(r vision.Result) error {
+	if r.Err != nil {
+		slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+		return nil
+	}
+	if c.wide > 0 {
+		row := []string{r.Name}
+		for i := 0; i < c.wide; i++ {
+			if i < len(r.Labels) {
+				row = append(row, r.Labels[i].Description, csvConfidence(r.Labels[i].Confidence))
+			} else {
+				row = append(row, "", "")
+			}
+		}
+		return c.w.Write(row)
+	}
+	var rows [][]string
+	labels := func(f vision.Feature, labels []vision.Label) {
+		for _, l := range labels {
+			var bounds string
+			if l.Bounds != nil {
+				bounds = l.Bounds.String()
+			}
+			rows = append(rows, []string{r.Name, string(f), l.Description, csvConfidence(l.Confidence), bounds})
+		}
+	}
+	for _, f := range c.opts.RequestedFeatures() {
+		switch f {
+		case vision.FeatureLabels:
+			labels(f, r.Labels)
+			if len(r.Description) > 0 {
+				rows = append(rows, []string{r.Name, "description", r.Description, "", ""})
+			}
+		case vision.FeatureText:
+			if len(r.Text) > 0 {
+				rows = append(rows, []string{r.Name, string(f), r.Text, "", ""})
+			}
+		case vision.FeatureFaces:
+			for _, face := range r.Faces {
+				rows = append(rows, []string{r.Name, string(f), "face", csvConfidence(face.Confidence), face.Bounds.String()})
+			}
+		case vision.FeatureLandmarks:
+			labels(f, r.Landmarks)
+		case vision.FeatureCelebrities:
+			labels(f, r.Celebrities)
+		case vision.FeatureLogos:
+			labels(f, r.Logos)
+		case vision.FeatureSafeSearch:
+			if s := r.SafeSearch; s != nil {
+				for _, v := range []struct {
+					name  string
+					score float64
+				}{{"adult", s.Adult}, {"racy", s.Racy}, {"violence", s.Violence}, {"medical", s.Medical}, {"spoof", s.Spoof}} {
+					rows = append(rows, []string{r.Name, string(f), v.name, csvConfidence(v.score), ""})
+				}
+			}
+		case vision.FeatureWeb:
+			if web := r.Web; web != nil {
+				for _, l := range web.BestGuessLabels {
+					rows = append(rows, []string{r.Name, string(f), l, "", ""})
+				}
+				labels(f, web.Entities)
+				for _, match := range []struct {
+					kind string
+					urls []string
+				}{{"full match", web.FullMatchingImages}, {"partial match", web.PartialMatchingImages}, {"similar", web.SimilarImages}} {
+					for _, u := range match.urls {
+						rows = append(rows, []string{r.Name, string(f), match.kind + ": " + u, "", ""})
+					}
+				}
+				for _, p := range web.Pages {
+					rows = append(rows, []string{r.Name, string(f), "page: " + p.URL, "", ""})
+				}
+			}
+		case vision.FeatureObjects:
+			labels(f, r.Objects)
+		case vision.FeatureDocument:
+			if d := r.Document; d != nil {
+				for _, p := range d.Pages {
+					for _, b := range p.Blocks {
+						for _, p := range b.Paragraphs {
+							for _, word := range p.Words {
+								rows = append(rows, []string{r.Name, string(f), word.Text, csvConfidence(word.Confidence), word.Bounds.String()})
+							}
+						}
+					}
+				}
+			}
+		case vision.FeatureCropHints:
+			for _, h := range r.CropHints {
+				rows = append(rows, []string{r.Name, string(f), "crop", csvConfidence(h.Confidence), h.Bounds.String()})
+			}
+		case vision.FeatureColors:
+			for _, c := range r.Colors {
+				rows = append(rows, []string{r.Name, string(f), c.String(), csvConfidence(c.Score), ""})
+			}
+		}
+	}
+	return c.w.WriteAll(rows)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func csvConfidence(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
+
+func labelDescriptions(labels []vision.Label) []string {
+	strs := make([]string, len(labels))
+	for i, l := range labels {
+		strs[i] = l.Description
+	}
+	return strs
+}
+
+// boundedDescriptions returns the descriptions of labels, followed by their
+// bounding boxes if known, e.g. "dog@120x80+10+20".
+func boundedDescriptions(labels []vision.Label) []string {
+	strs := make([]string, len(labels))
+	for i, l := range labels {
+		strs[i] = l.Description
+		if l.Bounds != nil {
+			strs[i] += "@" + l.Bounds.String()
+		}
+	}
+	return strs
+}
+
+// faceAttributes formats the attributes of face, e.g. " age=31 joy=0.75".
+func faceAttributes(face vision.Face) string {
+	var buf bytes.Buffer
+	if face.Confidence > 0 {
+		fmt.Fprintf(&buf, " confidence=%.2f", face.Confidence)
+	}
+	if face.Age > 0 {
+		fmt.Fprintf(&buf, " age=%.0f", face.Age)
+	}
+	if len(face.Gender) > 0 {
+		fmt.Fprintf(&buf, " gender=%s", face.Gender)
+	}
+	emotions := make([]string, 0, len(face.Emotions))
+	for e := range face.Emotions {
+		emotions = append(emotions, e)
+	}
+	sort.Strings(emotions)
+	for _, e := range emotions {
+		fmt.Fprintf(&buf, " %s=%.2f", e, face.Emotions[e])
+	}
+	if len(face.Landmarks) > 0 {
+		fmt.Fprintf(&buf, " landmarks=%d", len(face.Landmarks))
+	}
+	return buf.String()
+}
@@ -0,0 +1,226 @@
+// Package cache stores annotation results on local disk, keyed by the content
+// of the image, so that unchanged images are not annotated (and billed) again.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// Cache is a directory of cached results, one JSON file per entry.
+type Cache struct {
+	dir string
+}
+
+// entry is the format of each file in the cache.
+type entry struct {
+	Provider string           `json:"provider"`
+	Features []vision.Feature `json:"features"`
+	Result   vision.Result    `json:"result"`
+}
+
+// DefaultDir returns the default location of the cache, e.g.
+// ~/.cache/visionapi on Linux.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "visionapi"), nil
+}
+
+// Open returns the Cache in dir, creating the directory if necessary.
+func Open(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create cache directory: %v", err)
+	}
+	return &Cache{dir}, nil
+}
+
+// Dir returns the directory of c.
+func (c *Cache) Dir() string { return c.dir }
+
+// Size returns the number of entries in c and their total size in bytes.
+func (c *Cache) Size() (entries int, bytes int64, err error) {
+	err = c.walk(func(path string, info os.FileInfo) error {
+		entries++
+		bytes += info.Size()
+		return nil
+	})
+	return entries, bytes, err
+}
+
+// Clear removes all entries from c.
+func (c *Cache) Clear() error {
+	return c.walk(func(path string, info os.FileInfo) error {
+		return os.Remove(path)
+	})
+}
+
+// Each calls fn with each result in c and the provider that annotated it.
+// Entries that cannot be decoded are skipped, as by Get.
+func (c *Cache) Each(fn func(provider string, r vision.Result)) error {
+	return c.walk(func(path string, info os.FileInfo) error {
+		byts, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var e entry
+		if err := json.Unmarshal(byts, &e); err == nil {
+			fn(e.Provider, e.Result)
+		}
+		return nil
+	})
+}
+
+// walk calls fn for each entry of c.
+func (c *Cache) walk(fn func(path string, info os.FileInfo) error) error {
+	return filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		return fn(path, info)
+	})
+}
+
+// Key returns the key for the result of annotating content with the provider
+// of the identity (see vision.Identity) and the options that affect results.
+func Key(identity string, content []byte, opts vision.Options) string {
+	features := opts.RequestedFeatures()
+	sorted := make([]string, len(features))
+	for i, f := range features {
+		sorted[i] = string(f)
+	}
+	sort.Strings(sorted)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%q\x00%v\x00%d\x00", identity, sorted, opts.MinConfidence, opts.MaxResults)
+	if opts.Has(vision.FeatureCropHints) && len(opts.CropAspectRatios) > 0 {
+		fmt.Fprintf(h, "%v\x00", opts.CropAspectRatios)
+	}
+	if (opts.Has(vision.FeatureText) || opts.Has(vision.FeatureDocument)) && len(opts.LanguageHints) > 0 {
+		fmt.Fprintf(h, "%q\x00", opts.LanguageHints)
+	}
+	if len(opts.Classes) > 0 {
+		fmt.Fprintf(h, "%q\x00", opts.Classes)
+	}
+	if len(opts.Question) > 0 || len(opts.Prompt) > 0 {
+		fmt.Fprintf(h, "%q\x00%q\x00", opts.Question, opts.Prompt)
+	}
+	sum := sha256.Sum256(content)
+	h.Write(sum[:])
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// Get returns the result stored under key, if any.
+func (c *Cache) Get(key string) (vision.Result, bool) {
+	byts, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return vision.Result{}, false
+	}
+	var e entry
+	if err := json.Unmarshal(byts, &e); err != nil {
+		return vision.Result{}, false
+	}
+	return e.Result, true
+}
+
+// Put stores r under key. Failed results are not stored.
+func (c *Cache) Put(key, provider string, features []vision.Feature, r vision.Result) error {
+	if r.Err != nil {
+		return nil
+	}
+	byts, err := json.Marshal(entry{provider, features, r})
+	if err != nil {
+		return err
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so that concurrent readers never see a
+	// partially written entry.
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := ioutil.WriteFile(tmp, byts, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Wrap returns a Provider that returns results from c where available, and
+// otherwise annotates images with p and stores the results in c.
+func (c *Cache) Wrap(p vision.Provider) vision.Provider {
+	return &provider{c, p}
+}
+
+type provider struct {
+	cache *Cache
+	p     vision.Provider
+}
+
+func (p *provider) Name() string { return p.p.Name() }
+
+func (p *provider) Identity() string { return vision.Identity(p.p) }
+
+func (p *provider) Capabilities() []vision.Feature { return p.p.Capabilities() }
+
+func (p *provider) Annotate(ctx context.Context, images []vision.Image, opts vision.Options) ([]vision.Result, error) {
+	var (
+		features = opts.RequestedFeatures()
+		results  = make([]vision.Result, len(images))
+		keys     = make([]string, len(images))
+		missing  []vision.Image
+		indices  []int // into images, of each of missing
+	)
+	for i, img := range images {
+		// Images that are fetched by the provider itself cannot be hashed.
+		if len(img.Content) > 0 {
+			keys[i] = Key(vision.Identity(p.p), img.Content, opts)
+			if r, ok := p.cache.Get(keys[i]); ok {
+				r.Name = img.Name
+				results[i] = r
+				if s := opts.Stats; s != nil {
+					atomic.AddInt64(&s.Images, 1)
+					atomic.AddInt64(&s.Cached, 1)
+				}
+				continue
+			}
+		}
+		missing = append(missing, img)
+		indices = append(indices, i)
+	}
+	if len(missing) == 0 {
+		return results, nil
+	}
+	annotated, err := p.p.Annotate(ctx, missing, opts)
+	if err != nil {
+		return nil, err
+	}
+	for j, r := range annotated {
+		i := indices[j]
+		results[i] = r
+		if len(keys[i]) > 0 {
+			if err := p.cache.Put(keys[i], p.Name(), features, r); err != nil {
+				slog.Warn("Unable to cache result", "file", r.Name, "err", err)
+			}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,127 @@
+// Package clip tokenizes text for the text encoder of CLIP (Radford et al.,
+// "Learning Transferable Visual Models From Natural Language Supervision",
+// 2021), by the byte-level byte pair encoding of its vocabulary.
+package clip
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ContextLength is the number of tokens of each text, which are padded (or
+// truncated) to it.
+const ContextLength = 77
+
+// pattern splits text into words, as per the reference implementation.
+var pattern = regexp.MustCompile(`(?i)<\|startoftext\|>|<\|endoftext\|>|'s|'t|'re|'ve|'m|'ll|'d|\p{L}+|\p{N}|[^\s\p{L}\p{N}]+`)
+
+// Tokenizer encodes text into the token IDs of a vocabulary.
+type Tokenizer struct {
+	vocab map[string]int64
+	// ranks of the merges of pairs of symbols, lowest first.
+	ranks      map[[2]string]int
+	start, end int64
+}
+
+// NewTokenizer returns a Tokenizer of the vocabulary (a JSON object of the ID
+// of each token, e.g. vocab.json) and merges (a pair of symbols per line, in
+// order, e.g. merges.txt) of a CLIP model.
+func NewTokenizer(vocab, merges []byte) (*Tokenizer, error) {
+	t := &Tokenizer{ranks: make(map[[2]string]int)}
+	if err := json.Unmarshal(vocab, &t.vocab); err != nil {
+		return nil, fmt.Errorf("invalid vocabulary: %v", err)
+	}
+	var ok1, ok2 bool
+	t.start, ok1 = t.vocab["<|startoftext|>"]
+	t.end, ok2 = t.vocab["<|endoftext|>"]
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("invalid vocabulary: no start or end token")
+	}
+	s := bufio.NewScanner(bytes.NewReader(merges))
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "#") {
+			// The version.
+			continue
+		}
+		if pair := strings.Fields(line); len(pair) == 2 {
+			t.ranks[[2]string{pair[0], pair[1]}] = len(t.ranks)
+		}
+	}
+	return t, s.Err()
+}
+
+// Encode returns the token IDs of text, between the start and end tokens and
+// padded with end tokens to ContextLength.
+func (t *Tokenizer) Encode(text string) []int64 {
+	ids := []int64{t.start}
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	for _, word := range pattern.FindAllString(text, -1) {
+		for _, symbol := range t.bpe(word) {
+			if id, ok := t.vocab[symbol]; ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) > ContextLength-1 {
+		ids = ids[:ContextLength-1]
+	}
+	for len(ids) < ContextLength {
+		ids = append(ids, t.end)
+	}
+	return ids
+}
+
+// bpe returns the symbols of word, merged by rank.
+func (t *Tokenizer) bpe(word string) []string {
+	var symbols []string
+	for _, b := range []byte(word) {
+		symbols = append(symbols, string(byteRunes[b]))
+	}
+	// The end of each word is marked, to tell suffixes from prefixes.
+	symbols[len(symbols)-1] += "</w>"
+	for len(symbols) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i+1 < len(symbols); i++ {
+			if rank, ok := t.ranks[[2]string{symbols[i], symbols[i+1]}]; ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		first, second := symbols[best], symbols[best+1]
+		merged := symbols[:0:0]
+		for i := 0; i < len(symbols); i++ {
+			if i+1 < len(symbols) && symbols[i] == first && symbols[i+1] == second {
+				merged = append(merged, first+second)
+				i++
+			} else {
+				merged = append(merged, symbols[i])
+			}
+		}
+		symbols = merged
+	}
+	return symbols
+}
+
+// byteRunes maps each byte to a printable rune, as the vocabulary is of
+// symbols of those rather than of bytes: printable ASCII and Latin-1 bytes map
+// to themselves, and others to runes from 256.
+var byteRunes [256]rune
+
+func init() {
+	next := rune(256)
+	for b := 0; b < 256; b++ {
+		if ('!' <= b && b <= '~') || ('¡' <= b && b <= '¬') || ('®' <= b && b <= 'ÿ') {
+			byteRunes[b] = rune(b)
+		} else {
+			byteRunes[b] = next
+			next++
+		}
+	}
+}
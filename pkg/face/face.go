@@ -0,0 +1,126 @@
+// Package face describes faces locally, for grouping the faces of the same
+// people across photos without a cloud identity service.
+//
+// Faces are described by the histograms of the local binary patterns (LBP) of
+// a grid of cells of each face, as per Ahonen et al., "Face Description with
+// Local Binary Patterns" (2006), which are robust to lighting but not to large
+// changes of pose or expression, so clusters of the same person may be split.
+package face
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"math/bits"
+
+	"github.com/asimshankar/visionapi/pkg/preprocess"
+)
+
+const (
+	// size is the width and height, in pixels, faces are scaled to.
+	size = 64
+	// grid is the number of cells of each row and column of a face.
+	grid = 4
+	// bins is the number of uniform patterns (with at most 2 transitions
+	// between 0 and 1 bits), and a bin for all the others.
+	bins = 59
+)
+
+// uniform maps each pattern to its bin.
+var uniform [256]int
+
+func init() {
+	next := 0
+	for p := 0; p < 256; p++ {
+		// Transitions between neighbors, circularly.
+		if bits.OnesCount8(uint8(p)^bits.RotateLeft8(uint8(p), 1)) <= 2 {
+			uniform[p] = next
+			next++
+		} else {
+			uniform[p] = bins - 1
+		}
+	}
+}
+
+// Describe returns the descriptor of the face within bounds of img, of
+// grid*grid*59 values, normalized such that Similarity is the cosine of the
+// angle between descriptors.
+func Describe(img image.Image, bounds image.Rectangle) []float64 {
+	bounds = bounds.Intersect(img.Bounds())
+	gray := image.NewGray(image.Rect(0, 0, size, size))
+	if !bounds.Empty() {
+		crop := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(crop, crop.Bounds(), img, bounds.Min, draw.Src)
+		draw.Draw(gray, gray.Bounds(), preprocess.Resize(crop, size, size), image.Point{}, draw.Src)
+	}
+	descriptor := make([]float64, grid*grid*bins)
+	// Patterns of the 8 neighbors of each pixel, but those on the edges.
+	neighbors := []image.Point{{-1, -1}, {0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}}
+	for y := 1; y < size-1; y++ {
+		for x := 1; x < size-1; x++ {
+			center := gray.GrayAt(x, y).Y
+			var pattern int
+			for i, n := range neighbors {
+				if gray.GrayAt(x+n.X, y+n.Y).Y >= center {
+					pattern |= 1 << i
+				}
+			}
+			cell := (y*grid/size)*grid + x*grid/size
+			descriptor[cell*bins+uniform[pattern]]++
+		}
+	}
+	// The square roots of the histograms (as per the Hellinger distance),
+	// normalized to a unit vector.
+	var sum float64
+	for i, v := range descriptor {
+		descriptor[i] = math.Sqrt(v)
+		sum += v
+	}
+	if length := math.Sqrt(sum); length > 0 {
+		for i := range descriptor {
+			descriptor[i] /= length
+		}
+	}
+	return descriptor
+}
+
+// Similarity returns the similarity in [0, 1] of the faces of descriptors a
+// and b.
+func Similarity(a, b []float64) float64 {
+	var dot float64
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot
+}
+
+// Cluster groups descriptors whose similarity to the mean of a group is at
+// least threshold, returning the index of the group of each. Descriptors are
+// grouped in order, each with the most similar group so far, or a new one.
+func Cluster(descriptors [][]float64, threshold float64) []int {
+	var (
+		groups = make([]int, len(descriptors))
+		sums   [][]float64 // Of the descriptors of each group.
+	)
+	for i, d := range descriptors {
+		best, bestSimilarity := -1, threshold
+		for g, sum := range sums {
+			if s := Similarity(d, sum) / norm(sum); s >= bestSimilarity {
+				best, bestSimilarity = g, s
+			}
+		}
+		if best < 0 {
+			best = len(sums)
+			sums = append(sums, make([]float64, len(d)))
+		}
+		for j, v := range d {
+			sums[best][j] += v
+		}
+		groups[i] = best
+	}
+	return groups
+}
+
+func norm(v []float64) float64 {
+	return math.Sqrt(Similarity(v, v))
+}
@@ -0,0 +1,295 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"time"
+)
+
+var jpegExifHeader = []byte("Exif\x00\x00")
+
+// TIFF tags and types used to read dates and the orientation, and to write GPS
+// coordinates, as per the Exif 2.3 specification.
+const (
+	tiffTagOrientation      = 0x0112
+	tiffTagDateTime         = 0x0132
+	exifTagExifIFD          = 0x8769
+	exifTagDateTimeOriginal = 0x9003
+	tiffTagGPSIFD           = 0x8825
+	gpsTagVersionID         = 0x0000
+	gpsTagLatitudeRef       = 0x0001
+	gpsTagLatitude          = 0x0002
+	gpsTagLongitudeRef      = 0x0003
+	gpsTagLongitude         = 0x0004
+	tiffTypeByte            = 1
+	tiffTypeASCII           = 2
+	tiffTypeShort           = 3
+	tiffTypeLong            = 4
+	tiffTypeRational        = 5
+	tiffIFDEntrySize        = 12
+	tiffRationalDivisor     = 10000
+)
+
+// SetGPS sets the Exif GPS latitude and longitude (in degrees) of the JPEG
+// image in filename, replacing any existing GPS information while preserving
+// the rest of the Exif metadata.
+func SetGPS(filename string, latitude, longitude float64) error {
+	byts, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(byts, jpegSOI) {
+		return fmt.Errorf("writing Exif GPS coordinates is only supported in JPEG images")
+	}
+	updated, err := updateJPEGExif(byts, func(tiff []byte) ([]byte, error) { return setTIFFGPS(tiff, latitude, longitude) })
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, updated)
+}
+
+// updateJPEGExif replaces the Exif TIFF structure of a JPEG with update(tiff),
+// where tiff is empty if the image has none. A new Exif segment is inserted
+// after any JFIF segment.
+func updateJPEGExif(byts []byte, update func(tiff []byte) ([]byte, error)) ([]byte, error) {
+	segments, rest, err := splitJPEG(byts)
+	if err != nil {
+		return nil, err
+	}
+	index, insertAt := -1, 0
+	for i, s := range segments {
+		if s.marker == jpegAPP1 && bytes.HasPrefix(s.data, jpegExifHeader) {
+			index = i
+			break
+		}
+		if s.marker == jpegAPP0 {
+			insertAt = i + 1
+		}
+	}
+	var tiff []byte
+	if index >= 0 {
+		tiff = segments[index].data[len(jpegExifHeader):]
+	}
+	if tiff, err = update(tiff); err != nil {
+		return nil, err
+	}
+	data := append(append([]byte{}, jpegExifHeader...), tiff...)
+	if len(data) > jpegMaxSegmentData {
+		return nil, fmt.Errorf("Exif data of %d bytes is too large for a JPEG segment", len(tiff))
+	}
+	if index >= 0 {
+		segments[index].data = data
+	} else {
+		segments = append(segments[:insertAt], append([]jpegSegment{{jpegAPP1, data}}, segments[insertAt:]...)...)
+	}
+	return joinJPEG(segments, rest), nil
+}
+
+// setTIFFGPS returns tiff with a GPS IFD for the given coordinates. The
+// existing data is left in place (so the offsets within it remain valid), and
+// the GPS IFD and a copy of IFD0 pointing to it are appended.
+func setTIFFGPS(tiff []byte, latitude, longitude float64) ([]byte, error) {
+	if len(tiff) == 0 {
+		// An empty big-endian TIFF with no entries in IFD0.
+		tiff = []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 0, 0, 0, 0, 0}
+	}
+	order, err := tiffByteOrder(tiff)
+	if err != nil {
+		return nil, err
+	}
+	ifd0 := int(order.Uint32(tiff[4:]))
+	if ifd0+2 > len(tiff) {
+		return nil, fmt.Errorf("invalid Exif data: IFD0 offset %d out of range", ifd0)
+	}
+	n := int(order.Uint16(tiff[ifd0:]))
+	end := ifd0 + 2 + n*tiffIFDEntrySize
+	if end+4 > len(tiff) {
+		return nil, fmt.Errorf("invalid Exif data: IFD0 with %d entries out of range", n)
+	}
+	var entries [][]byte
+	for i := 0; i < n; i++ {
+		entry := tiff[ifd0+2+i*tiffIFDEntrySize : ifd0+2+(i+1)*tiffIFDEntrySize]
+		if order.Uint16(entry) != tiffTagGPSIFD {
+			entries = append(entries, entry)
+		}
+	}
+	next := order.Uint32(tiff[end:])
+
+	out := append([]byte{}, tiff...)
+	if len(out)%2 != 0 {
+		// IFDs must start on a word boundary.
+		out = append(out, 0)
+	}
+	gpsIFD := len(out)
+	latRef, lngRef := "N", "E"
+	if latitude < 0 {
+		latRef = "S"
+	}
+	if longitude < 0 {
+		lngRef = "W"
+	}
+	const gpsEntries = 5
+	data := uint32(gpsIFD + 2 + gpsEntries*tiffIFDEntrySize + 4)
+	out = appendUint16(out, order, gpsEntries)
+	out = appendIFDEntry(out, order, gpsTagVersionID, tiffTypeByte, 4, []byte{2, 3, 0, 0})
+	out = appendIFDEntry(out, order, gpsTagLatitudeRef, tiffTypeASCII, 2, []byte(latRef+"\x00"))
+	out = appendIFDEntry(out, order, gpsTagLatitude, tiffTypeRational, 3, appendUint32(nil, order, data))
+	out = appendIFDEntry(out, order, gpsTagLongitudeRef, tiffTypeASCII, 2, []byte(lngRef+"\x00"))
+	out = appendIFDEntry(out, order, gpsTagLongitude, tiffTypeRational, 3, appendUint32(nil, order, data+3*8))
+	out = appendUint32(out, order, 0)
+	out = appendDegrees(out, order, latitude)
+	out = appendDegrees(out, order, longitude)
+
+	entries = append(entries, appendIFDEntry(nil, order, tiffTagGPSIFD, tiffTypeLong, 1, appendUint32(nil, order, uint32(gpsIFD))))
+	sort.SliceStable(entries, func(i, j int) bool { return order.Uint16(entries[i]) < order.Uint16(entries[j]) })
+	newIFD0 := len(out)
+	out = appendUint16(out, order, uint16(len(entries)))
+	for _, e := range entries {
+		out = append(out, e...)
+	}
+	out = appendUint32(out, order, next)
+	order.PutUint32(out[4:], uint32(newIFD0))
+	return out, nil
+}
+
+// tiffByteOrder returns the byte order of the TIFF structure tiff, after
+// checking that it has a complete header.
+func tiffByteOrder(tiff []byte) (binary.ByteOrder, error) {
+	if len(tiff) < 8 {
+		return nil, fmt.Errorf("invalid Exif data: truncated TIFF header")
+	}
+	switch string(tiff[:2]) {
+	case "II":
+		return binary.LittleEndian, nil
+	case "MM":
+		return binary.BigEndian, nil
+	}
+	return nil, fmt.Errorf("invalid Exif data: unknown byte order %q", tiff[:2])
+}
+
+// Orientation returns the Exif orientation (1 to 8, see
+// https://www.exif.org/Exif2-2.PDF) of the JPEG or PNG image byts, which is 1
+// (upright) if it has none or it is invalid.
+func Orientation(byts []byte) int {
+	tiff := exifTIFF(byts)
+	order, err := tiffByteOrder(tiff)
+	if err != nil {
+		return 1
+	}
+	entry := ifdEntry(tiff, order, int(order.Uint32(tiff[4:])), tiffTagOrientation)
+	if entry == nil || order.Uint16(entry[2:]) != tiffTypeShort {
+		return 1
+	}
+	if o := int(order.Uint16(entry[8:])); o >= 1 && o <= 8 {
+		return o
+	}
+	return 1
+}
+
+// DateTaken returns the Exif DateTimeOriginal of the JPEG or PNG image byts,
+// or its DateTime if it has none, in the (unknown) time zone of the camera.
+func DateTaken(byts []byte) (time.Time, bool) {
+	tiff := exifTIFF(byts)
+	order, err := tiffByteOrder(tiff)
+	if err != nil {
+		return time.Time{}, false
+	}
+	ifd0 := int(order.Uint32(tiff[4:]))
+	if entry := ifdEntry(tiff, order, ifd0, exifTagExifIFD); entry != nil && order.Uint16(entry[2:]) == tiffTypeLong {
+		if t, ok := ifdTime(tiff, order, ifdEntry(tiff, order, int(order.Uint32(entry[8:])), exifTagDateTimeOriginal)); ok {
+			return t, true
+		}
+	}
+	return ifdTime(tiff, order, ifdEntry(tiff, order, ifd0, tiffTagDateTime))
+}
+
+// exifTIFF returns the Exif TIFF structure of the JPEG or PNG image byts, or
+// nil if it has none.
+func exifTIFF(byts []byte) []byte {
+	if segments, _, err := splitJPEG(byts); err == nil {
+		for _, s := range segments {
+			if s.marker == jpegAPP1 && bytes.HasPrefix(s.data, jpegExifHeader) {
+				return s.data[len(jpegExifHeader):]
+			}
+		}
+	} else if chunks, err := splitPNG(byts); err == nil {
+		for _, c := range chunks {
+			if c.typ == "eXIf" {
+				return c.data
+			}
+		}
+	}
+	return nil
+}
+
+// ifdEntry returns the entry of the IFD at offset ifd of tiff with the tag,
+// or nil if there is none.
+func ifdEntry(tiff []byte, order binary.ByteOrder, ifd int, tag uint16) []byte {
+	if ifd+2 > len(tiff) {
+		return nil
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < n; i++ {
+		pos := ifd + 2 + i*tiffIFDEntrySize
+		if pos+tiffIFDEntrySize > len(tiff) {
+			break
+		}
+		if entry := tiff[pos : pos+tiffIFDEntrySize]; order.Uint16(entry) == tag {
+			return entry
+		}
+	}
+	return nil
+}
+
+// ifdTime returns the time of the IFD entry of an Exif date and time, as
+// "YYYY:MM:DD HH:MM:SS" (whose 20 bytes are stored at an offset of tiff).
+func ifdTime(tiff []byte, order binary.ByteOrder, entry []byte) (time.Time, bool) {
+	if entry == nil || order.Uint16(entry[2:]) != tiffTypeASCII || order.Uint32(entry[4:]) < 19 {
+		return time.Time{}, false
+	}
+	offset := int(order.Uint32(entry[8:]))
+	if offset+19 > len(tiff) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006:01:02 15:04:05", string(tiff[offset:offset+19]))
+	return t, err == nil
+}
+
+// appendIFDEntry appends an IFD entry whose value (or offset to the value) is
+// value, padded to 4 bytes.
+func appendIFDEntry(b []byte, order binary.ByteOrder, tag, typ uint16, count uint32, value []byte) []byte {
+	b = appendUint16(b, order, tag)
+	b = appendUint16(b, order, typ)
+	b = appendUint32(b, order, count)
+	var v [4]byte
+	copy(v[:], value)
+	return append(b, v[:]...)
+}
+
+// appendDegrees appends the absolute value of deg as three rationals of
+// degrees, minutes and seconds.
+func appendDegrees(b []byte, order binary.ByteOrder, deg float64) []byte {
+	deg = math.Abs(deg)
+	d := math.Floor(deg)
+	m := math.Floor((deg - d) * 60)
+	s := ((deg-d)*60 - m) * 60
+	b = appendUint32(appendUint32(b, order, uint32(d)), order, 1)
+	b = appendUint32(appendUint32(b, order, uint32(m)), order, 1)
+	return appendUint32(appendUint32(b, order, uint32(math.Round(s*tiffRationalDivisor))), order, tiffRationalDivisor)
+}
+
+func appendUint16(b []byte, order binary.ByteOrder, v uint16) []byte {
+	var buf [2]byte
+	order.PutUint16(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint32(b []byte, order binary.ByteOrder, v uint32) []byte {
+	var buf [4]byte
+	order.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
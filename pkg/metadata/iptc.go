@@ -0,0 +1,263 @@
+package metadata
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	jpegPhotoshopHeader = []byte("Photoshop 3.0\x00")
+	photoshopSignature  = []byte("8BIM")
+	// iptcUTF8 is the value of the 1:90 coded character set of UTF-8.
+	iptcUTF8 = []byte("\x1b%G")
+)
+
+const (
+	jpegAPP13 = 0xed
+	// Photoshop image resources of the IPTC-IIM data and of its MD5 digest,
+	// by which readers detect whether it was changed without the XMP.
+	photoshopIPTC       = 0x0404
+	photoshopIPTCDigest = 0x0425
+	// The IPTC-IIM datasets (record and number) used, as per
+	// https://www.iptc.org/std/IIM/4.2/specification/IIMV4.2.pdf
+	iptcTag             = 0x1c
+	iptcCharacterSet    = 1<<8 | 90
+	iptcRecordVersion   = 2<<8 | 0
+	iptcKeywords        = 2<<8 | 25
+	iptcMaxKeywordBytes = 64
+)
+
+// photoshopResource is an image resource of a Photoshop APP13 segment.
+type photoshopResource struct {
+	id   uint16
+	name []byte // Pascal string, padded to an even length
+	data []byte
+}
+
+// iptcDataset is a dataset of IPTC-IIM data, of the record (high byte) and
+// number (low byte) of tag.
+type iptcDataset struct {
+	tag  uint16
+	data []byte
+}
+
+// updateJPEGIPTC adds keywords to the IPTC-IIM keywords (2:25) of a JPEG, in
+// the Photoshop APP13 segment, which is created after the existing application
+// segments if the image has none. Keywords longer than IIM allows are
+// shortened.
+func updateJPEGIPTC(byts []byte, keywords []string) ([]byte, error) {
+	segments, rest, err := splitJPEG(byts)
+	if err != nil {
+		return nil, err
+	}
+	index, insertAt := -1, 0
+	for i, s := range segments {
+		if s.marker == jpegAPP13 && bytes.HasPrefix(s.data, jpegPhotoshopHeader) {
+			index = i
+			break
+		}
+		if s.marker >= jpegAPP0 && s.marker <= jpegAPPF {
+			insertAt = i + 1
+		}
+	}
+	var resources []photoshopResource
+	if index >= 0 {
+		if resources, err = splitPhotoshop(segments[index].data[len(jpegPhotoshopHeader):]); err != nil {
+			return nil, err
+		}
+	}
+	iptc := -1
+	for i, r := range resources {
+		if r.id == photoshopIPTC {
+			iptc = i
+			break
+		}
+	}
+	var datasets []iptcDataset
+	if iptc >= 0 {
+		if datasets, err = splitIPTC(resources[iptc].data); err != nil {
+			return nil, err
+		}
+	} else {
+		resources = append(resources, photoshopResource{id: photoshopIPTC, name: []byte{0, 0}})
+		iptc = len(resources) - 1
+	}
+	data := joinIPTC(mergeIPTCKeywords(datasets, keywords))
+	resources[iptc].data = data
+	for i, r := range resources {
+		if r.id == photoshopIPTCDigest {
+			digest := md5.Sum(data)
+			resources[i].data = digest[:]
+		}
+	}
+	segment := append(append([]byte{}, jpegPhotoshopHeader...), joinPhotoshop(resources)...)
+	if len(segment) > jpegMaxSegmentData {
+		return nil, fmt.Errorf("Photoshop data of %d bytes is too large for a JPEG segment", len(segment))
+	}
+	if index >= 0 {
+		segments[index].data = segment
+	} else {
+		segments = append(segments[:insertAt], append([]jpegSegment{{jpegAPP13, segment}}, segments[insertAt:]...)...)
+	}
+	return joinJPEG(segments, rest), nil
+}
+
+// mergeIPTCKeywords returns datasets with the keywords that are not among
+// its keywords already (ignoring case), and with the record version and (if
+// needed for the keywords) UTF-8 character set the IIM requires. Keywords
+// other than ASCII are dropped if the data is in another character set.
+func mergeIPTCKeywords(datasets []iptcDataset, keywords []string) []iptcDataset {
+	var (
+		seen       = make(map[string]bool)
+		hasVersion bool
+		charset    []byte
+	)
+	for _, d := range datasets {
+		switch d.tag {
+		case iptcKeywords:
+			seen[strings.ToLower(string(d.data))] = true
+		case iptcRecordVersion:
+			hasVersion = true
+		case iptcCharacterSet:
+			charset = d.data
+		}
+	}
+	var added []iptcDataset
+	for _, k := range keywords {
+		k = truncateUTF8(k, iptcMaxKeywordBytes)
+		if len(k) == 0 || seen[strings.ToLower(k)] {
+			continue
+		}
+		if !isASCII(k) {
+			if charset == nil {
+				charset = iptcUTF8
+				datasets = insertIPTC(datasets, iptcDataset{iptcCharacterSet, iptcUTF8})
+			} else if !bytes.Equal(charset, iptcUTF8) {
+				continue
+			}
+		}
+		seen[strings.ToLower(k)] = true
+		added = append(added, iptcDataset{iptcKeywords, []byte(k)})
+	}
+	if len(added) == 0 {
+		return datasets
+	}
+	if !hasVersion {
+		datasets = insertIPTC(datasets, iptcDataset{iptcRecordVersion, []byte{0, 4}})
+	}
+	return insertIPTC(datasets, added...)
+}
+
+// insertIPTC returns datasets with added (of one tag) inserted after those
+// with the same or lower tags, as datasets are in order of their records and
+// numbers.
+func insertIPTC(datasets []iptcDataset, added ...iptcDataset) []iptcDataset {
+	at := len(datasets)
+	for i, d := range datasets {
+		if d.tag > added[0].tag {
+			at = i
+			break
+		}
+	}
+	return append(append(append([]iptcDataset{}, datasets[:at]...), added...), datasets[at:]...)
+}
+
+// splitPhotoshop returns the image resources of the Photoshop data byts.
+func splitPhotoshop(byts []byte) ([]photoshopResource, error) {
+	var resources []photoshopResource
+	for pos := 0; pos < len(byts); {
+		if pos+7 > len(byts) || !bytes.Equal(byts[pos:pos+4], photoshopSignature) {
+			return nil, fmt.Errorf("invalid Photoshop image resource at offset %d", pos)
+		}
+		id := binary.BigEndian.Uint16(byts[pos+4:])
+		nameLen := int(byts[pos+6]) + 1
+		nameLen += nameLen % 2
+		start := pos + 6 + nameLen + 4
+		if start > len(byts) {
+			return nil, fmt.Errorf("invalid Photoshop image resource at offset %d", pos)
+		}
+		size := int(binary.BigEndian.Uint32(byts[start-4:]))
+		if start+size > len(byts) {
+			return nil, fmt.Errorf("invalid Photoshop image resource size at offset %d", pos)
+		}
+		resources = append(resources, photoshopResource{id, byts[pos+6 : pos+6+nameLen], byts[start : start+size]})
+		pos = start + size + size%2
+	}
+	return resources, nil
+}
+
+func joinPhotoshop(resources []photoshopResource) []byte {
+	var buf bytes.Buffer
+	for _, r := range resources {
+		buf.Write(photoshopSignature)
+		binary.Write(&buf, binary.BigEndian, r.id)
+		buf.Write(r.name)
+		binary.Write(&buf, binary.BigEndian, uint32(len(r.data)))
+		buf.Write(r.data)
+		if len(r.data)%2 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	return buf.Bytes()
+}
+
+// splitIPTC returns the datasets of the IPTC-IIM data byts, which must not
+// have any of the extended datasets (of more than 32767 bytes).
+func splitIPTC(byts []byte) ([]iptcDataset, error) {
+	var datasets []iptcDataset
+	for pos := 0; pos < len(byts); {
+		if byts[pos] == 0 {
+			// Padding at the end.
+			break
+		}
+		if pos+5 > len(byts) || byts[pos] != iptcTag {
+			return nil, fmt.Errorf("invalid IPTC-IIM dataset at offset %d", pos)
+		}
+		size := int(binary.BigEndian.Uint16(byts[pos+3:]))
+		if size&0x8000 != 0 {
+			return nil, fmt.Errorf("unsupported extended IPTC-IIM dataset at offset %d", pos)
+		}
+		if pos+5+size > len(byts) {
+			return nil, fmt.Errorf("invalid IPTC-IIM dataset size at offset %d", pos)
+		}
+		datasets = append(datasets, iptcDataset{binary.BigEndian.Uint16(byts[pos+1:]), byts[pos+5 : pos+5+size]})
+		pos += 5 + size
+	}
+	return datasets, nil
+}
+
+func joinIPTC(datasets []iptcDataset) []byte {
+	var buf bytes.Buffer
+	for _, d := range datasets {
+		buf.WriteByte(iptcTag)
+		binary.Write(&buf, binary.BigEndian, d.tag)
+		binary.Write(&buf, binary.BigEndian, uint16(len(d.data)))
+		buf.Write(d.data)
+	}
+	return buf.Bytes()
+}
+
+// truncateUTF8 returns s shortened to at most n bytes, at a rune boundary.
+func truncateUTF8(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return strings.TrimSpace(s[:n])
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
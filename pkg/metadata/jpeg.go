@@ -0,0 +1,101 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+var (
+	jpegSOI       = []byte{0xff, 0xd8}
+	jpegXMPHeader = []byte("http://ns.adobe.com/xap/1.0/\x00")
+)
+
+const (
+	jpegAPP0 = 0xe0
+	jpegAPP1 = 0xe1
+	jpegAPPF = 0xef
+	jpegSOS  = 0xda
+	// The length of a segment includes the two length bytes.
+	jpegMaxSegmentData = 0xffff - 2
+)
+
+// jpegSegment is a marker segment preceding the image data.
+type jpegSegment struct {
+	marker byte
+	data   []byte
+}
+
+// splitJPEG returns the marker segments of a JPEG up to (but excluding) the
+// start of scan, and the remainder of the file.
+func splitJPEG(byts []byte) ([]jpegSegment, []byte, error) {
+	if !bytes.HasPrefix(byts, jpegSOI) {
+		return nil, nil, fmt.Errorf("not a JPEG image")
+	}
+	var segments []jpegSegment
+	pos := len(jpegSOI)
+	for {
+		if pos+4 > len(byts) || byts[pos] != 0xff {
+			return nil, nil, fmt.Errorf("invalid JPEG segment at offset %d", pos)
+		}
+		marker := byts[pos+1]
+		if marker == jpegSOS {
+			return segments, byts[pos:], nil
+		}
+		length := int(binary.BigEndian.Uint16(byts[pos+2:]))
+		if length < 2 || pos+2+length > len(byts) {
+			return nil, nil, fmt.Errorf("invalid JPEG segment length at offset %d", pos)
+		}
+		segments = append(segments, jpegSegment{marker, byts[pos+4 : pos+2+length]})
+		pos += 2 + length
+	}
+}
+
+func joinJPEG(segments []jpegSegment, rest []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(jpegSOI)
+	for _, s := range segments {
+		buf.Write([]byte{0xff, s.marker})
+		binary.Write(&buf, binary.BigEndian, uint16(len(s.data)+2))
+		buf.Write(s.data)
+	}
+	buf.Write(rest)
+	return buf.Bytes()
+}
+
+// updateJPEGXMP replaces the XMP packet of a JPEG with update(packet), where
+// packet is empty if the image has none. A new packet is inserted after the
+// existing application segments (e.g., JFIF and Exif).
+func updateJPEGXMP(byts []byte, update func(packet []byte) ([]byte, error)) ([]byte, error) {
+	segments, rest, err := splitJPEG(byts)
+	if err != nil {
+		return nil, err
+	}
+	index, insertAt := -1, 0
+	for i, s := range segments {
+		if s.marker == jpegAPP1 && bytes.HasPrefix(s.data, jpegXMPHeader) {
+			index = i
+			break
+		}
+		if s.marker >= jpegAPP0 && s.marker <= jpegAPPF {
+			insertAt = i + 1
+		}
+	}
+	var packet []byte
+	if index >= 0 {
+		packet = segments[index].data[len(jpegXMPHeader):]
+	}
+	if packet, err = update(packet); err != nil {
+		return nil, err
+	}
+	data := append(append([]byte{}, jpegXMPHeader...), packet...)
+	if len(data) > jpegMaxSegmentData {
+		return nil, fmt.Errorf("XMP packet of %d bytes is too large for a JPEG segment", len(packet))
+	}
+	if index >= 0 {
+		segments[index].data = data
+	} else {
+		segments = append(segments[:insertAt], append([]jpegSegment{{jpegAPP1, data}}, segments[insertAt:]...)...)
+	}
+	return joinJPEG(segments, rest), nil
+}
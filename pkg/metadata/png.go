@@ -0,0 +1,96 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngXMPKeyword identifies the iTXt chunk holding XMP.
+const pngXMPKeyword = "XML:com.adobe.xmp"
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+func splitPNG(byts []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(byts, pngSignature) {
+		return nil, fmt.Errorf("not a PNG image")
+	}
+	var chunks []pngChunk
+	for pos := len(pngSignature); pos < len(byts); {
+		if pos+12 > len(byts) {
+			return nil, fmt.Errorf("truncated PNG chunk at offset %d", pos)
+		}
+		length := int(binary.BigEndian.Uint32(byts[pos:]))
+		if pos+12+length > len(byts) {
+			return nil, fmt.Errorf("invalid PNG chunk length at offset %d", pos)
+		}
+		chunks = append(chunks, pngChunk{string(byts[pos+4 : pos+8]), byts[pos+8 : pos+8+length]})
+		pos += 12 + length
+	}
+	return chunks, nil
+}
+
+func joinPNG(chunks []pngChunk) []byte {
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	for _, c := range chunks {
+		binary.Write(&buf, binary.BigEndian, uint32(len(c.data)))
+		crc := crc32.NewIEEE()
+		crc.Write([]byte(c.typ))
+		crc.Write(c.data)
+		buf.WriteString(c.typ)
+		buf.Write(c.data)
+		binary.Write(&buf, binary.BigEndian, crc.Sum32())
+	}
+	return buf.Bytes()
+}
+
+// pngXMPHeader is the start of an uncompressed iTXt chunk with the XMP
+// keyword and empty language tag and translated keyword.
+func pngXMPHeader() []byte {
+	return []byte(pngXMPKeyword + "\x00\x00\x00\x00\x00")
+}
+
+// updatePNGXMP replaces the XMP packet of a PNG with update(packet), where
+// packet is empty if the image has none. A new packet is inserted after the
+// IHDR chunk.
+func updatePNGXMP(byts []byte, update func(packet []byte) ([]byte, error)) ([]byte, error) {
+	chunks, err := splitPNG(byts)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 || chunks[0].typ != "IHDR" {
+		return nil, fmt.Errorf("invalid PNG: missing IHDR chunk")
+	}
+	header := pngXMPHeader()
+	index := -1
+	for i, c := range chunks {
+		if c.typ == "iTXt" && bytes.HasPrefix(c.data, []byte(pngXMPKeyword+"\x00")) {
+			if !bytes.HasPrefix(c.data, header) {
+				return nil, fmt.Errorf("compressed or translated XMP in PNG is not supported")
+			}
+			index = i
+			break
+		}
+	}
+	var packet []byte
+	if index >= 0 {
+		packet = chunks[index].data[len(header):]
+	}
+	if packet, err = update(packet); err != nil {
+		return nil, err
+	}
+	chunk := pngChunk{"iTXt", append(header, packet...)}
+	if index >= 0 {
+		chunks[index] = chunk
+	} else {
+		chunks = append(chunks[:1], append([]pngChunk{chunk}, chunks[1:]...)...)
+	}
+	return joinPNG(chunks), nil
+}
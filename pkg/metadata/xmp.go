@@ -0,0 +1,154 @@
+// Package metadata embeds annotations into image files (or their sidecars) in
+// formats understood by photo management tools such as Lightroom and digiKam.
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const xmpTemplate = `<?xpacket begin="` + "\xef\xbb\xbf" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+var (
+	rdfOpenRE  = regexp.MustCompile(`<rdf:RDF[^>]*>`)
+	subjectRE  = regexp.MustCompile(`(?s)<dc:subject>\s*<rdf:Bag[^>]*?(?:/>|>(.*?)</rdf:Bag>)\s*</dc:subject>`)
+	listItemRE = regexp.MustCompile(`(?s)<rdf:li[^>]*>(.*?)</rdf:li>`)
+)
+
+// SidecarPath returns the path of the XMP sidecar of filename, e.g.
+// photo.xmp for photo.jpg.
+func SidecarPath(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".xmp"
+}
+
+// AddKeywords adds keywords to the XMP dc:subject of the image in filename,
+// and to its IPTC-IIM keywords if it is a JPEG, preserving any existing
+// metadata. Only JPEG and PNG images are supported.
+func AddKeywords(filename string, keywords []string) error {
+	byts, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var updated []byte
+	switch {
+	case bytes.HasPrefix(byts, jpegSOI):
+		updated, err = updateJPEGXMP(byts, func(packet []byte) ([]byte, error) { return mergeSubjects(packet, keywords) })
+		if err == nil {
+			updated, err = updateJPEGIPTC(updated, keywords)
+		}
+	case bytes.HasPrefix(byts, pngSignature):
+		updated, err = updatePNGXMP(byts, func(packet []byte) ([]byte, error) { return mergeSubjects(packet, keywords) })
+	default:
+		return fmt.Errorf("embedding XMP is only supported in JPEG and PNG images, use a sidecar instead")
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, updated)
+}
+
+// AddSidecarKeywords adds keywords to the XMP dc:subject of the sidecar of
+// filename (see SidecarPath), creating it if necessary.
+func AddSidecarKeywords(filename string, keywords []string) error {
+	path := SidecarPath(filename)
+	packet, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if packet, err = mergeSubjects(packet, keywords); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return writeFileAtomic(path, packet)
+}
+
+// mergeSubjects returns packet with keywords added to its dc:subject. An empty
+// packet is replaced by a new one.
+func mergeSubjects(packet []byte, keywords []string) ([]byte, error) {
+	if len(bytes.TrimSpace(packet)) == 0 {
+		packet = []byte(xmpTemplate)
+	}
+	s := string(packet)
+	var (
+		existing []string
+		seen     = make(map[string]bool)
+	)
+	loc := subjectRE.FindStringSubmatchIndex(s)
+	// The keywords of a self-closing <rdf:Bag/> are none.
+	if loc != nil && loc[2] >= 0 {
+		for _, m := range listItemRE.FindAllStringSubmatch(s[loc[2]:loc[3]], -1) {
+			existing = append(existing, html.UnescapeString(strings.TrimSpace(m[1])))
+		}
+	}
+	for _, k := range existing {
+		seen[strings.ToLower(k)] = true
+	}
+	all := existing
+	for _, k := range keywords {
+		if !seen[strings.ToLower(k)] {
+			seen[strings.ToLower(k)] = true
+			all = append(all, k)
+		}
+	}
+	subject := subjectXML(all)
+	if loc != nil {
+		return []byte(s[:loc[0]] + subject + s[loc[1]:]), nil
+	}
+	rdf := rdfOpenRE.FindStringIndex(s)
+	if rdf == nil {
+		return nil, fmt.Errorf("invalid XMP packet: no rdf:RDF element")
+	}
+	description := "\n  <rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\">\n   " + subject + "\n  </rdf:Description>"
+	return []byte(s[:rdf[1]] + description + s[rdf[1]:]), nil
+}
+
+func subjectXML(keywords []string) string {
+	var buf bytes.Buffer
+	buf.WriteString("<dc:subject><rdf:Bag>")
+	for _, k := range keywords {
+		buf.WriteString("<rdf:li>")
+		xml.EscapeText(&buf, []byte(k))
+		buf.WriteString("</rdf:li>")
+	}
+	buf.WriteString("</rdf:Bag></dc:subject>")
+	return buf.String()
+}
+
+// writeFileAtomic replaces the contents of filename (preserving its mode) by
+// writing to a temporary file first, so that a failure never leaves a
+// partially written image behind.
+func writeFileAtomic(filename string, byts []byte) error {
+	mode := os.FileMode(0644)
+	if stat, err := os.Stat(filename); err == nil {
+		mode = stat.Mode()
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(byts); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
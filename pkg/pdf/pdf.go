@@ -0,0 +1,214 @@
+// Package pdf writes searchable PDFs: images of scanned pages with an
+// invisible layer of the text detected in them.
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"strings"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// Page is a page of a searchable PDF.
+type Page struct {
+	// JPEG is the image of the page, which is embedded as is (see ToJPEG).
+	JPEG []byte
+	// Layout of the text of the page. The page is Layout.Width x
+	// Layout.Height points, which is also the coordinate space of the
+	// bounds of its words (whatever the resolution of the image).
+	Layout vision.Page
+}
+
+// Write writes a PDF of pages to w. Words are drawn in the standard Helvetica
+// font, so characters outside of the Windows-1252 encoding are replaced by
+// "?".
+func Write(w io.Writer, pages []Page) error {
+	p := &writer{w: bufio.NewWriter(w)}
+	p.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	// Objects 1, 2 and 3 are the catalog, the page tree and the font, and
+	// each page is followed by its image and contents.
+	var kids []string
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 4+3*i))
+	}
+	p.object("<< /Type /Catalog /Pages 2 0 R >>")
+	p.object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	p.object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	for i, page := range pages {
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(page.JPEG))
+		if err != nil {
+			return fmt.Errorf("page %d: failed to decode image: %v", i+1, err)
+		}
+		colorSpace := "/DeviceRGB"
+		switch cfg.ColorModel {
+		case color.GrayModel:
+			colorSpace = "/DeviceGray"
+		case color.CMYKModel:
+			return fmt.Errorf("page %d: CMYK images are not supported, use ToJPEG", i+1)
+		}
+		width, height := page.Layout.Width, page.Layout.Height
+		if width <= 0 || height <= 0 {
+			width, height = cfg.Width, cfg.Height
+		}
+		id := 4 + 3*i
+		p.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>", width, height, id+1, id+2))
+		p.stream(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode", cfg.Width, cfg.Height, colorSpace), page.JPEG)
+		p.stream("<<", contents(page.Layout, width, height))
+	}
+	xref := p.offset
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, o := range p.offsets {
+		p.printf("%010d 00000 n \n", o)
+	}
+	p.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, xref)
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
+
+// contents returns the content stream of a page: the image scaled to the page
+// and the words in invisible text (rendering mode 3), each scaled to fit its
+// bounds.
+func contents(layout vision.Page, width, height int) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "q %d 0 0 %d 0 0 cm /Im0 Do Q\nBT 3 Tr\n", width, height)
+	for _, b := range layout.Blocks {
+		for _, par := range b.Paragraphs {
+			for _, w := range par.Words {
+				text := winAnsi(w.Text)
+				if len(text) == 0 || w.Bounds.Width <= 0 || w.Bounds.Height <= 0 {
+					continue
+				}
+				size := float64(w.Bounds.Height)
+				scale := 100 * float64(w.Bounds.Width) / (textWidth(text) * size / 1000)
+				// PDF coordinates start at the bottom left, and text is
+				// positioned by its baseline, roughly a fifth of the height of
+				// the bounds above their bottom.
+				x, y := float64(w.Bounds.X), float64(height-w.Bounds.Y-w.Bounds.Height)+size/5
+				fmt.Fprintf(&buf, "/F1 %.2f Tf %.2f Tz 1 0 0 1 %.2f %.2f Tm (%s) Tj\n", size, scale, x, y, escape(text))
+			}
+		}
+	}
+	buf.WriteString("ET\n")
+	return buf.Bytes()
+}
+
+type writer struct {
+	w       *bufio.Writer
+	offset  int
+	offsets []int // of each object
+	err     error
+}
+
+func (p *writer) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.offset += n
+	p.err = err
+}
+
+func (p *writer) write(b []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(b)
+	p.offset += n
+	p.err = err
+}
+
+func (p *writer) object(dict string) {
+	p.offsets = append(p.offsets, p.offset)
+	p.printf("%d 0 obj\n%s\nendobj\n", len(p.offsets), dict)
+}
+
+// stream writes a stream object, where dict is the opening of its dictionary
+// (without the length or closing delimiter).
+func (p *writer) stream(dict string, data []byte) {
+	p.offsets = append(p.offsets, p.offset)
+	p.printf("%d 0 obj\n%s /Length %d >>\nstream\n", len(p.offsets), dict, len(data))
+	p.write(data)
+	p.printf("\nendstream\nendobj\n")
+}
+
+// winAnsi encodes s in (approximately) the WinAnsiEncoding of the standard
+// fonts: ASCII and Latin-1 are encoded as is, and other characters as "?".
+func winAnsi(s string) []byte {
+	var b []byte
+	for _, r := range s {
+		switch {
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			b = append(b, byte(r))
+		case r < 0x20:
+			// Control characters are dropped.
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+func escape(b []byte) string {
+	var buf strings.Builder
+	for _, c := range b {
+		if c == '(' || c == ')' || c == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// textWidth returns the width of text in Helvetica, in thousandths of the font
+// size.
+func textWidth(text []byte) float64 {
+	var w int
+	for _, c := range text {
+		if c >= 0x20 && c < 0x7f {
+			w += helveticaWidths[c-0x20]
+		} else {
+			w += 556
+		}
+	}
+	return float64(w)
+}
+
+// helveticaWidths are the widths of the printable ASCII characters in
+// Helvetica, from its Adobe Font Metrics.
+var helveticaWidths = [...]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
+
+// ToJPEG returns content if it is a grayscale or color JPEG, and otherwise
+// re-encodes it as one of the given quality, for embedding in a Page.
+func ToJPEG(content []byte, quality int) ([]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	if format == "jpeg" && cfg.ColorModel != color.CMYKModel {
+		return content, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,32 @@
+package preprocess
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+)
+
+// DHash returns the difference hash of img: whether each pixel is brighter
+// than the next, in each row of img downscaled to 9x8 pixels. Images that look
+// alike (e.g. resized or re-encoded copies, or burst shots) have hashes that
+// differ in few bits, see Distance.
+func DHash(img image.Image) uint64 {
+	small := Resize(img, 9, 8)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := color.GrayModel.Convert(small.At(x, y)).(color.Gray).Y
+			right := color.GrayModel.Convert(small.At(x+1, y)).(color.Gray).Y
+			hash <<= 1
+			if left > right {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance returns the number of bits in which the hashes a and b differ.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,53 @@
+package preprocess
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+
+	"golang.org/x/image/draw"
+)
+
+// GIFFrames returns every nth frame of the (animated) GIF byts, starting with
+// the first, as displayed (i.e. composed onto the frames before it), and the
+// offset of each from the start of the animation, in seconds.
+func GIFFrames(byts []byte, n int) (frames []image.Image, seconds []float64, err error) {
+	if n < 1 {
+		return nil, nil, fmt.Errorf("invalid frame interval %d, must be positive", n)
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(byts))
+	if err != nil {
+		return nil, nil, err
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	var elapsed int // In hundredths of a second.
+	for i, frame := range g.Image {
+		var previous *image.RGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(canvas.Bounds())
+			copy(previous.Pix, canvas.Pix)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		if i%n == 0 {
+			snapshot := image.NewRGBA(canvas.Bounds())
+			copy(snapshot.Pix, canvas.Pix)
+			frames = append(frames, snapshot)
+			seconds = append(seconds, float64(elapsed)/100)
+		}
+		if i < len(g.Delay) {
+			elapsed += g.Delay[i]
+		}
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames, seconds, nil
+}
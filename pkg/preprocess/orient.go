@@ -0,0 +1,49 @@
+package preprocess
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// Orient returns img transformed to be upright, as per its Exif orientation
+// (1 to 8). Images that are upright (orientation 1, or an unknown orientation)
+// are returned as is.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+	if orientation >= 5 {
+		// Orientations 5 to 8 transpose the image.
+		w, h = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// The pixel of src displayed at (x, y).
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirrored horizontally.
+				sx, sy = w-1-x, y
+			case 3: // Rotated 180°.
+				sx, sy = w-1-x, h-1-y
+			case 4: // Mirrored vertically.
+				sx, sy = x, h-1-y
+			case 5: // Transposed.
+				sx, sy = y, x
+			case 6: // Rotated 90° clockwise to display.
+				sx, sy = y, w-1-x
+			case 7: // Transversed.
+				sx, sy = h-1-y, w-1-x
+			case 8: // Rotated 90° counter-clockwise to display.
+				sx, sy = h-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
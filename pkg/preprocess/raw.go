@@ -0,0 +1,160 @@
+package preprocess
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/jpeg"
+	"path/filepath"
+	"strings"
+)
+
+// rawExtensions are the extensions of the camera RAW formats supported by
+// RAWPreview, all of which are TIFF-based.
+var rawExtensions = map[string]bool{
+	".arw": true, // Sony
+	".cr2": true, // Canon
+	".dng": true, // Adobe (and many phones)
+	".nef": true, // Nikon
+}
+
+// IsRAW returns true if filename has the extension of a camera RAW file
+// supported by RAWPreview.
+func IsRAW(filename string) bool {
+	return rawExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// TIFF tags used to find the JPEG previews embedded in RAW files.
+const (
+	tagCompression     = 0x0103
+	tagStripOffsets    = 0x0111
+	tagOrientation     = 0x0112
+	tagStripByteCounts = 0x0117
+	tagSubIFDs         = 0x014a
+	tagJPEGOffset      = 0x0201
+	tagJPEGLength      = 0x0202
+	compressionOldJPEG = 6
+	compressionJPEG    = 7
+	maxIFDs            = 64
+	ifdEntrySize       = 12
+	tiffTypeShort      = 3
+	tiffTypeLong       = 4
+	tiffTypeIFD        = 13
+)
+
+// RAWPreview returns the largest (usually full-size) JPEG preview embedded in
+// the camera RAW file raw, and the Exif orientation (1 to 8) of the RAW
+// image, which applies to the preview as well.
+func RAWPreview(raw []byte) (preview []byte, orientation int, err error) {
+	if len(raw) < 8 {
+		return nil, 0, fmt.Errorf("invalid RAW file: truncated TIFF header")
+	}
+	var order binary.ByteOrder
+	switch string(raw[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, fmt.Errorf("invalid RAW file: unknown byte order %q", raw[:2])
+	}
+	orientation = 1
+	var (
+		best, bestPixels = []byte(nil), 0
+		ifd0             = int(order.Uint32(raw[4:]))
+		ifds             = []int{ifd0}
+		seen             = make(map[int]bool)
+	)
+	for len(ifds) > 0 && len(seen) < maxIFDs {
+		ifd := ifds[0]
+		ifds = ifds[1:]
+		if ifd <= 0 || ifd+2 > len(raw) || seen[ifd] {
+			continue
+		}
+		seen[ifd] = true
+		var (
+			n                        = int(order.Uint16(raw[ifd:]))
+			compression              int
+			stripOffset, stripLength int
+			jpegOffset, jpegLength   int
+		)
+		for i := 0; i < n; i++ {
+			pos := ifd + 2 + i*ifdEntrySize
+			if pos+ifdEntrySize > len(raw) {
+				break
+			}
+			entry := raw[pos : pos+ifdEntrySize]
+			tag, typ, count := order.Uint16(entry), order.Uint16(entry[2:]), int(order.Uint32(entry[4:]))
+			var value int
+			switch typ {
+			case tiffTypeShort:
+				value = int(order.Uint16(entry[8:]))
+			case tiffTypeLong, tiffTypeIFD:
+				value = int(order.Uint32(entry[8:]))
+			default:
+				continue
+			}
+			switch tag {
+			case tagCompression:
+				compression = value
+			case tagOrientation:
+				if ifd == ifd0 && value >= 1 && value <= 8 {
+					orientation = value
+				}
+			case tagStripOffsets:
+				// Previews are stored in a single strip.
+				if count == 1 {
+					stripOffset = value
+				}
+			case tagStripByteCounts:
+				if count == 1 {
+					stripLength = value
+				}
+			case tagJPEGOffset:
+				jpegOffset = value
+			case tagJPEGLength:
+				jpegLength = value
+			case tagSubIFDs:
+				if count == 1 {
+					ifds = append(ifds, value)
+					continue
+				}
+				// value is the offset of the array of offsets.
+				for j := 0; j < count && value+4*(j+1) <= len(raw); j++ {
+					ifds = append(ifds, int(order.Uint32(raw[value+4*j:])))
+				}
+			}
+		}
+		var candidates [][2]int
+		if jpegOffset > 0 && jpegLength > 0 {
+			candidates = append(candidates, [2]int{jpegOffset, jpegLength})
+		}
+		if (compression == compressionOldJPEG || compression == compressionJPEG) && stripOffset > 0 && stripLength > 0 {
+			candidates = append(candidates, [2]int{stripOffset, stripLength})
+		}
+		for _, c := range candidates {
+			if c[0]+c[1] > len(raw) {
+				continue
+			}
+			// Raw image data may be lossless JPEG as well, which
+			// the image/jpeg package does not support, so only
+			// previews that it can decode are considered.
+			b := raw[c[0] : c[0]+c[1]]
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(b))
+			if err != nil {
+				continue
+			}
+			if pixels := cfg.Width * cfg.Height; pixels > bestPixels {
+				best, bestPixels = b, pixels
+			}
+		}
+		// The next IFD in the chain follows the entries.
+		if next := ifd + 2 + n*ifdEntrySize; next+4 <= len(raw) {
+			ifds = append(ifds, int(order.Uint32(raw[next:])))
+		}
+	}
+	if best == nil {
+		return nil, 0, fmt.Errorf("no JPEG preview found in RAW file")
+	}
+	return best, orientation, nil
+}
@@ -0,0 +1,55 @@
+// Package preprocess prepares images for annotation, e.g. by re-encoding them
+// to fit within the size limits of providers.
+package preprocess
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// maxAttempts bounds the number of times an image is re-encoded by Fit.
+const maxAttempts = 8
+
+// Fit re-encodes img as a JPEG of the given quality (1-100), downscaling it as
+// necessary for the encoding to be at most maxBytes.
+func Fit(img image.Image, maxBytes, quality int) ([]byte, error) {
+	var (
+		buf   bytes.Buffer
+		scale = 1.
+		b     = img.Bounds()
+	)
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		scaled := img
+		if scale < 1 {
+			w, h := int(float64(b.Dx())*scale), int(float64(b.Dy())*scale)
+			if w < 1 || h < 1 {
+				break
+			}
+			scaled = Resize(img, w, h)
+		}
+		buf.Reset()
+		if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		if buf.Len() <= maxBytes {
+			return buf.Bytes(), nil
+		}
+		// The size of the encoding is roughly proportional to the number of
+		// pixels, so scale both dimensions by the square root of the excess
+		// (and a little more, to converge quickly).
+		scale *= 0.95 * math.Sqrt(float64(maxBytes)/float64(buf.Len()))
+	}
+	return nil, fmt.Errorf("unable to re-encode image to at most %d bytes", maxBytes)
+}
+
+// Resize returns img scaled to width x height pixels.
+func Resize(img image.Image, width, height int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,153 @@
+// Package receipt extracts the merchant, date, total and line items of
+// receipts and invoices from the text detected in photos of them.
+//
+// Extraction is heuristic, based on the layout common to receipts: the
+// merchant is named at the top, items are lines ending with a price, and the
+// total follows them. It works best with text detected line by line, as by
+// document text detection.
+package receipt
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Receipt is the structured content of a receipt. Amounts are decimal numbers
+// with a '.' separator (e.g. "1234.50"), without currency symbols.
+type Receipt struct {
+	Merchant string `json:"merchant,omitempty"`
+	// Date is in the YYYY-MM-DD format if it could be parsed, and as printed
+	// otherwise.
+	Date  string `json:"date,omitempty"`
+	Total string `json:"total,omitempty"`
+	Items []Item `json:"items,omitempty"`
+}
+
+// Item is a line item of a receipt.
+type Item struct {
+	Description string `json:"description"`
+	Amount      string `json:"amount"`
+}
+
+var (
+	// amountRE matches a price at the end of a line, e.g. "$1,234.50",
+	// "12,50 €" or "-3.00".
+	amountRE = regexp.MustCompile(`(-?)[$€£¥]?\s*(\d{1,3}(?:[.,\s]\d{3})*|\d+)[.,](\d{2})\s*(?:[$€£¥]|[A-Z]{3})?\s*[A-Z*]?$`)
+	totalRE  = regexp.MustCompile(`(?i)\b(grand total|total|amount due|balance due|total due|montant|summe|gesamt)\b`)
+	// notItemRE matches lines with amounts that are not line items.
+	notItemRE = regexp.MustCompile(`(?i)\b(sub-?total|tax|vat|gst|hst|tip|gratuity|change|cash|card|visa|mastercard|amex|debit|credit|tender|paid|payment|balance|rounding|discount total|savings|points)\b`)
+	// notMerchantRE matches lines at the top of receipts that do not name
+	// the merchant.
+	notMerchantRE = regexp.MustCompile(`(?i)(receipt|invoice|welcome|tel|phone|www\.|https?:|@|\d{3}[-. )]\d{3}[-. ]\d{4})`)
+	currencyRE    = regexp.MustCompile(`^[A-Z]{3}$`)
+	dateRE        = regexp.MustCompile(`\b(\d{4}-\d{1,2}-\d{1,2}|\d{1,2}[/.-]\d{1,2}[/.-]\d{2,4}|(?i:\d{1,2} (?:jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]* \d{4})|(?i:(?:jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]* \d{1,2},? \d{4}))\b`)
+)
+
+// dateLayouts are the layouts tried to parse dates, in order, so that
+// ambiguous dates (e.g. 03/04/2024) are read as month first.
+var dateLayouts = []string{
+	"2006-1-2",
+	"1/2/2006", "1/2/06", "2.1.2006", "2.1.06", "1-2-2006", "1-2-06",
+	"2/1/2006", "2/1/06",
+	"2 Jan 2006", "2 January 2006", "Jan 2, 2006", "January 2, 2006", "Jan 2 2006", "January 2 2006",
+}
+
+// Parse extracts a receipt from text, with a line of the receipt per line.
+// Fields that are not found are empty.
+func Parse(text string) Receipt {
+	var (
+		r     Receipt
+		lines []string
+	)
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.TrimSpace(l); len(l) > 0 {
+			lines = append(lines, l)
+		}
+	}
+	for _, l := range lines {
+		if m := dateRE.FindString(l); len(m) > 0 {
+			r.Date = parseDate(m)
+			break
+		}
+	}
+	// The merchant is the first line, of the top few, with letters.
+	for _, l := range lines[:min(len(lines), 5)] {
+		if hasLetters(l) && !amountRE.MatchString(l) && !dateRE.MatchString(l) && !notMerchantRE.MatchString(l) {
+			r.Merchant = l
+			break
+		}
+	}
+	// The total is the last amount on, or following, a line labeled as such.
+	// Items are the lines with amounts before the first total.
+	totalLine := -1
+	for i, l := range lines {
+		if !totalRE.MatchString(l) || notItemRE.MatchString(l) {
+			continue
+		}
+		amount, _ := parseAmount(l)
+		if len(amount) == 0 && i+1 < len(lines) {
+			// E.g. the amount is detected as a block of its own.
+			if next, rest := parseAmount(lines[i+1]); !hasLetters(rest) || currencyRE.MatchString(rest) {
+				amount = next
+			}
+		}
+		if len(amount) > 0 {
+			r.Total = amount
+			if totalLine < 0 {
+				totalLine = i
+			}
+		}
+	}
+	items := lines
+	if totalLine >= 0 {
+		items = lines[:totalLine]
+	}
+	for _, l := range items {
+		amount, description := parseAmount(l)
+		if len(amount) == 0 || !hasLetters(description) || notItemRE.MatchString(l) || dateRE.MatchString(l) {
+			continue
+		}
+		r.Items = append(r.Items, Item{Description: description, Amount: amount})
+	}
+	return r
+}
+
+// parseAmount returns the amount at the end of line, normalized, and the text
+// before it.
+func parseAmount(line string) (amount, rest string) {
+	m := amountRE.FindStringSubmatchIndex(line)
+	if m == nil {
+		return "", line
+	}
+	sign, units, cents := line[m[2]:m[3]], line[m[4]:m[5]], line[m[6]:m[7]]
+	// Thousands separators are dropped.
+	units = strings.NewReplacer(",", "", ".", "", " ", "").Replace(units)
+	units = strings.TrimLeft(units, "0")
+	if len(units) == 0 {
+		units = "0"
+	}
+	rest = strings.TrimRight(strings.TrimSpace(line[:m[0]]), ".:-$€£¥")
+	return sign + units + "." + cents, strings.TrimSpace(rest)
+}
+
+// parseDate returns s as YYYY-MM-DD if it can be parsed, or s.
+func parseDate(s string) string {
+	// As in the layouts, e.g. "Jan 2, 2006".
+	fields := strings.Fields(strings.Replace(strings.ToLower(s), ",", ", ", 1))
+	for i, f := range fields {
+		fields[i] = strings.ToUpper(f[:1]) + f[1:]
+	}
+	normalized := strings.Join(fields, " ")
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, normalized); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	return s
+}
+
+func hasLetters(s string) bool {
+	return strings.IndexFunc(s, unicode.IsLetter) >= 0
+}
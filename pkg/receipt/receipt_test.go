@@ -0,0 +1,160 @@
+package receipt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want Receipt
+	}{
+		{
+			name: "grocery with subtotal, tax and tender",
+			text: `WHOLE FOODS MARKET
+123 Main St
+Tel 415-555-0134
+03/14/2024 10:42 AM
+ORGANIC BANANAS 1.99
+ALMOND MILK 4.49 F
+SOURDOUGH LOAF $6.00
+SUBTOTAL 12.48
+TAX 0.55
+TOTAL $13.03
+VISA 13.03
+CHANGE 0.00`,
+			want: Receipt{
+				Merchant: "WHOLE FOODS MARKET",
+				Date:     "2024-03-14",
+				Total:    "13.03",
+				Items: []Item{
+					{"ORGANIC BANANAS", "1.99"},
+					{"ALMOND MILK", "4.49"},
+					{"SOURDOUGH LOAF", "6.00"},
+				},
+			},
+		},
+		{
+			name: "european with comma decimals and day first",
+			text: `Receipt
+Café Central
+Kaffee 3,50 €
+Apfelstrudel 5,90 €
+Summe 9,40 €
+MwSt 19% 1,50
+24.12.2023`,
+			want: Receipt{
+				Merchant: "Café Central",
+				Date:     "2023-12-24",
+				Total:    "9.40",
+				Items: []Item{
+					{"Kaffee", "3.50"},
+					{"Apfelstrudel", "5.90"},
+				},
+			},
+		},
+		{
+			name: "invoice with thousands, spelled out date and total on its own line",
+			text: `INVOICE
+Acme Consulting LLC
+Date: March 5, 2024
+Design work $1,200.00
+Hosting 49.99
+Amount Due
+$1,249.99`,
+			want: Receipt{
+				Merchant: "Acme Consulting LLC",
+				Date:     "2024-03-05",
+				Total:    "1249.99",
+				Items: []Item{
+					{"Design work", "1200.00"},
+					{"Hosting", "49.99"},
+				},
+			},
+		},
+		{
+			name: "ISO date, discount and grand total after total",
+			text: `Corner Deli
+2024-06-01
+Sandwich 8.50
+Coupon -1.00
+Total 7.50
+Tip 1.50
+Grand Total 9.00`,
+			want: Receipt{
+				Merchant: "Corner Deli",
+				Date:     "2024-06-01",
+				Total:    "9.00",
+				Items: []Item{
+					{"Sandwich", "8.50"},
+					{"Coupon", "-1.00"},
+				},
+			},
+		},
+		{
+			name: "unparseable date kept as printed",
+			text: `Kiosk
+31/31/2024
+Water 1.00`,
+			want: Receipt{
+				Merchant: "Kiosk",
+				Date:     "31/31/2024",
+				Items:    []Item{{"Water", "1.00"}},
+			},
+		},
+		{
+			name: "no text",
+			text: "",
+			want: Receipt{},
+		},
+	}
+	for _, test := range tests {
+		if got := Parse(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"2024-3-7", "2024-03-07"},
+		// Ambiguous dates are read as month first.
+		{"03/04/2024", "2024-03-04"},
+		{"25/12/2024", "2024-12-25"},
+		{"3/4/24", "2024-03-04"},
+		{"24.12.2023", "2023-12-24"},
+		{"7 JUN 2024", "2024-06-07"},
+		{"7 june 2024", "2024-06-07"},
+		{"Jun 7, 2024", "2024-06-07"},
+		{"JUNE 7 2024", "2024-06-07"},
+		{"13/13/2024", "13/13/2024"},
+	}
+	for _, test := range tests {
+		if got := parseDate(test.in); got != test.want {
+			t.Errorf("parseDate(%q): got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		line, amount, rest string
+	}{
+		{"Milk 2.49", "2.49", "Milk"},
+		{"Milk $2.49 F", "2.49", "Milk"},
+		{"Laptop 1,299.00", "1299.00", "Laptop"},
+		{"Laptop 1.299,00 €", "1299.00", "Laptop"},
+		{"Refund -5.00", "-5.00", "Refund"},
+		{"Total: 12.00 USD", "12.00", "Total"},
+		{"Thank you", "", "Thank you"},
+	}
+	for _, test := range tests {
+		if amount, rest := parseAmount(test.line); amount != test.amount || rest != test.rest {
+			t.Errorf("parseAmount(%q): got (%q, %q), want (%q, %q)", test.line, amount, rest, test.amount, test.rest)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Styles of Redact.
+const (
+	// Blur blurs each area until its features are unrecognizable.
+	Blur = "blur"
+	// Pixelate replaces each area by a coarse grid of blocks of its mean
+	// color.
+	Pixelate = "pixelate"
+)
+
+// redactMargin is the fraction of the size of each area it is enlarged by on
+// every side, as faces are detected tightly (e.g. without the hair).
+const redactMargin = 0.15
+
+// Redact returns a copy of img with the areas (e.g. the bounds of faces)
+// blurred or pixelated, as per style.
+func Redact(img image.Image, areas []image.Rectangle, style string) (*image.RGBA, error) {
+	if style != Blur && style != Pixelate {
+		return nil, fmt.Errorf("unknown style %q, must be %q or %q", style, Blur, Pixelate)
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	for _, a := range areas {
+		dx, dy := int(float64(a.Dx())*redactMargin), int(float64(a.Dy())*redactMargin)
+		a = image.Rect(a.Min.X-dx, a.Min.Y-dy, a.Max.X+dx, a.Max.Y+dy).Intersect(dst.Bounds())
+		if a.Empty() {
+			continue
+		}
+		// Coarse enough to be unrecognizable regardless of the size of the
+		// area.
+		size := max(4, max(a.Dx(), a.Dy())/8)
+		if style == Pixelate {
+			pixelate(dst, a, size)
+		} else {
+			// Three passes of a box blur approximate a gaussian blur.
+			for i := 0; i < 3; i++ {
+				boxBlur(dst, a, size/2)
+			}
+		}
+	}
+	return dst, nil
+}
+
+// pixelate fills each block of size pixels of the area a of img with its mean
+// color.
+func pixelate(img *image.RGBA, a image.Rectangle, size int) {
+	for y := a.Min.Y; y < a.Max.Y; y += size {
+		for x := a.Min.X; x < a.Max.X; x += size {
+			block := image.Rect(x, y, x+size, y+size).Intersect(a)
+			var r, g, b, alpha, n int
+			for py := block.Min.Y; py < block.Max.Y; py++ {
+				for px := block.Min.X; px < block.Max.X; px++ {
+					c := img.RGBAAt(px, py)
+					r, g, b, alpha, n = r+int(c.R), g+int(c.G), b+int(c.B), alpha+int(c.A), n+1
+				}
+			}
+			mean := color.RGBA{uint8(r / n), uint8(g / n), uint8(b / n), uint8(alpha / n)}
+			draw.Draw(img, block, image.NewUniform(mean), image.Point{}, draw.Src)
+		}
+	}
+}
+
+// boxBlur replaces each pixel of the area a of img by the mean of the pixels
+// within radius of it (in a), horizontally then vertically.
+func boxBlur(img *image.RGBA, a image.Rectangle, radius int) {
+	if radius < 1 {
+		return
+	}
+	line := make([]color.RGBA, max(a.Dx(), a.Dy()))
+	blur := func(n int, at func(i int) (x, y int)) {
+		for i := 0; i < n; i++ {
+			line[i] = img.RGBAAt(at(i))
+		}
+		var r, g, b, alpha, count int
+		add := func(c color.RGBA, sign int) {
+			r, g, b, alpha, count = r+sign*int(c.R), g+sign*int(c.G), b+sign*int(c.B), alpha+sign*int(c.A), count+sign
+		}
+		for i := 0; i < min(radius, n); i++ {
+			add(line[i], 1)
+		}
+		for i := 0; i < n; i++ {
+			if j := i + radius; j < n {
+				add(line[j], 1)
+			}
+			if j := i - radius - 1; j >= 0 {
+				add(line[j], -1)
+			}
+			x, y := at(i)
+			img.SetRGBA(x, y, color.RGBA{uint8(r / count), uint8(g / count), uint8(b / count), uint8(alpha / count)})
+		}
+	}
+	for y := a.Min.Y; y < a.Max.Y; y++ {
+		blur(a.Dx(), func(i int) (int, int) { return a.Min.X + i, y })
+	}
+	for x := a.Min.X; x < a.Max.X; x++ {
+		blur(a.Dy(), func(i int) (int, int) { return x, a.Min.Y + i })
+	}
+}
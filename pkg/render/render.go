@@ -0,0 +1,112 @@
+// Package render draws annotations onto images, for visually inspecting
+// results.
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Colors of the boxes drawn for each kind of annotation.
+var (
+	objectColor = color.RGBA{0x00, 0xc8, 0x53, 0xff}
+	faceColor   = color.RGBA{0xff, 0x17, 0x44, 0xff}
+	textColor   = color.RGBA{0x29, 0x79, 0xff, 0xff}
+	logoColor   = color.RGBA{0xff, 0x91, 0x00, 0xff}
+)
+
+var (
+	parseFont sync.Once
+	goRegular *opentype.Font
+	fontErr   error
+)
+
+// Draw returns a copy of img with the bounding boxes of the objects, faces,
+// logos and text blocks in r drawn onto it, each with its description.
+func Draw(img image.Image, r vision.Result) (*image.RGBA, error) {
+	parseFont.Do(func() { goRegular, fontErr = opentype.Parse(goregular.TTF) })
+	if fontErr != nil {
+		return nil, fmt.Errorf("failed to parse font: %v", fontErr)
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	// Scale the lines and text with the image, so that they are legible
+	// without hiding too much of it.
+	size := max(b.Dx(), b.Dy())
+	face, err := opentype.NewFace(goRegular, &opentype.FaceOptions{Size: float64(max(12, size/60)), DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+	c := canvas{dst, face, max(2, size/400)}
+	for _, l := range r.TextBlocks {
+		c.box(l.Bounds, textColor, "")
+	}
+	for i, f := range r.Faces {
+		bounds := f.Bounds
+		c.box(&bounds, faceColor, fmt.Sprintf("face #%d", i))
+	}
+	for _, l := range r.Logos {
+		c.box(l.Bounds, logoColor, l.Description)
+	}
+	for _, l := range r.Objects {
+		c.box(l.Bounds, objectColor, fmt.Sprintf("%s %.0f%%", l.Description, l.Confidence*100))
+	}
+	return dst, nil
+}
+
+type canvas struct {
+	img   *image.RGBA
+	face  font.Face
+	width int // of lines
+}
+
+// box draws the outline of bounds, with label (if any) above it (or inside it
+// at the top of the image).
+func (c canvas) box(bounds *vision.BoundingBox, col color.RGBA, label string) {
+	if bounds == nil {
+		return
+	}
+	r := image.Rect(bounds.X, bounds.Y, bounds.X+bounds.Width, bounds.Y+bounds.Height)
+	fill := image.NewUniform(col)
+	for _, edge := range []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+c.width),
+		image.Rect(r.Min.X, r.Max.Y-c.width, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+c.width, r.Max.Y),
+		image.Rect(r.Max.X-c.width, r.Min.Y, r.Max.X, r.Max.Y),
+	} {
+		draw.Draw(c.img, edge, fill, image.Point{}, draw.Src)
+	}
+	if len(label) == 0 {
+		return
+	}
+	var (
+		metrics = c.face.Metrics()
+		height  = (metrics.Ascent + metrics.Descent).Ceil()
+		pad     = c.width
+		width   = font.MeasureString(c.face, label).Ceil()
+		top     = r.Min.Y - height - 2*pad
+	)
+	if top < 0 {
+		top = r.Min.Y
+	}
+	background := image.Rect(r.Min.X, top, r.Min.X+width+2*pad, top+height+2*pad)
+	draw.Draw(c.img, background, fill, image.Point{}, draw.Src)
+	d := font.Drawer{
+		Dst:  c.img,
+		Src:  image.White,
+		Face: c.face,
+		Dot:  fixed.P(r.Min.X+pad, top+pad+metrics.Ascent.Ceil()),
+	}
+	d.DrawString(label)
+}
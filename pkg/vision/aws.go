@@ -0,0 +1,432 @@
+package vision
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rekognition"
+)
+
+type awsProvider struct {
+	client *rekognition.Rekognition
+}
+
+// NewAWS returns a Provider backed by Amazon Rekognition, with credentials and
+// region picked up from the standard AWS SDK chain (environment variables,
+// ~/.aws/config and ~/.aws/credentials, instance roles etc.).
+func NewAWS() (Provider, error) {
+	client, err := awsClient("")
+	if err != nil {
+		return nil, err
+	}
+	return &awsProvider{client}, nil
+}
+
+// awsClient returns a Rekognition client configured by the standard AWS SDK
+// chain, in region if set.
+func awsClient(region string) (*rekognition.Rekognition, error) {
+	options := session.Options{SharedConfigState: session.SharedConfigEnable}
+	if len(region) > 0 {
+		options.Config.Region = aws.String(region)
+	}
+	sess, err := session.NewSessionWithOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	if len(aws.StringValue(sess.Config.Region)) == 0 {
+		return nil, fmt.Errorf("no AWS region configured (e.g., with the AWS_REGION environment variable)")
+	}
+	return rekognition.New(sess), nil
+}
+
+func (p *awsProvider) Name() string { return "aws" }
+
+func (p *awsProvider) Capabilities() []Feature {
+	return []Feature{FeatureLabels, FeatureObjects, FeatureText, FeatureFaces, FeatureSafeSearch, FeatureColors}
+}
+
+func (p *awsProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := p.annotate(ctx, img, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// annotate makes one Rekognition call per requested feature (labels, objects and
+// colors share a single DetectLabels call).
+func (p *awsProvider) annotate(ctx context.Context, img Image, result *Result, opts Options) error {
+	image, err := awsImage(ctx, &img)
+	if err != nil {
+		return err
+	}
+	// Bounds are relative to the image dimensions, which are only looked up
+	// (once) if needed.
+	var width, height int
+	size := func() (int, int, error) {
+		if width > 0 {
+			return width, height, nil
+		}
+		var err error
+		width, height, err = awsImageSize(ctx, img)
+		return width, height, err
+	}
+	if opts.Has(FeatureLabels) || opts.Has(FeatureObjects) || opts.Has(FeatureColors) {
+		if err := p.detectLabels(ctx, image, img.Name, size, result, opts); err != nil {
+			return err
+		}
+	}
+	if opts.Has(FeatureText) {
+		output, err := p.client.DetectTextWithContext(ctx, &rekognition.DetectTextInput{Image: image}, awsOptions(opts))
+		if err != nil {
+			return awsError("DetectText", err)
+		}
+		if opts.Verbose {
+			log.Printf("%s: %s\n", img.Name, output)
+		}
+		width, height, err := size()
+		if err != nil {
+			return err
+		}
+		var lines []string
+		for _, t := range output.TextDetections {
+			if aws.StringValue(t.Type) != rekognition.TextTypesLine {
+				continue
+			}
+			lines = append(lines, aws.StringValue(t.DetectedText))
+			l := Label{Description: aws.StringValue(t.DetectedText), Confidence: aws.Float64Value(t.Confidence) / 100}
+			if t.Geometry != nil && t.Geometry.BoundingBox != nil {
+				b := awsBoundingBox(t.Geometry.BoundingBox, width, height)
+				l.Bounds = &b
+			}
+			result.TextBlocks = append(result.TextBlocks, l)
+		}
+		result.Text = strings.Join(lines, "\n")
+	}
+	if opts.Has(FeatureFaces) {
+		output, err := p.client.DetectFacesWithContext(ctx, &rekognition.DetectFacesInput{
+			Image:      image,
+			Attributes: aws.StringSlice([]string{rekognition.AttributeAll}),
+		}, awsOptions(opts))
+		if err != nil {
+			return awsError("DetectFaces", err)
+		}
+		if opts.Verbose {
+			log.Printf("%s: %s\n", img.Name, output)
+		}
+		width, height, err := size()
+		if err != nil {
+			return err
+		}
+		for _, f := range output.FaceDetails {
+			result.Faces = append(result.Faces, awsFace(f, width, height))
+		}
+	}
+	if opts.Has(FeatureSafeSearch) {
+		output, err := p.client.DetectModerationLabelsWithContext(ctx, &rekognition.DetectModerationLabelsInput{
+			Image:         image,
+			MinConfidence: aws.Float64(0),
+		}, awsOptions(opts))
+		if err != nil {
+			return awsError("DetectModerationLabels", err)
+		}
+		if opts.Verbose {
+			log.Printf("%s: %s\n", img.Name, output)
+		}
+		result.SafeSearch = &SafeSearch{}
+		for _, l := range output.ModerationLabels {
+			// Only the top-level categories of the moderation taxonomy are
+			// mapped, see:
+			// https://docs.aws.amazon.com/rekognition/latest/dg/moderation.html
+			if len(aws.StringValue(l.ParentName)) > 0 {
+				continue
+			}
+			score := aws.Float64Value(l.Confidence) / 100
+			switch aws.StringValue(l.Name) {
+			case "Explicit Nudity":
+				result.SafeSearch.Adult = math.Max(result.SafeSearch.Adult, score)
+			case "Suggestive":
+				result.SafeSearch.Racy = math.Max(result.SafeSearch.Racy, score)
+			case "Violence", "Visually Disturbing":
+				result.SafeSearch.Violence = math.Max(result.SafeSearch.Violence, score)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *awsProvider) detectLabels(ctx context.Context, image *rekognition.Image, name string, size func() (int, int, error), result *Result, opts Options) error {
+	input := &rekognition.DetectLabelsInput{Image: image}
+	if opts.MinConfidence > 0 {
+		input.MinConfidence = aws.Float64(opts.MinConfidence * 100)
+	}
+	if opts.MaxResults > 0 && !opts.Has(FeatureObjects) {
+		// Objects are derived from labels, so are not limited here.
+		input.MaxLabels = aws.Int64(int64(opts.MaxResults))
+	}
+	if opts.Has(FeatureColors) {
+		features := []string{rekognition.DetectLabelsFeatureNameImageProperties}
+		if opts.Has(FeatureLabels) || opts.Has(FeatureObjects) {
+			features = append(features, rekognition.DetectLabelsFeatureNameGeneralLabels)
+		}
+		input.Features = aws.StringSlice(features)
+	}
+	output, err := p.client.DetectLabelsWithContext(ctx, input, awsOptions(opts))
+	if err != nil {
+		return awsError("DetectLabels", err)
+	}
+	if opts.Verbose {
+		log.Printf("%s: %s\n", name, output)
+	}
+	for _, l := range output.Labels {
+		if l.Name == nil || l.Confidence == nil {
+			continue
+		}
+		// Rekognition reports confidence as a percentage.
+		confidence := *l.Confidence / 100
+		if opts.Has(FeatureLabels) {
+			result.Labels = append(result.Labels, Label{Description: *l.Name, Confidence: confidence})
+		}
+		if opts.Has(FeatureObjects) && len(l.Instances) > 0 {
+			width, height, err := size()
+			if err != nil {
+				return err
+			}
+			for _, inst := range l.Instances {
+				b := awsBoundingBox(inst.BoundingBox, width, height)
+				result.Objects = append(result.Objects, Label{
+					Description: *l.Name,
+					Confidence:  aws.Float64Value(inst.Confidence) / 100,
+					Bounds:      &b,
+				})
+			}
+		}
+	}
+	sortLabels(result.Labels)
+	sortLabels(result.Objects)
+	if p := output.ImageProperties; p != nil {
+		for _, c := range p.DominantColors {
+			result.Colors = append(result.Colors, Color{
+				Hex:      strings.ToLower(aws.StringValue(c.HexCode)),
+				Name:     strings.ToLower(aws.StringValue(c.SimplifiedColor)),
+				Fraction: aws.Float64Value(c.PixelPercent) / 100,
+			})
+		}
+		sort.SliceStable(result.Colors, func(i, j int) bool { return result.Colors[i].Fraction > result.Colors[j].Fraction })
+	}
+	return nil
+}
+
+// awsHeaderBytes is the prefix of S3 objects read to find image dimensions,
+// which is enough for all but JPEGs with unusually large metadata.
+const awsHeaderBytes = 256 << 10
+
+// awsImage returns img as a Rekognition image, fetching its content unless it
+// is an S3 object: Rekognition reads S3 objects itself, but cannot fetch
+// arbitrary URLs.
+func awsImage(ctx context.Context, img *Image) (*rekognition.Image, error) {
+	if len(img.Content) == 0 && strings.HasPrefix(img.URI, "s3://") {
+		bucket, key, err := splitBucketURI(img.URI)
+		if err != nil {
+			return nil, err
+		}
+		return &rekognition.Image{S3Object: &rekognition.S3Object{Bucket: aws.String(bucket), Name: aws.String(key)}}, nil
+	}
+	var err error
+	if img.Content, err = img.fetch(ctx); err != nil {
+		return nil, err
+	}
+	return &rekognition.Image{Bytes: img.Content}, nil
+}
+
+// awsImageSize returns the dimensions of img, reading as little of S3 objects
+// as possible.
+func awsImageSize(ctx context.Context, img Image) (width, height int, err error) {
+	if len(img.Content) > 0 || !strings.HasPrefix(img.URI, "s3://") {
+		return imageSize(img.Content)
+	}
+	header, err := downloadS3(ctx, img.URI, awsHeaderBytes)
+	if err != nil {
+		return 0, 0, err
+	}
+	if width, height, err = imageSize(header); err == nil || len(header) < awsHeaderBytes {
+		return width, height, err
+	}
+	content, err := Download(ctx, img.URI)
+	if err != nil {
+		return 0, 0, err
+	}
+	return imageSize(content)
+}
+
+// awsBoundingBox converts a bounding box expressed as ratios of the image
+// dimensions into pixels.
+func awsBoundingBox(b *rekognition.BoundingBox, width, height int) BoundingBox {
+	if b == nil {
+		return BoundingBox{}
+	}
+	return BoundingBox{
+		X:      int(aws.Float64Value(b.Left) * float64(width)),
+		Y:      int(aws.Float64Value(b.Top) * float64(height)),
+		Width:  int(aws.Float64Value(b.Width) * float64(width)),
+		Height: int(aws.Float64Value(b.Height) * float64(height)),
+	}
+}
+
+// awsServiceCodes are the error codes of Rekognition that are failures of the
+// service (or of authentication with it) rather than of the image, see:
+// https://docs.aws.amazon.com/rekognition/latest/dg/error-handling.html
+var awsServiceCodes = map[string]bool{
+	"AccessDeniedException":                  true,
+	"ExpiredTokenException":                  true,
+	"InternalServerError":                    true,
+	"InvalidSignatureException":              true,
+	"LimitExceededException":                 true,
+	"ProvisionedThroughputExceededException": true,
+	"ResourceNotReadyException":              true, // a Custom Labels model that is not running
+	"ThrottlingException":                    true,
+	"UnrecognizedClientException":            true,
+}
+
+// awsError returns the failure of a call to api, as a *ServiceError unless it
+// was caused by the image.
+func awsError(api string, err error) error {
+	failure := fmt.Errorf("Rekognition %s failed: %v", api, err)
+	if e, ok := err.(awserr.RequestFailure); ok && !awsServiceCodes[e.Code()] && !isServiceStatus(e.StatusCode()) {
+		return failure
+	}
+	// Including failures to connect and to find credentials.
+	return &ServiceError{failure}
+}
+
+// awsOptions configures the SDK's own retry logic, which already backs off on
+// throttling and transient errors, from opts, and counts requests (including
+// retries) in opts.Stats and limits their rate by opts.RateLimit.
+func awsOptions(opts Options) request.Option {
+	delay := opts.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	return func(r *request.Request) {
+		r.Retryer = client.DefaultRetryer{
+			NumMaxRetries:    opts.Retries,
+			MinRetryDelay:    delay,
+			MinThrottleDelay: delay,
+		}
+		if opts.RateLimit != nil {
+			// Before each attempt is signed, which stops it on error.
+			r.Handlers.Sign.PushFront(func(r *request.Request) {
+				if err := opts.RateLimit.Wait(r.Context()); err != nil {
+					r.Error = err
+				}
+			})
+		}
+		if opts.Stats != nil {
+			r.Handlers.Send.PushFront(func(r *request.Request) {
+				opts.Stats.addRequest(r.HTTPRequest.ContentLength)
+			})
+		}
+	}
+}
+
+// awsEmotions maps Rekognition emotions to the names used by Face.Emotions.
+var awsEmotions = map[string]string{
+	rekognition.EmotionNameHappy:     "joy",
+	rekognition.EmotionNameSad:       "sorrow",
+	rekognition.EmotionNameAngry:     "anger",
+	rekognition.EmotionNameSurprised: "surprise",
+}
+
+func awsFace(f *rekognition.FaceDetail, width, height int) Face {
+	face := Face{
+		Bounds:     awsBoundingBox(f.BoundingBox, width, height),
+		Confidence: aws.Float64Value(f.Confidence) / 100,
+	}
+	for _, l := range f.Landmarks {
+		face.Landmarks = append(face.Landmarks, FaceLandmark{
+			Type: aws.StringValue(l.Type),
+			X:    int(aws.Float64Value(l.X) * float64(width)),
+			Y:    int(aws.Float64Value(l.Y) * float64(height)),
+		})
+	}
+	if len(f.Emotions) > 0 {
+		face.Emotions = make(map[string]float64)
+		for _, e := range f.Emotions {
+			name, ok := awsEmotions[aws.StringValue(e.Type)]
+			if !ok {
+				name = strings.ToLower(aws.StringValue(e.Type))
+			}
+			face.Emotions[name] = aws.Float64Value(e.Confidence) / 100
+		}
+	}
+	if a := f.AgeRange; a != nil {
+		face.Age = float64(aws.Int64Value(a.Low)+aws.Int64Value(a.High)) / 2
+	}
+	if g := f.Gender; g != nil {
+		face.Gender = strings.ToLower(aws.StringValue(g.Value))
+	}
+	return face
+}
+
+func (p *awsProvider) CompareFaces(ctx context.Context, source, target Image, minSimilarity float64, opts Options) ([]FaceMatch, error) {
+	sourceImage, err := awsImage(ctx, &source)
+	if err != nil {
+		return nil, err
+	}
+	targetImage, err := awsImage(ctx, &target)
+	if err != nil {
+		return nil, err
+	}
+	output, err := p.client.CompareFacesWithContext(ctx, &rekognition.CompareFacesInput{
+		SourceImage:         sourceImage,
+		TargetImage:         targetImage,
+		SimilarityThreshold: aws.Float64(minSimilarity * 100),
+	}, awsOptions(opts))
+	if err != nil {
+		return nil, awsError("CompareFaces", err)
+	}
+	if opts.Verbose {
+		log.Printf("%s: %s\n", target.Name, output)
+	}
+	if len(output.FaceMatches) == 0 {
+		return nil, nil
+	}
+	width, height, err := awsImageSize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]FaceMatch, 0, len(output.FaceMatches))
+	for _, m := range output.FaceMatches {
+		if m.Face == nil {
+			continue
+		}
+		matches = append(matches, FaceMatch{
+			Bounds: awsBoundingBox(m.Face.BoundingBox, width, height),
+			// Rekognition reports similarity as a percentage.
+			Similarity: aws.Float64Value(m.Similarity) / 100,
+		})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Similarity > matches[j].Similarity })
+	return matches, nil
+}
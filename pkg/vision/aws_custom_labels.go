@@ -0,0 +1,211 @@
+package vision
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rekognition"
+)
+
+// awsModelPollInterval is the delay between checks of the status of models
+// being started or stopped, which takes minutes.
+const awsModelPollInterval = 10 * time.Second
+
+// AWSModel is a model (a version of a project) of Amazon Rekognition Custom
+// Labels, which is billed for as long as it is running.
+type AWSModel struct {
+	client  *rekognition.Rekognition
+	arn     string
+	project string
+	version string
+}
+
+// NewAWSModel returns the model with the ARN arn, e.g.
+// arn:aws:rekognition:us-east-1:123456789012:project/birds/version/birds.2024-01-01T00.00.00/1704067200000,
+// with credentials picked up as for NewAWS, in the region of the model.
+func NewAWSModel(arn string) (*AWSModel, error) {
+	// arn:aws:rekognition:REGION:ACCOUNT:project/PROJECT/version/VERSION/TIMESTAMP
+	fields := strings.SplitN(arn, ":", 6)
+	var parts []string
+	if len(fields) == 6 {
+		parts = strings.Split(fields[5], "/")
+	}
+	if len(fields) != 6 || fields[2] != "rekognition" || len(parts) != 5 || parts[0] != "project" || parts[2] != "version" {
+		return nil, fmt.Errorf("invalid model ARN %q, must be arn:aws:rekognition:REGION:ACCOUNT:project/PROJECT/version/VERSION/TIMESTAMP", arn)
+	}
+	client, err := awsClient(fields[3])
+	if err != nil {
+		return nil, err
+	}
+	return &AWSModel{client, arn, parts[1], parts[3]}, nil
+}
+
+// Status returns the status of the model, e.g. "RUNNING" or "STOPPED", and
+// the message explaining it.
+func (m *AWSModel) Status(ctx context.Context) (status, message string, err error) {
+	// Versions are described by the ARN of their project, which is not part of
+	// theirs.
+	projects, err := m.client.DescribeProjectsWithContext(ctx, &rekognition.DescribeProjectsInput{ProjectNames: aws.StringSlice([]string{m.project})})
+	if err != nil {
+		return "", "", awsError("DescribeProjects", err)
+	}
+	if len(projects.ProjectDescriptions) == 0 {
+		return "", "", fmt.Errorf("no project %q", m.project)
+	}
+	versions, err := m.client.DescribeProjectVersionsWithContext(ctx, &rekognition.DescribeProjectVersionsInput{
+		ProjectArn:   projects.ProjectDescriptions[0].ProjectArn,
+		VersionNames: aws.StringSlice([]string{m.version}),
+	})
+	if err != nil {
+		return "", "", awsError("DescribeProjectVersions", err)
+	}
+	if len(versions.ProjectVersionDescriptions) == 0 {
+		return "", "", fmt.Errorf("no version %q of project %q", m.version, m.project)
+	}
+	v := versions.ProjectVersionDescriptions[0]
+	return aws.StringValue(v.Status), aws.StringValue(v.StatusMessage), nil
+}
+
+// Start starts the model with inferenceUnits (at least 1), returning once it
+// is running.
+func (m *AWSModel) Start(ctx context.Context, inferenceUnits int) error {
+	if inferenceUnits < 1 {
+		inferenceUnits = 1
+	}
+	if _, err := m.client.StartProjectVersionWithContext(ctx, &rekognition.StartProjectVersionInput{
+		ProjectVersionArn: aws.String(m.arn),
+		MinInferenceUnits: aws.Int64(int64(inferenceUnits)),
+	}); err != nil {
+		return awsError("StartProjectVersion", err)
+	}
+	return m.wait(ctx, rekognition.ProjectVersionStatusRunning)
+}
+
+// Stop stops the model, returning once it is stopped.
+func (m *AWSModel) Stop(ctx context.Context) error {
+	if _, err := m.client.StopProjectVersionWithContext(ctx, &rekognition.StopProjectVersionInput{ProjectVersionArn: aws.String(m.arn)}); err != nil {
+		return awsError("StopProjectVersion", err)
+	}
+	return m.wait(ctx, rekognition.ProjectVersionStatusStopped)
+}
+
+// wait polls the status of the model until it is want, failing if it is
+// neither want nor in transition to it.
+func (m *AWSModel) wait(ctx context.Context, want string) error {
+	for {
+		status, message, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		switch status {
+		case want:
+			return nil
+		case rekognition.ProjectVersionStatusStarting, rekognition.ProjectVersionStatusStopping:
+		default:
+			return fmt.Errorf("model is %s, not %s: %s", status, want, message)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(awsModelPollInterval):
+		}
+	}
+}
+
+type awsCustomLabelsProvider struct {
+	client *rekognition.Rekognition
+	arn    string
+}
+
+// NewAWSCustomLabels returns a Provider backed by the running model of Amazon
+// Rekognition Custom Labels with the ARN modelARN, which reports the labels
+// it detects with bounds as objects, and the others (of image-level
+// classification models) as labels.
+func NewAWSCustomLabels(modelARN string) (Provider, error) {
+	model, err := NewAWSModel(modelARN)
+	if err != nil {
+		return nil, err
+	}
+	return &awsCustomLabelsProvider{model.client, modelARN}, nil
+}
+
+func (p *awsCustomLabelsProvider) Name() string { return "awscustom" }
+
+func (p *awsCustomLabelsProvider) Identity() string { return p.Name() + "/" + p.arn }
+
+func (p *awsCustomLabelsProvider) Capabilities() []Feature {
+	return []Feature{FeatureLabels, FeatureObjects}
+}
+
+func (p *awsCustomLabelsProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := p.annotate(ctx, img, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+func (p *awsCustomLabelsProvider) annotate(ctx context.Context, img Image, result *Result, opts Options) error {
+	image, err := awsImage(ctx, &img)
+	if err != nil {
+		return err
+	}
+	input := &rekognition.DetectCustomLabelsInput{Image: image, ProjectVersionArn: aws.String(p.arn)}
+	if opts.MinConfidence > 0 {
+		input.MinConfidence = aws.Float64(opts.MinConfidence * 100)
+	} else {
+		// Otherwise, only labels above the threshold of the model are
+		// reported.
+		input.MinConfidence = aws.Float64(0)
+	}
+	if opts.MaxResults > 0 {
+		input.MaxResults = aws.Int64(int64(opts.MaxResults))
+	}
+	output, err := p.client.DetectCustomLabelsWithContext(ctx, input, awsOptions(opts))
+	if err != nil {
+		return awsError("DetectCustomLabels", err)
+	}
+	if opts.Verbose {
+		log.Printf("%s: %s\n", img.Name, output)
+	}
+	var width, height int
+	for _, l := range output.CustomLabels {
+		label := Label{Description: aws.StringValue(l.Name), Confidence: aws.Float64Value(l.Confidence) / 100}
+		if l.Geometry == nil || l.Geometry.BoundingBox == nil {
+			if opts.Has(FeatureLabels) {
+				result.Labels = append(result.Labels, label)
+			}
+			continue
+		}
+		if !opts.Has(FeatureObjects) {
+			continue
+		}
+		if width == 0 {
+			if width, height, err = awsImageSize(ctx, img); err != nil {
+				return err
+			}
+		}
+		b := awsBoundingBox(l.Geometry.BoundingBox, width, height)
+		label.Bounds = &b
+		result.Objects = append(result.Objects, label)
+	}
+	sortLabels(result.Labels)
+	sortLabels(result.Objects)
+	return nil
+}
@@ -0,0 +1,231 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+// ClarifaiModels are the shorthands of the models of Clarifai's community
+// (of the app clarifai/main) for ClarifaiConfig.Model.
+var ClarifaiModels = map[string]string{
+	"general": "general-image-recognition",
+	"food":    "food-item-recognition",
+	"travel":  "travel-recognition",
+	"nsfw":    "nsfw-recognition",
+	"apparel": "apparel-recognition",
+}
+
+// ClarifaiConfig configures the Clarifai provider.
+type ClarifaiConfig struct {
+	// PAT is a personal access token of Clarifai, see
+	// https://docs.clarifai.com/clarifai-basics/authentication/personal-access-tokens
+	PAT string
+	// Model is one of ClarifaiModels (defaulting to "general"), the ID of
+	// another model of clarifai/main, or the "user/app/model" ID of a model
+	// of another app (e.g. a custom model).
+	Model string
+}
+
+type clarifaiProvider struct {
+	client *http.Client
+	pat    string
+	url    string
+}
+
+// NewClarifai returns a Provider backed by a model of Clarifai, which reports
+// the concepts predicted for images as labels, and those of regions (by
+// detection models) as objects.
+func NewClarifai(cfg ClarifaiConfig) (Provider, error) {
+	if len(cfg.PAT) == 0 {
+		return nil, fmt.Errorf("no personal access token provided")
+	}
+	model := cfg.Model
+	if len(model) == 0 {
+		model = "general"
+	}
+	if m, ok := ClarifaiModels[model]; ok {
+		model = m
+	}
+	parts := strings.Split(model, "/")
+	switch len(parts) {
+	case 1:
+		parts = []string{"clarifai", "main", model}
+	case 3:
+	default:
+		return nil, fmt.Errorf("invalid model %q, must be the ID of a model of clarifai/main or user/app/model", model)
+	}
+	// From:
+	// https://docs.clarifai.com/api-guide/predict/images
+	url := fmt.Sprintf("https://api.clarifai.com/v2/users/%s/apps/%s/models/%s/outputs", parts[0], parts[1], parts[2])
+	return &clarifaiProvider{http.DefaultClient, cfg.PAT, url}, nil
+}
+
+func (p *clarifaiProvider) Name() string { return "clarifai" }
+
+func (p *clarifaiProvider) Identity() string { return p.Name() + "/" + p.url }
+
+func (p *clarifaiProvider) Capabilities() []Feature {
+	return []Feature{FeatureLabels, FeatureObjects}
+}
+
+func (p *clarifaiProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := img.fetchUnlessHTTP(ctx); err != nil {
+			results[i].Err = err
+			return
+		}
+		if err := p.annotate(ctx, img, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+type clarifaiConcept struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// clarifaiStatus is the status of a response, and of each of its outputs.
+type clarifaiStatus struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+	Details     string `json:"details"`
+}
+
+// clarifaiSuccess is the code of successful statuses.
+const clarifaiSuccess = 10000
+
+// clarifaiResponse is the subset of the response of predictions used here.
+type clarifaiResponse struct {
+	Status  clarifaiStatus `json:"status"`
+	Outputs []struct {
+		Status clarifaiStatus `json:"status"`
+		Data   struct {
+			Concepts []clarifaiConcept `json:"concepts"`
+			Regions  []struct {
+				RegionInfo struct {
+					// Bounds, as fractions of the dimensions of the image.
+					BoundingBox *struct {
+						TopRow    float64 `json:"top_row"`
+						LeftCol   float64 `json:"left_col"`
+						BottomRow float64 `json:"bottom_row"`
+						RightCol  float64 `json:"right_col"`
+					} `json:"bounding_box"`
+				} `json:"region_info"`
+				Data struct {
+					Concepts []clarifaiConcept `json:"concepts"`
+				} `json:"data"`
+			} `json:"regions"`
+		} `json:"data"`
+	} `json:"outputs"`
+}
+
+func (s clarifaiStatus) err() error {
+	if len(s.Details) > 0 {
+		return fmt.Errorf("%s: %s (%d)", s.Description, s.Details, s.Code)
+	}
+	return fmt.Errorf("%s (%d)", s.Description, s.Code)
+}
+
+func (p *clarifaiProvider) annotate(ctx context.Context, img Image, result *Result, opts Options) error {
+	image := map[string]interface{}{"url": img.URI}
+	if len(img.Content) > 0 {
+		image = map[string]interface{}{"base64": img.Content}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"inputs": []interface{}{map[string]interface{}{"data": map[string]interface{}{"image": image}}},
+	})
+	if err != nil {
+		return err
+	}
+	var response []byte
+	if err := withRetries(ctx, opts, func() error {
+		var err error
+		response, err = p.send(ctx, body, img.Name, opts)
+		return err
+	}); err != nil {
+		return err
+	}
+	var predictions clarifaiResponse
+	if err := json.Unmarshal(response, &predictions); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	if len(predictions.Outputs) == 0 {
+		return fmt.Errorf("prediction failed: %v", predictions.Status.err())
+	}
+	output := predictions.Outputs[0]
+	if output.Status.Code != clarifaiSuccess {
+		return fmt.Errorf("prediction failed: %v", output.Status.err())
+	}
+	if opts.Has(FeatureLabels) {
+		for _, c := range output.Data.Concepts {
+			result.Labels = append(result.Labels, Label{Description: c.Name, Confidence: c.Value})
+		}
+		sortLabels(result.Labels)
+	}
+	if opts.Has(FeatureObjects) && len(output.Data.Regions) > 0 {
+		content, err := img.fetch(ctx)
+		if err != nil {
+			return err
+		}
+		width, height, err := imageSize(content)
+		if err != nil {
+			return err
+		}
+		for _, r := range output.Data.Regions {
+			b := r.RegionInfo.BoundingBox
+			if b == nil || len(r.Data.Concepts) == 0 {
+				continue
+			}
+			// Of the concepts of each region, the most likely.
+			c := r.Data.Concepts[0]
+			result.Objects = append(result.Objects, Label{
+				Description: c.Name,
+				Confidence:  c.Value,
+				Bounds: &BoundingBox{
+					X:      int(b.LeftCol * float64(width)),
+					Y:      int(b.TopRow * float64(height)),
+					Width:  int((b.RightCol - b.LeftCol) * float64(width)),
+					Height: int((b.BottomRow - b.TopRow) * float64(height)),
+				},
+			})
+		}
+		sortLabels(result.Objects)
+	}
+	return nil
+}
+
+// send sends a prediction request, returning the body of a successful
+// response.
+func (p *clarifaiProvider) send(ctx context.Context, body []byte, name string, opts Options) ([]byte, error) {
+	body, _, err := sendHTTP(ctx, p.client, httpRequest{
+		method: "POST",
+		url:    p.url,
+		body:   body,
+		header: http.Header{"Content-Type": {"application/json"}, "Authorization": {"Key " + p.pat}},
+		errorMessage: func(body []byte) string {
+			var e clarifaiResponse
+			if err := json.Unmarshal(body, &e); err != nil || len(e.Status.Description) == 0 {
+				return ""
+			}
+			return e.Status.err().Error()
+		},
+	}, name, opts)
+	return body, err
+}
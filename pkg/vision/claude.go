@@ -0,0 +1,171 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+// DefaultClaudeModel is the model used by NewClaude if none is configured.
+const DefaultClaudeModel = "claude-sonnet-4-5"
+
+// ClaudeConfig configures the Claude provider, which annotates images by
+// prompting a model of the Anthropic API.
+type ClaudeConfig struct {
+	// APIKey of the Anthropic API, see
+	// https://docs.anthropic.com/en/api/getting-started
+	APIKey string
+	// Model to prompt, defaulting to DefaultClaudeModel.
+	Model string
+}
+
+type claudeProvider struct {
+	client *http.Client
+	key    string
+	model  string
+}
+
+// NewClaude returns a Provider backed by a Claude model, which captions images
+// (Result.Description), or answers Options.Question about them, and reports
+// labels and text in the same form as the other providers. As for gemini,
+// its confidences are estimated by the model itself.
+func NewClaude(cfg ClaudeConfig) (Provider, error) {
+	if len(cfg.APIKey) == 0 {
+		return nil, fmt.Errorf("no API key provided")
+	}
+	model := cfg.Model
+	if len(model) == 0 {
+		model = DefaultClaudeModel
+	}
+	return &claudeProvider{http.DefaultClient, cfg.APIKey, model}, nil
+}
+
+func (p *claudeProvider) Name() string { return "claude" }
+
+func (p *claudeProvider) Identity() string { return p.Name() + "/" + p.model }
+
+func (p *claudeProvider) Capabilities() []Feature {
+	// Objects are not supported, as the model does not locate them reliably.
+	return []Feature{FeatureLabels, FeatureText}
+}
+
+func (p *claudeProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	prompt := modelPrompt(opts)
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := p.annotate(ctx, img, prompt, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// claudeResponse is the subset of the messages response used here.
+type claudeResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+}
+
+// claudeError is the body of failed responses.
+type claudeError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *claudeProvider) annotate(ctx context.Context, img Image, prompt string, result *Result, opts Options) error {
+	content, err := img.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	// From:
+	// https://docs.anthropic.com/en/api/messages
+	request := map[string]interface{}{
+		"model":       p.model,
+		"max_tokens":  2048,
+		"temperature": 0,
+		"messages": []interface{}{
+			map[string]interface{}{
+				"role": "user",
+				"content": []interface{}{
+					map[string]interface{}{
+						"type": "image",
+						"source": map[string]interface{}{
+							"type":       "base64",
+							"media_type": http.DetectContentType(content),
+							"data":       content,
+						},
+					},
+					map[string]interface{}{"type": "text", "text": prompt},
+				},
+			},
+		},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	var response []byte
+	if err := withRetries(ctx, opts, func() error {
+		var err error
+		response, err = p.send(ctx, body, img.Name, opts)
+		return err
+	}); err != nil {
+		return err
+	}
+	var message claudeResponse
+	if err := json.Unmarshal(response, &message); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	var text strings.Builder
+	for _, c := range message.Content {
+		if c.Type == "text" {
+			text.WriteString(c.Text)
+		}
+	}
+	annotation, err := decodeModelAnnotation(text.String())
+	if err != nil {
+		return fmt.Errorf("failed to decode response of Claude (stop reason %s): %v", message.StopReason, err)
+	}
+	return annotation.fill(content, result, opts)
+}
+
+// send sends a message, returning the body of a successful response. 529
+// (Overloaded) responses are retried, as other 5xx ones.
+func (p *claudeProvider) send(ctx context.Context, body []byte, name string, opts Options) ([]byte, error) {
+	body, _, err := sendHTTP(ctx, p.client, httpRequest{
+		method: "POST",
+		url:    "https://api.anthropic.com/v1/messages",
+		body:   body,
+		header: http.Header{
+			"Content-Type":      {"application/json"},
+			"X-Api-Key":         {p.key},
+			"Anthropic-Version": {"2023-06-01"},
+		},
+		errorMessage: func(body []byte) string {
+			var e claudeError
+			if err := json.Unmarshal(body, &e); err != nil || len(e.Error.Message) == 0 {
+				return ""
+			}
+			return fmt.Sprintf("%s (%s)", e.Error.Message, e.Error.Type)
+		},
+	}, name, opts)
+	return body, err
+}
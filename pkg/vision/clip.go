@@ -0,0 +1,60 @@
+package vision
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/asimshankar/visionapi/pkg/clip"
+)
+
+// CLIPModelURLs are the URLs of the files of the CLIP model used by the clip
+// provider if none is configured: ViT-B/32, exported to ONNX as separate image
+// and text encoders (of 350 MB in total), which must be downloaded once.
+var CLIPModelURLs = CLIPConfig{
+	ImageModel: "https://huggingface.co/Xenova/clip-vit-base-patch32/resolve/main/onnx/vision_model.onnx",
+	TextModel:  "https://huggingface.co/Xenova/clip-vit-base-patch32/resolve/main/onnx/text_model.onnx",
+	Vocab:      "https://huggingface.co/Xenova/clip-vit-base-patch32/resolve/main/vocab.json",
+	Merges:     "https://huggingface.co/Xenova/clip-vit-base-patch32/resolve/main/merges.txt",
+}
+
+// CLIPConfig configures the clip provider, which scores images against the
+// labels of Options.Classes (zero-shot classification) with a CLIP model run
+// on this machine by ONNX Runtime.
+type CLIPConfig struct {
+	// ImageModel is the ONNX file of the image encoder, which takes a batch
+	// of RGB images of 224x224 pixels (pixel_values, NCHW) and returns their
+	// embeddings (image_embeds).
+	ImageModel string
+	// TextModel is the ONNX file of the text encoder, which takes a batch of
+	// tokenized texts (input_ids) and returns their embeddings (text_embeds).
+	TextModel string
+	// Vocab and Merges are the files of the tokenizer of the model, see
+	// clip.NewTokenizer.
+	Vocab  string
+	Merges string
+	// Library is the path of the ONNX Runtime shared library, as for
+	// LocalConfig.
+	Library string
+}
+
+// NewCLIP returns a Provider that labels images by their similarity to the
+// classes of Options.Classes. As for NewLocal, it is only supported by binaries
+// built with -tags onnx.
+func NewCLIP(cfg CLIPConfig) (Provider, error) {
+	if len(cfg.ImageModel) == 0 || len(cfg.TextModel) == 0 || len(cfg.Vocab) == 0 || len(cfg.Merges) == 0 {
+		return nil, fmt.Errorf("no models or tokenizer provided")
+	}
+	vocab, err := ioutil.ReadFile(cfg.Vocab)
+	if err != nil {
+		return nil, err
+	}
+	merges, err := ioutil.ReadFile(cfg.Merges)
+	if err != nil {
+		return nil, err
+	}
+	tokenizer, err := clip.NewTokenizer(vocab, merges)
+	if err != nil {
+		return nil, err
+	}
+	return newCLIPProvider(cfg, tokenizer)
+}
@@ -0,0 +1,200 @@
+//go:build onnx
+
+package vision
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"sync"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+	"github.com/asimshankar/visionapi/pkg/clip"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// Mean and standard deviation of the channels of the images CLIP was trained
+// on, which images are normalized by.
+var (
+	clipMean = [3]float32{0.48145466, 0.4578275, 0.40821073}
+	clipStd  = [3]float32{0.26862954, 0.26130258, 0.27577711}
+)
+
+// clipLogitScale is the (learned) temperature of the similarities of CLIP,
+// which are scaled by it before the softmax across classes.
+const clipLogitScale = 100
+
+// clipTemplate is the text each class is embedded in, which classifies better
+// than the class alone as captions are what CLIP was trained on.
+const clipTemplate = "a photo of a %s."
+
+type clipProvider struct {
+	model     string // path of the ONNX file of the image model
+	tokenizer *clip.Tokenizer
+	// size of the embeddings.
+	size int64
+	// mu serializes runs of the sessions, and guards classes.
+	mu          sync.Mutex
+	image, text *ort.DynamicAdvancedSession
+	// classes are the normalized embeddings of the classes embedded so far.
+	classes map[string][]float32
+}
+
+func newCLIPProvider(cfg CLIPConfig, tokenizer *clip.Tokenizer) (Provider, error) {
+	if err := initONNX(cfg.Library); err != nil {
+		return nil, err
+	}
+	_, outputs, err := ort.GetInputOutputInfo(cfg.ImageModel)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load %s: %v", cfg.ImageModel, err)
+	}
+	var size int64
+	for _, o := range outputs {
+		if o.Name == "image_embeds" {
+			size = o.Dimensions[len(o.Dimensions)-1]
+		}
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("%s is not the image encoder of a CLIP model: no image_embeds", cfg.ImageModel)
+	}
+	p := &clipProvider{model: cfg.ImageModel, tokenizer: tokenizer, size: size, classes: make(map[string][]float32)}
+	if p.image, err = ort.NewDynamicAdvancedSession(cfg.ImageModel, []string{"pixel_values"}, []string{"image_embeds"}, nil); err != nil {
+		return nil, fmt.Errorf("unable to load %s: %v", cfg.ImageModel, err)
+	}
+	if p.text, err = ort.NewDynamicAdvancedSession(cfg.TextModel, []string{"input_ids"}, []string{"text_embeds"}, nil); err != nil {
+		return nil, fmt.Errorf("unable to load %s: %v", cfg.TextModel, err)
+	}
+	return p, nil
+}
+
+func (p *clipProvider) Name() string { return "clip" }
+
+func (p *clipProvider) Identity() string { return p.Name() + "/" + p.model }
+
+func (p *clipProvider) Capabilities() []Feature {
+	return []Feature{FeatureLabels}
+}
+
+func (p *clipProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	if len(opts.Classes) < 2 {
+		return nil, fmt.Errorf("clip requires at least 2 classes to score images against")
+	}
+	classes, err := p.embedClasses(opts.Classes)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		defer opts.Stats.addImages(1)
+		if err := p.annotate(ctx, images[i], opts.Classes, classes, &results[i]); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// embedClasses returns the normalized embeddings of names, embedding those
+// that are not yet in a single batch.
+func (p *clipProvider) embedClasses(names []string) ([][]float32, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var (
+		missing []string
+		ids     []int64
+	)
+	for _, name := range names {
+		if _, ok := p.classes[name]; !ok {
+			missing = append(missing, name)
+			ids = append(ids, p.tokenizer.Encode(fmt.Sprintf(clipTemplate, name))...)
+		}
+	}
+	if len(missing) > 0 {
+		n := int64(len(missing))
+		input, err := ort.NewTensor(ort.NewShape(n, clip.ContextLength), ids)
+		if err != nil {
+			return nil, err
+		}
+		defer input.Destroy()
+		output, err := ort.NewEmptyTensor[float32](ort.NewShape(n, p.size))
+		if err != nil {
+			return nil, err
+		}
+		defer output.Destroy()
+		if err := p.text.Run([]ort.Value{input}, []ort.Value{output}); err != nil {
+			return nil, fmt.Errorf("embedding classes failed: %v", err)
+		}
+		data := output.GetData()
+		for i, name := range missing {
+			p.classes[name] = normalize(append([]float32(nil), data[int64(i)*p.size:int64(i+1)*p.size]...))
+		}
+	}
+	embeddings := make([][]float32, len(names))
+	for i, name := range names {
+		embeddings[i] = p.classes[name]
+	}
+	return embeddings, nil
+}
+
+func (p *clipProvider) annotate(ctx context.Context, img Image, names []string, classes [][]float32, result *Result) error {
+	content, err := img.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %v", err)
+	}
+	input, err := ort.NewTensor(ort.NewShape(1, 3, localSize, localSize), imageTensor(decoded, clipMean, clipStd))
+	if err != nil {
+		return err
+	}
+	defer input.Destroy()
+	output, err := ort.NewEmptyTensor[float32](ort.NewShape(1, p.size))
+	if err != nil {
+		return err
+	}
+	defer output.Destroy()
+	p.mu.Lock()
+	err = p.image.Run([]ort.Value{input}, []ort.Value{output})
+	p.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("embedding image failed: %v", err)
+	}
+	embedding := normalize(output.GetData())
+	logits := make([]float32, len(classes))
+	for i, c := range classes {
+		var similarity float32
+		for j := range c {
+			similarity += c[j] * embedding[j]
+		}
+		logits[i] = clipLogitScale * similarity
+	}
+	for i, confidence := range softmax(logits) {
+		result.Labels = append(result.Labels, Label{Description: names[i], Confidence: confidence})
+	}
+	sortLabels(result.Labels)
+	return nil
+}
+
+// normalize scales v to a unit vector, in place.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if length := float32(math.Sqrt(sum)); length > 0 {
+		for i := range v {
+			v[i] /= length
+		}
+	}
+	return v
+}
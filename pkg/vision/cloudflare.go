@@ -0,0 +1,196 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+// Models of Workers AI used by NewCloudflare if none are configured.
+const (
+	DefaultCloudflareModel        = "@cf/microsoft/resnet-50"
+	DefaultCloudflareCaptionModel = "@cf/llava-hf/llava-1.5-7b-hf"
+)
+
+// CloudflareConfig configures the Cloudflare Workers AI provider.
+type CloudflareConfig struct {
+	// AccountID of the Cloudflare account, and an API token of it with
+	// permission to use Workers AI, see
+	// https://developers.cloudflare.com/workers-ai/get-started/rest-api/
+	AccountID string
+	Token     string
+	// Model is the image classification model labeling images, defaulting to
+	// DefaultCloudflareModel.
+	Model string
+	// CaptionModel is the image-to-text model captioning images (or
+	// answering Options.Question about them, or following Options.Prompt),
+	// if set.
+	CaptionModel string
+}
+
+type cloudflareProvider struct {
+	client              *http.Client
+	token               string
+	url                 string // of the models of the account
+	model, captionModel string
+}
+
+// NewCloudflare returns a Provider backed by models of Cloudflare Workers AI.
+func NewCloudflare(cfg CloudflareConfig) (Provider, error) {
+	if len(cfg.AccountID) == 0 || len(cfg.Token) == 0 {
+		return nil, fmt.Errorf("no account ID or API token provided")
+	}
+	model := cfg.Model
+	if len(model) == 0 {
+		model = DefaultCloudflareModel
+	}
+	// From:
+	// https://developers.cloudflare.com/api/resources/ai/methods/run/
+	url := "https://api.cloudflare.com/client/v4/accounts/" + cfg.AccountID + "/ai/run/"
+	return &cloudflareProvider{http.DefaultClient, cfg.Token, url, model, cfg.CaptionModel}, nil
+}
+
+func (p *cloudflareProvider) Name() string { return "cloudflare" }
+
+func (p *cloudflareProvider) Identity() string {
+	return p.Name() + "/" + p.model + "/" + p.captionModel
+}
+
+func (p *cloudflareProvider) Capabilities() []Feature {
+	return []Feature{FeatureLabels}
+}
+
+func (p *cloudflareProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := p.annotate(ctx, img, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// cloudflareResponse is the envelope of the responses of the API, whose
+// result depends on the model.
+type cloudflareResponse struct {
+	Result  json.RawMessage `json:"result"`
+	Success bool            `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (r cloudflareResponse) err() error {
+	var messages []string
+	for _, e := range r.Errors {
+		messages = append(messages, fmt.Sprintf("%s (%d)", e.Message, e.Code))
+	}
+	return fmt.Errorf("%s", strings.Join(messages, "; "))
+}
+
+func (p *cloudflareProvider) annotate(ctx context.Context, img Image, result *Result, opts Options) error {
+	content, err := img.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	// Classification models take the image as is.
+	body, err := p.run(ctx, p.model, content, "application/octet-stream", img.Name, opts)
+	if err != nil {
+		return err
+	}
+	var labels []struct {
+		Label string  `json:"label"`
+		Score float64 `json:"score"`
+	}
+	if err := json.Unmarshal(body, &labels); err != nil {
+		return fmt.Errorf("failed to decode result of %s: %v", p.model, err)
+	}
+	for _, l := range labels {
+		// ImageNet classes, e.g. "TABBY".
+		result.Labels = append(result.Labels, Label{Description: strings.ToLower(l.Label), Confidence: l.Score})
+	}
+	sortLabels(result.Labels)
+	if len(p.captionModel) == 0 {
+		return nil
+	}
+	prompt := "Describe this image in one sentence."
+	if len(opts.Question) > 0 {
+		prompt = opts.Question
+	} else if len(opts.Prompt) > 0 {
+		prompt = opts.Prompt
+	}
+	// Image-to-text models take the bytes of the image as an array of
+	// numbers.
+	byts := make([]int, len(content))
+	for i, b := range content {
+		byts[i] = int(b)
+	}
+	request, err := json.Marshal(map[string]interface{}{"image": byts, "prompt": prompt, "max_tokens": 256})
+	if err != nil {
+		return err
+	}
+	if body, err = p.run(ctx, p.captionModel, request, "application/json", img.Name, opts); err != nil {
+		return err
+	}
+	var caption struct {
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(body, &caption); err != nil {
+		return fmt.Errorf("failed to decode result of %s: %v", p.captionModel, err)
+	}
+	result.Description = strings.TrimSpace(caption.Description)
+	return nil
+}
+
+// run runs model on body, returning its result.
+func (p *cloudflareProvider) run(ctx context.Context, model string, body []byte, contentType, name string, opts Options) (json.RawMessage, error) {
+	var result json.RawMessage
+	err := withRetries(ctx, opts, func() error {
+		var err error
+		result, err = p.send(ctx, p.url+model, body, contentType, name, opts)
+		return err
+	})
+	return result, err
+}
+
+// send runs a model, returning the result of a successful response.
+func (p *cloudflareProvider) send(ctx context.Context, url string, body []byte, contentType, name string, opts Options) (json.RawMessage, error) {
+	body, _, err := sendHTTP(ctx, p.client, httpRequest{
+		method: "POST",
+		url:    url,
+		body:   body,
+		header: http.Header{"Content-Type": {contentType}, "Authorization": {"Bearer " + p.token}},
+		errorMessage: func(body []byte) string {
+			var e cloudflareResponse
+			if err := json.Unmarshal(body, &e); err != nil || len(e.Errors) == 0 {
+				return ""
+			}
+			return e.err().Error()
+		},
+	}, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	var response cloudflareResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("request failed: %v", response.err())
+	}
+	return response.Result, nil
+}
@@ -0,0 +1,21 @@
+package vision
+
+import "context"
+
+// FaceMatch is a face of a target image of FaceComparer.CompareFaces that is
+// of the same person as the face of the source image.
+type FaceMatch struct {
+	// Bounds of the face, in pixels of the target image.
+	Bounds BoundingBox `json:"bounds"`
+	// Similarity of the face to the face of the source image, in [0, 1].
+	Similarity float64 `json:"similarity"`
+}
+
+// FaceComparer is implemented by Providers that can verify whether faces are
+// of the same person.
+type FaceComparer interface {
+	// CompareFaces compares the largest face of source with each face of
+	// target, returning those whose similarity is at least minSimilarity, most
+	// similar first.
+	CompareFaces(ctx context.Context, source, target Image, minSimilarity float64, opts Options) ([]FaceMatch, error)
+}
@@ -0,0 +1,174 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+type customVisionProvider struct {
+	client *http.Client
+	key    string
+	url    string // of the predictions of the project, with %s for the type and input
+}
+
+// NewCustomVision returns a Provider backed by the published iteration
+// (cfg.CustomVisionIteration) of the Azure Custom Vision project
+// cfg.CustomVisionProject, whose prediction resource is configured by the key,
+// endpoint and region of cfg. Classification projects report labels, and
+// object detection projects objects.
+func NewCustomVision(cfg MicrosoftConfig) (Provider, error) {
+	if len(cfg.Key) == 0 {
+		return nil, fmt.Errorf("no prediction key provided")
+	}
+	if len(cfg.CustomVisionProject) == 0 || len(cfg.CustomVisionIteration) == 0 {
+		return nil, fmt.Errorf("no project or published iteration provided")
+	}
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if len(endpoint) == 0 {
+		if len(cfg.Region) == 0 {
+			return nil, fmt.Errorf("one of the endpoint or region of the resource must be provided")
+		}
+		endpoint = fmt.Sprintf("https://%s.api.cognitive.microsoft.com", cfg.Region)
+	}
+	// From:
+	// https://learn.microsoft.com/en-us/rest/api/customvision/predictions
+	url := fmt.Sprintf("%s/customvision/v3.0/Prediction/%s/%%s/iterations/%s/%%s", endpoint, cfg.CustomVisionProject, cfg.CustomVisionIteration)
+	return &customVisionProvider{http.DefaultClient, cfg.Key, url}, nil
+}
+
+func (p *customVisionProvider) Name() string { return "customvision" }
+
+func (p *customVisionProvider) Identity() string { return p.Name() + "/" + p.url }
+
+func (p *customVisionProvider) Capabilities() []Feature {
+	return []Feature{FeatureLabels, FeatureObjects}
+}
+
+func (p *customVisionProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := img.fetchUnlessHTTP(ctx); err != nil {
+			results[i].Err = err
+			return
+		}
+		if err := p.annotate(ctx, img, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// customVisionPrediction is a prediction of a tag of the project, with the
+// bounds (as fractions of the dimensions of the image) of objects detected.
+type customVisionPrediction struct {
+	Probability float64 `json:"probability"`
+	TagName     string  `json:"tagName"`
+	BoundingBox *struct {
+		Left   float64 `json:"left"`
+		Top    float64 `json:"top"`
+		Width  float64 `json:"width"`
+		Height float64 `json:"height"`
+	} `json:"boundingBox"`
+}
+
+func (p *customVisionProvider) annotate(ctx context.Context, img Image, result *Result, opts Options) error {
+	if opts.Has(FeatureLabels) {
+		predictions, err := p.predict(ctx, "classify", img, opts)
+		if err != nil {
+			return err
+		}
+		for _, pr := range predictions {
+			result.Labels = append(result.Labels, Label{Description: pr.TagName, Confidence: pr.Probability})
+		}
+		sortLabels(result.Labels)
+	}
+	if opts.Has(FeatureObjects) {
+		predictions, err := p.predict(ctx, "detect", img, opts)
+		if err != nil {
+			return err
+		}
+		content, err := img.fetch(ctx)
+		if err != nil {
+			return err
+		}
+		width, height, err := imageSize(content)
+		if err != nil {
+			return err
+		}
+		for _, pr := range predictions {
+			b := pr.BoundingBox
+			if b == nil {
+				continue
+			}
+			result.Objects = append(result.Objects, Label{
+				Description: pr.TagName,
+				Confidence:  pr.Probability,
+				Bounds: &BoundingBox{
+					X:      int(b.Left * float64(width)),
+					Y:      int(b.Top * float64(height)),
+					Width:  int(b.Width * float64(width)),
+					Height: int(b.Height * float64(height)),
+				},
+			})
+		}
+		sortLabels(result.Objects)
+	}
+	return nil
+}
+
+// predict returns the predictions of the classify or detect method of the
+// project for img, which is sent by its URL (which the API fetches itself) or
+// its content.
+func (p *customVisionProvider) predict(ctx context.Context, method string, img Image, opts Options) ([]customVisionPrediction, error) {
+	url := fmt.Sprintf(p.url, method, "image")
+	body, contentType := img.Content, "application/octet-stream"
+	if len(body) == 0 {
+		url = fmt.Sprintf(p.url, method, "url")
+		var err error
+		if body, err = json.Marshal(map[string]string{"Url": img.URI}); err != nil {
+			return nil, err
+		}
+		contentType = "application/json"
+	}
+	var response struct {
+		Predictions []customVisionPrediction `json:"predictions"`
+	}
+	err := withRetries(ctx, opts, func() error {
+		byts, err := p.send(ctx, url, body, contentType, img.Name, opts)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(byts, &response); err != nil {
+			return fmt.Errorf("failed to decode response: %v", err)
+		}
+		return nil
+	})
+	return response.Predictions, err
+}
+
+// send sends a prediction request to url, returning the body of a successful
+// response.
+func (p *customVisionProvider) send(ctx context.Context, url string, body []byte, contentType, name string, opts Options) ([]byte, error) {
+	body, _, err := sendHTTP(ctx, p.client, httpRequest{
+		method:       "POST",
+		url:          url,
+		body:         body,
+		header:       http.Header{"Content-Type": {contentType}, "Prediction-Key": {p.key}},
+		errorMessage: microsoftErrorMessage,
+	}, name, opts)
+	return body, err
+}
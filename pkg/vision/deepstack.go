@@ -0,0 +1,191 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+// DefaultDeepStackHost is the URL of the server used by NewDeepStack if none
+// is configured, that of CodeProject.AI Server.
+const DefaultDeepStackHost = "http://localhost:32168"
+
+// DeepStackConfig configures the DeepStack provider, which annotates images
+// with a self-hosted server speaking the REST API of DeepStack (e.g.
+// DeepStack itself or CodeProject.AI Server), so that images never leave the
+// network.
+type DeepStackConfig struct {
+	// Host is the URL of the server, defaulting to DefaultDeepStackHost. The
+	// scheme defaults to http.
+	Host string
+	// APIKey, if set, is the key the server was started with (DeepStack's
+	// API-KEY).
+	APIKey string
+}
+
+type deepStackProvider struct {
+	client *http.Client
+	url    string
+	apiKey string
+}
+
+// NewDeepStack returns a Provider backed by a DeepStack-compatible server,
+// which detects objects (/v1/vision/detection) and faces (/v1/vision/face).
+func NewDeepStack(cfg DeepStackConfig) (Provider, error) {
+	host := strings.TrimSuffix(cfg.Host, "/")
+	if len(host) == 0 {
+		host = DefaultDeepStackHost
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return &deepStackProvider{http.DefaultClient, host + "/v1/vision/", cfg.APIKey}, nil
+}
+
+func (p *deepStackProvider) Name() string { return "deepstack" }
+
+// Identity includes the server, whose models may differ from those of others.
+func (p *deepStackProvider) Identity() string {
+	return p.Name() + "/" + strings.TrimSuffix(p.url, "/v1/vision/")
+}
+
+func (p *deepStackProvider) Capabilities() []Feature {
+	return []Feature{FeatureObjects, FeatureFaces}
+}
+
+func (p *deepStackProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := p.annotate(ctx, img, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// deepStackResponse is the response of the detection and face endpoints,
+// whose predictions are in pixels.
+type deepStackResponse struct {
+	Success     bool   `json:"success"`
+	Error       string `json:"error"`
+	Predictions []struct {
+		Label      string  `json:"label"`
+		Confidence float64 `json:"confidence"`
+		XMin       int     `json:"x_min"`
+		YMin       int     `json:"y_min"`
+		XMax       int     `json:"x_max"`
+		YMax       int     `json:"y_max"`
+	} `json:"predictions"`
+}
+
+func (p *deepStackProvider) annotate(ctx context.Context, img Image, result *Result, opts Options) error {
+	content, err := img.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	// From:
+	// https://docs.deepstack.cc/object-detection/
+	if opts.Has(FeatureObjects) {
+		response, err := p.detect(ctx, "detection", content, img.Name, opts)
+		if err != nil {
+			return err
+		}
+		for _, d := range response.Predictions {
+			result.Objects = append(result.Objects, Label{
+				Description: d.Label,
+				Confidence:  d.Confidence,
+				Bounds:      &BoundingBox{X: d.XMin, Y: d.YMin, Width: d.XMax - d.XMin, Height: d.YMax - d.YMin},
+			})
+		}
+		sortLabels(result.Objects)
+	}
+	// https://docs.deepstack.cc/face-detection/
+	if opts.Has(FeatureFaces) {
+		response, err := p.detect(ctx, "face", content, img.Name, opts)
+		if err != nil {
+			return err
+		}
+		for _, d := range response.Predictions {
+			result.Faces = append(result.Faces, Face{
+				Bounds:     BoundingBox{X: d.XMin, Y: d.YMin, Width: d.XMax - d.XMin, Height: d.YMax - d.YMin},
+				Confidence: d.Confidence,
+			})
+		}
+	}
+	return nil
+}
+
+// detect returns the predictions of endpoint for the image content.
+func (p *deepStackProvider) detect(ctx context.Context, endpoint string, content []byte, name string, opts Options) (deepStackResponse, error) {
+	var response deepStackResponse
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	f, err := w.CreateFormFile("image", name)
+	if err != nil {
+		return response, err
+	}
+	f.Write(content)
+	if len(p.apiKey) > 0 {
+		w.WriteField("api_key", p.apiKey)
+	}
+	if opts.MinConfidence > 0 {
+		// Otherwise, the server's default (0.4) applies.
+		w.WriteField("min_confidence", strconv.FormatFloat(opts.MinConfidence, 'f', -1, 64))
+	}
+	if err := w.Close(); err != nil {
+		return response, err
+	}
+	err = withRetries(ctx, opts, func() error {
+		byts, err := p.send(ctx, p.url+endpoint, body.Bytes(), w.FormDataContentType(), name, opts)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(byts, &response); err != nil {
+			return fmt.Errorf("failed to decode response: %v", err)
+		}
+		return nil
+	})
+	if err == nil && !response.Success {
+		err = fmt.Errorf("%s failed: %s", endpoint, response.Error)
+	}
+	return response, err
+}
+
+// send posts a form to the endpoint url. As for Ollama, failures to connect
+// are typically as the server is not running, and 404s and 401s as the
+// endpoint is not enabled (e.g. DeepStack started without VISION-FACE=True)
+// or the API key is wrong.
+func (p *deepStackProvider) send(ctx context.Context, url string, body []byte, contentType, name string, opts Options) ([]byte, error) {
+	body, _, err := sendHTTP(ctx, p.client, httpRequest{
+		method: "POST",
+		url:    url,
+		body:   body,
+		header: http.Header{"Content-Type": {contentType}},
+		errorMessage: func(body []byte) string {
+			var e deepStackResponse
+			json.Unmarshal(body, &e)
+			return e.Error
+		},
+		serviceStatus: func(code int) bool {
+			return code == http.StatusNotFound || code == http.StatusUnauthorized
+		},
+		unreachable: "is the server running?",
+	}, name, opts)
+	return body, err
+}
@@ -0,0 +1,40 @@
+package vision
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+// File is a multi-page document, such as a scanned PDF, to be annotated.
+type File struct {
+	// Name identifies the file (typically the filename) in results.
+	Name string
+	// Content of the file.
+	Content []byte
+	// MIMEType of the file, e.g. "application/pdf" or "image/tiff".
+	MIMEType string
+}
+
+// FileProvider is implemented by Providers that can annotate multi-page files.
+// The single Result of each file has its text and the layout of every page
+// (Result.Document) filled in.
+type FileProvider interface {
+	// AnnotateFiles annotates files, staging them and their results in the
+	// storage bucket (and optional prefix, e.g. "bucket/tmp") as needed.
+	AnnotateFiles(ctx context.Context, files []File, bucket string, opts Options) ([]Result, error)
+}
+
+// fileMIMETypes are the types of files supported by FileProvider, by
+// extension.
+var fileMIMETypes = map[string]string{
+	".pdf":  "application/pdf",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+}
+
+// FileMIMEType returns the MIME type of filename if it is a multi-page file
+// supported by FileProvider, and "" otherwise.
+func FileMIMEType(filename string) string {
+	return fileMIMETypes[strings.ToLower(filepath.Ext(filename))]
+}
@@ -0,0 +1,174 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+// DefaultGeminiModel is the model used by NewGemini if none is configured.
+const DefaultGeminiModel = "gemini-2.5-flash"
+
+// GeminiConfig configures the Gemini provider, which annotates images by
+// prompting a multimodal model of the Generative Language API.
+type GeminiConfig struct {
+	// APIKey of the Generative Language API, see
+	// https://ai.google.dev/gemini-api/docs/api-key
+	APIKey string
+	// Model to prompt, defaulting to DefaultGeminiModel.
+	Model string
+}
+
+type geminiProvider struct {
+	client *http.Client
+	key    string
+	url    string
+}
+
+// NewGemini returns a Provider backed by a Gemini model, which captions images
+// (Result.Description), or answers Options.Question about them, and reports
+// labels, text and objects in the same form as the other providers. Unlike
+// theirs, its confidences are estimated by the model itself.
+func NewGemini(cfg GeminiConfig) (Provider, error) {
+	if len(cfg.APIKey) == 0 {
+		return nil, fmt.Errorf("no API key provided")
+	}
+	model := cfg.Model
+	if len(model) == 0 {
+		model = DefaultGeminiModel
+	}
+	// From:
+	// https://ai.google.dev/api/generate-content#method:-models.generatecontent
+	url := "https://generativelanguage.googleapis.com/v1beta/models/" + strings.TrimPrefix(model, "models/") + ":generateContent"
+	return &geminiProvider{http.DefaultClient, cfg.APIKey, url}, nil
+}
+
+func (p *geminiProvider) Name() string { return "gemini" }
+
+func (p *geminiProvider) Identity() string { return p.Name() + "/" + p.url }
+
+func (p *geminiProvider) Capabilities() []Feature {
+	return []Feature{FeatureLabels, FeatureText, FeatureObjects}
+}
+
+func (p *geminiProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	prompt := modelPrompt(opts)
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := p.annotate(ctx, img, prompt, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// geminiResponse is the subset of the generateContent response used here.
+type geminiResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+}
+
+// geminiError is the body of failed responses.
+type geminiError struct {
+	Error struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+func (p *geminiProvider) annotate(ctx context.Context, img Image, prompt string, result *Result, opts Options) error {
+	// The API only fetches files it stores itself, so images are sent inline.
+	content, err := img.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	request := map[string]interface{}{
+		"contents": []interface{}{
+			map[string]interface{}{
+				"parts": []interface{}{
+					map[string]interface{}{"inline_data": map[string]interface{}{
+						"mime_type": http.DetectContentType(content),
+						"data":      content,
+					}},
+					map[string]interface{}{"text": prompt},
+				},
+			},
+		},
+		"generationConfig": map[string]interface{}{
+			"responseMimeType": "application/json",
+			"temperature":      0,
+		},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	var response []byte
+	if err := withRetries(ctx, opts, func() error {
+		var err error
+		response, err = p.send(ctx, body, img.Name, opts)
+		return err
+	}); err != nil {
+		return err
+	}
+	var generated geminiResponse
+	if err := json.Unmarshal(response, &generated); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	if f := generated.PromptFeedback; f != nil && len(f.BlockReason) > 0 {
+		return fmt.Errorf("image blocked by Gemini: %s", f.BlockReason)
+	}
+	if len(generated.Candidates) == 0 || len(generated.Candidates[0].Content.Parts) == 0 {
+		return fmt.Errorf("no response from Gemini")
+	}
+	candidate := generated.Candidates[0]
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
+	}
+	annotation, err := decodeModelAnnotation(text.String())
+	if err != nil {
+		return fmt.Errorf("failed to decode response of Gemini (finish reason %s): %v", candidate.FinishReason, err)
+	}
+	return annotation.fill(content, result, opts)
+}
+
+// send sends a single request, returning the body of a successful response.
+func (p *geminiProvider) send(ctx context.Context, body []byte, name string, opts Options) ([]byte, error) {
+	body, _, err := sendHTTP(ctx, p.client, httpRequest{
+		method: "POST",
+		url:    p.url,
+		body:   body,
+		header: http.Header{"Content-Type": {"application/json"}, "X-Goog-Api-Key": {p.key}},
+		errorMessage: func(body []byte) string {
+			var e geminiError
+			if err := json.Unmarshal(body, &e); err != nil || len(e.Error.Message) == 0 {
+				return ""
+			}
+			return fmt.Sprintf("%s (%s)", e.Error.Message, e.Error.Status)
+		},
+	}, name, opts)
+	return body, err
+}
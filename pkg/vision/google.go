@@ -0,0 +1,600 @@
+package vision
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/googleapi/transport"
+	gvision "google.golang.org/api/vision/v1"
+)
+
+// 8 MB per request size limit as per:
+// https://cloud.google.com/vision/docs/best-practices#file_sizes
+const googleMaxRequestBytes = 8 << 20
+
+// 16 images per request limit as per:
+// https://cloud.google.com/vision/quotas
+const googleMaxRequestImages = 16
+
+var googleFeatureTypes = map[Feature]string{
+	FeatureLabels:     "LABEL_DETECTION",
+	FeatureText:       "TEXT_DETECTION",
+	FeatureFaces:      "FACE_DETECTION",
+	FeatureLandmarks:  "LANDMARK_DETECTION",
+	FeatureLogos:      "LOGO_DETECTION",
+	FeatureSafeSearch: "SAFE_SEARCH_DETECTION",
+	FeatureWeb:        "WEB_DETECTION",
+	FeatureObjects:    "OBJECT_LOCALIZATION",
+	FeatureCropHints:  "CROP_HINTS",
+	FeatureColors:     "IMAGE_PROPERTIES",
+	FeatureDocument:   "DOCUMENT_TEXT_DETECTION",
+}
+
+// googleServiceCodes are the google.rpc.Code values of per-image errors that
+// are failures of the service rather than of the image, see:
+// https://github.com/googleapis/googleapis/blob/master/google/rpc/code.proto
+var googleServiceCodes = map[int64]bool{
+	7:  true, // PERMISSION_DENIED
+	8:  true, // RESOURCE_EXHAUSTED
+	13: true, // INTERNAL
+	14: true, // UNAVAILABLE
+	16: true, // UNAUTHENTICATED
+}
+
+type googleProvider struct {
+	client  *http.Client
+	service *gvision.Service
+}
+
+// GoogleConfig configures the authentication of the Google Cloud Vision API
+// provider, which by default uses Application Default Credentials.
+type GoogleConfig struct {
+	// APIKey, if set, authenticates requests with an API key instead. Only
+	// public images can be read from Cloud Storage with an API key.
+	APIKey string
+	// CredentialsFile, if set, is the path of a service account (or other
+	// credentials) JSON file to use instead.
+	CredentialsFile string
+	// Model is the Vertex AI endpoint
+	// ("projects/PROJECT/locations/LOCATION/endpoints/ENDPOINT") of a
+	// custom-trained model used by NewVertex.
+	Model string
+}
+
+// NewGoogle returns a Provider backed by the Google Cloud Vision API, using
+// Application Default Credentials.
+func NewGoogle(ctx context.Context) (Provider, error) {
+	return NewGoogleWithConfig(ctx, GoogleConfig{})
+}
+
+// NewGoogleWithConfig returns a Provider backed by the Google Cloud Vision API,
+// authenticated as per cfg.
+func NewGoogleWithConfig(ctx context.Context, cfg GoogleConfig) (Provider, error) {
+	client, err := googleClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	service, err := gvision.New(client)
+	if err != nil {
+		return nil, err
+	}
+	return &googleProvider{client, service}, nil
+}
+
+func googleClient(ctx context.Context, cfg GoogleConfig) (*http.Client, error) {
+	switch {
+	case len(cfg.APIKey) > 0 && len(cfg.CredentialsFile) > 0:
+		return nil, fmt.Errorf("only one of an API key and a credentials file can be used")
+	case len(cfg.APIKey) > 0:
+		return &http.Client{Transport: &transport.APIKey{Key: cfg.APIKey}}, nil
+	case len(cfg.CredentialsFile) > 0:
+		byts, err := ioutil.ReadFile(cfg.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		creds, err := google.CredentialsFromJSON(ctx, byts, gvision.CloudPlatformScope)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credentials in %s: %v", cfg.CredentialsFile, err)
+		}
+		return oauth2.NewClient(ctx, creds.TokenSource), nil
+	default:
+		return google.DefaultClient(ctx, gvision.CloudPlatformScope)
+	}
+}
+
+// fetch returns the content of img, reading gs:// objects with the credentials
+// of p.
+func (p *googleProvider) fetch(ctx context.Context, img Image) ([]byte, error) {
+	if len(img.Content) > 0 || !strings.HasPrefix(img.URI, "gs://") {
+		return img.fetch(ctx)
+	}
+	return downloadGCSWith(ctx, p.client, img.URI)
+}
+
+func (p *googleProvider) Name() string { return "google" }
+
+func (p *googleProvider) Capabilities() []Feature {
+	var features []Feature
+	for _, f := range AllFeatures {
+		if _, ok := googleFeatureTypes[f]; ok {
+			features = append(features, f)
+		}
+	}
+	return features
+}
+
+func (p *googleProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	var features []*gvision.Feature
+	for _, f := range opts.RequestedFeatures() {
+		t, ok := googleFeatureTypes[f]
+		if !ok {
+			return nil, fmt.Errorf("feature %q is not supported by %s", f, p.Name())
+		}
+		feature := &gvision.Feature{Type: t}
+		switch f {
+		case FeatureLabels, FeatureLandmarks, FeatureLogos, FeatureObjects:
+			// Not limiting faces, web entities and the like, as per
+			// Options.MaxResults.
+			feature.MaxResults = int64(opts.MaxResults)
+		}
+		features = append(features, feature)
+	}
+	results := make([]Result, len(images))
+	images = append([]Image(nil), images...)
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		results[i].Name = images[i].Name
+		// Cloud Vision fetches http(s) URLs and gs:// objects itself, others
+		// (s3://) are downloaded first.
+		if uri := images[i].URI; len(images[i].Content) > 0 || len(uri) == 0 || isHTTPURL(uri) || strings.HasPrefix(uri, "gs://") {
+			return
+		}
+		var err error
+		if images[i].Content, err = images[i].fetch(ctx); err != nil {
+			results[i].Err = err
+			opts.Stats.addImages(1)
+		}
+	})
+	// Split images into batches of at most googleMaxRequestBytes and
+	// googleMaxRequestImages (or those of opts), which are then encoded and
+	// sent concurrently.
+	maxBytes, maxCount := googleMaxRequestBytes, googleMaxRequestImages
+	if opts.BatchBytes > 0 {
+		maxBytes = opts.BatchBytes
+	}
+	if opts.BatchCount > 0 {
+		maxCount = opts.BatchCount
+	}
+	var (
+		batches [][]int // indices into images
+		batch   []int
+		size    = 0
+	)
+	for i, img := range images {
+		if results[i].Err != nil {
+			continue
+		}
+		if len(batch) > 0 && (size+len(img.Content) > maxBytes || len(batch) == maxCount) {
+			batches = append(batches, batch)
+			batch = nil
+			size = 0
+		}
+		batch = append(batch, i)
+		size += len(img.Content)
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	// The other fields of each request, which are the same for every image.
+	rest := &gvision.AnnotateImageRequest{Features: features}
+	if opts.Has(FeatureCropHints) && len(opts.CropAspectRatios) > 0 {
+		rest.ImageContext = &gvision.ImageContext{CropHintsParams: &gvision.CropHintsParams{AspectRatios: opts.CropAspectRatios}}
+	}
+	if (opts.Has(FeatureText) || opts.Has(FeatureDocument)) && len(opts.LanguageHints) > 0 {
+		if rest.ImageContext == nil {
+			rest.ImageContext = &gvision.ImageContext{}
+		}
+		rest.ImageContext.LanguageHints = opts.LanguageHints
+	}
+	restJSON, err := json.Marshal(rest)
+	if err != nil {
+		return nil, err
+	}
+	parallel.For(len(batches), opts.Concurrency, func(b int) {
+		batch := &googleBatch{rest: restJSON}
+		for _, i := range batches[b] {
+			batch.images = append(batch.images, images[i])
+		}
+		p.execute(ctx, batch, batches[b], results, opts)
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// execute sends a single batch request, filling in the results of the images
+// at indices (which must be of the same length as batch.images).
+//
+// If the request is rejected (rather than failing because of the service),
+// e.g. because of one image that the API cannot decode, the batch is split in
+// halves that are sent in turn, so that only the offending images fail.
+func (p *googleProvider) execute(ctx context.Context, batch *googleBatch, indices []int, results []Result, opts Options) {
+	var size int64
+	for _, img := range batch.images {
+		size += int64(len(img.Content))
+	}
+	var response *gvision.BatchAnnotateImagesResponse
+	err := withRetries(ctx, opts, func() error {
+		opts.Stats.addRequest(size)
+		var err error
+		response, err = p.send(ctx, batch)
+		if e, ok := err.(*googleapi.Error); ok && isRetryableStatus(e.Code) {
+			return &retryableError{err, parseRetryAfter(e.Header)}
+		}
+		return err
+	})
+	if err != nil {
+		e, ok := err.(*googleapi.Error)
+		rejected := ok && !isServiceStatus(e.Code)
+		if rejected && len(indices) > 1 {
+			half := len(indices) / 2
+			for _, h := range [][2]int{{0, half}, {half, len(indices)}} {
+				p.execute(ctx, &googleBatch{images: batch.images[h[0]:h[1]], rest: batch.rest}, indices[h[0]:h[1]], results, opts)
+			}
+			return
+		}
+		// Including errors that persisted through retries, and those of the
+		// connection or credentials.
+		var failure error = &ServiceError{fmt.Errorf("Cloud Vision API request failed: %v", err)}
+		if rejected {
+			failure = fmt.Errorf("Cloud Vision API rejected %s: %v", batch.images[0].Name, err)
+		}
+		for _, i := range indices {
+			results[i].Err = failure
+		}
+		opts.Stats.addImages(len(indices))
+		return
+	}
+	defer opts.Stats.addImages(len(indices))
+	if opts.Verbose {
+		txt, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			log.Printf("%+v\n", response)
+		} else {
+			log.Printf("%s\n", txt)
+		}
+	}
+	for j, r := range response.Responses {
+		i := indices[j]
+		if r.Error != nil {
+			results[i].Err = fmt.Errorf("Cloud Vision API error %d: %s", r.Error.Code, r.Error.Message)
+			if googleServiceCodes[r.Error.Code] {
+				results[i].Err = &ServiceError{results[i].Err}
+			}
+			continue
+		}
+		if err := p.fillResult(ctx, &results[i], batch.images[j], r, opts); err != nil {
+			results[i].Err = err
+		}
+	}
+}
+
+// googleBatch is a batch request of images, which is streamed as it is sent
+// rather than marshaled, which would hold the base64 encoding of the images
+// and then the request in memory at once.
+type googleBatch struct {
+	images []Image
+	// rest is the JSON encoding of the fields other than the image of each
+	// AnnotateImageRequest.
+	rest []byte
+}
+
+// write writes the JSON encoding of the BatchAnnotateImagesRequest of b.
+func (b *googleBatch) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`{"requests":[`)
+	for i, img := range b.images {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		bw.WriteString(`{"image":`)
+		if len(img.Content) > 0 {
+			bw.WriteString(`{"content":"`)
+			enc := base64.NewEncoder(base64.StdEncoding, bw)
+			enc.Write(img.Content)
+			enc.Close()
+			bw.WriteString(`"}`)
+		} else {
+			source, err := json.Marshal(&gvision.Image{Source: &gvision.ImageSource{ImageUri: img.URI}})
+			if err != nil {
+				return err
+			}
+			bw.Write(source)
+		}
+		// rest is an object with at least the features, whose fields follow
+		// the image.
+		bw.WriteByte(',')
+		bw.Write(b.rest[1:])
+	}
+	bw.WriteString(`]}`)
+	return bw.Flush()
+}
+
+// send sends the request of batch, equivalently to p.service.Images.Annotate,
+// streaming the request and response.
+func (p *googleProvider) send(ctx context.Context, batch *googleBatch) (*gvision.BatchAnnotateImagesResponse, error) {
+	pr, pw := io.Pipe()
+	// Closed by the transport once the request is sent (or fails), which stops
+	// the writer.
+	go func() { pw.CloseWithError(batch.write(pw)) }()
+	req, err := http.NewRequest("POST", p.service.BasePath+"v1/images:annotate", pr)
+	if err != nil {
+		pr.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return nil, err
+	}
+	response := &gvision.BatchAnnotateImagesResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (p *googleProvider) fillResult(ctx context.Context, result *Result, img Image, r *gvision.AnnotateImageResponse, opts Options) error {
+	result.Labels = googleLabels(r.LabelAnnotations)
+	if r.FullTextAnnotation != nil {
+		result.Text = r.FullTextAnnotation.Text
+		for _, page := range r.FullTextAnnotation.Pages {
+			for _, b := range page.Blocks {
+				bounds := googleBoundingBox(b.BoundingBox)
+				result.TextBlocks = append(result.TextBlocks, Label{Description: googleBlockText(b), Confidence: b.Confidence, Bounds: &bounds})
+			}
+		}
+		if opts.Has(FeatureDocument) {
+			result.Document = googleDocument(r.FullTextAnnotation)
+		}
+	} else if len(r.TextAnnotations) > 0 {
+		// The first annotation is the entire extracted text.
+		result.Text = r.TextAnnotations[0].Description
+	}
+	for _, f := range r.FaceAnnotations {
+		face := Face{
+			Bounds:     googleBoundingBox(f.BoundingPoly),
+			Confidence: f.DetectionConfidence,
+			Emotions: map[string]float64{
+				"joy":      googleLikelihood(f.JoyLikelihood),
+				"sorrow":   googleLikelihood(f.SorrowLikelihood),
+				"anger":    googleLikelihood(f.AngerLikelihood),
+				"surprise": googleLikelihood(f.SurpriseLikelihood),
+			},
+		}
+		for _, l := range f.Landmarks {
+			if l.Position != nil {
+				face.Landmarks = append(face.Landmarks, FaceLandmark{Type: l.Type, X: int(l.Position.X), Y: int(l.Position.Y)})
+			}
+		}
+		result.Faces = append(result.Faces, face)
+	}
+	result.Landmarks = googleLabels(r.LandmarkAnnotations)
+	result.Logos = googleLabels(r.LogoAnnotations)
+	if s := r.SafeSearchAnnotation; s != nil {
+		result.SafeSearch = &SafeSearch{
+			Adult:    googleLikelihood(s.Adult),
+			Racy:     googleLikelihood(s.Racy),
+			Violence: googleLikelihood(s.Violence),
+			Medical:  googleLikelihood(s.Medical),
+			Spoof:    googleLikelihood(s.Spoof),
+		}
+	}
+	if w := r.WebDetection; w != nil {
+		result.Web = &WebDetection{}
+		for _, l := range w.BestGuessLabels {
+			result.Web.BestGuessLabels = append(result.Web.BestGuessLabels, l.Label)
+		}
+		for _, e := range w.WebEntities {
+			if len(e.Description) > 0 {
+				result.Web.Entities = append(result.Web.Entities, Label{Description: e.Description, Confidence: e.Score})
+			}
+		}
+		sortLabels(result.Web.Entities)
+		result.Web.FullMatchingImages = googleWebImages(w.FullMatchingImages)
+		result.Web.PartialMatchingImages = googleWebImages(w.PartialMatchingImages)
+		result.Web.SimilarImages = googleWebImages(w.VisuallySimilarImages)
+		for _, p := range w.PagesWithMatchingImages {
+			// Titles may contain HTML markup, e.g. <b> for matching terms.
+			result.Web.Pages = append(result.Web.Pages, WebPage{URL: p.Url, Title: html.UnescapeString(htmlTagRE.ReplaceAllString(p.PageTitle, ""))})
+		}
+	}
+	if p := r.ImagePropertiesAnnotation; p != nil && p.DominantColors != nil {
+		for _, c := range p.DominantColors.Colors {
+			if c.Color == nil {
+				continue
+			}
+			result.Colors = append(result.Colors, Color{
+				Hex:      fmt.Sprintf("#%02x%02x%02x", int(c.Color.Red), int(c.Color.Green), int(c.Color.Blue)),
+				Fraction: c.PixelFraction,
+				Score:    c.Score,
+			})
+		}
+	}
+	if c := r.CropHintsAnnotation; c != nil {
+		for _, h := range c.CropHints {
+			result.CropHints = append(result.CropHints, CropHint{
+				Bounds:             googleBoundingBox(h.BoundingPoly),
+				Confidence:         h.Confidence,
+				ImportanceFraction: h.ImportanceFraction,
+			})
+		}
+	}
+	if len(r.LocalizedObjectAnnotations) > 0 {
+		// Object bounds are normalized to [0, 1].
+		content, err := p.fetch(ctx, img)
+		if err != nil {
+			return err
+		}
+		width, height, err := imageSize(content)
+		if err != nil {
+			return err
+		}
+		for _, o := range r.LocalizedObjectAnnotations {
+			l := Label{Description: o.Name, Confidence: o.Score}
+			if o.BoundingPoly != nil {
+				b := googleNormalizedBoundingBox(o.BoundingPoly.NormalizedVertices, width, height)
+				l.Bounds = &b
+			}
+			result.Objects = append(result.Objects, l)
+		}
+		sortLabels(result.Objects)
+	}
+	return nil
+}
+
+func googleDocument(t *gvision.TextAnnotation) *Document {
+	doc := &Document{}
+	for _, p := range t.Pages {
+		page := Page{Width: int(p.Width), Height: int(p.Height)}
+		for _, b := range p.Blocks {
+			block := Block{Bounds: googleBoundingBox(b.BoundingBox), Confidence: b.Confidence}
+			for _, p := range b.Paragraphs {
+				paragraph := Paragraph{Bounds: googleBoundingBox(p.BoundingBox), Confidence: p.Confidence}
+				for _, w := range p.Words {
+					var text strings.Builder
+					for _, s := range w.Symbols {
+						text.WriteString(s.Text)
+					}
+					paragraph.Words = append(paragraph.Words, Word{Text: text.String(), Bounds: googleBoundingBox(w.BoundingBox), Confidence: w.Confidence})
+				}
+				block.Paragraphs = append(block.Paragraphs, paragraph)
+			}
+			page.Blocks = append(page.Blocks, block)
+		}
+		doc.Pages = append(doc.Pages, page)
+	}
+	return doc
+}
+
+// googleBlockText returns the text of a block, with words separated by spaces
+// and paragraphs by newlines.
+func googleBlockText(b *gvision.Block) string {
+	var paragraphs []string
+	for _, p := range b.Paragraphs {
+		var words []string
+		for _, w := range p.Words {
+			var word strings.Builder
+			for _, s := range w.Symbols {
+				word.WriteString(s.Text)
+			}
+			words = append(words, word.String())
+		}
+		paragraphs = append(paragraphs, strings.Join(words, " "))
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+var htmlTagRE = regexp.MustCompile(`<[^>]*>`)
+
+func googleWebImages(images []*gvision.WebImage) []string {
+	var urls []string
+	for _, img := range images {
+		urls = append(urls, img.Url)
+	}
+	return urls
+}
+
+func googleLabels(annotations []*gvision.EntityAnnotation) []Label {
+	var labels []Label
+	for _, a := range annotations {
+		l := Label{Description: a.Description, Confidence: a.Score}
+		if a.BoundingPoly != nil {
+			b := googleBoundingBox(a.BoundingPoly)
+			l.Bounds = &b
+		}
+		for _, loc := range a.Locations {
+			if loc.LatLng != nil {
+				l.Location = &LatLng{Latitude: loc.LatLng.Latitude, Longitude: loc.LatLng.Longitude}
+				break
+			}
+		}
+		labels = append(labels, l)
+	}
+	sortLabels(labels)
+	return labels
+}
+
+// googleBoundingBox returns the smallest rectangle enclosing poly. Vertices
+// with a coordinate of 0 are omitted from the JSON response, so missing
+// vertices are treated as the origin.
+func googleBoundingBox(poly *gvision.BoundingPoly) BoundingBox {
+	if poly == nil || len(poly.Vertices) == 0 {
+		return BoundingBox{}
+	}
+	minX, minY, maxX, maxY := int64(math.MaxInt64), int64(math.MaxInt64), int64(0), int64(0)
+	for _, v := range poly.Vertices {
+		var x, y int64
+		if v != nil {
+			x, y = v.X, v.Y
+		}
+		minX, minY = min(minX, x), min(minY, y)
+		maxX, maxY = max(maxX, x), max(maxY, y)
+	}
+	return BoundingBox{X: int(minX), Y: int(minY), Width: int(maxX - minX), Height: int(maxY - minY)}
+}
+
+func googleNormalizedBoundingBox(vertices []*gvision.NormalizedVertex, width, height int) BoundingBox {
+	if len(vertices) == 0 {
+		return BoundingBox{}
+	}
+	minX, minY, maxX, maxY := 1., 1., 0., 0.
+	for _, v := range vertices {
+		var x, y float64
+		if v != nil {
+			x, y = v.X, v.Y
+		}
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	return BoundingBox{
+		X:      int(minX * float64(width)),
+		Y:      int(minY * float64(height)),
+		Width:  int((maxX - minX) * float64(width)),
+		Height: int((maxY - minY) * float64(height)),
+	}
+}
+
+// googleLikelihood maps the Likelihood enum to the range [0, 1].
+func googleLikelihood(l string) float64 {
+	switch l {
+	case "VERY_UNLIKELY":
+		return 0
+	case "UNLIKELY":
+		return 0.25
+	case "POSSIBLE":
+		return 0.5
+	case "LIKELY":
+		return 0.75
+	case "VERY_LIKELY":
+		return 1
+	}
+	return 0
+}
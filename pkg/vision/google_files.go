@@ -0,0 +1,214 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	storage "google.golang.org/api/storage/v1"
+	gvision "google.golang.org/api/vision/v1"
+)
+
+// googleOperationPollInterval is the delay between checks of the status of
+// asynchronous file annotation.
+const googleOperationPollInterval = 5 * time.Second
+
+// AnnotateFiles uploads files to Google Cloud Storage and annotates them with
+// files:asyncBatchAnnotate, as per:
+// https://cloud.google.com/vision/docs/pdf
+// Only FeatureText and FeatureDocument are supported. The uploaded files and
+// results are deleted once downloaded.
+func (p *googleProvider) AnnotateFiles(ctx context.Context, files []File, bucket string, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, FeatureText, FeatureDocument); err != nil {
+		return nil, err
+	}
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(bucket, "gs://"), "/")
+	if len(bucket) == 0 {
+		return nil, fmt.Errorf("no Cloud Storage bucket to stage files in")
+	}
+	gcs, err := storage.New(p.client)
+	if err != nil {
+		return nil, err
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	prefix = path.Join(prefix, "visionapi-"+hex.EncodeToString(id[:]))
+	defer p.deleteObjects(gcs, bucket, prefix)
+
+	// The features that apply to files are all text detection, so
+	// FeatureText is served by DOCUMENT_TEXT_DETECTION too.
+	features := []*gvision.Feature{{Type: googleFeatureTypes[FeatureDocument]}}
+	var (
+		results = make([]Result, len(files))
+		request = &gvision.AsyncBatchAnnotateFilesRequest{}
+		outputs = make([]string, len(files))
+		indices []int // into files, of each of request.Requests
+	)
+	for i, f := range files {
+		results[i].Name = f.Name
+		input := path.Join(prefix, fmt.Sprintf("%d%s", i, path.Ext(f.Name)))
+		if _, err := gcs.Objects.Insert(bucket, &storage.Object{Name: input}).Media(bytes.NewReader(f.Content)).Context(ctx).Do(); err != nil {
+			results[i].Err = fmt.Errorf("upload to gs://%s/%s failed: %v", bucket, input, err)
+			opts.Stats.addImages(1)
+			continue
+		}
+		opts.Stats.addRequest(int64(len(f.Content)))
+		outputs[i] = path.Join(prefix, fmt.Sprintf("output-%d", i)) + "/"
+		request.Requests = append(request.Requests, &gvision.AsyncAnnotateFileRequest{
+			Features:     features,
+			InputConfig:  &gvision.InputConfig{GcsSource: &gvision.GcsSource{Uri: "gs://" + bucket + "/" + input}, MimeType: f.MIMEType},
+			OutputConfig: &gvision.OutputConfig{GcsDestination: &gvision.GcsDestination{Uri: "gs://" + bucket + "/" + outputs[i]}},
+		})
+		indices = append(indices, i)
+	}
+	if len(indices) == 0 {
+		return results, nil
+	}
+	if err := p.runFileOperation(ctx, request, opts); err != nil {
+		for _, i := range indices {
+			results[i].Err = err
+		}
+		opts.Stats.addImages(len(indices))
+		return results, nil
+	}
+	for _, i := range indices {
+		if err := p.downloadFileResult(ctx, gcs, bucket, outputs[i], &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+		opts.Stats.addImages(1)
+	}
+	return results, nil
+}
+
+// runFileOperation starts the asynchronous annotation and waits for it to
+// complete.
+func (p *googleProvider) runFileOperation(ctx context.Context, request *gvision.AsyncBatchAnnotateFilesRequest, opts Options) error {
+	var op *gvision.Operation
+	err := withRetries(ctx, opts, func() error {
+		var err error
+		op, err = p.service.Files.AsyncBatchAnnotate(request).Context(ctx).Do()
+		if e, ok := err.(*googleapi.Error); ok && isRetryableStatus(e.Code) {
+			return &retryableError{err, parseRetryAfter(e.Header)}
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Cloud Vision API request failed: %v", err)
+	}
+	for !op.Done {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(googleOperationPollInterval):
+		}
+		name := op.Name
+		err := withRetries(ctx, opts, func() error {
+			var err error
+			op, err = p.service.Operations.Get(name).Context(ctx).Do()
+			if e, ok := err.(*googleapi.Error); ok && isRetryableStatus(e.Code) {
+				return &retryableError{err, parseRetryAfter(e.Header)}
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Cloud Vision API operation %s failed: %v", name, err)
+		}
+	}
+	if op.Error != nil {
+		return fmt.Errorf("Cloud Vision API error %d: %s", op.Error.Code, op.Error.Message)
+	}
+	return nil
+}
+
+// downloadFileResult fills in result from the responses written under prefix,
+// in batches of pages.
+func (p *googleProvider) downloadFileResult(ctx context.Context, gcs *storage.Service, bucket, prefix string, result *Result, opts Options) error {
+	var responses []*gvision.AnnotateImageResponse
+	err := gcs.Objects.List(bucket).Prefix(prefix).Pages(ctx, func(objects *storage.Objects) error {
+		for _, o := range objects.Items {
+			resp, err := gcs.Objects.Get(bucket, o.Name).Context(ctx).Download()
+			if err != nil {
+				return err
+			}
+			byts, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return err
+			}
+			if opts.Verbose {
+				log.Printf("%s: %s\n", result.Name, byts)
+			}
+			var f gvision.AnnotateFileResponse
+			if err := json.Unmarshal(byts, &f); err != nil {
+				return fmt.Errorf("failed to decode gs://%s/%s: %v", bucket, o.Name, err)
+			}
+			if f.Error != nil {
+				return fmt.Errorf("Cloud Vision API error %d: %s", f.Error.Code, f.Error.Message)
+			}
+			responses = append(responses, f.Responses...)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to download results: %v", err)
+	}
+	// Batches are listed by name, which does not sort them by page.
+	sort.SliceStable(responses, func(i, j int) bool { return googlePageNumber(responses[i]) < googlePageNumber(responses[j]) })
+	var text []string
+	result.Document = &Document{}
+	for _, r := range responses {
+		if r.Error != nil {
+			return fmt.Errorf("page %d: Cloud Vision API error %d: %s", googlePageNumber(r), r.Error.Code, r.Error.Message)
+		}
+		if r.FullTextAnnotation == nil {
+			// A page with no text.
+			result.Document.Pages = append(result.Document.Pages, Page{})
+			continue
+		}
+		text = append(text, r.FullTextAnnotation.Text)
+		result.Document.Pages = append(result.Document.Pages, googleDocument(r.FullTextAnnotation).Pages...)
+	}
+	result.Text = strings.Join(text, "\n")
+	if !opts.Has(FeatureDocument) {
+		result.Document = nil
+	}
+	return nil
+}
+
+func googlePageNumber(r *gvision.AnnotateImageResponse) int64 {
+	if r.Context == nil {
+		return 0
+	}
+	return r.Context.PageNumber
+}
+
+// deleteObjects deletes the staged files and results, logging any failures.
+func (p *googleProvider) deleteObjects(gcs *storage.Service, bucket, prefix string) {
+	// The context of the annotation may have been canceled, but the objects
+	// should still be deleted.
+	ctx := context.Background()
+	err := gcs.Objects.List(bucket).Prefix(prefix+"/").Pages(ctx, func(objects *storage.Objects) error {
+		for _, o := range objects.Items {
+			if err := gcs.Objects.Delete(bucket, o.Name).Context(ctx).Do(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Warn("Unable to delete output", "uri", fmt.Sprintf("gs://%s/%s/", bucket, prefix), "err", err)
+	}
+}
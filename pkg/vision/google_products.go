@@ -0,0 +1,140 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+	gvision "google.golang.org/api/vision/v1"
+)
+
+// SearchProducts searches the product set of query with Vision API Product
+// Search, see:
+// https://cloud.google.com/vision/product-search/docs/searching
+//
+// The products matching each object detected in img are returned, with the
+// bounds of the object, or those matching the image as a whole if no objects
+// are detected.
+func (p *googleProvider) SearchProducts(ctx context.Context, img Image, query ProductQuery, opts Options) ([]ProductMatch, error) {
+	if len(query.ProductSet) == 0 {
+		return nil, fmt.Errorf("no product set to search")
+	}
+	if len(query.Categories) == 0 {
+		return nil, fmt.Errorf("no product categories to search")
+	}
+	// As for Annotate, http(s) URLs and gs:// objects are fetched by the API.
+	if uri := img.URI; len(img.Content) == 0 && !isHTTPURL(uri) && !strings.HasPrefix(uri, "gs://") {
+		var err error
+		if img.Content, err = img.fetch(ctx); err != nil {
+			return nil, err
+		}
+	}
+	rest, err := json.Marshal(&gvision.AnnotateImageRequest{
+		Features: []*gvision.Feature{{Type: "PRODUCT_SEARCH", MaxResults: int64(opts.MaxResults)}},
+		ImageContext: &gvision.ImageContext{ProductSearchParams: &gvision.ProductSearchParams{
+			ProductSet:        query.ProductSet,
+			ProductCategories: query.Categories,
+			Filter:            query.Filter,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response *gvision.BatchAnnotateImagesResponse
+	if err := withRetries(ctx, opts, func() error {
+		opts.Stats.addRequest(int64(len(img.Content)))
+		var err error
+		response, err = p.send(ctx, &googleBatch{images: []Image{img}, rest: rest})
+		if e, ok := err.(*googleapi.Error); ok && isRetryableStatus(e.Code) {
+			return &retryableError{err, parseRetryAfter(e.Header)}
+		}
+		return err
+	}); err != nil {
+		if e, ok := err.(*googleapi.Error); ok && !isServiceStatus(e.Code) {
+			return nil, fmt.Errorf("Cloud Vision API rejected %s: %v", img.Name, err)
+		}
+		return nil, &ServiceError{fmt.Errorf("Cloud Vision API request failed: %v", err)}
+	}
+	if opts.Verbose {
+		txt, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			log.Printf("%+v\n", response)
+		} else {
+			log.Printf("%s\n", txt)
+		}
+	}
+	if len(response.Responses) == 0 {
+		return nil, nil
+	}
+	r := response.Responses[0]
+	if r.Error != nil {
+		err := fmt.Errorf("Cloud Vision API error %d: %s", r.Error.Code, r.Error.Message)
+		if googleServiceCodes[r.Error.Code] {
+			return nil, &ServiceError{err}
+		}
+		return nil, err
+	}
+	results := r.ProductSearchResults
+	if results == nil {
+		return nil, nil
+	}
+	var matches []ProductMatch
+	if len(results.ProductGroupedResults) == 0 {
+		for _, pr := range results.Results {
+			matches = append(matches, googleProductMatch(pr, nil))
+		}
+	} else {
+		// Bounds of objects may be normalized to [0, 1].
+		var width, height int
+		for _, g := range results.ProductGroupedResults {
+			var bounds *BoundingBox
+			if poly := g.BoundingPoly; poly != nil && len(poly.NormalizedVertices) > 0 {
+				if width == 0 {
+					content, err := p.fetch(ctx, img)
+					if err != nil {
+						return nil, err
+					}
+					if width, height, err = imageSize(content); err != nil {
+						return nil, err
+					}
+				}
+				b := googleNormalizedBoundingBox(poly.NormalizedVertices, width, height)
+				bounds = &b
+			} else if poly != nil {
+				b := googleBoundingBox(poly)
+				bounds = &b
+			}
+			for _, pr := range g.Results {
+				matches = append(matches, googleProductMatch(pr, bounds))
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if opts.MinConfidence > 0 {
+		for i, m := range matches {
+			if m.Score < opts.MinConfidence {
+				matches = matches[:i]
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+func googleProductMatch(r *gvision.Result, bounds *BoundingBox) ProductMatch {
+	m := ProductMatch{Score: r.Score, Image: r.Image, Bounds: bounds}
+	if p := r.Product; p != nil {
+		m.Product, m.DisplayName, m.Category = p.Name, p.DisplayName, p.ProductCategory
+		for _, l := range p.ProductLabels {
+			if m.Labels == nil {
+				m.Labels = make(map[string]string)
+			}
+			m.Labels[l.Key] = l.Value
+		}
+	}
+	return m
+}
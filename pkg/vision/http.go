@@ -0,0 +1,98 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+// httpRequest is a request of a provider to its HTTP API, sent by sendHTTP.
+type httpRequest struct {
+	method, url string
+	body        []byte
+	// header has the content type and credentials of the request.
+	header http.Header
+	// errorMessage, if set, returns the message of the error in the body of a
+	// failed response, or "" if it has none.
+	errorMessage func(body []byte) string
+	// retryAfter, if set, returns the delay before a failed response may be
+	// retried, from its body, if it has no Retry-After header.
+	retryAfter func(body []byte) time.Duration
+	// serviceStatus, if set, returns true for the status codes of failures of
+	// the service other than those of isServiceStatus.
+	serviceStatus func(code int) bool
+	// unreachable, if set, is the hint of the ServiceError of a failure to
+	// connect (e.g. to a local server that is not running), which is
+	// otherwise retried.
+	unreachable string
+}
+
+// sendHTTP sends r with client, for the image named name, returning the body
+// and header of a successful (2xx) response. Transient failures are
+// *retryableErrors, and those of the service *ServiceErrors.
+func sendHTTP(ctx context.Context, client *http.Client, r httpRequest, name string, opts Options) ([]byte, http.Header, error) {
+	opts.Stats.addRequest(int64(len(r.body)))
+	req, err := http.NewRequest(r.method, r.url, bytes.NewReader(r.body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if len(r.unreachable) > 0 {
+			return nil, nil, &ServiceError{fmt.Errorf("HTTP request failed (%s): %v", r.unreachable, err)}
+		}
+		return nil, nil, &retryableError{err: fmt.Errorf("HTTP request failed: %v", err)}
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("HTTP request failed: %v", err)
+	}
+	if opts.Verbose {
+		var txt bytes.Buffer
+		if err := json.Indent(&txt, body, "", "  "); err != nil {
+			log.Printf("%s: %s\n", name, body)
+		} else {
+			log.Printf("%s: %s\n", name, txt.Bytes())
+		}
+	}
+	if resp.StatusCode/100 == 2 {
+		return body, resp.Header, nil
+	}
+	err = fmt.Errorf("HTTP request failed: %s", resp.Status)
+	if r.errorMessage != nil {
+		if msg := r.errorMessage(body); len(msg) > 0 {
+			err = fmt.Errorf("HTTP request failed: %s: %s", resp.Status, msg)
+		}
+	}
+	if isRetryableStatus(resp.StatusCode) {
+		retryAfter := parseRetryAfter(resp.Header)
+		if retryAfter == 0 && r.retryAfter != nil {
+			retryAfter = r.retryAfter(body)
+		}
+		return nil, nil, &retryableError{err, retryAfter}
+	}
+	if isServiceStatus(resp.StatusCode) || r.serviceStatus != nil && r.serviceStatus(resp.StatusCode) {
+		return nil, nil, &ServiceError{err}
+	}
+	return nil, nil, err
+}
+
+// jsonErrorMessage returns the message of errors of the form {"error": "..."},
+// e.g. of Ollama and the Hugging Face Inference API.
+func jsonErrorMessage(body []byte) string {
+	var e struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(body, &e)
+	return e.Error
+}
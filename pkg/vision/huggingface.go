@@ -0,0 +1,154 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+// DefaultHuggingFaceModel is the model used by NewHuggingFace if none is
+// configured, an image classifier trained on ImageNet.
+const DefaultHuggingFaceModel = "google/vit-base-patch16-224"
+
+// HuggingFaceConfig configures the Hugging Face provider, which annotates
+// images with a model of the Hugging Face Hub, run by its Inference API.
+type HuggingFaceConfig struct {
+	// Token is an access token of Hugging Face, see
+	// https://huggingface.co/settings/tokens
+	Token string
+	// Model is the ID of the model on the Hub, e.g. "facebook/detr-resnet-50",
+	// defaulting to DefaultHuggingFaceModel.
+	Model string
+}
+
+type huggingFaceProvider struct {
+	client *http.Client
+	token  string
+	url    string
+}
+
+// NewHuggingFace returns a Provider backed by a model of the Hugging Face Hub,
+// whose task determines the results: image classification models report
+// labels, object detection models objects and image-to-text models the
+// description (caption) of images.
+func NewHuggingFace(cfg HuggingFaceConfig) (Provider, error) {
+	if len(cfg.Token) == 0 {
+		return nil, fmt.Errorf("no access token provided")
+	}
+	model := strings.Trim(cfg.Model, "/")
+	if len(model) == 0 {
+		model = DefaultHuggingFaceModel
+	}
+	// From:
+	// https://huggingface.co/docs/inference-providers/providers/hf-inference
+	return &huggingFaceProvider{http.DefaultClient, cfg.Token, "https://router.huggingface.co/hf-inference/models/" + model}, nil
+}
+
+func (p *huggingFaceProvider) Name() string { return "huggingface" }
+
+func (p *huggingFaceProvider) Identity() string { return p.Name() + "/" + p.url }
+
+func (p *huggingFaceProvider) Capabilities() []Feature {
+	return []Feature{FeatureLabels, FeatureObjects}
+}
+
+func (p *huggingFaceProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := p.annotate(ctx, img, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// huggingFacePrediction is an element of the responses of the image
+// classification (label and score), object detection (also box) and
+// image-to-text (generated_text) tasks.
+type huggingFacePrediction struct {
+	Label string  `json:"label"`
+	Score float64 `json:"score"`
+	Box   *struct {
+		XMin int `json:"xmin"`
+		YMin int `json:"ymin"`
+		XMax int `json:"xmax"`
+		YMax int `json:"ymax"`
+	} `json:"box"`
+	GeneratedText string `json:"generated_text"`
+}
+
+func (p *huggingFaceProvider) annotate(ctx context.Context, img Image, result *Result, opts Options) error {
+	content, err := img.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	var response []byte
+	if err := withRetries(ctx, opts, func() error {
+		var err error
+		response, err = p.send(ctx, content, img.Name, opts)
+		return err
+	}); err != nil {
+		return err
+	}
+	var predictions []huggingFacePrediction
+	if err := json.Unmarshal(response, &predictions); err != nil {
+		return fmt.Errorf("failed to decode response (is the model for image classification, object detection or image-to-text?): %v", err)
+	}
+	for _, pr := range predictions {
+		switch {
+		case len(pr.GeneratedText) > 0:
+			result.Description = strings.TrimSpace(pr.GeneratedText)
+		case pr.Box != nil:
+			if opts.Has(FeatureObjects) {
+				b := pr.Box
+				result.Objects = append(result.Objects, Label{Description: pr.Label, Confidence: pr.Score, Bounds: &BoundingBox{X: b.XMin, Y: b.YMin, Width: b.XMax - b.XMin, Height: b.YMax - b.YMin}})
+			}
+		default:
+			if opts.Has(FeatureLabels) {
+				// Classifiers of ImageNet label classes by several synonyms,
+				// e.g. "tabby, tabby cat".
+				label := strings.TrimSpace(strings.SplitN(pr.Label, ",", 2)[0])
+				result.Labels = append(result.Labels, Label{Description: label, Confidence: pr.Score})
+			}
+		}
+	}
+	sortLabels(result.Labels)
+	sortLabels(result.Objects)
+	return nil
+}
+
+// send sends content to the model, returning the body of a successful
+// response.
+func (p *huggingFaceProvider) send(ctx context.Context, body []byte, name string, opts Options) ([]byte, error) {
+	body, _, err := sendHTTP(ctx, p.client, httpRequest{
+		method:       "POST",
+		url:          p.url,
+		body:         body,
+		header:       http.Header{"Content-Type": {http.DetectContentType(body)}, "Authorization": {"Bearer " + p.token}},
+		errorMessage: jsonErrorMessage,
+		retryAfter: func(body []byte) time.Duration {
+			// The number of seconds until a model that is not loaded (503)
+			// is.
+			var e struct {
+				EstimatedTime float64 `json:"estimated_time"`
+			}
+			json.Unmarshal(body, &e)
+			return time.Duration(e.EstimatedTime * float64(time.Second))
+		},
+	}, name, opts)
+	return body, err
+}
@@ -0,0 +1,188 @@
+package vision
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+// ImaggaConfig configures the Imagga provider.
+type ImaggaConfig struct {
+	// Key and Secret of the API, see https://imagga.com/profile/dashboard.
+	// Key may also be both, as "key:secret", or as the authorization
+	// shown there (the base64 encoding of "key:secret").
+	Key    string
+	Secret string
+	// Categorizer, if set, is the ID of a categorizer (e.g.
+	// "personal_photos") whose categories are also reported as labels, see
+	// https://docs.imagga.com/#categories-categorizer_id
+	Categorizer string
+}
+
+type imaggaProvider struct {
+	client      *http.Client
+	key, secret string
+	categorizer string
+}
+
+// NewImagga returns a Provider backed by the Imagga API.
+func NewImagga(cfg ImaggaConfig) (Provider, error) {
+	key, secret := strings.TrimPrefix(cfg.Key, "Basic "), cfg.Secret
+	if len(secret) == 0 {
+		if decoded, err := base64.StdEncoding.DecodeString(key); err == nil && strings.Contains(string(decoded), ":") {
+			key = string(decoded)
+		}
+		if i := strings.Index(key, ":"); i >= 0 {
+			key, secret = key[:i], key[i+1:]
+		}
+	}
+	if len(key) == 0 || len(secret) == 0 {
+		return nil, fmt.Errorf("no API key or secret provided")
+	}
+	return &imaggaProvider{http.DefaultClient, key, secret, cfg.Categorizer}, nil
+}
+
+func (p *imaggaProvider) Name() string { return "imagga" }
+
+func (p *imaggaProvider) Identity() string { return p.Name() + "/" + p.categorizer }
+
+func (p *imaggaProvider) Capabilities() []Feature {
+	return []Feature{FeatureLabels, FeatureColors}
+}
+
+func (p *imaggaProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := img.fetchUnlessHTTP(ctx); err != nil {
+			results[i].Err = err
+			return
+		}
+		if err := p.annotate(ctx, img, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// imaggaResponse is the subset of the responses of the tags, categories and
+// colors endpoints used here. Confidences and percentages are in [0, 100].
+type imaggaResponse struct {
+	Result struct {
+		Tags []struct {
+			Confidence float64 `json:"confidence"`
+			Tag        struct {
+				En string `json:"en"`
+			} `json:"tag"`
+		} `json:"tags"`
+		Categories []struct {
+			Confidence float64 `json:"confidence"`
+			Name       struct {
+				En string `json:"en"`
+			} `json:"name"`
+		} `json:"categories"`
+		Colors struct {
+			ImageColors []struct {
+				HTMLCode string  `json:"html_code"`
+				Parent   string  `json:"closest_palette_color_parent"`
+				Percent  float64 `json:"percent"`
+			} `json:"image_colors"`
+		} `json:"colors"`
+	} `json:"result"`
+	Status struct {
+		Text string `json:"text"`
+		Type string `json:"type"`
+	} `json:"status"`
+}
+
+func (p *imaggaProvider) annotate(ctx context.Context, img Image, result *Result, opts Options) error {
+	// From:
+	// https://docs.imagga.com/#tags
+	if opts.Has(FeatureLabels) {
+		tags, err := p.get(ctx, "tags", img, opts)
+		if err != nil {
+			return err
+		}
+		for _, t := range tags.Result.Tags {
+			result.Labels = append(result.Labels, Label{Description: t.Tag.En, Confidence: t.Confidence / 100})
+		}
+		if len(p.categorizer) > 0 {
+			categories, err := p.get(ctx, "categories/"+p.categorizer, img, opts)
+			if err != nil {
+				return err
+			}
+			for _, c := range categories.Result.Categories {
+				result.Labels = append(result.Labels, Label{Description: c.Name.En, Confidence: c.Confidence / 100})
+			}
+		}
+		sortLabels(result.Labels)
+	}
+	if opts.Has(FeatureColors) {
+		colors, err := p.get(ctx, "colors", img, opts)
+		if err != nil {
+			return err
+		}
+		for _, c := range colors.Result.Colors.ImageColors {
+			result.Colors = append(result.Colors, Color{Hex: strings.ToLower(c.HTMLCode), Name: c.Parent, Fraction: c.Percent / 100})
+		}
+		sort.SliceStable(result.Colors, func(i, j int) bool { return result.Colors[i].Fraction > result.Colors[j].Fraction })
+	}
+	return nil
+}
+
+// get returns the response of endpoint for img, which is sent by its URL
+// (which the API fetches itself) or its content.
+func (p *imaggaProvider) get(ctx context.Context, endpoint string, img Image, opts Options) (imaggaResponse, error) {
+	var response imaggaResponse
+	form := url.Values{}
+	if len(img.Content) > 0 {
+		form.Set("image_base64", base64.StdEncoding.EncodeToString(img.Content))
+	} else {
+		form.Set("image_url", img.URI)
+	}
+	err := withRetries(ctx, opts, func() error {
+		body, err := p.send(ctx, "https://api.imagga.com/v2/"+endpoint, form, img.Name, opts)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return fmt.Errorf("failed to decode response: %v", err)
+		}
+		return nil
+	})
+	return response, err
+}
+
+// send posts form to the endpoint, returning the body of a successful
+// response.
+func (p *imaggaProvider) send(ctx context.Context, endpoint string, form url.Values, name string, opts Options) ([]byte, error) {
+	req := httpRequest{
+		method: "POST",
+		url:    endpoint,
+		body:   []byte(form.Encode()),
+		header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+		errorMessage: func(body []byte) string {
+			var e imaggaResponse
+			json.Unmarshal(body, &e)
+			return e.Status.Text
+		},
+	}
+	req.header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(p.key+":"+p.secret)))
+	body, _, err := sendHTTP(ctx, p.client, req, name, opts)
+	return body, err
+}
@@ -0,0 +1,64 @@
+package vision
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// The classifier used by the local provider if none is configured: MobileNet
+// v2 (of 14 MB) trained on the 1000 classes of ImageNet, from the ONNX Model
+// Zoo (https://github.com/onnx/models), which must be downloaded once.
+const (
+	LocalModelURL  = "https://github.com/onnx/models/raw/main/validated/vision/classification/mobilenet/model/mobilenetv2-12.onnx"
+	LocalLabelsURL = "https://raw.githubusercontent.com/onnx/models/main/validated/vision/classification/synset.txt"
+)
+
+// LocalConfig configures the local provider, which labels images with an
+// image classifier run on this machine by ONNX Runtime, without any network
+// access or keys.
+type LocalConfig struct {
+	// Model is the ONNX file of the classifier, which takes a batch of RGB
+	// images of 224x224 pixels normalized as for ImageNet (NCHW), and
+	// returns the scores of each class.
+	Model string
+	// Labels is the file of the names of the classes, one per line in the
+	// order of the scores, optionally prefixed by an identifier (as in
+	// the synset.txt of ImageNet, e.g. "n02084071 dog, domestic dog").
+	Labels string
+	// Library is the path of the ONNX Runtime shared library (e.g.
+	// libonnxruntime.so), defaulting to that found by the system.
+	Library string
+}
+
+// NewLocal returns a Provider that labels images locally. It is only
+// supported by binaries built with -tags onnx, as ONNX Runtime requires cgo
+// and its shared library.
+func NewLocal(cfg LocalConfig) (Provider, error) {
+	if len(cfg.Model) == 0 || len(cfg.Labels) == 0 {
+		return nil, fmt.Errorf("no model or labels provided")
+	}
+	byts, err := ioutil.ReadFile(cfg.Labels)
+	if err != nil {
+		return nil, err
+	}
+	return newLocalProvider(cfg, parseLocalLabels(byts))
+}
+
+// parseLocalLabels returns the names of the classes of LocalConfig.Labels,
+// keeping only the first of several synonyms (e.g. "dog").
+func parseLocalLabels(byts []byte) []string {
+	var labels []string
+	s := bufio.NewScanner(bytes.NewReader(byts))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if fields := strings.Fields(line); len(fields) > 1 && len(fields[0]) == 9 && fields[0][0] == 'n' {
+			// A WordNet identifier, e.g. "n02084071".
+			line = strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		}
+		labels = append(labels, strings.TrimSpace(strings.SplitN(line, ",", 2)[0]))
+	}
+	return labels
+}
@@ -0,0 +1,192 @@
+//go:build onnx
+
+package vision
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"sync"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+	"github.com/asimshankar/visionapi/pkg/preprocess"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// localSize is the width and height of the images classified.
+const localSize = 224
+
+// Mean and standard deviation of the channels of ImageNet, which images are
+// normalized by.
+var (
+	localMean = [3]float32{0.485, 0.456, 0.406}
+	localStd  = [3]float32{0.229, 0.224, 0.225}
+)
+
+// onnxEnvironment is ONNX Runtime, initialized once per process.
+var onnxEnvironment struct {
+	once sync.Once
+	err  error
+}
+
+// initONNX initializes ONNX Runtime, with the shared library at library if
+// set.
+func initONNX(library string) error {
+	onnxEnvironment.once.Do(func() {
+		if len(library) > 0 {
+			ort.SetSharedLibraryPath(library)
+		}
+		onnxEnvironment.err = ort.InitializeEnvironment()
+	})
+	if err := onnxEnvironment.err; err != nil {
+		return fmt.Errorf("unable to initialize ONNX Runtime: %v", err)
+	}
+	return nil
+}
+
+type localProvider struct {
+	model   string // path of the ONNX file
+	labels  []string
+	classes int64
+	// mu serializes runs of session, which uses all cores for each.
+	mu      sync.Mutex
+	session *ort.DynamicAdvancedSession
+}
+
+func newLocalProvider(cfg LocalConfig, labels []string) (Provider, error) {
+	if err := initONNX(cfg.Library); err != nil {
+		return nil, err
+	}
+	inputs, outputs, err := ort.GetInputOutputInfo(cfg.Model)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load %s: %v", cfg.Model, err)
+	}
+	if len(inputs) != 1 || len(outputs) != 1 {
+		return nil, fmt.Errorf("%s is not a classifier: %d inputs and %d outputs", cfg.Model, len(inputs), len(outputs))
+	}
+	dims := outputs[0].Dimensions
+	classes := dims[len(dims)-1]
+	if int(classes) != len(labels) {
+		return nil, fmt.Errorf("%s has %d classes, but there are %d labels", cfg.Model, classes, len(labels))
+	}
+	session, err := ort.NewDynamicAdvancedSession(cfg.Model, []string{inputs[0].Name}, []string{outputs[0].Name}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load %s: %v", cfg.Model, err)
+	}
+	return &localProvider{model: cfg.Model, labels: labels, classes: classes, session: session}, nil
+}
+
+func (p *localProvider) Name() string { return "local" }
+
+func (p *localProvider) Identity() string { return p.Name() + "/" + p.model }
+
+func (p *localProvider) Capabilities() []Feature {
+	return []Feature{FeatureLabels}
+}
+
+func (p *localProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		defer opts.Stats.addImages(1)
+		if err := p.annotate(ctx, images[i], &results[i]); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// localMinConfidence is the confidence below which classes are not reported,
+// of the 1000 classes of ImageNet of which most have a negligible score.
+const localMinConfidence = 0.01
+
+func (p *localProvider) annotate(ctx context.Context, img Image, result *Result) error {
+	content, err := img.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %v", err)
+	}
+	input, err := ort.NewTensor(ort.NewShape(1, 3, localSize, localSize), imageTensor(decoded, localMean, localStd))
+	if err != nil {
+		return err
+	}
+	defer input.Destroy()
+	output, err := ort.NewEmptyTensor[float32](ort.NewShape(1, p.classes))
+	if err != nil {
+		return err
+	}
+	defer output.Destroy()
+	p.mu.Lock()
+	err = p.session.Run([]ort.Value{input}, []ort.Value{output})
+	p.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("classification failed: %v", err)
+	}
+	for i, confidence := range softmax(output.GetData()) {
+		if confidence >= localMinConfidence {
+			result.Labels = append(result.Labels, Label{Description: p.labels[i], Confidence: confidence})
+		}
+	}
+	sortLabels(result.Labels)
+	return nil
+}
+
+// imageTensor returns the center square of img, scaled to localSize pixels and
+// normalized by the mean and std of each channel, as planes of the red, green
+// and blue channels.
+func imageTensor(img image.Image, mean, std [3]float32) []float32 {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	square := image.Rect(0, 0, side, side).Add(b.Min).Add(image.Pt((b.Dx()-side)/2, (b.Dy()-side)/2))
+	scaled := preprocess.Resize(cropImage(img, square), localSize, localSize).(*image.RGBA)
+	data := make([]float32, 3*localSize*localSize)
+	for y := 0; y < localSize; y++ {
+		for x := 0; x < localSize; x++ {
+			c := scaled.RGBAAt(x, y)
+			for channel, v := range [3]uint8{c.R, c.G, c.B} {
+				data[channel*localSize*localSize+y*localSize+x] = (float32(v)/255 - mean[channel]) / std[channel]
+			}
+		}
+	}
+	return data
+}
+
+// cropImage returns the area r of img.
+func cropImage(img image.Image, r image.Rectangle) image.Image {
+	if s, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r)
+	}
+	return img
+}
+
+// softmax returns the probabilities of the classes of scores, which are
+// logits (as output by the classifiers of the ONNX Model Zoo), in order.
+func softmax(scores []float32) []float64 {
+	probabilities := make([]float64, len(scores))
+	top := math.Inf(-1)
+	for _, s := range scores {
+		top = math.Max(top, float64(s))
+	}
+	var sum float64
+	for i, s := range scores {
+		probabilities[i] = math.Exp(float64(s) - top)
+		sum += probabilities[i]
+	}
+	for i := range probabilities {
+		probabilities[i] /= sum
+	}
+	return probabilities
+}
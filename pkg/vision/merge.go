@@ -0,0 +1,112 @@
+package vision
+
+import "strings"
+
+// LabelSynonyms maps labels (in lowercase) that providers use for the same
+// thing to the label that MergeLabels reports them as.
+var LabelSynonyms = map[string]string{
+	"aeroplane":     "airplane",
+	"aircraft":      "airplane",
+	"automobile":    "car",
+	"bike":          "bicycle",
+	"canine":        "dog",
+	"cell phone":    "mobile phone",
+	"cellphone":     "mobile phone",
+	"feline":        "cat",
+	"human":         "person",
+	"human face":    "face",
+	"licence plate": "license plate",
+	"motor vehicle": "vehicle",
+	"motorbike":     "motorcycle",
+	"number plate":  "license plate",
+	"people":        "person",
+	"smartphone":    "mobile phone",
+	"tv":            "television",
+}
+
+// NormalizeLabel returns the key by which MergeLabels merges labels: the
+// description in lowercase, with underscores and runs of whitespace replaced
+// by single spaces, mapped through LabelSynonyms.
+func NormalizeLabel(description string) string {
+	key := strings.Join(strings.Fields(strings.ReplaceAll(strings.ToLower(description), "_", " ")), " ")
+	if synonym, ok := LabelSynonyms[key]; ok {
+		return synonym
+	}
+	return key
+}
+
+// MergeLabels combines the labels reported for the same image by several
+// providers (one list each) into a consensus. Labels with the same
+// NormalizeLabel key are merged, with a confidence of the mean of their
+// confidences across all lists (counting 0 for lists without the label), so
+// that labels reported by more providers, and with more confidence, rank
+// higher. Labels reported in fewer than minLists of the lists are dropped.
+func MergeLabels(minLists int, lists ...[]Label) []Label {
+	if len(lists) == 0 {
+		return nil
+	}
+	var (
+		merged []Label
+		counts []int
+		index  = make(map[string]int) // into merged, by key
+	)
+	for _, labels := range lists {
+		// The highest confidence of labels with the same key in this list.
+		best := make(map[string]float64)
+		for _, l := range labels {
+			key := NormalizeLabel(l.Description)
+			if _, ok := index[key]; !ok {
+				description := l.Description
+				if _, ok := LabelSynonyms[strings.ToLower(description)]; ok {
+					description = key
+				}
+				index[key] = len(merged)
+				merged = append(merged, Label{Description: description})
+				counts = append(counts, 0)
+			}
+			if c, ok := best[key]; !ok || l.Confidence > c {
+				best[key] = l.Confidence
+			}
+		}
+		for key, c := range best {
+			merged[index[key]].Confidence += c
+			counts[index[key]]++
+		}
+	}
+	var consensus []Label
+	for i, l := range merged {
+		if counts[i] < minLists {
+			continue
+		}
+		l.Confidence /= float64(len(lists))
+		consensus = append(consensus, l)
+	}
+	sortLabels(consensus)
+	return consensus
+}
+
+// MergeFrames combines the labels of the frames of a video into the labels of
+// the whole video. Labels with the same NormalizeLabel key are merged, with
+// the highest of their confidences in any frame.
+func MergeFrames(frames []Frame) []Label {
+	var (
+		merged []Label
+		index  = make(map[string]int) // into merged, by key
+	)
+	for _, f := range frames {
+		for _, l := range f.Labels {
+			key := NormalizeLabel(l.Description)
+			i, ok := index[key]
+			if !ok {
+				index[key] = len(merged)
+				merged = append(merged, Label{Description: l.Description, Confidence: l.Confidence})
+				continue
+			}
+			if l.Confidence > merged[i].Confidence {
+				merged[i].Confidence = l.Confidence
+			}
+		}
+	}
+	sortLabels(merged)
+	return merged
+}
@@ -0,0 +1,513 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+// Microsoft API versions supported by NewMicrosoft.
+const (
+	MicrosoftV32 = "v3.2"
+	// MicrosoftV4 is Image Analysis 4.0, which does not support faces or
+	// safe search.
+	MicrosoftV4 = "4.0"
+)
+
+// OCR APIs of MicrosoftConfig.OCR.
+const (
+	// MicrosoftOCR is the synchronous OCR API.
+	MicrosoftOCR = "ocr"
+	// MicrosoftRead is the asynchronous Read API, which recognizes
+	// handwriting and dense documents better.
+	MicrosoftRead = "read"
+)
+
+// MicrosoftConfig configures the Azure AI Vision (formerly Microsoft
+// Cognitive Services Computer Vision) provider.
+type MicrosoftConfig struct {
+	// Key is the subscription key of the resource.
+	Key string
+	// Endpoint of the resource, e.g. https://myvision.cognitiveservices.azure.com.
+	// Defaults to the regional endpoint of Region.
+	Endpoint string
+	// Region of the resource, e.g. "westus", used if Endpoint is empty.
+	Region string
+	// APIVersion is MicrosoftV32 (the default) or MicrosoftV4.
+	APIVersion string
+	// OCR is the v3.2 API detecting text: MicrosoftOCR (the default) or
+	// MicrosoftRead.
+	OCR string
+	// CustomVisionProject is the ID of the Custom Vision project, and
+	// CustomVisionIteration the name its iteration was published as, used by
+	// NewCustomVision.
+	CustomVisionProject   string
+	CustomVisionIteration string
+}
+
+type microsoftProvider struct {
+	client   *http.Client
+	key      string
+	endpoint string
+	version  string
+	ocrAPI   string
+}
+
+// NewMicrosoft returns a Provider backed by the Azure AI Vision API.
+func NewMicrosoft(cfg MicrosoftConfig) (Provider, error) {
+	if len(cfg.Key) == 0 {
+		return nil, fmt.Errorf("no subscription key provided")
+	}
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if len(endpoint) == 0 {
+		if len(cfg.Region) == 0 {
+			return nil, fmt.Errorf("one of the endpoint or region of the resource must be provided")
+		}
+		endpoint = fmt.Sprintf("https://%s.api.cognitive.microsoft.com", cfg.Region)
+	}
+	version := cfg.APIVersion
+	switch version {
+	case "":
+		version = MicrosoftV32
+	case MicrosoftV32, MicrosoftV4:
+	default:
+		return nil, fmt.Errorf("unsupported API version %q, must be %q or %q", version, MicrosoftV32, MicrosoftV4)
+	}
+	ocr := cfg.OCR
+	switch ocr {
+	case "":
+		ocr = MicrosoftOCR
+	case MicrosoftOCR, MicrosoftRead:
+	default:
+		return nil, fmt.Errorf("unsupported OCR API %q, must be %q or %q", ocr, MicrosoftOCR, MicrosoftRead)
+	}
+	return &microsoftProvider{http.DefaultClient, cfg.Key, endpoint, version, ocr}, nil
+}
+
+func (p *microsoftProvider) Name() string { return "microsoft" }
+
+func (p *microsoftProvider) Identity() string {
+	return p.Name() + "/" + p.version + "/" + p.ocrAPI
+}
+
+func (p *microsoftProvider) Capabilities() []Feature {
+	if p.version == MicrosoftV4 {
+		return []Feature{FeatureLabels, FeatureText, FeatureObjects, FeatureCropHints}
+	}
+	return []Feature{FeatureLabels, FeatureText, FeatureFaces, FeatureLandmarks, FeatureCelebrities, FeatureSafeSearch, FeatureObjects, FeatureLogos, FeatureCropHints, FeatureColors, FeatureDocument}
+}
+
+func (p *microsoftProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if p.version == MicrosoftV4 {
+		return p.annotateV4(ctx, images, opts)
+	}
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	var visualFeatures, details []string
+	if opts.Has(FeatureLabels) {
+		visualFeatures = append(visualFeatures, "Description", "Tags")
+	}
+	if opts.Has(FeatureFaces) {
+		visualFeatures = append(visualFeatures, "Faces")
+	}
+	if opts.Has(FeatureSafeSearch) {
+		visualFeatures = append(visualFeatures, "Adult")
+	}
+	if opts.Has(FeatureObjects) {
+		visualFeatures = append(visualFeatures, "Objects")
+	}
+	if opts.Has(FeatureLogos) {
+		visualFeatures = append(visualFeatures, "Brands")
+	}
+	if opts.Has(FeatureColors) {
+		visualFeatures = append(visualFeatures, "Color")
+	}
+	// Celebrities and landmarks are details of the categories of the image.
+	if opts.Has(FeatureCelebrities) {
+		details = append(details, "Celebrities")
+	}
+	if opts.Has(FeatureLandmarks) {
+		details = append(details, "Landmarks")
+	}
+	if len(details) > 0 {
+		visualFeatures = append(visualFeatures, "Categories")
+	}
+	// From:
+	// https://westus.dev.cognitive.microsoft.com/docs/services/computer-vision-v3-2/operations/56f91f2e778daf14a499f21b
+	url := p.endpoint + "/vision/v3.2/analyze?visualFeatures=" + strings.Join(visualFeatures, ",")
+	if len(details) > 0 {
+		url += "&details=" + strings.Join(details, ",")
+	}
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := img.fetchUnlessHTTP(ctx); err != nil {
+			results[i].Err = err
+			return
+		}
+		if len(visualFeatures) > 0 {
+			if err := p.analyze(ctx, url, img, &results[i], opts); err != nil {
+				results[i].Err = err
+				return
+			}
+		}
+		if opts.Has(FeatureText) || opts.Has(FeatureDocument) {
+			ocr := p.ocr
+			if p.ocrAPI == MicrosoftRead {
+				ocr = p.read
+			}
+			if err := ocr(ctx, img, &results[i], opts); err != nil {
+				results[i].Err = err
+				return
+			}
+		}
+		if opts.Has(FeatureCropHints) {
+			if err := p.areaOfInterest(ctx, img, &results[i], opts); err != nil {
+				results[i].Err = err
+			}
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// microsoftAnalyzeResponse is the subset of the analyze response used here.
+type microsoftAnalyzeResponse struct {
+	Tags []struct {
+		Name       string  `json:"name"`
+		Confidence float64 `json:"confidence"`
+	} `json:"tags"`
+	Description struct {
+		Captions []struct {
+			Text       string  `json:"text"`
+			Confidence float64 `json:"confidence"`
+		} `json:"captions"`
+	} `json:"description"`
+	Faces []struct {
+		Age           float64            `json:"age"`
+		Gender        string             `json:"gender"`
+		FaceRectangle microsoftRectangle `json:"faceRectangle"`
+	} `json:"faces"`
+	Adult *struct {
+		AdultScore float64 `json:"adultScore"`
+		RacyScore  float64 `json:"racyScore"`
+		GoreScore  float64 `json:"goreScore"`
+	} `json:"adult"`
+	Objects []struct {
+		Rectangle  microsoftXYWH `json:"rectangle"`
+		Object     string        `json:"object"`
+		Confidence float64       `json:"confidence"`
+	} `json:"objects"`
+	Brands []struct {
+		Name       string        `json:"name"`
+		Confidence float64       `json:"confidence"`
+		Rectangle  microsoftXYWH `json:"rectangle"`
+	} `json:"brands"`
+	Color *struct {
+		DominantColors []string `json:"dominantColors"`
+	} `json:"color"`
+	Categories []struct {
+		Detail *struct {
+			Celebrities []struct {
+				Name          string             `json:"name"`
+				Confidence    float64            `json:"confidence"`
+				FaceRectangle microsoftRectangle `json:"faceRectangle"`
+			} `json:"celebrities"`
+			Landmarks []struct {
+				Name       string  `json:"name"`
+				Confidence float64 `json:"confidence"`
+			} `json:"landmarks"`
+		} `json:"detail"`
+	} `json:"categories"`
+}
+
+// microsoftXYWH is the rectangle format used for objects, and by Image
+// Analysis 4.0.
+type microsoftXYWH struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+func (r microsoftXYWH) boundingBox() *BoundingBox {
+	return &BoundingBox{X: r.X, Y: r.Y, Width: r.W, Height: r.H}
+}
+
+// microsoftOCRResponse is the subset of the OCR response used here.
+// Bounding boxes are strings of "x,y,width,height".
+type microsoftOCRResponse struct {
+	Regions []struct {
+		BoundingBox string `json:"boundingBox"`
+		Lines       []struct {
+			BoundingBox string `json:"boundingBox"`
+			Words       []struct {
+				BoundingBox string `json:"boundingBox"`
+				Text        string `json:"text"`
+			} `json:"words"`
+		} `json:"lines"`
+	} `json:"regions"`
+}
+
+// microsoftOCRBox parses the bounding boxes of microsoftOCRResponse, returning
+// false if s is invalid.
+func microsoftOCRBox(s string) (BoundingBox, bool) {
+	var b BoundingBox
+	_, err := fmt.Sscanf(s, "%d,%d,%d,%d", &b.X, &b.Y, &b.Width, &b.Height)
+	return b, err == nil
+}
+
+type microsoftRectangle struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (r microsoftRectangle) boundingBox() BoundingBox {
+	return BoundingBox{X: r.Left, Y: r.Top, Width: r.Width, Height: r.Height}
+}
+
+// microsoftError is the body of failed responses, which nests the code and
+// message under "error" in recent API versions.
+type microsoftError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Error   *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// fetchUnlessHTTP downloads the content of img if its URI is a cloud storage
+// object, which the API cannot fetch itself.
+func (img *Image) fetchUnlessHTTP(ctx context.Context) error {
+	if isHTTPURL(img.URI) {
+		return nil
+	}
+	var err error
+	img.Content, err = img.fetch(ctx)
+	return err
+}
+
+// post sends the image to url, returning the body of a successful response.
+func (p *microsoftProvider) post(ctx context.Context, url string, img Image, opts Options) ([]byte, error) {
+	body, _, err := p.postWithHeader(ctx, url, img, opts)
+	return body, err
+}
+
+// postWithHeader is post, also returning the header of the response.
+func (p *microsoftProvider) postWithHeader(ctx context.Context, url string, img Image, opts Options) ([]byte, http.Header, error) {
+	var (
+		body        = img.Content
+		contentType = "application/octet-stream"
+	)
+	if len(body) == 0 && isHTTPURL(img.URI) {
+		// The API fetches the image itself.
+		var err error
+		if body, err = json.Marshal(map[string]string{"url": img.URI}); err != nil {
+			return nil, nil, err
+		}
+		contentType = "application/json"
+	}
+	var (
+		response []byte
+		header   http.Header
+	)
+	err := withRetries(ctx, opts, func() error {
+		var err error
+		response, header, err = p.send(ctx, "POST", url, body, contentType, img.Name, opts)
+		return err
+	})
+	return response, header, err
+}
+
+// send sends a request, returning the body and header of a successful
+// response.
+func (p *microsoftProvider) send(ctx context.Context, method, url string, body []byte, contentType, name string, opts Options) ([]byte, http.Header, error) {
+	header := http.Header{"Ocp-Apim-Subscription-Key": {p.key}}
+	if len(contentType) > 0 {
+		header.Set("Content-Type", contentType)
+	}
+	return sendHTTP(ctx, p.client, httpRequest{
+		method:       method,
+		url:          url,
+		body:         body,
+		header:       header,
+		errorMessage: microsoftErrorMessage,
+	}, name, opts)
+}
+
+// microsoftErrorMessage returns the message of the error of a failed response
+// of Azure AI services, as of v3.2 or (nested in "error") 4.0.
+func microsoftErrorMessage(body []byte) string {
+	var e microsoftError
+	if err := json.Unmarshal(body, &e); err == nil && e.Error != nil {
+		e.Code, e.Message = e.Error.Code, e.Error.Message
+	}
+	if len(e.Message) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s)", e.Message, e.Code)
+}
+
+func (p *microsoftProvider) analyze(ctx context.Context, url string, img Image, result *Result, opts Options) error {
+	body, err := p.post(ctx, url, img, opts)
+	if err != nil {
+		return err
+	}
+	var analysis microsoftAnalyzeResponse
+	if err := json.Unmarshal(body, &analysis); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	for _, t := range analysis.Tags {
+		result.Labels = append(result.Labels, Label{Description: t.Name, Confidence: t.Confidence})
+	}
+	sortLabels(result.Labels)
+	if len(analysis.Description.Captions) > 0 {
+		result.Description = analysis.Description.Captions[0].Text
+	}
+	for _, f := range analysis.Faces {
+		result.Faces = append(result.Faces, Face{
+			Bounds: f.FaceRectangle.boundingBox(),
+			Age:    f.Age,
+			Gender: strings.ToLower(f.Gender),
+		})
+	}
+	if a := analysis.Adult; a != nil {
+		result.SafeSearch = &SafeSearch{Adult: a.AdultScore, Racy: a.RacyScore, Violence: a.GoreScore}
+	}
+	for _, o := range analysis.Objects {
+		result.Objects = append(result.Objects, Label{Description: o.Object, Confidence: o.Confidence, Bounds: o.Rectangle.boundingBox()})
+	}
+	sortLabels(result.Objects)
+	for _, b := range analysis.Brands {
+		result.Logos = append(result.Logos, Label{Description: b.Name, Confidence: b.Confidence, Bounds: b.Rectangle.boundingBox()})
+	}
+	sortLabels(result.Logos)
+	if c := analysis.Color; c != nil {
+		// Only the names of the colors are reported.
+		for _, name := range c.DominantColors {
+			result.Colors = append(result.Colors, Color{Name: strings.ToLower(name)})
+		}
+	}
+	// The same celebrity or landmark may be a detail of several categories.
+	seen := make(map[string]bool)
+	for _, c := range analysis.Categories {
+		if c.Detail == nil {
+			continue
+		}
+		for _, celebrity := range c.Detail.Celebrities {
+			if key := "celebrity:" + celebrity.Name; !seen[key] {
+				seen[key] = true
+				bounds := celebrity.FaceRectangle.boundingBox()
+				result.Celebrities = append(result.Celebrities, Label{Description: celebrity.Name, Confidence: celebrity.Confidence, Bounds: &bounds})
+			}
+		}
+		for _, l := range c.Detail.Landmarks {
+			if key := "landmark:" + l.Name; !seen[key] {
+				seen[key] = true
+				result.Landmarks = append(result.Landmarks, Label{Description: l.Name, Confidence: l.Confidence})
+			}
+		}
+	}
+	sortLabels(result.Celebrities)
+	sortLabels(result.Landmarks)
+	return nil
+}
+
+func (p *microsoftProvider) ocr(ctx context.Context, img Image, result *Result, opts Options) error {
+	// From:
+	// https://westus.dev.cognitive.microsoft.com/docs/services/computer-vision-v3-2/operations/56f91f2e778daf14a499f20d
+	// Only a single language can be specified.
+	language := "unk"
+	if len(opts.LanguageHints) > 0 {
+		language = opts.LanguageHints[0]
+	}
+	body, err := p.post(ctx, p.endpoint+"/vision/v3.2/ocr?language="+language+"&detectOrientation=true", img, opts)
+	if err != nil {
+		return err
+	}
+	var ocr microsoftOCRResponse
+	if err := json.Unmarshal(body, &ocr); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	// Lines are separated by newlines and regions by blank lines.
+	var regions []string
+	for _, r := range ocr.Regions {
+		var lines []string
+		for _, l := range r.Lines {
+			words := make([]string, len(l.Words))
+			for i, w := range l.Words {
+				words[i] = w.Text
+			}
+			lines = append(lines, strings.Join(words, " "))
+			if b, ok := microsoftOCRBox(l.BoundingBox); ok {
+				result.TextBlocks = append(result.TextBlocks, Label{Description: lines[len(lines)-1], Bounds: &b})
+			}
+		}
+		regions = append(regions, strings.Join(lines, "\n"))
+	}
+	result.Text = strings.Join(regions, "\n\n")
+	if opts.Has(FeatureDocument) {
+		content, err := img.fetch(ctx)
+		if err != nil {
+			return err
+		}
+		width, height, err := imageSize(content)
+		if err != nil {
+			return err
+		}
+		result.Document = microsoftDocument(ocr, width, height)
+	}
+	return nil
+}
+
+// microsoftDocument returns the layout of an OCR response, with regions as
+// blocks and lines as paragraphs.
+func microsoftDocument(ocr microsoftOCRResponse, width, height int) *Document {
+	page := Page{Width: width, Height: height}
+	for _, r := range ocr.Regions {
+		block := Block{}
+		block.Bounds, _ = microsoftOCRBox(r.BoundingBox)
+		for _, l := range r.Lines {
+			paragraph := Paragraph{}
+			paragraph.Bounds, _ = microsoftOCRBox(l.BoundingBox)
+			for _, w := range l.Words {
+				bounds, _ := microsoftOCRBox(w.BoundingBox)
+				paragraph.Words = append(paragraph.Words, Word{Text: w.Text, Bounds: bounds})
+			}
+			block.Paragraphs = append(block.Paragraphs, paragraph)
+		}
+		page.Blocks = append(page.Blocks, block)
+	}
+	return &Document{Pages: []Page{page}}
+}
+
+// areaOfInterest reports the most important area of the image as its only
+// crop hint. Unlike Google, the hint does not have a requested aspect ratio.
+func (p *microsoftProvider) areaOfInterest(ctx context.Context, img Image, result *Result, opts Options) error {
+	// From:
+	// https://westus.dev.cognitive.microsoft.com/docs/services/computer-vision-v3-2/operations/b156d0f5e11e492d9f64418d
+	body, err := p.post(ctx, p.endpoint+"/vision/v3.2/areaOfInterest", img, opts)
+	if err != nil {
+		return err
+	}
+	var response struct {
+		AreaOfInterest microsoftXYWH `json:"areaOfInterest"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	result.CropHints = []CropHint{{Bounds: *response.AreaOfInterest.boundingBox()}}
+	return nil
+}
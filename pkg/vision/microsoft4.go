@@ -0,0 +1,158 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+// microsoftV4Response is the subset of the Image Analysis 4.0 response used
+// here.
+type microsoftV4Response struct {
+	CaptionResult *struct {
+		Text       string  `json:"text"`
+		Confidence float64 `json:"confidence"`
+	} `json:"captionResult"`
+	TagsResult *struct {
+		Values []struct {
+			Name       string  `json:"name"`
+			Confidence float64 `json:"confidence"`
+		} `json:"values"`
+	} `json:"tagsResult"`
+	ObjectsResult *struct {
+		Values []struct {
+			BoundingBox microsoftXYWH `json:"boundingBox"`
+			Tags        []struct {
+				Name       string  `json:"name"`
+				Confidence float64 `json:"confidence"`
+			} `json:"tags"`
+		} `json:"values"`
+	} `json:"objectsResult"`
+	ReadResult *struct {
+		Blocks []struct {
+			Lines []struct {
+				Text            string `json:"text"`
+				BoundingPolygon []struct {
+					X int `json:"x"`
+					Y int `json:"y"`
+				} `json:"boundingPolygon"`
+			} `json:"lines"`
+		} `json:"blocks"`
+	} `json:"readResult"`
+	SmartCropsResult *struct {
+		Values []struct {
+			AspectRatio float64       `json:"aspectRatio"`
+			BoundingBox microsoftXYWH `json:"boundingBox"`
+		} `json:"values"`
+	} `json:"smartCropsResult"`
+}
+
+// annotateV4 uses the Image Analysis 4.0 API, which returns all features in a
+// single call.
+func (p *microsoftProvider) annotateV4(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	var features []string
+	if opts.Has(FeatureLabels) {
+		features = append(features, "tags", "caption")
+	}
+	if opts.Has(FeatureText) {
+		features = append(features, "read")
+	}
+	if opts.Has(FeatureObjects) {
+		features = append(features, "objects")
+	}
+	var smartCrops string
+	if opts.Has(FeatureCropHints) {
+		features = append(features, "smartCrops")
+		ratios := make([]string, len(opts.CropAspectRatios))
+		for i, r := range opts.CropAspectRatios {
+			ratios[i] = strconv.FormatFloat(r, 'f', 2, 64)
+		}
+		if len(ratios) > 0 {
+			smartCrops = "&smartcrops-aspect-ratios=" + strings.Join(ratios, ",")
+		}
+	}
+	// From:
+	// https://learn.microsoft.com/en-us/rest/api/computervision/image-analysis/analyze-image
+	url := p.endpoint + "/computervision/imageanalysis:analyze?api-version=2023-10-01&features=" + strings.Join(features, ",") + smartCrops
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		defer opts.Stats.addImages(1)
+		img := images[i]
+		if err := img.fetchUnlessHTTP(ctx); err != nil {
+			results[i].Err = err
+			return
+		}
+		if err := p.analyzeV4(ctx, url, img, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+func (p *microsoftProvider) analyzeV4(ctx context.Context, url string, img Image, result *Result, opts Options) error {
+	body, err := p.post(ctx, url, img, opts)
+	if err != nil {
+		return err
+	}
+	var analysis microsoftV4Response
+	if err := json.Unmarshal(body, &analysis); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	if c := analysis.CaptionResult; c != nil {
+		result.Description = c.Text
+	}
+	if t := analysis.TagsResult; t != nil {
+		for _, v := range t.Values {
+			result.Labels = append(result.Labels, Label{Description: v.Name, Confidence: v.Confidence})
+		}
+		sortLabels(result.Labels)
+	}
+	if o := analysis.ObjectsResult; o != nil {
+		for _, v := range o.Values {
+			if len(v.Tags) == 0 {
+				continue
+			}
+			result.Objects = append(result.Objects, Label{Description: v.Tags[0].Name, Confidence: v.Tags[0].Confidence, Bounds: v.BoundingBox.boundingBox()})
+		}
+		sortLabels(result.Objects)
+	}
+	if c := analysis.SmartCropsResult; c != nil {
+		for _, v := range c.Values {
+			result.CropHints = append(result.CropHints, CropHint{Bounds: *v.BoundingBox.boundingBox()})
+		}
+	}
+	if r := analysis.ReadResult; r != nil {
+		// Lines are separated by newlines and blocks by blank lines, as for
+		// the v3.2 OCR API.
+		var blocks []string
+		for _, b := range r.Blocks {
+			lines := make([]string, len(b.Lines))
+			for i, l := range b.Lines {
+				lines[i] = l.Text
+				if len(l.BoundingPolygon) == 0 {
+					continue
+				}
+				minX, minY, maxX, maxY := l.BoundingPolygon[0].X, l.BoundingPolygon[0].Y, l.BoundingPolygon[0].X, l.BoundingPolygon[0].Y
+				for _, v := range l.BoundingPolygon[1:] {
+					minX, minY = min(minX, v.X), min(minY, v.Y)
+					maxX, maxY = max(maxX, v.X), max(maxY, v.Y)
+				}
+				result.TextBlocks = append(result.TextBlocks, Label{Description: l.Text, Bounds: &BoundingBox{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}})
+			}
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		}
+		result.Text = strings.Join(blocks, "\n\n")
+	}
+	return nil
+}
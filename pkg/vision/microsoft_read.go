@@ -0,0 +1,128 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// microsoftReadPollInterval is the delay between checks of the status of
+// Read operations.
+const microsoftReadPollInterval = time.Second
+
+// microsoftReadResponse is the subset of the result of Read operations used
+// here. Bounding boxes are polygons of 4 points, as x1,y1,...,x4,y4.
+type microsoftReadResponse struct {
+	Status        string `json:"status"`
+	AnalyzeResult *struct {
+		ReadResults []struct {
+			Width  float64 `json:"width"`
+			Height float64 `json:"height"`
+			Lines  []struct {
+				BoundingBox []float64 `json:"boundingBox"`
+				Text        string    `json:"text"`
+				Words       []struct {
+					BoundingBox []float64 `json:"boundingBox"`
+					Text        string    `json:"text"`
+				} `json:"words"`
+			} `json:"lines"`
+		} `json:"readResults"`
+	} `json:"analyzeResult"`
+}
+
+// microsoftPolygonBox returns the bounds of a polygon of the Read API.
+func microsoftPolygonBox(polygon []float64) BoundingBox {
+	if len(polygon) < 2 {
+		return BoundingBox{}
+	}
+	minX, minY, maxX, maxY := polygon[0], polygon[1], polygon[0], polygon[1]
+	for i := 2; i+1 < len(polygon); i += 2 {
+		minX, minY = min(minX, polygon[i]), min(minY, polygon[i+1])
+		maxX, maxY = max(maxX, polygon[i]), max(maxY, polygon[i+1])
+	}
+	return BoundingBox{X: int(minX), Y: int(minY), Width: int(maxX - minX), Height: int(maxY - minY)}
+}
+
+// read detects text with the asynchronous Read API: the image is submitted,
+// and the operation whose URL is in the Operation-Location header of the
+// response is polled until it completes.
+func (p *microsoftProvider) read(ctx context.Context, img Image, result *Result, opts Options) error {
+	// From:
+	// https://westus.dev.cognitive.microsoft.com/docs/services/computer-vision-v3-2/operations/5d986960601faab4bf452005
+	url := p.endpoint + "/vision/v3.2/read/analyze"
+	if len(opts.LanguageHints) > 0 {
+		url += "?language=" + opts.LanguageHints[0]
+	}
+	_, header, err := p.postWithHeader(ctx, url, img, opts)
+	if err != nil {
+		return err
+	}
+	operation := header.Get("Operation-Location")
+	if len(operation) == 0 {
+		return fmt.Errorf("no Operation-Location in the response")
+	}
+	var read microsoftReadResponse
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(microsoftReadPollInterval):
+		}
+		var body []byte
+		err := withRetries(ctx, opts, func() error {
+			var err error
+			body, _, err = p.send(ctx, "GET", operation, nil, "", img.Name, opts)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(body, &read); err != nil {
+			return fmt.Errorf("failed to decode response: %v", err)
+		}
+		if read.Status == "failed" {
+			return fmt.Errorf("Read operation %s failed", operation)
+		}
+		if read.Status == "succeeded" && read.AnalyzeResult != nil {
+			break
+		}
+	}
+	// Lines are separated by newlines and pages by blank lines, and each
+	// page is a single block of lines.
+	var (
+		pages []string
+		doc   Document
+	)
+	for _, r := range read.AnalyzeResult.ReadResults {
+		var (
+			lines   []string
+			block   Block
+			polygon []float64 // Of the corners of every line.
+		)
+		for _, l := range r.Lines {
+			lines = append(lines, l.Text)
+			polygon = append(polygon, l.BoundingBox...)
+			bounds := microsoftPolygonBox(l.BoundingBox)
+			result.TextBlocks = append(result.TextBlocks, Label{Description: l.Text, Bounds: &bounds})
+			paragraph := Paragraph{Bounds: bounds}
+			for _, w := range l.Words {
+				paragraph.Words = append(paragraph.Words, Word{Text: w.Text, Bounds: microsoftPolygonBox(w.BoundingBox)})
+			}
+			block.Paragraphs = append(block.Paragraphs, paragraph)
+		}
+		pages = append(pages, strings.Join(lines, "\n"))
+		page := Page{Width: int(r.Width), Height: int(r.Height)}
+		if len(block.Paragraphs) > 0 {
+			block.Bounds = microsoftPolygonBox(polygon)
+			page.Blocks = []Block{block}
+		}
+		doc.Pages = append(doc.Pages, page)
+	}
+	result.Text = strings.Join(pages, "\n\n")
+	if opts.Has(FeatureDocument) {
+		result.Document = &doc
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+type mockProvider struct {
+	fixtures []PluginResult
+}
+
+// NewMock returns a Provider that makes no requests, and instead returns the
+// results in the file fixtures, for testing. The file is a sequence of JSON
+// documents in the format of PluginResult (e.g., as output by the CLI with
+// --output=json). Each image is annotated with the first of them whose name,
+// a pattern as per path.Match, matches the name of the image or its base
+// name, or that has no name. Results with an error fail the image.
+func NewMock(fixtures string) (Provider, error) {
+	f, err := os.Open(fixtures)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p := &mockProvider{}
+	dec := json.NewDecoder(f)
+	for {
+		var r PluginResult
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %v", fixtures, err)
+		}
+		if _, err := path.Match(r.Name, ""); err != nil {
+			return nil, fmt.Errorf("invalid name %q in %s: %v", r.Name, fixtures, err)
+		}
+		for _, labels := range [][]Label{r.Labels, r.Landmarks, r.Celebrities, r.Logos, r.Objects} {
+			sortLabels(labels)
+		}
+		p.fixtures = append(p.fixtures, r)
+	}
+	return p, nil
+}
+
+func (p *mockProvider) Name() string { return "mock" }
+
+// Capabilities are all features, as fixtures may be of any.
+func (p *mockProvider) Capabilities() []Feature { return AllFeatures }
+
+func (p *mockProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i] = p.result(img.Name)
+		opts.Stats.addImages(1)
+	}
+	filterResults(results, opts)
+	return results, nil
+}
+
+func (p *mockProvider) result(name string) Result {
+	for _, f := range p.fixtures {
+		if len(f.Name) > 0 && !mockMatch(f.Name, name) {
+			continue
+		}
+		r := f.Result
+		r.Name = name
+		if len(f.Error) > 0 {
+			r.Err = fmt.Errorf("%s", f.Error)
+		}
+		return r
+	}
+	return Result{Name: name, Err: fmt.Errorf("no result for %s in the fixtures", name)}
+}
+
+func mockMatch(pattern, name string) bool {
+	for _, n := range []string{name, filepath.Base(name)} {
+		if ok, _ := path.Match(pattern, n); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+//go:build !onnx
+
+package vision
+
+import (
+	"fmt"
+
+	"github.com/asimshankar/visionapi/pkg/clip"
+)
+
+func newLocalProvider(cfg LocalConfig, labels []string) (Provider, error) {
+	return nil, fmt.Errorf("the local provider requires a binary built with -tags onnx")
+}
+
+func newCLIPProvider(cfg CLIPConfig, tokenizer *clip.Tokenizer) (Provider, error) {
+	return nil, fmt.Errorf("the clip provider requires a binary built with -tags onnx")
+}
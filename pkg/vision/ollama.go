@@ -0,0 +1,150 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+// Defaults of OllamaConfig.
+const (
+	DefaultOllamaHost  = "http://localhost:11434"
+	DefaultOllamaModel = "llava"
+)
+
+// OllamaConfig configures the Ollama provider, which annotates images by
+// prompting a vision model (e.g. llava or moondream) served locally by Ollama,
+// so that images never leave the machine.
+type OllamaConfig struct {
+	// Host is the URL of the Ollama server, defaulting to DefaultOllamaHost.
+	// The scheme defaults to http, as for the OLLAMA_HOST environment
+	// variable of Ollama (e.g. "127.0.0.1:11434").
+	Host string
+	// Model to prompt, which must have been pulled (e.g. with "ollama pull
+	// llava"), defaulting to DefaultOllamaModel.
+	Model string
+}
+
+type ollamaProvider struct {
+	client *http.Client
+	url    string
+	model  string
+}
+
+// NewOllama returns a Provider backed by a model served by Ollama, which
+// captions images (Result.Description), or answers Options.Question about
+// them, and reports labels and text in the same form as the other providers.
+// As for gemini and claude, its confidences are estimated by the model itself.
+func NewOllama(cfg OllamaConfig) (Provider, error) {
+	host := strings.TrimSuffix(cfg.Host, "/")
+	if len(host) == 0 {
+		host = DefaultOllamaHost
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	model := cfg.Model
+	if len(model) == 0 {
+		model = DefaultOllamaModel
+	}
+	return &ollamaProvider{http.DefaultClient, host + "/api/chat", model}, nil
+}
+
+func (p *ollamaProvider) Name() string { return "ollama" }
+
+func (p *ollamaProvider) Identity() string { return p.Name() + "/" + p.model }
+
+func (p *ollamaProvider) Capabilities() []Feature {
+	// Objects are not supported, as local models do not locate them.
+	return []Feature{FeatureLabels, FeatureText}
+}
+
+func (p *ollamaProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	prompt := modelPrompt(opts)
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := p.annotate(ctx, img, prompt, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+func (p *ollamaProvider) annotate(ctx context.Context, img Image, prompt string, result *Result, opts Options) error {
+	content, err := img.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	// From:
+	// https://github.com/ollama/ollama/blob/main/docs/api.md#generate-a-chat-completion
+	request := map[string]interface{}{
+		"model": p.model,
+		"messages": []interface{}{
+			map[string]interface{}{
+				"role":    "user",
+				"content": prompt,
+				"images":  [][]byte{content},
+			},
+		},
+		"format":  "json",
+		"stream":  false,
+		"options": map[string]interface{}{"temperature": 0},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	var response []byte
+	if err := withRetries(ctx, opts, func() error {
+		var err error
+		response, err = p.send(ctx, body, img.Name, opts)
+		return err
+	}); err != nil {
+		return err
+	}
+	var chat struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(response, &chat); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	annotation, err := decodeModelAnnotation(chat.Message.Content)
+	if err != nil {
+		return fmt.Errorf("failed to decode response of %s: %v", p.model, err)
+	}
+	return annotation.fill(content, result, opts)
+}
+
+// send sends a chat request. Failures to connect (typically as the server is
+// not running, which retries will not fix) and 404s (as the model has not
+// been pulled) are failures of the service, which another provider may not
+// have.
+func (p *ollamaProvider) send(ctx context.Context, body []byte, name string, opts Options) ([]byte, error) {
+	body, _, err := sendHTTP(ctx, p.client, httpRequest{
+		method:       "POST",
+		url:          p.url,
+		body:         body,
+		header:       http.Header{"Content-Type": {"application/json"}},
+		errorMessage: jsonErrorMessage,
+		serviceStatus: func(code int) bool {
+			return code == http.StatusNotFound
+		},
+		unreachable: "is Ollama running?",
+	}, name, opts)
+	return body, err
+}
@@ -0,0 +1,70 @@
+package vision
+
+import (
+	"regexp"
+	"strings"
+)
+
+// plateRE matches text that may be a license plate: 2 or 3 groups of letters
+// and digits (e.g. "ABC 1234", "AB12 CDE" or "B-MW 1234").
+var plateRE = regexp.MustCompile(`^[A-Z0-9]{1,4}(?:[ \-·.]?[A-Z0-9]{1,4}){1,2}$`)
+
+// IsPlate returns whether text is formatted like a license plate, with 4 to
+// 8 uppercase letters and digits, of which at least one of each.
+func IsPlate(text string) bool {
+	text = strings.TrimSpace(text)
+	if !plateRE.MatchString(text) {
+		return false
+	}
+	var letters, digits int
+	for _, c := range text {
+		switch {
+		case 'A' <= c && c <= 'Z':
+			letters++
+		case '0' <= c && c <= '9':
+			digits++
+		}
+	}
+	return letters > 0 && digits > 0 && letters+digits >= 4 && letters+digits <= 8
+}
+
+// FindPlates returns the license plates located in r: the objects detected as
+// license plates (FeatureObjects), described by the text within them if any,
+// and the blocks of text (FeatureText) formatted like plates (see IsPlate)
+// outside of them, with a confidence of 0.
+func FindPlates(r Result) []Label {
+	var plates []Label
+	for _, o := range r.Objects {
+		if key := NormalizeLabel(o.Description); o.Bounds == nil || (key != "license plate" && key != "vehicle registration plate") {
+			continue
+		}
+		plate := Label{Description: "license plate", Confidence: o.Confidence, Bounds: o.Bounds}
+		for _, t := range r.TextBlocks {
+			if t.Bounds != nil && contains(*o.Bounds, *t.Bounds) && IsPlate(t.Description) {
+				plate.Description = t.Description
+				break
+			}
+		}
+		plates = append(plates, plate)
+	}
+	objects := len(plates)
+	for _, t := range r.TextBlocks {
+		if t.Bounds == nil || !IsPlate(t.Description) {
+			continue
+		}
+		located := false
+		for _, p := range plates[:objects] {
+			located = located || contains(*p.Bounds, *t.Bounds)
+		}
+		if !located {
+			plates = append(plates, Label{Description: t.Description, Bounds: t.Bounds})
+		}
+	}
+	return plates
+}
+
+// contains returns whether the center of inner is within outer.
+func contains(outer, inner BoundingBox) bool {
+	x, y := inner.X+inner.Width/2, inner.Y+inner.Height/2
+	return outer.X <= x && x < outer.X+outer.Width && outer.Y <= y && y < outer.Y+outer.Height
+}
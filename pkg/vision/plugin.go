@@ -0,0 +1,128 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+// PluginPrefix is the prefix of the executables found by NewPlugin.
+const PluginPrefix = "visionapi-provider-"
+
+// PluginRequest is the JSON document written to the standard input of a
+// plugin. Content is base64-encoded, as per encoding/json.
+type PluginRequest struct {
+	Features      []Feature     `json:"features"`
+	MinConfidence float64       `json:"minConfidence,omitempty"`
+	MaxResults    int           `json:"maxResults,omitempty"`
+	Images        []PluginImage `json:"images"`
+}
+
+// PluginImage is an image in a PluginRequest, with either Content or URI set.
+type PluginImage struct {
+	Name    string `json:"name"`
+	Content []byte `json:"content,omitempty"`
+	URI     string `json:"uri,omitempty"`
+}
+
+// PluginResponse is the JSON document a plugin must write to its standard
+// output, with one result per image of the request, in the same order.
+type PluginResponse struct {
+	Results []PluginResult `json:"results"`
+}
+
+// PluginResult is the result for one image, with Error set if the image could
+// not be annotated.
+type PluginResult struct {
+	Result
+	Error string `json:"error,omitempty"`
+}
+
+type pluginProvider struct {
+	name, path string
+}
+
+// NewPlugin returns a Provider backed by the executable named PluginPrefix+name
+// in $PATH, e.g. visionapi-provider-foo. The executable is run once per image
+// with a PluginRequest on its standard input, and must write a PluginResponse
+// to its standard output and exit with a zero status. A non-zero exit status
+// fails the image, with the standard error of the plugin as the message.
+func NewPlugin(name string) (Provider, error) {
+	path, err := exec.LookPath(PluginPrefix + name)
+	if err != nil {
+		return nil, err
+	}
+	return &pluginProvider{name, path}, nil
+}
+
+func (p *pluginProvider) Name() string { return p.name }
+
+// Capabilities are all features, as those of a plugin are not known: a plugin
+// fails the images of features it does not support.
+func (p *pluginProvider) Capabilities() []Feature { return AllFeatures }
+
+func (p *pluginProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		defer opts.Stats.addImages(1)
+		if err := p.annotate(ctx, images[i], &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+func (p *pluginProvider) annotate(ctx context.Context, img Image, result *Result, opts Options) error {
+	request, err := json.Marshal(PluginRequest{
+		Features:      opts.RequestedFeatures(),
+		MinConfidence: opts.MinConfidence,
+		MaxResults:    opts.MaxResults,
+		Images:        []PluginImage{{Name: img.Name, Content: img.Content, URI: img.URI}},
+	})
+	if err != nil {
+		return err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.path)
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := opts.RateLimit.Wait(ctx); err != nil {
+		return err
+	}
+	opts.Stats.addRequest(int64(len(request)))
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+			return fmt.Errorf("%s failed: %v: %s", p.path, err, msg)
+		}
+		return fmt.Errorf("%s failed: %v", p.path, err)
+	}
+	if opts.Verbose {
+		log.Printf("%s: %s\n", img.Name, stdout.Bytes())
+	}
+	var response PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return fmt.Errorf("failed to decode response of %s: %v", p.path, err)
+	}
+	if len(response.Results) != 1 {
+		return fmt.Errorf("%s returned %d results for 1 image", p.path, len(response.Results))
+	}
+	r := response.Results[0]
+	if len(r.Error) > 0 {
+		return fmt.Errorf("%s", r.Error)
+	}
+	*result = r.Result
+	result.Name = img.Name
+	sortLabels(result.Labels)
+	return nil
+}
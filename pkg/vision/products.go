@@ -0,0 +1,45 @@
+package vision
+
+import "context"
+
+// ProductQuery selects the catalog that ProductSearcher.SearchProducts
+// searches.
+type ProductQuery struct {
+	// ProductSet is the resource name of the product set, e.g.
+	// projects/PROJECT/locations/LOCATION/productSets/SET.
+	ProductSet string
+	// Categories are the categories of the products searched, e.g.
+	// "apparel-v2", "homegoods-v2", "toys-v2", "packagedgoods-v1" or
+	// "general-v1".
+	Categories []string
+	// Filter, if set, restricts the products to those with matching labels,
+	// e.g. "color=red AND style=kids".
+	Filter string
+}
+
+// ProductMatch is a product of a catalog that is similar to (an object of) an
+// image searched by ProductSearcher.SearchProducts.
+type ProductMatch struct {
+	// Product is the resource name of the product.
+	Product     string `json:"product"`
+	DisplayName string `json:"displayName,omitempty"`
+	Category    string `json:"category,omitempty"`
+	// Labels are the key-value labels of the product, e.g. "color": "red".
+	Labels map[string]string `json:"labels,omitempty"`
+	// Score is the similarity of the product to the image in [0, 1].
+	Score float64 `json:"score"`
+	// Image is the resource name of the reference image of the product that
+	// matched.
+	Image string `json:"image,omitempty"`
+	// Bounds of the object of the image that matched, in pixels, if the image
+	// has several.
+	Bounds *BoundingBox `json:"bounds,omitempty"`
+}
+
+// ProductSearcher is implemented by Providers that can search a catalog of
+// products for those similar to images.
+type ProductSearcher interface {
+	// SearchProducts returns the products of the catalog of query that are
+	// similar to the objects of img, most similar first.
+	SearchProducts(ctx context.Context, img Image, query ProductQuery, opts Options) ([]ProductMatch, error)
+}
@@ -0,0 +1,134 @@
+package vision
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// modelPrompt returns the prompt requesting the annotations of opts from a
+// multimodal model (gemini, claude, ollama), as a JSON object decoded into
+// modelAnnotation. Options.Prompt replaces the default instructions, whose
+// response is the description, but not the format of the response, and the
+// labels are chosen from Options.Classes,
+// if set.
+func modelPrompt(opts Options) string {
+	instructions := "Annotate this image."
+	if len(opts.Prompt) > 0 {
+		instructions = strings.TrimSpace(opts.Prompt)
+	}
+	var fields []string
+	if len(opts.Question) > 0 {
+		fields = append(fields, fmt.Sprintf(`"description": a concise answer to the question %q about the image`, opts.Question))
+	} else if len(opts.Prompt) > 0 {
+		fields = append(fields, `"description": your response to the instructions above, as plain text`)
+	} else {
+		fields = append(fields, `"description": a one-sentence caption of the image`)
+	}
+	if opts.Has(FeatureLabels) && len(opts.Classes) > 0 {
+		// Zero-shot classification, as for clip, so that the labels are
+		// comparable with those of classifiers.
+		classes, _ := json.Marshal(opts.Classes)
+		fields = append(fields, fmt.Sprintf(`"labels": an array with an object for each of the classes %s, each with the "description" of the class, verbatim, and a "confidence" between 0 and 1 that the class applies to the image`, classes))
+	} else if opts.Has(FeatureLabels) {
+		fields = append(fields, `"labels": an array of the entities, activities and concepts in the image, most prominent first, each an object with a "description" (a short lowercase English noun phrase, e.g. "dog") and a "confidence" between 0 and 1`)
+	}
+	if opts.Has(FeatureText) {
+		hint := ""
+		if len(opts.LanguageHints) > 0 {
+			hint = fmt.Sprintf(" (expected to be in %s)", strings.Join(opts.LanguageHints, ", "))
+		}
+		fields = append(fields, `"text": all of the text in the image`+hint+`, verbatim, with a line per line of text, or "" if there is none`)
+	}
+	if opts.Has(FeatureObjects) {
+		fields = append(fields, `"objects": an array of the distinct physical objects in the image, each an object with a "description" (a short lowercase English noun, e.g. "car"), a "confidence" between 0 and 1 and a "box_2d" of [ymin, xmin, ymax, xmax] normalized to 0-1000`)
+	}
+	return instructions + " Respond with only a JSON object with the fields:\n- " + strings.Join(fields, "\n- ")
+}
+
+// modelAnnotation is the JSON object requested by modelPrompt.
+type modelAnnotation struct {
+	Description string `json:"description"`
+	Labels      []struct {
+		Description string  `json:"description"`
+		Confidence  float64 `json:"confidence"`
+	} `json:"labels"`
+	Text    string `json:"text"`
+	Objects []struct {
+		Description string  `json:"description"`
+		Confidence  float64 `json:"confidence"`
+		// Box is [ymin, xmin, ymax, xmax], normalized to 0-1000.
+		Box []float64 `json:"box_2d"`
+	} `json:"objects"`
+}
+
+// decodeModelAnnotation decodes the response of a model to modelPrompt,
+// ignoring any text around the JSON object (e.g. a Markdown code block).
+func decodeModelAnnotation(response string) (modelAnnotation, error) {
+	var a modelAnnotation
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return a, fmt.Errorf("no JSON object in response")
+	}
+	err := json.Unmarshal([]byte(response[start:end+1]), &a)
+	return a, err
+}
+
+// fill sets the annotations of opts in result, converting the bounds of
+// objects into pixels of the image (content).
+func (a modelAnnotation) fill(content []byte, result *Result, opts Options) error {
+	result.Description = a.Description
+	if opts.Has(FeatureLabels) {
+		classes := make(map[string]string) // by NormalizeLabel key
+		for _, c := range opts.Classes {
+			classes[NormalizeLabel(c)] = c
+		}
+		reported := make(map[string]bool)
+		for _, l := range a.Labels {
+			description := l.Description
+			if len(classes) > 0 {
+				// Models do not always repeat the classes verbatim, nor
+				// keep to them.
+				class, ok := classes[NormalizeLabel(description)]
+				if !ok || reported[class] {
+					continue
+				}
+				description, reported[class] = class, true
+			}
+			result.Labels = append(result.Labels, Label{Description: description, Confidence: clamp(l.Confidence)})
+		}
+		sortLabels(result.Labels)
+	}
+	if opts.Has(FeatureText) {
+		result.Text = strings.TrimSpace(a.Text)
+	}
+	if opts.Has(FeatureObjects) && len(a.Objects) > 0 {
+		width, height, err := imageSize(content)
+		if err != nil {
+			return err
+		}
+		for _, o := range a.Objects {
+			object := Label{Description: o.Description, Confidence: clamp(o.Confidence)}
+			if len(o.Box) == 4 {
+				x0, y0 := int(o.Box[1]*float64(width)/1000), int(o.Box[0]*float64(height)/1000)
+				x1, y1 := int(o.Box[3]*float64(width)/1000), int(o.Box[2]*float64(height)/1000)
+				object.Bounds = &BoundingBox{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}
+			}
+			result.Objects = append(result.Objects, object)
+		}
+		sortLabels(result.Objects)
+	}
+	return nil
+}
+
+// clamp returns v within [0, 1], as models do not always respect the range
+// they are asked for.
+func clamp(v float64) float64 {
+	switch {
+	case v < 0:
+		return 0
+	case v > 1:
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,265 @@
+package vision
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+// replicatePollInterval is the delay between checks of the status of
+// predictions.
+const replicatePollInterval = time.Second
+
+// ReplicateConfig configures the Replicate provider.
+type ReplicateConfig struct {
+	// Token is an API token of Replicate, see
+	// https://replicate.com/account/api-tokens
+	Token string
+	// Model is "owner/name:version", or "owner/name" for the latest version
+	// of official models.
+	Model string
+	// ImageInput is the name of the input of the model that images are sent
+	// as, defaulting to "image".
+	ImageInput string
+	// Inputs are other inputs of the model, e.g. the "query" of an open
+	// vocabulary detector.
+	Inputs map[string]string
+}
+
+type replicateProvider struct {
+	client  *http.Client
+	token   string
+	url     string // of the predictions of the model
+	version string // of the model, if set
+	image   string
+	inputs  map[string]string
+}
+
+// NewReplicate returns a Provider backed by a model hosted by Replicate, whose
+// output determines the results: captions (a string, or an object with a
+// "caption") are the description of images, tags (an object with "tags", e.g.
+// of the Recognize Anything Model) are labels without confidences, and
+// detections (an object with "detections" of a "label", "confidence" and
+// "bbox" of [x1, y1, x2, y2], e.g. of GroundingDINO) are objects.
+func NewReplicate(cfg ReplicateConfig) (Provider, error) {
+	if len(cfg.Token) == 0 {
+		return nil, fmt.Errorf("no API token provided")
+	}
+	model, version := cfg.Model, ""
+	if i := strings.Index(model, ":"); i >= 0 {
+		model, version = model[:i], model[i+1:]
+	}
+	if strings.Count(model, "/") != 1 {
+		return nil, fmt.Errorf("invalid model %q, must be owner/name:version or owner/name", cfg.Model)
+	}
+	// From:
+	// https://replicate.com/docs/reference/http#predictions.create
+	url := "https://api.replicate.com/v1/models/" + model + "/predictions"
+	if len(version) > 0 {
+		url = "https://api.replicate.com/v1/predictions"
+	}
+	image := cfg.ImageInput
+	if len(image) == 0 {
+		image = "image"
+	}
+	return &replicateProvider{http.DefaultClient, cfg.Token, url, version, image, cfg.Inputs}, nil
+}
+
+func (p *replicateProvider) Name() string { return "replicate" }
+
+// Identity includes the model (in the URL, or its version) and its inputs.
+func (p *replicateProvider) Identity() string {
+	inputs := make([]string, 0, len(p.inputs))
+	for k, v := range p.inputs {
+		inputs = append(inputs, k+"="+v)
+	}
+	sort.Strings(inputs)
+	return fmt.Sprintf("%s/%s/%s/%s/%q", p.Name(), p.url, p.version, p.image, inputs)
+}
+
+func (p *replicateProvider) Capabilities() []Feature {
+	return []Feature{FeatureLabels, FeatureObjects}
+}
+
+func (p *replicateProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := img.fetchUnlessHTTP(ctx); err != nil {
+			results[i].Err = err
+			return
+		}
+		if err := p.annotate(ctx, img, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// replicatePrediction is the subset of predictions used here.
+type replicatePrediction struct {
+	Status string          `json:"status"`
+	Output json.RawMessage `json:"output"`
+	Error  interface{}     `json:"error"`
+	URLs   struct {
+		Get string `json:"get"`
+	} `json:"urls"`
+}
+
+// replicateOutput is the output of the models supported, when an object.
+type replicateOutput struct {
+	Caption string `json:"caption"`
+	// Tags are either a list, or a string of tags separated by "|" or ",".
+	Tags       json.RawMessage `json:"tags"`
+	Detections []struct {
+		Label      string    `json:"label"`
+		Confidence float64   `json:"confidence"`
+		BBox       []float64 `json:"bbox"`
+	} `json:"detections"`
+}
+
+func (p *replicateProvider) annotate(ctx context.Context, img Image, result *Result, opts Options) error {
+	input := make(map[string]interface{})
+	for k, v := range p.inputs {
+		input[k] = v
+	}
+	if len(img.Content) > 0 {
+		input[p.image] = "data:" + http.DetectContentType(img.Content) + ";base64," + base64.StdEncoding.EncodeToString(img.Content)
+	} else {
+		// Replicate fetches the image itself.
+		input[p.image] = img.URI
+	}
+	request := map[string]interface{}{"input": input}
+	if len(p.version) > 0 {
+		request["version"] = p.version
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	var prediction replicatePrediction
+	if err := p.call(ctx, "POST", p.url, body, img.Name, &prediction, opts); err != nil {
+		return err
+	}
+	for prediction.Status != "succeeded" {
+		switch prediction.Status {
+		case "failed", "canceled":
+			return fmt.Errorf("prediction %s: %v", prediction.Status, prediction.Error)
+		}
+		if len(prediction.URLs.Get) == 0 {
+			return fmt.Errorf("no URL of the prediction in the response")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(replicatePollInterval):
+		}
+		if err := p.call(ctx, "GET", prediction.URLs.Get, nil, img.Name, &prediction, opts); err != nil {
+			return err
+		}
+	}
+	return replicateResult(prediction.Output, result, opts)
+}
+
+// replicateResult sets the annotations of output in result.
+func replicateResult(output json.RawMessage, result *Result, opts Options) error {
+	var (
+		caption string
+		chunks  []string
+		object  replicateOutput
+	)
+	switch {
+	case json.Unmarshal(output, &caption) == nil:
+	case json.Unmarshal(output, &chunks) == nil:
+		// Of language models, whose output is streamed.
+		caption = strings.Join(chunks, "")
+	case json.Unmarshal(output, &object) == nil:
+		caption = object.Caption
+	default:
+		return fmt.Errorf("unsupported output: %s", output)
+	}
+	// Captions of BLIP are prefixed.
+	result.Description = strings.TrimSpace(strings.TrimPrefix(caption, "Caption:"))
+	if opts.Has(FeatureLabels) && len(object.Tags) > 0 {
+		var tags []string
+		var s string
+		if err := json.Unmarshal(object.Tags, &s); err == nil {
+			tags = strings.FieldsFunc(s, func(r rune) bool { return r == '|' || r == ',' })
+		} else if err := json.Unmarshal(object.Tags, &tags); err != nil {
+			return fmt.Errorf("unsupported tags: %s", object.Tags)
+		}
+		for _, t := range tags {
+			if t = strings.TrimSpace(t); len(t) > 0 {
+				result.Labels = append(result.Labels, Label{Description: t})
+			}
+		}
+	}
+	if opts.Has(FeatureObjects) {
+		for _, d := range object.Detections {
+			o := Label{Description: d.Label, Confidence: d.Confidence}
+			if b := d.BBox; len(b) == 4 {
+				o.Bounds = &BoundingBox{X: int(b[0]), Y: int(b[1]), Width: int(b[2] - b[0]), Height: int(b[3] - b[1])}
+			}
+			result.Objects = append(result.Objects, o)
+		}
+		sortLabels(result.Objects)
+	}
+	return nil
+}
+
+// call sends a request (with retries), decoding the response into v.
+func (p *replicateProvider) call(ctx context.Context, method, url string, body []byte, name string, v interface{}, opts Options) error {
+	return withRetries(ctx, opts, func() error {
+		response, err := p.send(ctx, method, url, body, name, opts)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(response, v); err != nil {
+			return fmt.Errorf("failed to decode response: %v", err)
+		}
+		return nil
+	})
+}
+
+// send sends a request of the predictions API (with a body to create one),
+// returning the body of a successful response.
+func (p *replicateProvider) send(ctx context.Context, method, url string, body []byte, name string, opts Options) ([]byte, error) {
+	header := http.Header{"Authorization": {"Bearer " + p.token}}
+	if len(body) > 0 {
+		header.Set("Content-Type", "application/json")
+		// Wait (up to a minute) for the prediction to complete, rather than
+		// polling it.
+		header.Set("Prefer", "wait")
+	}
+	body, _, err := sendHTTP(ctx, p.client, httpRequest{
+		method: method,
+		url:    url,
+		body:   body,
+		header: header,
+		errorMessage: func(body []byte) string {
+			// Errors are problem details (RFC 7807).
+			var e struct {
+				Title  string `json:"title"`
+				Detail string `json:"detail"`
+			}
+			json.Unmarshal(body, &e)
+			return e.Detail
+		},
+	}, name, opts)
+	return body, err
+}
@@ -0,0 +1,104 @@
+package vision
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultRetryDelay = time.Second
+
+// retryableError is a failure that may succeed if the request is retried,
+// such as a 429 (Too Many Requests) or 5xx HTTP response.
+type retryableError struct {
+	err error
+	// retryAfter is the delay requested by the server, or 0 if none.
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+// ServiceError is the failure of an image caused by the service rather than
+// the image, such as an exhausted quota, invalid credentials or a transient
+// failure that persisted through retries, for which another provider may
+// succeed.
+type ServiceError struct {
+	Err error
+}
+
+func (e *ServiceError) Error() string { return e.Err.Error() }
+
+// IsServiceError returns true if err is a *ServiceError.
+func IsServiceError(err error) bool {
+	_, ok := err.(*ServiceError)
+	return ok
+}
+
+// isServiceStatus returns true for HTTP status codes that indicate a failure
+// of the service, or of authentication with it.
+func isServiceStatus(code int) bool {
+	return code == http.StatusUnauthorized || code == http.StatusForbidden || isRetryableStatus(code)
+}
+
+// isRetryableStatus returns true for HTTP status codes that indicate a
+// transient failure.
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// parseRetryAfter parses the Retry-After header, which is either a number of
+// seconds or an HTTP date. It returns 0 if the header is absent or invalid.
+func parseRetryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if len(v) == 0 {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// withRetries calls fn until it succeeds, fails with an error that is not a
+// *retryableError, or opts.Retries retries have been made (returning a
+// *ServiceError). Each attempt waits for opts.RateLimit, and retries are spaced
+// by the server's Retry-After delay if provided, and otherwise by a jittered
+// exponential backoff starting at opts.RetryDelay.
+func withRetries(ctx context.Context, opts Options, fn func() error) error {
+	delay := opts.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	for attempt := 0; ; attempt++ {
+		if err := opts.RateLimit.Wait(ctx); err != nil {
+			return err
+		}
+		err := fn()
+		re, ok := err.(*retryableError)
+		if !ok {
+			return err
+		}
+		if attempt >= opts.Retries {
+			return &ServiceError{re.err}
+		}
+		wait := re.retryAfter
+		if wait == 0 {
+			// "Equal jitter": half the delay plus a random amount up to the
+			// other half.
+			wait = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+			delay *= 2
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
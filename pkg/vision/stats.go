@@ -0,0 +1,53 @@
+package vision
+
+import "sync/atomic"
+
+// Stats counts the work done by providers, for reporting progress. The fields
+// are updated atomically (possibly concurrently with calls to Annotate) and
+// must be read with Snapshot.
+type Stats struct {
+	// Images is the number of images annotated, successfully or not.
+	Images int64
+	// Cached is the number of those images whose results were cached.
+	Cached int64
+	// Requests is the number of API requests made, including retries.
+	Requests int64
+	// Bytes is the number of bytes uploaded in those requests.
+	Bytes int64
+}
+
+// Snapshot returns a copy of s.
+func (s *Stats) Snapshot() Stats {
+	return Stats{
+		Images:   atomic.LoadInt64(&s.Images),
+		Cached:   atomic.LoadInt64(&s.Cached),
+		Requests: atomic.LoadInt64(&s.Requests),
+		Bytes:    atomic.LoadInt64(&s.Bytes),
+	}
+}
+
+// addImages records that n images have been annotated. s may be nil.
+func (s *Stats) addImages(n int) {
+	if s != nil {
+		atomic.AddInt64(&s.Images, int64(n))
+	}
+}
+
+// addRequest records a request uploading bytes. s may be nil.
+func (s *Stats) addRequest(bytes int64) {
+	if s != nil {
+		atomic.AddInt64(&s.Requests, 1)
+		atomic.AddInt64(&s.Bytes, bytes)
+	}
+}
+
+// Add adds the counts of d to s (e.g., those of a call to Annotate with its own
+// Stats). s may be nil.
+func (s *Stats) Add(d Stats) {
+	if s != nil {
+		atomic.AddInt64(&s.Images, d.Images)
+		atomic.AddInt64(&s.Cached, d.Cached)
+		atomic.AddInt64(&s.Requests, d.Requests)
+		atomic.AddInt64(&s.Bytes, d.Bytes)
+	}
+}
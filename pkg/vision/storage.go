@@ -0,0 +1,96 @@
+package vision
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"golang.org/x/oauth2/google"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// IsCloudStorageURI returns true if uri names an object in Google Cloud
+// Storage (gs://bucket/object) or Amazon S3 (s3://bucket/key).
+func IsCloudStorageURI(uri string) bool {
+	return strings.HasPrefix(uri, "gs://") || strings.HasPrefix(uri, "s3://")
+}
+
+// isHTTPURL returns true if uri can be fetched by APIs that accept image URLs.
+func isHTTPURL(uri string) bool {
+	return strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://")
+}
+
+// splitBucketURI splits a gs:// or s3:// URI into the bucket and object names.
+func splitBucketURI(uri string) (bucket, object string, err error) {
+	_, rest, _ := strings.Cut(uri, "://")
+	bucket, object, _ = strings.Cut(rest, "/")
+	if len(bucket) == 0 || len(object) == 0 {
+		return "", "", fmt.Errorf("invalid URI %q, must be of the form scheme://bucket/object", uri)
+	}
+	return bucket, object, nil
+}
+
+// downloadGCS returns the content of a gs:// object, using Application Default
+// Credentials.
+func downloadGCS(ctx context.Context, uri string) ([]byte, error) {
+	client, err := google.DefaultClient(ctx, storage.DevstorageReadOnlyScope)
+	if err != nil {
+		return nil, err
+	}
+	return downloadGCSWith(ctx, client, uri)
+}
+
+// downloadGCSWith returns the content of a gs:// object, authenticated by
+// client.
+func downloadGCSWith(ctx context.Context, client *http.Client, uri string) ([]byte, error) {
+	bucket, object, err := splitBucketURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	gcs, err := storage.New(client)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := gcs.Objects.Get(bucket, object).Context(ctx).Download()
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %v", err)
+	}
+	defer resp.Body.Close()
+	byts, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %v", err)
+	}
+	return byts, nil
+}
+
+// downloadS3 returns the content of an s3:// object, or only its first n bytes
+// if n is positive, with credentials from the standard AWS SDK chain.
+func downloadS3(ctx context.Context, uri string, n int) ([]byte, error) {
+	bucket, key, err := splitBucketURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, err
+	}
+	input := &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	if n > 0 {
+		input.Range = aws.String(fmt.Sprintf("bytes=0-%d", n-1))
+	}
+	output, err := s3.New(sess).GetObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %v", err)
+	}
+	defer output.Body.Close()
+	byts, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %v", err)
+	}
+	return byts, nil
+}
@@ -0,0 +1,175 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	translate "google.golang.org/api/translate/v2"
+)
+
+// 128 strings per request limit as per:
+// https://cloud.google.com/translate/quotas
+const googleMaxTranslateTexts = 128
+
+// Translator translates the labels and text of results, see Translate.
+type Translator interface {
+	// Name returns a short identifier of the translator, e.g. "google".
+	Name() string
+	// Translate returns the translation of each of texts into the language
+	// target (a BCP-47 code, e.g. "fr"), in the same order.
+	Translate(ctx context.Context, texts []string, target string) ([]string, error)
+}
+
+type googleTranslator struct {
+	service *translate.Service
+}
+
+// NewGoogleTranslator returns a Translator backed by the Google Cloud
+// Translation API (v2), authenticated as per cfg.
+func NewGoogleTranslator(ctx context.Context, cfg GoogleConfig) (Translator, error) {
+	client, err := googleClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	service, err := translate.New(client)
+	if err != nil {
+		return nil, err
+	}
+	return &googleTranslator{service}, nil
+}
+
+func (t *googleTranslator) Name() string { return "google" }
+
+func (t *googleTranslator) Translate(ctx context.Context, texts []string, target string) ([]string, error) {
+	translated := make([]string, 0, len(texts))
+	for start := 0; start < len(texts); start += googleMaxTranslateTexts {
+		batch := texts[start:min(start+googleMaxTranslateTexts, len(texts))]
+		res, err := t.service.Translations.List(batch, target).Format("text").Context(ctx).Do()
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Translations) != len(batch) {
+			return nil, fmt.Errorf("got %d translations of %d texts", len(res.Translations), len(batch))
+		}
+		for _, tr := range res.Translations {
+			translated = append(translated, tr.TranslatedText)
+		}
+	}
+	return translated, nil
+}
+
+// TranslatorPluginPrefix is the prefix of the executables found by
+// NewTranslatorPlugin.
+const TranslatorPluginPrefix = "visionapi-translator-"
+
+// TranslatorPluginRequest is the JSON document written to the standard input
+// of a translator plugin.
+type TranslatorPluginRequest struct {
+	Target string   `json:"target"`
+	Texts  []string `json:"texts"`
+}
+
+// TranslatorPluginResponse is the JSON document a translator plugin must
+// write to its standard output, with the translation of each of the texts of
+// the request, in the same order.
+type TranslatorPluginResponse struct {
+	Texts []string `json:"texts"`
+}
+
+type translatorPlugin struct {
+	name, path string
+}
+
+// NewTranslatorPlugin returns a Translator backed by the executable named
+// TranslatorPluginPrefix+name in $PATH, e.g. visionapi-translator-foo. The
+// executable is run once per call with a TranslatorPluginRequest on its
+// standard input, and must write a TranslatorPluginResponse to its standard
+// output and exit with a zero status.
+func NewTranslatorPlugin(name string) (Translator, error) {
+	path, err := exec.LookPath(TranslatorPluginPrefix + name)
+	if err != nil {
+		return nil, err
+	}
+	return &translatorPlugin{name, path}, nil
+}
+
+func (t *translatorPlugin) Name() string { return t.name }
+
+func (t *translatorPlugin) Translate(ctx context.Context, texts []string, target string) ([]string, error) {
+	request, err := json.Marshal(TranslatorPluginRequest{Target: target, Texts: texts})
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.path)
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+			return nil, fmt.Errorf("%s failed: %v: %s", t.path, err, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %v", t.path, err)
+	}
+	var response TranslatorPluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("failed to decode response of %s: %v", t.path, err)
+	}
+	if len(response.Texts) != len(texts) {
+		return nil, fmt.Errorf("%s returned %d translations of %d texts", t.path, len(response.Texts), len(texts))
+	}
+	return response.Texts, nil
+}
+
+// TranslateResults translates, with t, the descriptions of the labels (of
+// the image and of each frame) and objects, the caption and the text of each
+// of results that succeeded into target. Each distinct string is translated
+// once, unless already in known (the translations of earlier calls, by
+// original, which is updated).
+func TranslateResults(ctx context.Context, t Translator, results []Result, target string, known map[string]string) error {
+	var strs []*string
+	for i := range results {
+		r := &results[i]
+		if r.Err != nil {
+			continue
+		}
+		for _, labels := range [][]Label{r.Labels, r.Objects} {
+			for j := range labels {
+				strs = append(strs, &labels[j].Description)
+			}
+		}
+		for _, f := range r.Frames {
+			for j := range f.Labels {
+				strs = append(strs, &f.Labels[j].Description)
+			}
+		}
+		strs = append(strs, &r.Description, &r.Text)
+	}
+	var texts []string
+	pending := make(map[string]bool)
+	for _, s := range strs {
+		if _, ok := known[*s]; len(*s) > 0 && !ok && !pending[*s] {
+			texts = append(texts, *s)
+			pending[*s] = true
+		}
+	}
+	if len(texts) > 0 {
+		translated, err := t.Translate(ctx, texts, target)
+		if err != nil {
+			return err
+		}
+		for i, text := range texts {
+			known[text] = translated[i]
+		}
+	}
+	for _, s := range strs {
+		if tr, ok := known[*s]; ok {
+			*s = tr
+		}
+	}
+	return nil
+}
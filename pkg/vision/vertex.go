@@ -0,0 +1,183 @@
+package vision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+)
+
+type vertexProvider struct {
+	client *http.Client
+	url    string // of the predict method of the endpoint
+}
+
+// NewVertex returns a Provider backed by the custom-trained (e.g. AutoML)
+// image classification or object detection model deployed to the Vertex AI
+// endpoint cfg.Model, authenticated as for NewGoogleWithConfig except that
+// API keys are not supported.
+func NewVertex(ctx context.Context, cfg GoogleConfig) (Provider, error) {
+	parts := strings.Split(strings.Trim(cfg.Model, "/"), "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "endpoints" {
+		return nil, fmt.Errorf("invalid model %q, must be projects/PROJECT/locations/LOCATION/endpoints/ENDPOINT", cfg.Model)
+	}
+	if len(cfg.APIKey) > 0 {
+		return nil, fmt.Errorf("API keys are not supported by Vertex AI endpoints")
+	}
+	client, err := googleClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	// From:
+	// https://cloud.google.com/vertex-ai/docs/reference/rest/v1/projects.locations.endpoints/predict
+	url := fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/%s:predict", parts[3], strings.Join(parts, "/"))
+	return &vertexProvider{client, url}, nil
+}
+
+func (p *vertexProvider) Name() string { return "vertex" }
+
+func (p *vertexProvider) Identity() string { return p.Name() + "/" + p.url }
+
+func (p *vertexProvider) Capabilities() []Feature {
+	return []Feature{FeatureLabels, FeatureObjects}
+}
+
+func (p *vertexProvider) Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error) {
+	if err := checkFeatures(p.Name(), opts, p.Capabilities()...); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(images))
+	for i, img := range images {
+		results[i].Name = img.Name
+	}
+	parallel.For(len(images), opts.Concurrency, func(i int) {
+		img := images[i]
+		defer opts.Stats.addImages(1)
+		if err := p.annotate(ctx, img, &results[i], opts); err != nil {
+			results[i].Err = err
+		}
+	})
+	filterResults(results, opts)
+	return results, nil
+}
+
+// vertexPrediction is a prediction of AutoML image classification (display
+// names and confidences) and object detection (also bounding boxes,
+// as fractions [xMin, xMax, yMin, yMax] of the dimensions of the image)
+// models, see:
+// https://cloud.google.com/vertex-ai/docs/image-data/classification/get-predictions
+type vertexPrediction struct {
+	DisplayNames []string    `json:"displayNames"`
+	Confidences  []float64   `json:"confidences"`
+	BBoxes       [][]float64 `json:"bboxes"`
+}
+
+func (p *vertexProvider) annotate(ctx context.Context, img Image, result *Result, opts Options) error {
+	// Endpoints do not fetch images themselves, so gs:// objects are read
+	// with the credentials of p.
+	var content []byte
+	var err error
+	if len(img.Content) == 0 && strings.HasPrefix(img.URI, "gs://") {
+		content, err = downloadGCSWith(ctx, p.client, img.URI)
+	} else {
+		content, err = img.fetch(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	parameters := make(map[string]interface{})
+	if opts.MinConfidence > 0 {
+		parameters["confidenceThreshold"] = opts.MinConfidence
+	}
+	if opts.MaxResults > 0 {
+		parameters["maxPredictions"] = opts.MaxResults
+	}
+	// Endpoints of AutoML models accept a single instance per request.
+	body, err := json.Marshal(map[string]interface{}{
+		"instances":  []interface{}{map[string]interface{}{"content": content}},
+		"parameters": parameters,
+	})
+	if err != nil {
+		return err
+	}
+	var response struct {
+		Predictions []vertexPrediction `json:"predictions"`
+	}
+	if err := withRetries(ctx, opts, func() error {
+		byts, err := p.send(ctx, body, img.Name, opts)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(byts, &response); err != nil {
+			return fmt.Errorf("failed to decode response (is the model for image classification or object detection?): %v", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(response.Predictions) == 0 {
+		return nil
+	}
+	prediction := response.Predictions[0]
+	if len(prediction.Confidences) != len(prediction.DisplayNames) {
+		return fmt.Errorf("invalid prediction: %d display names, and %d confidences", len(prediction.DisplayNames), len(prediction.Confidences))
+	}
+	if len(prediction.BBoxes) == 0 {
+		// Of a classification model.
+		if opts.Has(FeatureLabels) {
+			for i, name := range prediction.DisplayNames {
+				result.Labels = append(result.Labels, Label{Description: name, Confidence: prediction.Confidences[i]})
+			}
+			sortLabels(result.Labels)
+		}
+		return nil
+	}
+	if !opts.Has(FeatureObjects) {
+		return nil
+	}
+	width, height, err := imageSize(content)
+	if err != nil {
+		return err
+	}
+	for i, b := range prediction.BBoxes {
+		if i >= len(prediction.DisplayNames) || len(b) != 4 {
+			break
+		}
+		result.Objects = append(result.Objects, Label{
+			Description: prediction.DisplayNames[i],
+			Confidence:  prediction.Confidences[i],
+			Bounds: &BoundingBox{
+				X:      int(b[0] * float64(width)),
+				Y:      int(b[2] * float64(height)),
+				Width:  int((b[1] - b[0]) * float64(width)),
+				Height: int((b[3] - b[2]) * float64(height)),
+			},
+		})
+	}
+	sortLabels(result.Objects)
+	return nil
+}
+
+// send sends a prediction request, returning the body of a successful
+// response.
+func (p *vertexProvider) send(ctx context.Context, body []byte, name string, opts Options) ([]byte, error) {
+	body, _, err := sendHTTP(ctx, p.client, httpRequest{
+		method: "POST",
+		url:    p.url,
+		body:   body,
+		header: http.Header{"Content-Type": {"application/json"}},
+		errorMessage: func(body []byte) string {
+			var e struct {
+				Error struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			json.Unmarshal(body, &e)
+			return e.Error.Message
+		},
+	}, name, opts)
+	return body, err
+}
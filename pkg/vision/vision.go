@@ -0,0 +1,533 @@
+// Package vision provides a common interface to cloud image annotation
+// services, such as the Google Cloud Vision API and the Microsoft Cognitive
+// Services Computer Vision API.
+package vision
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Feature is a type of annotation that can be requested for an image.
+type Feature string
+
+const (
+	FeatureLabels     Feature = "labels"
+	FeatureText       Feature = "text"
+	FeatureFaces      Feature = "faces"
+	FeatureLandmarks  Feature = "landmarks"
+	FeatureLogos      Feature = "logos"
+	FeatureSafeSearch Feature = "safe-search"
+	FeatureWeb        Feature = "web"
+	FeatureObjects    Feature = "objects"
+	FeatureCropHints  Feature = "crop-hints"
+	FeatureColors     Feature = "colors"
+	// FeatureDocument is dense text detection (e.g. of scanned pages), with
+	// the text in Result.Text and its layout in Result.Document.
+	FeatureDocument Feature = "document"
+	// FeatureCelebrities is the recognition of celebrities, by name, in
+	// Result.Celebrities.
+	FeatureCelebrities Feature = "celebrities"
+)
+
+// AllFeatures lists every Feature, in the order results are reported.
+var AllFeatures = []Feature{
+	FeatureLabels,
+	FeatureText,
+	FeatureFaces,
+	FeatureLandmarks,
+	FeatureCelebrities,
+	FeatureLogos,
+	FeatureSafeSearch,
+	FeatureWeb,
+	FeatureObjects,
+	FeatureCropHints,
+	FeatureColors,
+	FeatureDocument,
+}
+
+// ParseFeatures parses a comma-separated list of features, e.g. "labels,text".
+func ParseFeatures(s string) ([]Feature, error) {
+	var features []Feature
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) == 0 {
+			continue
+		}
+		f := Feature(name)
+		if !isKnownFeature(f) {
+			return nil, fmt.Errorf("unknown feature %q, must be one of %v", name, AllFeatures)
+		}
+		features = append(features, f)
+	}
+	return features, nil
+}
+
+func isKnownFeature(f Feature) bool {
+	for _, known := range AllFeatures {
+		if f == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Image is an image to be annotated.
+type Image struct {
+	// Name identifies the image (typically the filename) in results.
+	Name string
+	// Content is the encoded image (JPEG, PNG, GIF etc.).
+	Content []byte
+	// URI is the http(s) URL of the image, or a gs:// or s3:// object, used
+	// when Content is empty. Providers that can fetch images themselves are
+	// sent the URI, while others download it first.
+	URI string
+}
+
+// fetch returns the content of img, downloading it from img.URI if necessary.
+func (img Image) fetch(ctx context.Context) ([]byte, error) {
+	if len(img.Content) > 0 || len(img.URI) == 0 {
+		return img.Content, nil
+	}
+	return Download(ctx, img.URI)
+}
+
+// Download returns the content at the http(s) URL uri, or of the gs:// or s3://
+// object uri.
+func Download(ctx context.Context, uri string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(uri, "gs://"):
+		return downloadGCS(ctx, uri)
+	case strings.HasPrefix(uri, "s3://"):
+		return downloadS3(ctx, uri, 0)
+	}
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+	byts, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %v", err)
+	}
+	return byts, nil
+}
+
+// Options control the annotation requests made by a Provider.
+type Options struct {
+	// Features to request, defaulting to FeatureLabels if empty.
+	Features []Feature
+	// Concurrency is the maximum number of requests a provider will have in
+	// flight at once, defaulting to 1.
+	Concurrency int
+	// Retries is the number of times a request that failed with a transient
+	// error (e.g., HTTP 429 or 5xx) is retried.
+	Retries int
+	// RetryDelay is the initial delay between retries, which grows
+	// exponentially. Defaults to 1 second.
+	RetryDelay time.Duration
+	// MinConfidence, if positive, drops labels, landmarks, logos and objects
+	// with a lower confidence.
+	MinConfidence float64
+	// MaxResults, if positive, limits the number of labels, landmarks, logos
+	// and objects returned per image.
+	MaxResults int
+	// CropAspectRatios are the aspect ratios (width / height) of the crop
+	// hints requested with FeatureCropHints, if supported by the provider.
+	CropAspectRatios []float64
+	// LanguageHints are the languages (BCP-47 codes, e.g. "en") expected in
+	// the text detected with FeatureText or FeatureDocument, if supported by
+	// the provider. Providers detect the language otherwise.
+	LanguageHints []string
+	// Question, if set, is a question about each image answered in
+	// Result.Description, instead of a caption, by the providers that prompt
+	// models (gemini, claude, ollama, cloudflare).
+	Question string
+	// Prompt, if set, replaces the instructions of the providers that prompt
+	// models (e.g. "Describe any safety hazards visible"), but not the format
+	// of their responses: the response to the instructions is
+	// Result.Description, instead of a caption, unless Question is set.
+	Prompt string
+	// Classes are the labels that images are scored against by zero-shot
+	// classifiers (clip), e.g. the categories of a custom taxonomy. The
+	// providers that prompt models (gemini, claude, ollama) choose their
+	// labels from them too, with a confidence for each.
+	Classes []string
+	// Verbose, if true, logs the raw responses from the provider.
+	Verbose bool
+	// Stats, if not nil, is updated as images are annotated.
+	Stats *Stats
+	// RateLimit, if not nil, limits the rate of requests (including retries).
+	RateLimit *RateLimiter
+	// BatchBytes and BatchCount, if positive, limit the total size and the
+	// number of images of each request of providers that send images in
+	// batches (google), instead of the limits of the API.
+	BatchBytes int
+	BatchCount int
+}
+
+// RequestedFeatures returns the features to be requested by a provider.
+func (o Options) RequestedFeatures() []Feature {
+	if len(o.Features) == 0 {
+		return []Feature{FeatureLabels}
+	}
+	return o.Features
+}
+
+// Has returns true if f is one of the requested features.
+func (o Options) Has(f Feature) bool {
+	for _, r := range o.RequestedFeatures() {
+		if r == f {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFeatures returns an error if any of the requested features are not in
+// supported.
+func checkFeatures(provider string, opts Options, supported ...Feature) error {
+	for _, f := range opts.RequestedFeatures() {
+		ok := false
+		for _, s := range supported {
+			if f == s {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("feature %q is not supported by %s", f, provider)
+		}
+	}
+	return nil
+}
+
+// Unsupported returns the features of features that are not among the
+// Capabilities of p.
+func Unsupported(p Provider, features []Feature) []Feature {
+	supported := make(map[Feature]bool)
+	for _, f := range p.Capabilities() {
+		supported[f] = true
+	}
+	var unsupported []Feature
+	for _, f := range features {
+		if !supported[f] {
+			unsupported = append(unsupported, f)
+		}
+	}
+	return unsupported
+}
+
+// Label is an entity detected in an image, such as a label, landmark, logo or
+// object.
+type Label struct {
+	Description string `json:"description"`
+	// Confidence in the range [0, 1].
+	Confidence float64 `json:"confidence"`
+	// Bounds of the entity in the image, if localized (e.g., for objects and
+	// logos).
+	Bounds *BoundingBox `json:"bounds,omitempty"`
+	// Location of the entity, if known (e.g., for landmarks).
+	Location *LatLng `json:"location,omitempty"`
+}
+
+// LatLng is a geographic location, in degrees.
+type LatLng struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+func (l LatLng) String() string {
+	return fmt.Sprintf("%.6f,%.6f", l.Latitude, l.Longitude)
+}
+
+// BoundingBox is an axis-aligned rectangle in pixel coordinates of the image.
+type BoundingBox struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (b BoundingBox) String() string {
+	return fmt.Sprintf("%dx%d+%d+%d", b.Width, b.Height, b.X, b.Y)
+}
+
+// Face is a face detected in an image.
+type Face struct {
+	Bounds BoundingBox `json:"bounds"`
+	// Confidence of the detection in the range [0, 1], or 0 if the provider
+	// does not report one.
+	Confidence float64 `json:"confidence"`
+	// Landmarks are the positions of facial features, named as by the
+	// provider (e.g., "LEFT_EYE" for Google and "eyeLeft" for AWS).
+	Landmarks []FaceLandmark `json:"landmarks,omitempty"`
+	// Emotions maps emotions to their likelihood in the range [0, 1]. The
+	// emotions reported by all providers are named "joy", "sorrow", "anger"
+	// and "surprise".
+	Emotions map[string]float64 `json:"emotions,omitempty"`
+	// Age is the estimated age in years, or 0 if not reported.
+	Age float64 `json:"age,omitempty"`
+	// Gender is the estimated gender, if reported.
+	Gender string `json:"gender,omitempty"`
+}
+
+// FaceLandmark is the position, in pixels, of a facial feature.
+type FaceLandmark struct {
+	Type string `json:"type"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+}
+
+// SafeSearch is the likelihood, in the range [0, 1], of an image containing
+// various categories of sensitive content.
+type SafeSearch struct {
+	Adult    float64 `json:"adult"`
+	Racy     float64 `json:"racy"`
+	Violence float64 `json:"violence"`
+	Medical  float64 `json:"medical"`
+	Spoof    float64 `json:"spoof"`
+}
+
+// Document is the layout of the text detected in an image.
+type Document struct {
+	Pages []Page `json:"pages"`
+}
+
+// Page is a page of text, in an image of Width x Height pixels.
+type Page struct {
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	Blocks []Block `json:"blocks,omitempty"`
+}
+
+// Block is a block (e.g. column) of text on a Page.
+type Block struct {
+	Bounds     BoundingBox `json:"bounds"`
+	Confidence float64     `json:"confidence,omitempty"`
+	Paragraphs []Paragraph `json:"paragraphs,omitempty"`
+}
+
+// Paragraph is a paragraph (or line, depending on the provider) of a Block.
+type Paragraph struct {
+	Bounds     BoundingBox `json:"bounds"`
+	Confidence float64     `json:"confidence,omitempty"`
+	Words      []Word      `json:"words,omitempty"`
+}
+
+// Word is a word of a Paragraph.
+type Word struct {
+	Text       string      `json:"text"`
+	Bounds     BoundingBox `json:"bounds"`
+	Confidence float64     `json:"confidence,omitempty"`
+}
+
+// CropHint is a suggested crop of an image, e.g. for a thumbnail.
+type CropHint struct {
+	Bounds BoundingBox `json:"bounds"`
+	// Confidence of the hint in the range [0, 1], or 0 if the provider does
+	// not report one.
+	Confidence float64 `json:"confidence,omitempty"`
+	// ImportanceFraction is the fraction of the salient content of the image
+	// within the crop, if reported.
+	ImportanceFraction float64 `json:"importanceFraction,omitempty"`
+}
+
+// Color is a dominant color of an image.
+type Color struct {
+	// Hex is the color as #rrggbb, if reported.
+	Hex string `json:"hex,omitempty"`
+	// Name of the color (e.g. "blue"), if reported.
+	Name string `json:"name,omitempty"`
+	// Fraction of the pixels of the image that are of the color, in the
+	// range [0, 1], if reported.
+	Fraction float64 `json:"fraction,omitempty"`
+	// Score of the color in the range [0, 1], if reported.
+	Score float64 `json:"score,omitempty"`
+}
+
+func (c Color) String() string {
+	s := c.Hex
+	if len(c.Name) > 0 {
+		if len(s) > 0 {
+			s = c.Name + "(" + s + ")"
+		} else {
+			s = c.Name
+		}
+	}
+	if c.Fraction > 0 {
+		s += fmt.Sprintf(":%.0f%%", c.Fraction*100)
+	}
+	return s
+}
+
+// WebDetection describes references to an image found on the web.
+type WebDetection struct {
+	// BestGuessLabels are the best guesses of the topic of the image.
+	BestGuessLabels []string `json:"bestGuessLabels,omitempty"`
+	// Entities inferred from similar images on the web. Confidences are
+	// relative and not normalized to [0, 1].
+	Entities []Label `json:"entities,omitempty"`
+	// URLs of images on the web that fully or partially match the image,
+	// and of visually similar images.
+	FullMatchingImages    []string `json:"fullMatchingImages,omitempty"`
+	PartialMatchingImages []string `json:"partialMatchingImages,omitempty"`
+	SimilarImages         []string `json:"similarImages,omitempty"`
+	// Pages containing matching images.
+	Pages []WebPage `json:"pages,omitempty"`
+}
+
+// WebPage is a web page referring to an image.
+type WebPage struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+}
+
+// Result is the annotation of a single image.
+type Result struct {
+	// Name of the annotated Image.
+	Name string `json:"name"`
+	// Labels sorted by decreasing confidence.
+	Labels []Label `json:"labels,omitempty"`
+	// Description is a human readable caption of the image, if the provider
+	// generates one.
+	Description string `json:"description,omitempty"`
+	// Text detected in the image (FeatureText).
+	Text string `json:"text,omitempty"`
+	// TextBlocks are the blocks (or lines, depending on the provider) of
+	// Text, with their bounds.
+	TextBlocks []Label `json:"textBlocks,omitempty"`
+	// Faces detected in the image (FeatureFaces).
+	Faces []Face `json:"faces,omitempty"`
+	// Landmarks detected in the image (FeatureLandmarks).
+	Landmarks []Label `json:"landmarks,omitempty"`
+	// Celebrities recognized in the image (FeatureCelebrities), with the
+	// bounds of their faces.
+	Celebrities []Label `json:"celebrities,omitempty"`
+	// Logos detected in the image (FeatureLogos).
+	Logos []Label `json:"logos,omitempty"`
+	// Document is the layout of Text, if FeatureDocument was requested.
+	Document *Document `json:"document,omitempty"`
+	// CropHints detected in the image (FeatureCropHints), best first.
+	CropHints []CropHint `json:"cropHints,omitempty"`
+	// SafeSearch is non-nil if FeatureSafeSearch was requested.
+	SafeSearch *SafeSearch `json:"safeSearch,omitempty"`
+	// Web is non-nil if FeatureWeb was requested.
+	Web *WebDetection `json:"web,omitempty"`
+	// Objects localized in the image (FeatureObjects).
+	Objects []Label `json:"objects,omitempty"`
+	// Colors are the dominant colors of the image (FeatureColors), most
+	// dominant first.
+	Colors []Color `json:"colors,omitempty"`
+	// Frames are the labels of the frames sampled from a video, in order,
+	// which are merged (see MergeFrames) into Labels.
+	Frames []Frame `json:"frames,omitempty"`
+	// Err is non-nil if the image could not be annotated.
+	Err error `json:"-"`
+}
+
+// Frame is a frame of a video, and the labels detected in it.
+type Frame struct {
+	// Seconds from the start of the video.
+	Seconds float64 `json:"seconds"`
+	Labels  []Label `json:"labels,omitempty"`
+}
+
+// Provider is implemented by each annotation service.
+type Provider interface {
+	// Name returns a short identifier of the provider, e.g. "google".
+	Name() string
+	// Capabilities returns the features supported by the provider. Annotate
+	// fails (rather than any image) if others are requested.
+	Capabilities() []Feature
+	// Annotate returns one Result per image, in the same order as images.
+	//
+	// Failures affecting a single image are reported in Result.Err, while a
+	// non-nil error indicates that none of the images could be annotated.
+	Annotate(ctx context.Context, images []Image, opts Options) ([]Result, error)
+}
+
+// Configured is implemented by providers whose results depend on their
+// configuration, e.g. the model they run, and not just on their name.
+type Configured interface {
+	// Identity returns the name of the provider followed by its
+	// configuration, e.g. "gemini/gemini-2.5-flash", which differs whenever
+	// its results could.
+	Identity() string
+}
+
+// Identity returns the identity of p (see Configured), which is its name if
+// its results depend on the options of each request only.
+func Identity(p Provider) string {
+	if c, ok := p.(Configured); ok {
+		return c.Identity()
+	}
+	return p.Name()
+}
+
+type labelsByConfidence []Label
+
+func (l labelsByConfidence) Len() int           { return len(l) }
+func (l labelsByConfidence) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l labelsByConfidence) Less(i, j int) bool { return l[i].Confidence > l[j].Confidence }
+
+func sortLabels(labels []Label) {
+	sort.Stable(labelsByConfidence(labels))
+}
+
+// filterLabels applies opts.MinConfidence and opts.MaxResults to labels,
+// which must be sorted by decreasing confidence.
+func filterLabels(labels []Label, opts Options) []Label {
+	if opts.MinConfidence > 0 {
+		for i, l := range labels {
+			if l.Confidence < opts.MinConfidence {
+				labels = labels[:i]
+				break
+			}
+		}
+	}
+	if opts.MaxResults > 0 && len(labels) > opts.MaxResults {
+		labels = labels[:opts.MaxResults]
+	}
+	return labels
+}
+
+// filterResults applies filterLabels uniformly across providers, some of which
+// do not support filtering in their requests.
+func filterResults(results []Result, opts Options) {
+	for i := range results {
+		r := &results[i]
+		r.Labels = filterLabels(r.Labels, opts)
+		r.Landmarks = filterLabels(r.Landmarks, opts)
+		r.Celebrities = filterLabels(r.Celebrities, opts)
+		r.Logos = filterLabels(r.Logos, opts)
+		r.Objects = filterLabels(r.Objects, opts)
+	}
+}
+
+// imageSize returns the dimensions of an encoded image, which some providers
+// require to convert relative coordinates into pixels.
+func imageSize(content []byte) (width, height int, err error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode image: %v", err)
+	}
+	return cfg.Width, cfg.Height, nil
+}
@@ -0,0 +1,5 @@
+// Package visionpb is the gRPC service of the serve --grpc command, generated
+// from vision.proto.
+package visionpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative vision.proto
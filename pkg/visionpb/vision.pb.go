@@ -0,0 +1,1546 @@
+// The gRPC service of the serve --grpc command, whose messages mirror the
+// types of the github.com/asimshankar/visionapi/pkg/vision package.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: vision.proto
+
+package visionpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AnnotateRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Id is copied to the response, to match responses to requests on a stream.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Name of the image in the result, defaulting to the url or "image".
+	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// Types that are valid to be assigned to Image:
+	//
+	//	*AnnotateRequest_Content
+	//	*AnnotateRequest_Url
+	Image isAnnotateRequest_Image `protobuf_oneof:"image"`
+	// Features to detect (e.g. "labels"), defaulting to those of the
+	// server's --features.
+	Features      []string `protobuf:"bytes,5,rep,name=features,proto3" json:"features,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnnotateRequest) Reset() {
+	*x = AnnotateRequest{}
+	mi := &file_vision_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnnotateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnnotateRequest) ProtoMessage() {}
+
+func (x *AnnotateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnnotateRequest.ProtoReflect.Descriptor instead.
+func (*AnnotateRequest) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AnnotateRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *AnnotateRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *AnnotateRequest) GetImage() isAnnotateRequest_Image {
+	if x != nil {
+		return x.Image
+	}
+	return nil
+}
+
+func (x *AnnotateRequest) GetContent() []byte {
+	if x != nil {
+		if x, ok := x.Image.(*AnnotateRequest_Content); ok {
+			return x.Content
+		}
+	}
+	return nil
+}
+
+func (x *AnnotateRequest) GetUrl() string {
+	if x != nil {
+		if x, ok := x.Image.(*AnnotateRequest_Url); ok {
+			return x.Url
+		}
+	}
+	return ""
+}
+
+func (x *AnnotateRequest) GetFeatures() []string {
+	if x != nil {
+		return x.Features
+	}
+	return nil
+}
+
+type isAnnotateRequest_Image interface {
+	isAnnotateRequest_Image()
+}
+
+type AnnotateRequest_Content struct {
+	// Content is the encoded image (JPEG, PNG, GIF etc.).
+	Content []byte `protobuf:"bytes,3,opt,name=content,proto3,oneof"`
+}
+
+type AnnotateRequest_Url struct {
+	// Url is the http(s) URL of the image, or a gs:// or s3:// object, for
+	// the API to fetch.
+	Url string `protobuf:"bytes,4,opt,name=url,proto3,oneof"`
+}
+
+func (*AnnotateRequest_Content) isAnnotateRequest_Image() {}
+
+func (*AnnotateRequest_Url) isAnnotateRequest_Image() {}
+
+type AnnotateResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Provider is the API that annotated the image, e.g. "google".
+	Provider      string  `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
+	Result        *Result `protobuf:"bytes,3,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnnotateResponse) Reset() {
+	*x = AnnotateResponse{}
+	mi := &file_vision_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnnotateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnnotateResponse) ProtoMessage() {}
+
+func (x *AnnotateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnnotateResponse.ProtoReflect.Descriptor instead.
+func (*AnnotateResponse) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AnnotateResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *AnnotateResponse) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *AnnotateResponse) GetResult() *Result {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+// Result is the annotation of a single image.
+type Result struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Labels sorted by decreasing confidence.
+	Labels []*Label `protobuf:"bytes,2,rep,name=labels,proto3" json:"labels,omitempty"`
+	// Description is a human readable caption of the image, if the provider
+	// generates one.
+	Description string        `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Text        string        `protobuf:"bytes,4,opt,name=text,proto3" json:"text,omitempty"`
+	TextBlocks  []*Label      `protobuf:"bytes,5,rep,name=text_blocks,json=textBlocks,proto3" json:"text_blocks,omitempty"`
+	Faces       []*Face       `protobuf:"bytes,6,rep,name=faces,proto3" json:"faces,omitempty"`
+	Landmarks   []*Label      `protobuf:"bytes,7,rep,name=landmarks,proto3" json:"landmarks,omitempty"`
+	Logos       []*Label      `protobuf:"bytes,8,rep,name=logos,proto3" json:"logos,omitempty"`
+	Document    *Document     `protobuf:"bytes,9,opt,name=document,proto3" json:"document,omitempty"`
+	CropHints   []*CropHint   `protobuf:"bytes,10,rep,name=crop_hints,json=cropHints,proto3" json:"crop_hints,omitempty"`
+	SafeSearch  *SafeSearch   `protobuf:"bytes,11,opt,name=safe_search,json=safeSearch,proto3" json:"safe_search,omitempty"`
+	Web         *WebDetection `protobuf:"bytes,12,opt,name=web,proto3" json:"web,omitempty"`
+	Objects     []*Label      `protobuf:"bytes,13,rep,name=objects,proto3" json:"objects,omitempty"`
+	Colors      []*Color      `protobuf:"bytes,14,rep,name=colors,proto3" json:"colors,omitempty"`
+	// Error is set if the image could not be annotated.
+	Error         string `protobuf:"bytes,15,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Result) Reset() {
+	*x = Result{}
+	mi := &file_vision_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Result) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Result) ProtoMessage() {}
+
+func (x *Result) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Result.ProtoReflect.Descriptor instead.
+func (*Result) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Result) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Result) GetLabels() []*Label {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Result) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Result) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Result) GetTextBlocks() []*Label {
+	if x != nil {
+		return x.TextBlocks
+	}
+	return nil
+}
+
+func (x *Result) GetFaces() []*Face {
+	if x != nil {
+		return x.Faces
+	}
+	return nil
+}
+
+func (x *Result) GetLandmarks() []*Label {
+	if x != nil {
+		return x.Landmarks
+	}
+	return nil
+}
+
+func (x *Result) GetLogos() []*Label {
+	if x != nil {
+		return x.Logos
+	}
+	return nil
+}
+
+func (x *Result) GetDocument() *Document {
+	if x != nil {
+		return x.Document
+	}
+	return nil
+}
+
+func (x *Result) GetCropHints() []*CropHint {
+	if x != nil {
+		return x.CropHints
+	}
+	return nil
+}
+
+func (x *Result) GetSafeSearch() *SafeSearch {
+	if x != nil {
+		return x.SafeSearch
+	}
+	return nil
+}
+
+func (x *Result) GetWeb() *WebDetection {
+	if x != nil {
+		return x.Web
+	}
+	return nil
+}
+
+func (x *Result) GetObjects() []*Label {
+	if x != nil {
+		return x.Objects
+	}
+	return nil
+}
+
+func (x *Result) GetColors() []*Color {
+	if x != nil {
+		return x.Colors
+	}
+	return nil
+}
+
+func (x *Result) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type Label struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Description string                 `protobuf:"bytes,1,opt,name=description,proto3" json:"description,omitempty"`
+	// Confidence in the range [0, 1].
+	Confidence    float64      `protobuf:"fixed64,2,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	Bounds        *BoundingBox `protobuf:"bytes,3,opt,name=bounds,proto3" json:"bounds,omitempty"`
+	Location      *LatLng      `protobuf:"bytes,4,opt,name=location,proto3" json:"location,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Label) Reset() {
+	*x = Label{}
+	mi := &file_vision_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Label) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Label) ProtoMessage() {}
+
+func (x *Label) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Label.ProtoReflect.Descriptor instead.
+func (*Label) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Label) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Label) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *Label) GetBounds() *BoundingBox {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *Label) GetLocation() *LatLng {
+	if x != nil {
+		return x.Location
+	}
+	return nil
+}
+
+type LatLng struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Latitude      float64                `protobuf:"fixed64,1,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude     float64                `protobuf:"fixed64,2,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LatLng) Reset() {
+	*x = LatLng{}
+	mi := &file_vision_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LatLng) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LatLng) ProtoMessage() {}
+
+func (x *LatLng) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LatLng.ProtoReflect.Descriptor instead.
+func (*LatLng) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *LatLng) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *LatLng) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+// BoundingBox is an axis-aligned rectangle in pixel coordinates of the image.
+type BoundingBox struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             int32                  `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
+	Width         int32                  `protobuf:"varint,3,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32                  `protobuf:"varint,4,opt,name=height,proto3" json:"height,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BoundingBox) Reset() {
+	*x = BoundingBox{}
+	mi := &file_vision_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BoundingBox) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BoundingBox) ProtoMessage() {}
+
+func (x *BoundingBox) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BoundingBox.ProtoReflect.Descriptor instead.
+func (*BoundingBox) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BoundingBox) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *BoundingBox) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *BoundingBox) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *BoundingBox) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+type Face struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Bounds     *BoundingBox           `protobuf:"bytes,1,opt,name=bounds,proto3" json:"bounds,omitempty"`
+	Confidence float64                `protobuf:"fixed64,2,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	Landmarks  []*FaceLandmark        `protobuf:"bytes,3,rep,name=landmarks,proto3" json:"landmarks,omitempty"`
+	// Emotions maps emotions to their likelihood in the range [0, 1].
+	Emotions      map[string]float64 `protobuf:"bytes,4,rep,name=emotions,proto3" json:"emotions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"`
+	Age           float64            `protobuf:"fixed64,5,opt,name=age,proto3" json:"age,omitempty"`
+	Gender        string             `protobuf:"bytes,6,opt,name=gender,proto3" json:"gender,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Face) Reset() {
+	*x = Face{}
+	mi := &file_vision_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Face) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Face) ProtoMessage() {}
+
+func (x *Face) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Face.ProtoReflect.Descriptor instead.
+func (*Face) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Face) GetBounds() *BoundingBox {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *Face) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *Face) GetLandmarks() []*FaceLandmark {
+	if x != nil {
+		return x.Landmarks
+	}
+	return nil
+}
+
+func (x *Face) GetEmotions() map[string]float64 {
+	if x != nil {
+		return x.Emotions
+	}
+	return nil
+}
+
+func (x *Face) GetAge() float64 {
+	if x != nil {
+		return x.Age
+	}
+	return 0
+}
+
+func (x *Face) GetGender() string {
+	if x != nil {
+		return x.Gender
+	}
+	return ""
+}
+
+type FaceLandmark struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	X             int32                  `protobuf:"varint,2,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,3,opt,name=y,proto3" json:"y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FaceLandmark) Reset() {
+	*x = FaceLandmark{}
+	mi := &file_vision_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FaceLandmark) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FaceLandmark) ProtoMessage() {}
+
+func (x *FaceLandmark) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FaceLandmark.ProtoReflect.Descriptor instead.
+func (*FaceLandmark) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FaceLandmark) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *FaceLandmark) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *FaceLandmark) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+type SafeSearch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Adult         float64                `protobuf:"fixed64,1,opt,name=adult,proto3" json:"adult,omitempty"`
+	Racy          float64                `protobuf:"fixed64,2,opt,name=racy,proto3" json:"racy,omitempty"`
+	Violence      float64                `protobuf:"fixed64,3,opt,name=violence,proto3" json:"violence,omitempty"`
+	Medical       float64                `protobuf:"fixed64,4,opt,name=medical,proto3" json:"medical,omitempty"`
+	Spoof         float64                `protobuf:"fixed64,5,opt,name=spoof,proto3" json:"spoof,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SafeSearch) Reset() {
+	*x = SafeSearch{}
+	mi := &file_vision_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SafeSearch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SafeSearch) ProtoMessage() {}
+
+func (x *SafeSearch) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SafeSearch.ProtoReflect.Descriptor instead.
+func (*SafeSearch) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SafeSearch) GetAdult() float64 {
+	if x != nil {
+		return x.Adult
+	}
+	return 0
+}
+
+func (x *SafeSearch) GetRacy() float64 {
+	if x != nil {
+		return x.Racy
+	}
+	return 0
+}
+
+func (x *SafeSearch) GetViolence() float64 {
+	if x != nil {
+		return x.Violence
+	}
+	return 0
+}
+
+func (x *SafeSearch) GetMedical() float64 {
+	if x != nil {
+		return x.Medical
+	}
+	return 0
+}
+
+func (x *SafeSearch) GetSpoof() float64 {
+	if x != nil {
+		return x.Spoof
+	}
+	return 0
+}
+
+type Document struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pages         []*Page                `protobuf:"bytes,1,rep,name=pages,proto3" json:"pages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Document) Reset() {
+	*x = Document{}
+	mi := &file_vision_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Document) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Document) ProtoMessage() {}
+
+func (x *Document) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Document.ProtoReflect.Descriptor instead.
+func (*Document) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Document) GetPages() []*Page {
+	if x != nil {
+		return x.Pages
+	}
+	return nil
+}
+
+type Page struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Width         int32                  `protobuf:"varint,1,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32                  `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	Blocks        []*Block               `protobuf:"bytes,3,rep,name=blocks,proto3" json:"blocks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Page) Reset() {
+	*x = Page{}
+	mi := &file_vision_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Page) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Page) ProtoMessage() {}
+
+func (x *Page) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Page.ProtoReflect.Descriptor instead.
+func (*Page) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Page) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *Page) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *Page) GetBlocks() []*Block {
+	if x != nil {
+		return x.Blocks
+	}
+	return nil
+}
+
+type Block struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bounds        *BoundingBox           `protobuf:"bytes,1,opt,name=bounds,proto3" json:"bounds,omitempty"`
+	Confidence    float64                `protobuf:"fixed64,2,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	Paragraphs    []*Paragraph           `protobuf:"bytes,3,rep,name=paragraphs,proto3" json:"paragraphs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Block) Reset() {
+	*x = Block{}
+	mi := &file_vision_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Block) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Block) ProtoMessage() {}
+
+func (x *Block) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Block.ProtoReflect.Descriptor instead.
+func (*Block) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Block) GetBounds() *BoundingBox {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *Block) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *Block) GetParagraphs() []*Paragraph {
+	if x != nil {
+		return x.Paragraphs
+	}
+	return nil
+}
+
+type Paragraph struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bounds        *BoundingBox           `protobuf:"bytes,1,opt,name=bounds,proto3" json:"bounds,omitempty"`
+	Confidence    float64                `protobuf:"fixed64,2,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	Words         []*Word                `protobuf:"bytes,3,rep,name=words,proto3" json:"words,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Paragraph) Reset() {
+	*x = Paragraph{}
+	mi := &file_vision_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Paragraph) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Paragraph) ProtoMessage() {}
+
+func (x *Paragraph) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Paragraph.ProtoReflect.Descriptor instead.
+func (*Paragraph) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Paragraph) GetBounds() *BoundingBox {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *Paragraph) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *Paragraph) GetWords() []*Word {
+	if x != nil {
+		return x.Words
+	}
+	return nil
+}
+
+type Word struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	Bounds        *BoundingBox           `protobuf:"bytes,2,opt,name=bounds,proto3" json:"bounds,omitempty"`
+	Confidence    float64                `protobuf:"fixed64,3,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Word) Reset() {
+	*x = Word{}
+	mi := &file_vision_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Word) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Word) ProtoMessage() {}
+
+func (x *Word) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Word.ProtoReflect.Descriptor instead.
+func (*Word) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Word) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Word) GetBounds() *BoundingBox {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *Word) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+type CropHint struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Bounds             *BoundingBox           `protobuf:"bytes,1,opt,name=bounds,proto3" json:"bounds,omitempty"`
+	Confidence         float64                `protobuf:"fixed64,2,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	ImportanceFraction float64                `protobuf:"fixed64,3,opt,name=importance_fraction,json=importanceFraction,proto3" json:"importance_fraction,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *CropHint) Reset() {
+	*x = CropHint{}
+	mi := &file_vision_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CropHint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CropHint) ProtoMessage() {}
+
+func (x *CropHint) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CropHint.ProtoReflect.Descriptor instead.
+func (*CropHint) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *CropHint) GetBounds() *BoundingBox {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *CropHint) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *CropHint) GetImportanceFraction() float64 {
+	if x != nil {
+		return x.ImportanceFraction
+	}
+	return 0
+}
+
+type Color struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Hex is the color as #rrggbb, if reported.
+	Hex           string  `protobuf:"bytes,1,opt,name=hex,proto3" json:"hex,omitempty"`
+	Name          string  `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Fraction      float64 `protobuf:"fixed64,3,opt,name=fraction,proto3" json:"fraction,omitempty"`
+	Score         float64 `protobuf:"fixed64,4,opt,name=score,proto3" json:"score,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Color) Reset() {
+	*x = Color{}
+	mi := &file_vision_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Color) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Color) ProtoMessage() {}
+
+func (x *Color) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Color.ProtoReflect.Descriptor instead.
+func (*Color) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Color) GetHex() string {
+	if x != nil {
+		return x.Hex
+	}
+	return ""
+}
+
+func (x *Color) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Color) GetFraction() float64 {
+	if x != nil {
+		return x.Fraction
+	}
+	return 0
+}
+
+func (x *Color) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+type WebDetection struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	BestGuessLabels       []string               `protobuf:"bytes,1,rep,name=best_guess_labels,json=bestGuessLabels,proto3" json:"best_guess_labels,omitempty"`
+	Entities              []*Label               `protobuf:"bytes,2,rep,name=entities,proto3" json:"entities,omitempty"`
+	FullMatchingImages    []string               `protobuf:"bytes,3,rep,name=full_matching_images,json=fullMatchingImages,proto3" json:"full_matching_images,omitempty"`
+	PartialMatchingImages []string               `protobuf:"bytes,4,rep,name=partial_matching_images,json=partialMatchingImages,proto3" json:"partial_matching_images,omitempty"`
+	SimilarImages         []string               `protobuf:"bytes,5,rep,name=similar_images,json=similarImages,proto3" json:"similar_images,omitempty"`
+	Pages                 []*WebPage             `protobuf:"bytes,6,rep,name=pages,proto3" json:"pages,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *WebDetection) Reset() {
+	*x = WebDetection{}
+	mi := &file_vision_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WebDetection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WebDetection) ProtoMessage() {}
+
+func (x *WebDetection) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WebDetection.ProtoReflect.Descriptor instead.
+func (*WebDetection) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *WebDetection) GetBestGuessLabels() []string {
+	if x != nil {
+		return x.BestGuessLabels
+	}
+	return nil
+}
+
+func (x *WebDetection) GetEntities() []*Label {
+	if x != nil {
+		return x.Entities
+	}
+	return nil
+}
+
+func (x *WebDetection) GetFullMatchingImages() []string {
+	if x != nil {
+		return x.FullMatchingImages
+	}
+	return nil
+}
+
+func (x *WebDetection) GetPartialMatchingImages() []string {
+	if x != nil {
+		return x.PartialMatchingImages
+	}
+	return nil
+}
+
+func (x *WebDetection) GetSimilarImages() []string {
+	if x != nil {
+		return x.SimilarImages
+	}
+	return nil
+}
+
+func (x *WebDetection) GetPages() []*WebPage {
+	if x != nil {
+		return x.Pages
+	}
+	return nil
+}
+
+type WebPage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WebPage) Reset() {
+	*x = WebPage{}
+	mi := &file_vision_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WebPage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WebPage) ProtoMessage() {}
+
+func (x *WebPage) ProtoReflect() protoreflect.Message {
+	mi := &file_vision_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WebPage.ProtoReflect.Descriptor instead.
+func (*WebPage) Descriptor() ([]byte, []int) {
+	return file_vision_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *WebPage) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *WebPage) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+var File_vision_proto protoreflect.FileDescriptor
+
+const file_vision_proto_rawDesc = "" +
+	"\n" +
+	"\fvision.proto\x12\tvisionapi\"\x8a\x01\n" +
+	"\x0fAnnotateRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1a\n" +
+	"\acontent\x18\x03 \x01(\fH\x00R\acontent\x12\x12\n" +
+	"\x03url\x18\x04 \x01(\tH\x00R\x03url\x12\x1a\n" +
+	"\bfeatures\x18\x05 \x03(\tR\bfeaturesB\a\n" +
+	"\x05image\"i\n" +
+	"\x10AnnotateResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
+	"\bprovider\x18\x02 \x01(\tR\bprovider\x12)\n" +
+	"\x06result\x18\x03 \x01(\v2\x11.visionapi.ResultR\x06result\"\xe2\x04\n" +
+	"\x06Result\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12(\n" +
+	"\x06labels\x18\x02 \x03(\v2\x10.visionapi.LabelR\x06labels\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x12\n" +
+	"\x04text\x18\x04 \x01(\tR\x04text\x121\n" +
+	"\vtext_blocks\x18\x05 \x03(\v2\x10.visionapi.LabelR\n" +
+	"textBlocks\x12%\n" +
+	"\x05faces\x18\x06 \x03(\v2\x0f.visionapi.FaceR\x05faces\x12.\n" +
+	"\tlandmarks\x18\a \x03(\v2\x10.visionapi.LabelR\tlandmarks\x12&\n" +
+	"\x05logos\x18\b \x03(\v2\x10.visionapi.LabelR\x05logos\x12/\n" +
+	"\bdocument\x18\t \x01(\v2\x13.visionapi.DocumentR\bdocument\x122\n" +
+	"\n" +
+	"crop_hints\x18\n" +
+	" \x03(\v2\x13.visionapi.CropHintR\tcropHints\x126\n" +
+	"\vsafe_search\x18\v \x01(\v2\x15.visionapi.SafeSearchR\n" +
+	"safeSearch\x12)\n" +
+	"\x03web\x18\f \x01(\v2\x17.visionapi.WebDetectionR\x03web\x12*\n" +
+	"\aobjects\x18\r \x03(\v2\x10.visionapi.LabelR\aobjects\x12(\n" +
+	"\x06colors\x18\x0e \x03(\v2\x10.visionapi.ColorR\x06colors\x12\x14\n" +
+	"\x05error\x18\x0f \x01(\tR\x05error\"\xa8\x01\n" +
+	"\x05Label\x12 \n" +
+	"\vdescription\x18\x01 \x01(\tR\vdescription\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x02 \x01(\x01R\n" +
+	"confidence\x12.\n" +
+	"\x06bounds\x18\x03 \x01(\v2\x16.visionapi.BoundingBoxR\x06bounds\x12-\n" +
+	"\blocation\x18\x04 \x01(\v2\x11.visionapi.LatLngR\blocation\"B\n" +
+	"\x06LatLng\x12\x1a\n" +
+	"\blatitude\x18\x01 \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\x02 \x01(\x01R\tlongitude\"W\n" +
+	"\vBoundingBox\x12\f\n" +
+	"\x01x\x18\x01 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\x05R\x01y\x12\x14\n" +
+	"\x05width\x18\x03 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x04 \x01(\x05R\x06height\"\xaf\x02\n" +
+	"\x04Face\x12.\n" +
+	"\x06bounds\x18\x01 \x01(\v2\x16.visionapi.BoundingBoxR\x06bounds\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x02 \x01(\x01R\n" +
+	"confidence\x125\n" +
+	"\tlandmarks\x18\x03 \x03(\v2\x17.visionapi.FaceLandmarkR\tlandmarks\x129\n" +
+	"\bemotions\x18\x04 \x03(\v2\x1d.visionapi.Face.EmotionsEntryR\bemotions\x12\x10\n" +
+	"\x03age\x18\x05 \x01(\x01R\x03age\x12\x16\n" +
+	"\x06gender\x18\x06 \x01(\tR\x06gender\x1a;\n" +
+	"\rEmotionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\">\n" +
+	"\fFaceLandmark\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\f\n" +
+	"\x01x\x18\x02 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x03 \x01(\x05R\x01y\"\x82\x01\n" +
+	"\n" +
+	"SafeSearch\x12\x14\n" +
+	"\x05adult\x18\x01 \x01(\x01R\x05adult\x12\x12\n" +
+	"\x04racy\x18\x02 \x01(\x01R\x04racy\x12\x1a\n" +
+	"\bviolence\x18\x03 \x01(\x01R\bviolence\x12\x18\n" +
+	"\amedical\x18\x04 \x01(\x01R\amedical\x12\x14\n" +
+	"\x05spoof\x18\x05 \x01(\x01R\x05spoof\"1\n" +
+	"\bDocument\x12%\n" +
+	"\x05pages\x18\x01 \x03(\v2\x0f.visionapi.PageR\x05pages\"^\n" +
+	"\x04Page\x12\x14\n" +
+	"\x05width\x18\x01 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x02 \x01(\x05R\x06height\x12(\n" +
+	"\x06blocks\x18\x03 \x03(\v2\x10.visionapi.BlockR\x06blocks\"\x8d\x01\n" +
+	"\x05Block\x12.\n" +
+	"\x06bounds\x18\x01 \x01(\v2\x16.visionapi.BoundingBoxR\x06bounds\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x02 \x01(\x01R\n" +
+	"confidence\x124\n" +
+	"\n" +
+	"paragraphs\x18\x03 \x03(\v2\x14.visionapi.ParagraphR\n" +
+	"paragraphs\"\x82\x01\n" +
+	"\tParagraph\x12.\n" +
+	"\x06bounds\x18\x01 \x01(\v2\x16.visionapi.BoundingBoxR\x06bounds\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x02 \x01(\x01R\n" +
+	"confidence\x12%\n" +
+	"\x05words\x18\x03 \x03(\v2\x0f.visionapi.WordR\x05words\"j\n" +
+	"\x04Word\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12.\n" +
+	"\x06bounds\x18\x02 \x01(\v2\x16.visionapi.BoundingBoxR\x06bounds\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x03 \x01(\x01R\n" +
+	"confidence\"\x8b\x01\n" +
+	"\bCropHint\x12.\n" +
+	"\x06bounds\x18\x01 \x01(\v2\x16.visionapi.BoundingBoxR\x06bounds\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x02 \x01(\x01R\n" +
+	"confidence\x12/\n" +
+	"\x13importance_fraction\x18\x03 \x01(\x01R\x12importanceFraction\"_\n" +
+	"\x05Color\x12\x10\n" +
+	"\x03hex\x18\x01 \x01(\tR\x03hex\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1a\n" +
+	"\bfraction\x18\x03 \x01(\x01R\bfraction\x12\x14\n" +
+	"\x05score\x18\x04 \x01(\x01R\x05score\"\xa3\x02\n" +
+	"\fWebDetection\x12*\n" +
+	"\x11best_guess_labels\x18\x01 \x03(\tR\x0fbestGuessLabels\x12,\n" +
+	"\bentities\x18\x02 \x03(\v2\x10.visionapi.LabelR\bentities\x120\n" +
+	"\x14full_matching_images\x18\x03 \x03(\tR\x12fullMatchingImages\x126\n" +
+	"\x17partial_matching_images\x18\x04 \x03(\tR\x15partialMatchingImages\x12%\n" +
+	"\x0esimilar_images\x18\x05 \x03(\tR\rsimilarImages\x12(\n" +
+	"\x05pages\x18\x06 \x03(\v2\x12.visionapi.WebPageR\x05pages\"1\n" +
+	"\aWebPage\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title2\x9c\x01\n" +
+	"\x06Vision\x12C\n" +
+	"\bAnnotate\x12\x1a.visionapi.AnnotateRequest\x1a\x1b.visionapi.AnnotateResponse\x12M\n" +
+	"\x0eAnnotateStream\x12\x1a.visionapi.AnnotateRequest\x1a\x1b.visionapi.AnnotateResponse(\x010\x01B/Z-github.com/asimshankar/visionapi/pkg/visionpbb\x06proto3"
+
+var (
+	file_vision_proto_rawDescOnce sync.Once
+	file_vision_proto_rawDescData []byte
+)
+
+func file_vision_proto_rawDescGZIP() []byte {
+	file_vision_proto_rawDescOnce.Do(func() {
+		file_vision_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_vision_proto_rawDesc), len(file_vision_proto_rawDesc)))
+	})
+	return file_vision_proto_rawDescData
+}
+
+var file_vision_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_vision_proto_goTypes = []any{
+	(*AnnotateRequest)(nil),  // 0: visionapi.AnnotateRequest
+	(*AnnotateResponse)(nil), // 1: visionapi.AnnotateResponse
+	(*Result)(nil),           // 2: visionapi.Result
+	(*Label)(nil),            // 3: visionapi.Label
+	(*LatLng)(nil),           // 4: visionapi.LatLng
+	(*BoundingBox)(nil),      // 5: visionapi.BoundingBox
+	(*Face)(nil),             // 6: visionapi.Face
+	(*FaceLandmark)(nil),     // 7: visionapi.FaceLandmark
+	(*SafeSearch)(nil),       // 8: visionapi.SafeSearch
+	(*Document)(nil),         // 9: visionapi.Document
+	(*Page)(nil),             // 10: visionapi.Page
+	(*Block)(nil),            // 11: visionapi.Block
+	(*Paragraph)(nil),        // 12: visionapi.Paragraph
+	(*Word)(nil),             // 13: visionapi.Word
+	(*CropHint)(nil),         // 14: visionapi.CropHint
+	(*Color)(nil),            // 15: visionapi.Color
+	(*WebDetection)(nil),     // 16: visionapi.WebDetection
+	(*WebPage)(nil),          // 17: visionapi.WebPage
+	nil,                      // 18: visionapi.Face.EmotionsEntry
+}
+var file_vision_proto_depIdxs = []int32{
+	2,  // 0: visionapi.AnnotateResponse.result:type_name -> visionapi.Result
+	3,  // 1: visionapi.Result.labels:type_name -> visionapi.Label
+	3,  // 2: visionapi.Result.text_blocks:type_name -> visionapi.Label
+	6,  // 3: visionapi.Result.faces:type_name -> visionapi.Face
+	3,  // 4: visionapi.Result.landmarks:type_name -> visionapi.Label
+	3,  // 5: visionapi.Result.logos:type_name -> visionapi.Label
+	9,  // 6: visionapi.Result.document:type_name -> visionapi.Document
+	14, // 7: visionapi.Result.crop_hints:type_name -> visionapi.CropHint
+	8,  // 8: visionapi.Result.safe_search:type_name -> visionapi.SafeSearch
+	16, // 9: visionapi.Result.web:type_name -> visionapi.WebDetection
+	3,  // 10: visionapi.Result.objects:type_name -> visionapi.Label
+	15, // 11: visionapi.Result.colors:type_name -> visionapi.Color
+	5,  // 12: visionapi.Label.bounds:type_name -> visionapi.BoundingBox
+	4,  // 13: visionapi.Label.location:type_name -> visionapi.LatLng
+	5,  // 14: visionapi.Face.bounds:type_name -> visionapi.BoundingBox
+	7,  // 15: visionapi.Face.landmarks:type_name -> visionapi.FaceLandmark
+	18, // 16: visionapi.Face.emotions:type_name -> visionapi.Face.EmotionsEntry
+	10, // 17: visionapi.Document.pages:type_name -> visionapi.Page
+	11, // 18: visionapi.Page.blocks:type_name -> visionapi.Block
+	5,  // 19: visionapi.Block.bounds:type_name -> visionapi.BoundingBox
+	12, // 20: visionapi.Block.paragraphs:type_name -> visionapi.Paragraph
+	5,  // 21: visionapi.Paragraph.bounds:type_name -> visionapi.BoundingBox
+	13, // 22: visionapi.Paragraph.words:type_name -> visionapi.Word
+	5,  // 23: visionapi.Word.bounds:type_name -> visionapi.BoundingBox
+	5,  // 24: visionapi.CropHint.bounds:type_name -> visionapi.BoundingBox
+	3,  // 25: visionapi.WebDetection.entities:type_name -> visionapi.Label
+	17, // 26: visionapi.WebDetection.pages:type_name -> visionapi.WebPage
+	0,  // 27: visionapi.Vision.Annotate:input_type -> visionapi.AnnotateRequest
+	0,  // 28: visionapi.Vision.AnnotateStream:input_type -> visionapi.AnnotateRequest
+	1,  // 29: visionapi.Vision.Annotate:output_type -> visionapi.AnnotateResponse
+	1,  // 30: visionapi.Vision.AnnotateStream:output_type -> visionapi.AnnotateResponse
+	29, // [29:31] is the sub-list for method output_type
+	27, // [27:29] is the sub-list for method input_type
+	27, // [27:27] is the sub-list for extension type_name
+	27, // [27:27] is the sub-list for extension extendee
+	0,  // [0:27] is the sub-list for field type_name
+}
+
+func init() { file_vision_proto_init() }
+func file_vision_proto_init() {
+	if File_vision_proto != nil {
+		return
+	}
+	file_vision_proto_msgTypes[0].OneofWrappers = []any{
+		(*AnnotateRequest_Content)(nil),
+		(*AnnotateRequest_Url)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_vision_proto_rawDesc), len(file_vision_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   19,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_vision_proto_goTypes,
+		DependencyIndexes: file_vision_proto_depIdxs,
+		MessageInfos:      file_vision_proto_msgTypes,
+	}.Build()
+	File_vision_proto = out.File
+	file_vision_proto_goTypes = nil
+	file_vision_proto_depIdxs = nil
+}
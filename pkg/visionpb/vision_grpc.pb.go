@@ -0,0 +1,163 @@
+// The gRPC service of the serve --grpc command, whose messages mirror the
+// types of the github.com/asimshankar/visionapi/pkg/vision package.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: vision.proto
+
+package visionpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Vision_Annotate_FullMethodName       = "/visionapi.Vision/Annotate"
+	Vision_AnnotateStream_FullMethodName = "/visionapi.Vision/AnnotateStream"
+)
+
+// VisionClient is the client API for Vision service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VisionClient interface {
+	// Annotate annotates a single image.
+	Annotate(ctx context.Context, in *AnnotateRequest, opts ...grpc.CallOption) (*AnnotateResponse, error)
+	// AnnotateStream annotates each image sent on the stream, responding as
+	// each is annotated, which may not be in the order they were sent.
+	AnnotateStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[AnnotateRequest, AnnotateResponse], error)
+}
+
+type visionClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVisionClient(cc grpc.ClientConnInterface) VisionClient {
+	return &visionClient{cc}
+}
+
+func (c *visionClient) Annotate(ctx context.Context, in *AnnotateRequest, opts ...grpc.CallOption) (*AnnotateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AnnotateResponse)
+	err := c.cc.Invoke(ctx, Vision_Annotate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *visionClient) AnnotateStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[AnnotateRequest, AnnotateResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Vision_ServiceDesc.Streams[0], Vision_AnnotateStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AnnotateRequest, AnnotateResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Vision_AnnotateStreamClient = grpc.BidiStreamingClient[AnnotateRequest, AnnotateResponse]
+
+// VisionServer is the server API for Vision service.
+// All implementations must embed UnimplementedVisionServer
+// for forward compatibility.
+type VisionServer interface {
+	// Annotate annotates a single image.
+	Annotate(context.Context, *AnnotateRequest) (*AnnotateResponse, error)
+	// AnnotateStream annotates each image sent on the stream, responding as
+	// each is annotated, which may not be in the order they were sent.
+	AnnotateStream(grpc.BidiStreamingServer[AnnotateRequest, AnnotateResponse]) error
+	mustEmbedUnimplementedVisionServer()
+}
+
+// UnimplementedVisionServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedVisionServer struct{}
+
+func (UnimplementedVisionServer) Annotate(context.Context, *AnnotateRequest) (*AnnotateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Annotate not implemented")
+}
+func (UnimplementedVisionServer) AnnotateStream(grpc.BidiStreamingServer[AnnotateRequest, AnnotateResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method AnnotateStream not implemented")
+}
+func (UnimplementedVisionServer) mustEmbedUnimplementedVisionServer() {}
+func (UnimplementedVisionServer) testEmbeddedByValue()                {}
+
+// UnsafeVisionServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VisionServer will
+// result in compilation errors.
+type UnsafeVisionServer interface {
+	mustEmbedUnimplementedVisionServer()
+}
+
+func RegisterVisionServer(s grpc.ServiceRegistrar, srv VisionServer) {
+	// If the following call pancis, it indicates UnimplementedVisionServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Vision_ServiceDesc, srv)
+}
+
+func _Vision_Annotate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnnotateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VisionServer).Annotate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Vision_Annotate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VisionServer).Annotate(ctx, req.(*AnnotateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Vision_AnnotateStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(VisionServer).AnnotateStream(&grpc.GenericServerStream[AnnotateRequest, AnnotateResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Vision_AnnotateStreamServer = grpc.BidiStreamingServer[AnnotateRequest, AnnotateResponse]
+
+// Vision_ServiceDesc is the grpc.ServiceDesc for Vision service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Vision_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "visionapi.Vision",
+	HandlerType: (*VisionServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Annotate",
+			Handler:    _Vision_Annotate_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "AnnotateStream",
+			Handler:       _Vision_AnnotateStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "vision.proto",
+}
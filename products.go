@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/asimshankar/visionapi/internal/parallel"
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// productSearch is the products matching an image, in the output of the
+// products command.
+type productSearch struct {
+	File     string                `json:"file"`
+	Products []vision.ProductMatch `json:"products,omitempty"`
+	Error    string                `json:"error,omitempty"`
+}
+
+// productsMain implements the products command, which searches a catalog of
+// products for those similar to images, with a provider that searches products
+// (vision.ProductSearcher).
+func productsMain(args []string) {
+	fs := newFlagSet("products", "<filename or URL>...")
+	verbose := fs.Bool("v", false, "Verbose output")
+	var (
+		pf providerFlags
+		rf rateFlags
+		lf loadFlags
+	)
+	pf.register(fs, "google")
+	rf.register(fs)
+	lf.register(fs)
+	productSet := fs.String("product-set", "", "Resource name of the product set to search, e.g. projects/PROJECT/locations/us-west1/productSets/SET")
+	categories := fs.String("product-categories", "general-v1", "Comma-separated list of the categories of the products searched: apparel-v2, homegoods-v2, toys-v2, packagedgoods-v1 or general-v1")
+	filter := fs.String("filter", "", "Expression over the labels of the products searched, e.g. \"color=red AND style=kids\"")
+	minScore := fs.Float64("min-score", 0, "Drop products with a similarity score (in [0, 1]) below this")
+	maxResults := fs.Int("max-results", 10, "Maximum number of products to report per image")
+	output := fs.String("output", "text", "Output format: text or json")
+	retries := fs.Int("retries", 3, "Number of times to retry requests that fail with transient errors")
+	concurrency := fs.Int("concurrency", 1, "Number of files to load and requests to send in parallel")
+	parseFlags(fs, args)
+	if fs.NArg() < 1 || len(*productSet) == 0 {
+		fs.Usage()
+		return
+	}
+	if *output != "text" && *output != "json" {
+		fatal(fmt.Errorf("unknown --output=%q, must be text or json", *output))
+	}
+	limiter, err := rf.limiter()
+	if err != nil {
+		fatal(err)
+	}
+	opts := vision.Options{
+		MinConfidence: *minScore,
+		MaxResults:    *maxResults,
+		Concurrency:   *concurrency,
+		Retries:       *retries,
+		RetryDelay:    time.Second,
+		Verbose:       *verbose,
+		RateLimit:     limiter,
+	}
+	query := vision.ProductQuery{ProductSet: *productSet, Categories: splitList(*categories), Filter: *filter}
+	ctx := context.Background()
+	// Searches are not cached, so the provider is not wrapped.
+	p, err := pf.newProvider(ctx)
+	if err != nil {
+		fatal(err)
+	}
+	searcher, ok := p.(vision.ProductSearcher)
+	if !ok {
+		fatal(fmt.Errorf("searching products is not supported by %s", p.Name()))
+	}
+	lo := lf.options()
+	lo.applyDefaults(p.Name())
+	var summary runSummary
+	searches := make([]productSearch, 0, fs.NArg())
+	loadChunks(ctx, fs.Args(), lo, opts.Concurrency, func(images []vision.Image, failed []vision.Result) {
+		for _, r := range failed {
+			slog.Error("Unable to search", "file", r.Name, "err", r.Err)
+		}
+		summary.skipped += len(failed)
+		searched := make([]productSearch, len(images))
+		parallel.For(len(images), opts.Concurrency, func(i int) {
+			searched[i].File = images[i].Name
+			products, err := searcher.SearchProducts(ctx, images[i], query, opts)
+			if err != nil {
+				searched[i].Error = err.Error()
+				return
+			}
+			searched[i].Products = products
+		})
+		searches = append(searches, searched...)
+	})
+	for _, s := range searches {
+		if len(s.Error) > 0 {
+			slog.Error("Unable to search", "file", s.File, "err", s.Error)
+			summary.failed++
+			continue
+		}
+		summary.succeeded++
+		if *output == "json" {
+			continue
+		}
+		if len(s.Products) == 0 {
+			fmt.Printf("%s: no match\n", s.File)
+		}
+		for _, m := range s.Products {
+			name := m.DisplayName
+			if len(name) == 0 {
+				name = m.Product
+			}
+			fmt.Printf("%s: %.2f %s", s.File, m.Score, name)
+			if b := m.Bounds; b != nil {
+				fmt.Printf(" at (%d, %d) %dx%d", b.X, b.Y, b.Width, b.Height)
+			}
+			fmt.Println()
+		}
+	}
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(searches); err != nil {
+			fatal(err)
+		}
+	}
+	if code := summary.exitCode(); code != 0 {
+		exitWith(code)
+	}
+}
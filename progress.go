@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+const progressInterval = 200 * time.Millisecond
+
+// progressBar periodically redraws a single line with the number of files
+// annotated out of total, bytes uploaded and the estimated time remaining.
+//
+// It is also an io.Writer, for log output, which is printed above the bar.
+type progressBar struct {
+	w       io.Writer
+	total   int
+	stats   *vision.Stats
+	start   time.Time
+	mu      sync.Mutex
+	skipped int // files that failed to load, which count as done
+	perFile int // images annotated per file, e.g. by each of several providers
+	drawn   bool
+	stop    chan struct{}
+	done    chan struct{}
+	// first is when the first files were annotated, and firstCount how many.
+	first      time.Time
+	firstCount int
+}
+
+func newProgressBar(w io.Writer, total int, stats *vision.Stats) *progressBar {
+	b := &progressBar{
+		w:       w,
+		total:   total,
+		stats:   stats,
+		perFile: 1,
+		start:   time.Now(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+func (b *progressBar) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.mu.Lock()
+			b.draw()
+			b.mu.Unlock()
+		}
+	}
+}
+
+// Skip records files that were not sent to the provider.
+func (b *progressBar) Skip(n int) {
+	b.mu.Lock()
+	b.skipped += n
+	b.mu.Unlock()
+}
+
+// Write prints p (typically a log line) above the bar.
+func (b *progressBar) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clear()
+	n, err := b.w.Write(p)
+	b.draw()
+	return n, err
+}
+
+// Close draws the final state of the bar and stops redrawing it.
+func (b *progressBar) Close() {
+	close(b.stop)
+	<-b.done
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.draw()
+	if b.drawn {
+		fmt.Fprintln(b.w)
+		b.drawn = false
+	}
+}
+
+func (b *progressBar) clear() {
+	if b.drawn {
+		// Return to the start of the line and erase it.
+		fmt.Fprint(b.w, "\r\033[K")
+		b.drawn = false
+	}
+}
+
+// draw must be called with b.mu held.
+func (b *progressBar) draw() {
+	const width = 30
+	s := b.stats.Snapshot()
+	// Images annotated again by fallback providers are counted twice.
+	annotated := min(int(s.Images)/b.perFile, b.total)
+	done := min(annotated+b.skipped, b.total)
+	if annotated > 0 && b.first.IsZero() {
+		b.first, b.firstCount = time.Now(), annotated
+	}
+	filled := width
+	if b.total > 0 {
+		filled = width * done / b.total
+	}
+	bar := strings.Repeat("=", filled)
+	if filled < width {
+		bar += ">" + strings.Repeat(" ", width-filled-1)
+	}
+	line := fmt.Sprintf("[%s] %d/%d files, %s uploaded", bar, done, b.total, formatBytes(s.Bytes))
+	// Files are only counted once annotated (often a batch at a time), so the
+	// estimate is based on the rate since the first were annotated, or on the
+	// time they took until others are. Skipped files take no time, so they are
+	// left out of the rate.
+	if annotated > 0 && done < b.total {
+		perFile := b.first.Sub(b.start) / time.Duration(b.firstCount)
+		if n := annotated - b.firstCount; n > 0 {
+			perFile = time.Since(b.first) / time.Duration(n)
+		}
+		eta := perFile * time.Duration(b.total-done)
+		line += fmt.Sprintf(", ETA %v", eta.Round(time.Second))
+	}
+	b.clear()
+	fmt.Fprint(b.w, line)
+	b.drawn = true
+}
+
+// runSummary counts the outcome of each file, for the summary printed at the
+// end of a run.
+type runSummary struct {
+	succeeded, failed, skipped int
+}
+
+// log prints the summary to stderr, or logs it with --log-format=json.
+func (s *runSummary) log(stats vision.Stats, elapsed time.Duration) {
+	if logging.format == "json" {
+		slog.Info("Done", "succeeded", s.succeeded, "failed", s.failed, "skipped", s.skipped, "cached", stats.Cached, "requests", stats.Requests, "bytes", stats.Bytes, "elapsed", elapsed.Round(time.Millisecond).String())
+		return
+	}
+	s.print(os.Stderr, stats, elapsed)
+}
+
+// exitCode returns the exit code of the run: exitPartial if some images failed
+// (or were skipped), exitFailed if all of them did, and otherwise 0.
+func (s *runSummary) exitCode() int {
+	switch {
+	case s.failed+s.skipped == 0:
+		return 0
+	case s.succeeded == 0:
+		return exitFailed
+	default:
+		return exitPartial
+	}
+}
+
+func (s *runSummary) print(w io.Writer, stats vision.Stats, elapsed time.Duration) {
+	fmt.Fprintf(w, "%d succeeded, %d failed, %d skipped", s.succeeded, s.failed, s.skipped)
+	if stats.Cached > 0 {
+		fmt.Fprintf(w, " (%d from the cache)", stats.Cached)
+	}
+	fmt.Fprintf(w, "; %d API requests, %s uploaded in %v\n", stats.Requests, formatBytes(stats.Bytes), elapsed.Round(time.Millisecond))
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// isTerminal returns true if f is a terminal (character device).
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
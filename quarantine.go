@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// isFlagged returns true if the image is likely to contain adult, violent or
+// racy content.
+func isFlagged(s *vision.SafeSearch, threshold float64) bool {
+	return s != nil && (s.Adult >= threshold || s.Violence >= threshold || s.Racy >= threshold)
+}
+
+// quarantine moves filename into dir, renaming it if a file of the same name
+// already exists there, and returns the new path.
+func quarantine(filename, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	dest := uniquePath(dir, filepath.Base(filename))
+	return dest, moveFile(filename, dest)
+}
+
+// moveFile moves the file src to dest.
+func moveFile(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+	// Rename fails across filesystems, so fall back to copying.
+	if err := copyFile(src, dest); err != nil {
+		os.Remove(dest)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// uniquePath returns the path of a file named base in dir, with a numeric
+// suffix (e.g. photo.1.jpg) if a file of that name already exists.
+func uniquePath(dir, base string) string {
+	var (
+		ext  = filepath.Ext(base)
+		dest = filepath.Join(dir, base)
+	)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(dest); os.IsNotExist(err) {
+			return dest
+		}
+		dest = filepath.Join(dir, fmt.Sprintf("%s.%d%s", strings.TrimSuffix(base, ext), i, ext))
+	}
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, stat.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
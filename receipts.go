@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/asimshankar/visionapi/pkg/receipt"
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// receiptsMain implements the receipts subcommand, which detects the text of
+// photos of receipts and invoices and writes a ledger of their merchants,
+// dates, totals and line items, as extracted by receipt.Parse.
+func receiptsMain(args []string) {
+	fs := newFlagSet("receipts", "<filename or URL>...")
+	verbose := fs.Bool("v", false, "Verbose output")
+	var (
+		pf providerFlags
+		cf cacheFlags
+		rf rateFlags
+		lf loadFlags
+	)
+	pf.register(fs, "auto")
+	cf.register(fs)
+	rf.register(fs)
+	lf.register(fs)
+	output := fs.String("output", "csv", "Output format: csv (one row per receipt), csv-items (one row per line item) or json (a document per receipt, with its items and text)")
+	ocrLanguages := fs.String("ocr-languages", "", "Comma-separated list of the languages (BCP-47 codes, e.g. en,de) of the receipts, by default detected by the API")
+	retries := fs.Int("retries", 3, "Number of times to retry requests that fail with transient errors")
+	concurrency := fs.Int("concurrency", 1, "Number of files to load and requests to send in parallel")
+	parseFlags(fs, args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return
+	}
+	limiter, err := rf.limiter()
+	if err != nil {
+		fatal(err)
+	}
+	opts := vision.Options{
+		Concurrency:   *concurrency,
+		Retries:       *retries,
+		RetryDelay:    time.Second,
+		Verbose:       *verbose,
+		RateLimit:     limiter,
+		LanguageHints: splitList(*ocrLanguages),
+	}
+	ctx := context.Background()
+	p, err := pf.newProvider(ctx)
+	if err != nil {
+		fatal(err)
+	}
+	// Dense text detection reads receipts line by line, where supported.
+	opts.Features = []vision.Feature{vision.FeatureDocument}
+	if p.Name() == "aws" {
+		opts.Features = []vision.Feature{vision.FeatureText}
+	}
+	if p, err = cf.wrap(p); err != nil {
+		fatal(err)
+	}
+	out, err := newReceiptWriter(*output)
+	if err != nil {
+		fatal(err)
+	}
+	lo := lf.options()
+	lo.applyDefaults(p.Name())
+	var summary runSummary
+	annotateChunks(ctx, p, expandPatterns(fs.Args(), false, nil, false), lo, opts, &summary, func(_ []vision.Image, results []vision.Result) {
+		for _, r := range results {
+			if r.Err != nil {
+				slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+				summary.failed++
+				continue
+			}
+			rec := receipt.Parse(r.Text)
+			if len(rec.Total) == 0 {
+				slog.Warn("No total found", "file", r.Name)
+			}
+			if err := out.write(r, rec); err != nil {
+				fatal(err)
+			}
+			summary.succeeded++
+		}
+	})
+	if err := out.close(); err != nil {
+		fatal(err)
+	}
+	if code := summary.exitCode(); code != 0 {
+		exitWith(code)
+	}
+}
+
+// receiptWriter writes the receipts extracted from results to stdout.
+type receiptWriter struct {
+	format string
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+func newReceiptWriter(format string) (*receiptWriter, error) {
+	w := &receiptWriter{format: format}
+	var header []string
+	switch format {
+	case "csv":
+		header = []string{"file", "merchant", "date", "total", "items"}
+	case "csv-items":
+		header = []string{"file", "merchant", "date", "description", "amount"}
+	case "json":
+		w.json = json.NewEncoder(os.Stdout)
+		return w, nil
+	default:
+		return nil, fmt.Errorf("invalid --output(%s), must be 'csv', 'csv-items' or 'json'", format)
+	}
+	w.csv = csv.NewWriter(os.Stdout)
+	if err := w.csv.Write(header); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *receiptWriter) write(r vision.Result, rec receipt.Receipt) error {
+	switch w.format {
+	case "json":
+		return w.json.Encode(struct {
+			File string `json:"file"`
+			receipt.Receipt
+			Text string `json:"text"`
+		}{r.Name, rec, r.Text})
+	case "csv-items":
+		for _, item := range rec.Items {
+			if err := w.csv.Write([]string{r.Name, rec.Merchant, rec.Date, item.Description, item.Amount}); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return w.csv.Write([]string{r.Name, rec.Merchant, rec.Date, rec.Total, strconv.Itoa(len(rec.Items))})
+	}
+}
+
+func (w *receiptWriter) close() error {
+	if w.csv == nil {
+		return nil
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/asimshankar/visionapi/pkg/render"
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// redactImage writes a copy of the original image of r (see loadOriginal),
+// with areas (e.g. the bounds of faces, in pixels of the image sent) redacted
+// as per style (see render.Redact), into dir and returns its path. PNG images
+// are written as PNGs, and others as JPEGs, without any of the metadata of
+// the original.
+func redactImage(ctx context.Context, img vision.Image, r vision.Result, areas []vision.BoundingBox, style string, quality int, orient bool, dir string) (string, error) {
+	decoded, scale, err := loadOriginal(ctx, img, r, orient)
+	if err != nil {
+		return "", err
+	}
+	rects := make([]image.Rectangle, len(areas))
+	for i, b := range areas {
+		rects[i] = image.Rect(int(float64(b.X)*scale), int(float64(b.Y)*scale), int(float64(b.X+b.Width)*scale), int(float64(b.Y+b.Height)*scale))
+	}
+	redacted, err := render.Redact(decoded, rects, style)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	base := filepath.Base(r.Name)
+	if isURL(r.Name) {
+		base = path.Base(strings.SplitN(r.Name, "?", 2)[0])
+	}
+	var buf bytes.Buffer
+	ext := ".jpg"
+	if strings.EqualFold(filepath.Ext(base), ".png") {
+		ext = ".png"
+		err = png.Encode(&buf, redacted)
+	} else {
+		err = jpeg.Encode(&buf, redacted, &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		return "", err
+	}
+	dest := uniquePath(dir, strings.TrimSuffix(base, filepath.Ext(base))+ext)
+	return dest, ioutil.WriteFile(dest, buf.Bytes(), 0644)
+}
+
+// redactedAreas returns the bounds of the faces (if faces is set) and the
+// license plates (if plates is set, see vision.FindPlates) located in r.
+func redactedAreas(r vision.Result, faces, plates bool) []vision.BoundingBox {
+	var areas []vision.BoundingBox
+	if faces {
+		for _, f := range r.Faces {
+			areas = append(areas, f.Bounds)
+		}
+	}
+	if plates {
+		for _, p := range vision.FindPlates(r) {
+			areas = append(areas, *p.Bounds)
+		}
+	}
+	return areas
+}
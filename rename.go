@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/asimshankar/visionapi/pkg/metadata"
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// renameResult is the data of the template of --rename-template, which has
+// the fields of vision.Result.
+type renameResult struct {
+	vision.Result
+	// Date is the date the image was taken (as per its Exif metadata), or
+	// otherwise last modified, e.g. 2024-06-01.
+	Date string
+	// Caption is Description, e.g. "A golden retriever on a beach."
+	Caption string
+	// Label is the description of the most confident label, if any.
+	Label string
+	// Base is the name of the file without its directory and extension, e.g.
+	// IMG_4821, and Ext is its extension in lowercase, e.g. .jpg.
+	Base, Ext string
+}
+
+// renamer renames images as per a text/template of their results, e.g.
+// '{{.Date}}-{{.Caption | slug}}{{.Ext}}', in their directories.
+type renamer struct {
+	tmpl *template.Template
+}
+
+func newRenamer(text string) (*renamer, error) {
+	tmpl, err := template.New("rename").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --rename-template: %v", err)
+	}
+	return &renamer{tmpl}, nil
+}
+
+// rename renames the local image of r, and the files written alongside it
+// (by --write-text, --sidecar-json and --write-metadata --sidecar), returning
+// its new path, which is unchanged if the template names it as it is already.
+func (rn *renamer) rename(r vision.Result) (string, error) {
+	stat, err := os.Stat(r.Name)
+	if err != nil {
+		return "", err
+	}
+	data := renameResult{
+		Result:  r,
+		Date:    stat.ModTime().Format("2006-01-02"),
+		Caption: r.Description,
+		Ext:     strings.ToLower(filepath.Ext(r.Name)),
+	}
+	data.Base = strings.TrimSuffix(filepath.Base(r.Name), filepath.Ext(r.Name))
+	if l, ok := topAnnotation(r, vision.FeatureLabels); ok {
+		data.Label = l.Description
+	}
+	if byts, err := ioutil.ReadFile(r.Name); err == nil {
+		if t, ok := metadata.DateTaken(byts); ok {
+			data.Date = t.Format("2006-01-02")
+		}
+	}
+	var buf bytes.Buffer
+	if err := rn.tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	base := strings.TrimSpace(buf.String())
+	if strings.ContainsAny(base, `/\`) || len(strings.TrimSuffix(base, filepath.Ext(base))) == 0 {
+		return "", fmt.Errorf("invalid name %q of --rename-template", base)
+	}
+	if base == filepath.Base(r.Name) {
+		return r.Name, nil
+	}
+	dest := uniquePath(filepath.Dir(r.Name), base)
+	if err := os.Rename(r.Name, dest); err != nil {
+		return "", err
+	}
+	for _, sidecar := range []func(string) string{
+		func(f string) string { return f + ".txt" },
+		func(f string) string { return f + ".vision.json" },
+		metadata.SidecarPath,
+	} {
+		if _, err := os.Stat(sidecar(r.Name)); err == nil {
+			if err := os.Rename(sidecar(r.Name), sidecar(dest)); err != nil {
+				return dest, err
+			}
+		}
+	}
+	return dest, nil
+}
+
+// slug returns s in lowercase, with runs of other characters than letters and
+// digits replaced by single hyphens, and shortened to about 50 characters at
+// a hyphen, for names of files, e.g. "golden-retriever-on-a-beach".
+func slug(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	slug := b.String()
+	if len(slug) > 50 {
+		if i := strings.LastIndex(slug[:50], "-"); i > 0 {
+			slug = slug[:i]
+		}
+	}
+	return slug
+}
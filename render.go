@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/asimshankar/visionapi/pkg/render"
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// renderResult draws the annotations of r onto a copy of img, written as a PNG
+// into dir, and returns its path. The image sent to the provider is used
+// (rather than the original file), as that is what the bounds refer to.
+func renderResult(ctx context.Context, img vision.Image, r vision.Result, dir string) (string, error) {
+	content := img.Content
+	if len(content) == 0 {
+		var err error
+		if content, err = vision.Download(ctx, img.URI); err != nil {
+			return "", err
+		}
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %v", err)
+	}
+	rendered, err := render.Draw(decoded, r)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	base := filepath.Base(r.Name)
+	if isURL(r.Name) {
+		base = path.Base(strings.SplitN(r.Name, "?", 2)[0])
+	}
+	dest := uniquePath(dir, strings.TrimSuffix(base, filepath.Ext(base))+".png")
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, rendered); err != nil {
+		return "", err
+	}
+	return dest, ioutil.WriteFile(dest, buf.Bytes(), 0644)
+}
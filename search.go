@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// searchMain implements the search command, which ranks the images whose
+// stored labels match every word of a query.
+func searchMain(args []string) {
+	fs := newFlagSet("search", "<query>")
+	verbose := fs.Bool("v", false, "Print the labels that matched each image")
+	var cf cacheFlags
+	cf.register(fs)
+	db := fs.String("db", "", "SQLite database written with --output=sqlite:FILE to search, instead of the cache")
+	limit := fs.Int("limit", 20, "Maximum number of images to print (0 for no limit)")
+	minConfidence := fs.Float64("min-confidence", 0, "Ignore labels with a confidence (in [0, 1]) below this")
+	parseFlags(fs, args)
+	// Synonyms of the whole query and of each of its words are matched.
+	var terms []string
+	for _, word := range strings.Fields(vision.NormalizeLabel(strings.Join(fs.Args(), " "))) {
+		terms = append(terms, vision.NormalizeLabel(word))
+	}
+	if len(terms) == 0 {
+		fs.Usage()
+		return
+	}
+	labels := make(map[string][]vision.Label) // by image name
+	add := func(name string, l vision.Label) {
+		if l.Confidence >= *minConfidence {
+			labels[name] = append(labels[name], l)
+		}
+	}
+	if len(*db) > 0 {
+		if err := sqliteLabels(*db, add); err != nil {
+			fatal(err)
+		}
+	} else {
+		// The cache is searched even if it is disabled by --no-cache.
+		cf.disabled = false
+		c, err := cf.open()
+		if err != nil {
+			fatal(err)
+		}
+		err = c.Each(func(provider string, r vision.Result) {
+			for _, l := range resultLabels(r) {
+				add(r.Name, l)
+			}
+		})
+		if err != nil {
+			fatal(err)
+		}
+	}
+	type match struct {
+		name    string
+		score   float64
+		matched []string
+	}
+	var matches []match
+	for name, ls := range labels {
+		m := match{name: name}
+		for _, term := range terms {
+			l, ok := matchTerm(term, ls)
+			if !ok {
+				m.matched = nil
+				break
+			}
+			m.score += l.Confidence / float64(len(terms))
+			m.matched = append(m.matched, fmt.Sprintf("%s(%.2f)", l.Description, l.Confidence))
+		}
+		if len(m.matched) > 0 {
+			matches = append(matches, m)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].name < matches[j].name
+	})
+	if *limit > 0 && len(matches) > *limit {
+		matches = matches[:*limit]
+	}
+	for _, m := range matches {
+		if *verbose {
+			fmt.Printf("%.2f\t%s\t%s\n", m.score, m.name, strings.Join(m.matched, " "))
+		} else {
+			fmt.Printf("%.2f\t%s\n", m.score, m.name)
+		}
+	}
+}
+
+// matchTerm returns the most confident of labels that match term, a word of the
+// query: their normalized description is, or has a word that is, term.
+func matchTerm(term string, labels []vision.Label) (best vision.Label, ok bool) {
+	for _, l := range labels {
+		if ok && l.Confidence <= best.Confidence {
+			continue
+		}
+		key := vision.NormalizeLabel(l.Description)
+		if key == term {
+			best, ok = l, true
+			continue
+		}
+		for _, word := range strings.Fields(key) {
+			if vision.NormalizeLabel(word) == term {
+				best, ok = l, true
+				break
+			}
+		}
+	}
+	return best, ok
+}
+
+// resultLabels returns the labels, objects, landmarks, celebrities, logos and
+// web entities of r: the annotations that are searched, as in the database of
+// --output=sqlite.
+func resultLabels(r vision.Result) []vision.Label {
+	var labels []vision.Label
+	for _, ls := range [][]vision.Label{r.Labels, r.Objects, r.Landmarks, r.Celebrities, r.Logos} {
+		labels = append(labels, ls...)
+	}
+	if r.Web != nil {
+		labels = append(labels, r.Web.Entities...)
+	}
+	return labels
+}
+
+// sqliteLabels calls add with each label in the database at path, as written by
+// sqliteWriter, and the name of its image.
+func sqliteLabels(path string, add func(name string, l vision.Label)) error {
+	// Opening a database that does not exist would create it.
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %v", path, err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT images.name, labels.description, labels.confidence FROM labels JOIN images ON images.id = labels.image_id")
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			name string
+			l    vision.Label
+		)
+		if err := rows.Scan(&name, &l.Description, &l.Confidence); err != nil {
+			return err
+		}
+		add(name, l)
+	}
+	return rows.Err()
+}
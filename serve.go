@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// serveMaxBodyBytes limits the size of images posted to the server, before
+// they are re-encoded to fit --max-bytes.
+const serveMaxBodyBytes = 32 << 20
+
+// serveMain implements the serve command, an HTTP server that annotates the
+// images posted to /annotate, or with --grpc a gRPC server of the
+// visionpb.Vision service.
+func serveMain(args []string) {
+	fs := newFlagSet("serve", "")
+	verbose := fs.Bool("v", false, "Verbose output")
+	var (
+		pf providerFlags
+		cf cacheFlags
+		rf rateFlags
+	)
+	pf.register(fs, "auto")
+	cf.register(fs)
+	rf.register(fs)
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	useGRPC := fs.Bool("grpc", false, "Serve the gRPC service defined in pkg/visionpb/vision.proto instead of HTTP")
+	metricsAddr := fs.String("metrics-addr", "", "Address to serve Prometheus metrics on, at /metrics (default: --addr, unless --grpc is set)")
+	features := fs.String("features", "labels", "Comma-separated list of features to detect, unless a request sets ?features=: "+featureNames())
+	retries := fs.Int("retries", 3, "Number of times to retry requests that fail with transient errors")
+	retryDelay := fs.Duration("retry-delay", time.Second, "Initial delay between retries, which grows exponentially")
+	minConfidence := fs.Float64("min-confidence", 0, "Drop labels, landmarks, logos and objects with a confidence (in [0, 1]) below this")
+	maxResults := fs.Int("max-results", 0, "Maximum number of labels, landmarks, logos and objects per image (0 for no limit)")
+	force := fs.Bool("force", false, "Send images that are outside the recommended size limits anyway")
+	maxBytes := fs.Int("max-bytes", recommendedMaxBytes, "Maximum size of images to send, larger images are re-encoded to fit")
+	jpegQuality := fs.Int("jpeg-quality", 85, "JPEG quality (1-100) of re-encoded images")
+	noOrient := fs.Bool("no-orient", false, "Do not rotate images upright as per their Exif orientation (by re-encoding them) before sending them")
+	parseFlags(fs, args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return
+	}
+	s := &server{
+		opts: vision.Options{
+			Concurrency:   1,
+			Retries:       *retries,
+			RetryDelay:    *retryDelay,
+			MinConfidence: *minConfidence,
+			MaxResults:    *maxResults,
+			Verbose:       *verbose,
+			Stats:         &vision.Stats{},
+		},
+		lo: loadOptions{maxBytes: *maxBytes, force: *force, resize: true, jpegQuality: *jpegQuality, orient: !*noOrient},
+	}
+	var err error
+	if s.opts.Features, err = vision.ParseFeatures(*features); err != nil {
+		fatal(err)
+	}
+	// Shared by all requests to the server.
+	if s.opts.RateLimit, err = rf.limiter(); err != nil {
+		fatal(err)
+	}
+	cf.metrics = newMetrics()
+	if len(*metricsAddr) > 0 {
+		cf.metrics.serve(*metricsAddr)
+	}
+	if s.p, err = pf.newProvider(context.Background()); err != nil {
+		fatal(err)
+	}
+	if s.p, err = cf.wrap(s.p); err != nil {
+		fatal(err)
+	}
+	s.lo.applyDefaults(s.p.Name())
+	if *useGRPC {
+		fatal(serveGRPC(s, *addr))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/annotate", s.annotate)
+	if len(*metricsAddr) == 0 {
+		mux.Handle("/metrics", cf.metrics.handler())
+	}
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, "ok") })
+	slog.Info("Serving", "api", s.p.Name(), "url", "http://"+*addr+"/annotate")
+	fatal(http.ListenAndServe(*addr, otelhttp.NewHandler(mux, "serve")))
+}
+
+type server struct {
+	p    vision.Provider
+	opts vision.Options
+	lo   loadOptions
+}
+
+// annotate handles POST /annotate, whose body is an image (or empty, with
+// ?url= set to an image the API should fetch), responding with the result in
+// the format of --output=json. ?features= overrides --features and ?name= sets
+// the name of the image in the result.
+func (s *server) annotate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	var (
+		query = r.URL.Query()
+		opts  = s.opts
+		img   = vision.Image{Name: query.Get("name")}
+		err   error
+	)
+	if f := query.Get("features"); len(f) > 0 {
+		if opts.Features, err = vision.ParseFeatures(f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if u := query.Get("url"); len(u) > 0 {
+		if !isURL(u) {
+			http.Error(w, fmt.Sprintf("invalid url %q", u), http.StatusBadRequest)
+			return
+		}
+		img.URI = u
+		if len(img.Name) == 0 {
+			img.Name = u
+		}
+	} else {
+		if len(img.Name) == 0 {
+			img.Name = "image"
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, serveMaxBodyBytes))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if img.Content, err = prepareImage(r.Context(), img.Name, body, s.lo); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	results, err := s.p.Annotate(r.Context(), []vision.Image{img}, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	doc := newJSONResult(results[0], s.p.Name())
+	status := http.StatusOK
+	if err := results[0].Err; err != nil {
+		slog.Error("Unable to annotate", "file", img.Name, "err", err)
+		status = http.StatusBadGateway
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(doc)
+}
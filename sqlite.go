@@ -0,0 +1,217 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema is the schema of the database written by sqliteWriter, with one
+// row in images per annotated image and rows in the other tables per
+// annotation of it.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS images (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	provider TEXT NOT NULL,
+	annotated_at TEXT NOT NULL,
+	description TEXT,
+	text TEXT,
+	adult REAL,
+	racy REAL,
+	violence REAL,
+	medical REAL,
+	spoof REAL
+);
+-- Labels, objects, landmarks, logos and web entities.
+CREATE TABLE IF NOT EXISTS labels (
+	image_id INTEGER NOT NULL REFERENCES images(id) ON DELETE CASCADE,
+	feature TEXT NOT NULL,
+	description TEXT NOT NULL COLLATE NOCASE,
+	confidence REAL NOT NULL,
+	x INTEGER,
+	y INTEGER,
+	width INTEGER,
+	height INTEGER,
+	latitude REAL,
+	longitude REAL
+);
+CREATE INDEX IF NOT EXISTS labels_by_description ON labels(description, confidence);
+CREATE INDEX IF NOT EXISTS labels_by_image ON labels(image_id);
+CREATE TABLE IF NOT EXISTS faces (
+	image_id INTEGER NOT NULL REFERENCES images(id) ON DELETE CASCADE,
+	confidence REAL NOT NULL,
+	x INTEGER NOT NULL,
+	y INTEGER NOT NULL,
+	width INTEGER NOT NULL,
+	height INTEGER NOT NULL,
+	age REAL,
+	gender TEXT,
+	joy REAL,
+	sorrow REAL,
+	anger REAL,
+	surprise REAL
+);
+CREATE INDEX IF NOT EXISTS faces_by_image ON faces(image_id);
+CREATE TABLE IF NOT EXISTS text_blocks (
+	image_id INTEGER NOT NULL REFERENCES images(id) ON DELETE CASCADE,
+	text TEXT NOT NULL,
+	confidence REAL NOT NULL,
+	x INTEGER,
+	y INTEGER,
+	width INTEGER,
+	height INTEGER
+);
+CREATE INDEX IF NOT EXISTS text_blocks_by_image ON text_blocks(image_id);
+CREATE TABLE IF NOT EXISTS colors (
+	image_id INTEGER NOT NULL REFERENCES images(id) ON DELETE CASCADE,
+	hex TEXT,
+	name TEXT,
+	fraction REAL,
+	score REAL
+);
+CREATE INDEX IF NOT EXISTS colors_by_image ON colors(image_id);
+`
+
+// sqliteWriter writes results into a SQLite database, replacing the earlier
+// annotations of images that are annotated again. Errors are logged.
+type sqliteWriter struct {
+	db       *sql.DB
+	provider string
+}
+
+func newSQLiteWriter(path, provider string) (*sqliteWriter, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %v", path, err)
+	}
+	// A single connection, so that the pragmas apply to all statements.
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{"PRAGMA foreign_keys = ON", "PRAGMA journal_mode = WAL", sqliteSchema} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("unable to initialize %s: %v", path, err)
+		}
+	}
+	return &sqliteWriter{db, provider}, nil
+}
+
+func (s *sqliteWriter) Write(r vision.Result) error {
+	if r.Err != nil {
+		slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := s.insert(tx, r); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("unable to write %s to the database: %v", r.Name, err)
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteWriter) insert(tx *sql.Tx, r vision.Result) error {
+	if _, err := tx.Exec("DELETE FROM images WHERE name = ?", r.Name); err != nil {
+		return err
+	}
+	var safe [5]interface{}
+	if ss := r.SafeSearch; ss != nil {
+		safe = [5]interface{}{ss.Adult, ss.Racy, ss.Violence, ss.Medical, ss.Spoof}
+	}
+	res, err := tx.Exec("INSERT INTO images (name, provider, annotated_at, description, text, adult, racy, violence, medical, spoof) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		r.Name, s.provider, time.Now().UTC().Format(time.RFC3339), nullString(r.Description), nullString(r.Text), safe[0], safe[1], safe[2], safe[3], safe[4])
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	var web []vision.Label
+	if r.Web != nil {
+		web = r.Web.Entities
+	}
+	for _, ls := range []struct {
+		feature vision.Feature
+		labels  []vision.Label
+	}{
+		{vision.FeatureLabels, r.Labels},
+		{vision.FeatureObjects, r.Objects},
+		{vision.FeatureLandmarks, r.Landmarks},
+		{vision.FeatureCelebrities, r.Celebrities},
+		{vision.FeatureLogos, r.Logos},
+		{vision.FeatureWeb, web},
+	} {
+		for _, l := range ls.labels {
+			x, y, w, h := nullBounds(l.Bounds)
+			var lat, lng interface{}
+			if l.Location != nil {
+				lat, lng = l.Location.Latitude, l.Location.Longitude
+			}
+			if _, err := tx.Exec("INSERT INTO labels VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", id, string(ls.feature), l.Description, l.Confidence, x, y, w, h, lat, lng); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range r.Faces {
+		emotion := func(name string) interface{} {
+			if v, ok := f.Emotions[name]; ok {
+				return v
+			}
+			return nil
+		}
+		var age interface{}
+		if f.Age > 0 {
+			age = f.Age
+		}
+		b := f.Bounds
+		if _, err := tx.Exec("INSERT INTO faces VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", id, f.Confidence, b.X, b.Y, b.Width, b.Height, age, nullString(f.Gender),
+			emotion("joy"), emotion("sorrow"), emotion("anger"), emotion("surprise")); err != nil {
+			return err
+		}
+	}
+	for _, t := range r.TextBlocks {
+		x, y, w, h := nullBounds(t.Bounds)
+		if _, err := tx.Exec("INSERT INTO text_blocks VALUES (?, ?, ?, ?, ?, ?, ?)", id, t.Description, t.Confidence, x, y, w, h); err != nil {
+			return err
+		}
+	}
+	for _, c := range r.Colors {
+		if _, err := tx.Exec("INSERT INTO colors VALUES (?, ?, ?, ?, ?)", id, nullString(c.Hex), nullString(c.Name), nullFloat(c.Fraction), nullFloat(c.Score)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteWriter) Close() error { return s.db.Close() }
+
+// nullString returns s, or NULL if it is empty.
+func nullString(s string) interface{} {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// nullFloat returns f, or NULL if it is 0 (i.e., not reported).
+func nullFloat(f float64) interface{} {
+	if f == 0 {
+		return nil
+	}
+	return f
+}
+
+// nullBounds returns the coordinates of b, or NULLs if it is nil.
+func nullBounds(b *vision.BoundingBox) (x, y, width, height interface{}) {
+	if b == nil {
+		return nil, nil, nil, nil
+	}
+	return b.X, b.Y, b.Width, b.Height
+}
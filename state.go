@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/asimshankar/visionapi/pkg/cache"
+)
+
+// checkpointFiles is the maximum number of files annotated between
+// checkpoints of the runState.
+const checkpointFiles = 100
+
+// chunkBytes is the size of the images loaded at once, and annotated between
+// checkpoints, unless --concurrency requires more.
+const chunkBytes = 64 << 20
+
+// runState is the progress of an annotate run, checkpointed to a file so that
+// an interrupted run can be resumed (with --resume) from the files that were
+// not yet annotated.
+type runState struct {
+	path string
+	// Args are the command and the files and patterns it was run with.
+	Args []string `json:"args"`
+	// Pending are the files yet to be annotated, in order.
+	Pending []string `json:"pending"`
+	// Done is the number of files annotated so far.
+	Done int `json:"done"`
+}
+
+// newRunState returns the state of a run of command with args, checkpointed to
+// path, or (if empty) to a file in the default cache directory that is specific
+// to the command, args and the working directory.
+func newRunState(path, command string, args []string) (*runState, error) {
+	st := &runState{path: path, Args: append([]string{command}, args...)}
+	if len(path) > 0 {
+		return st, nil
+	}
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return nil, fmt.Errorf("unable to locate the state directory: %v", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	fmt.Fprintln(h, wd)
+	for _, arg := range st.Args {
+		fmt.Fprintln(h, arg)
+	}
+	st.path = filepath.Join(dir, "runs", hex.EncodeToString(h.Sum(nil))[:16]+".state")
+	return st, nil
+}
+
+// load reads the state checkpointed by an earlier run, returning the files it
+// had yet to annotate.
+func (st *runState) load() ([]string, error) {
+	data, err := ioutil.ReadFile(st.path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no interrupted run to resume (%s does not exist)", st.path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", st.path, err)
+	}
+	return st.Pending, nil
+}
+
+// checkpoint records that done more files were annotated, and that pending are
+// yet to be.
+func (st *runState) checkpoint(done int, pending []string) error {
+	st.Done += done
+	st.Pending = pending
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(st.path), 0755); err != nil {
+		return fmt.Errorf("unable to create state directory: %v", err)
+	}
+	// Write atomically, so that an interrupted checkpoint leaves the previous
+	// one intact.
+	tmp := st.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("unable to write state: %v", err)
+	}
+	return os.Rename(tmp, st.path)
+}
+
+// remove deletes the state of a run that completed.
+func (st *runState) remove() error {
+	if err := os.Remove(st.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the commands, which are only exported if
+// --otel-endpoint is set.
+var tracer = otel.Tracer("github.com/asimshankar/visionapi")
+
+// traceFlags configure the tracing of all commands.
+type traceFlags struct {
+	endpoint string
+	provider *sdktrace.TracerProvider
+}
+
+var tracing traceFlags
+
+func (tf *traceFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&tf.endpoint, "otel-endpoint", "", "URL of an OpenTelemetry collector (e.g. http://localhost:4318) to export traces of the loading of images, API requests and writing of results to, over OTLP/HTTP")
+}
+
+// setup exports spans to the collector at tf.endpoint, if set, including those
+// of HTTP requests to the APIs.
+func (tf *traceFlags) setup() error {
+	if len(tf.endpoint) == 0 {
+		return nil
+	}
+	exp, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(tf.endpoint))
+	if err != nil {
+		return fmt.Errorf("invalid --otel-endpoint(%s): %v", tf.endpoint, err)
+	}
+	tf.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "visionapi"))),
+	)
+	otel.SetTracerProvider(tf.provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	// The providers (and the downloads of images) use the default transport,
+	// whose spans time the upload of each request and the response of the
+	// API.
+	http.DefaultTransport = otelhttp.NewTransport(http.DefaultTransport)
+	return nil
+}
+
+// shutdown exports the spans that were not yet.
+func (tf *traceFlags) shutdown() {
+	if tf.provider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tf.provider.Shutdown(ctx); err != nil {
+		slog.Warn("Unable to export traces", "err", err)
+	}
+}
+
+// wrap returns p, with a span per call to Annotate. If tracing is not set up,
+// p is returned as is.
+func (tf *traceFlags) wrap(p vision.Provider) vision.Provider {
+	if tf.provider == nil {
+		return p
+	}
+	return &tracedProvider{p}
+}
+
+type tracedProvider struct {
+	p vision.Provider
+}
+
+func (tp *tracedProvider) Name() string { return tp.p.Name() }
+
+func (tp *tracedProvider) Capabilities() []vision.Feature { return tp.p.Capabilities() }
+
+func (tp *tracedProvider) Annotate(ctx context.Context, images []vision.Image, opts vision.Options) ([]vision.Result, error) {
+	ctx, span := tracer.Start(ctx, "Annotate", trace.WithAttributes(
+		attribute.String("provider", tp.p.Name()),
+		attribute.Int("images", len(images)),
+	))
+	defer span.End()
+	results, err := tp.p.Annotate(ctx, images, opts)
+	if err != nil {
+		spanError(span, err)
+		return nil, err
+	}
+	var failed int
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	span.SetAttributes(attribute.Int("failed", failed))
+	return results, nil
+}
+
+// spanError records err, if not nil, as the failure of span.
+func spanError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
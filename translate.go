@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/asimshankar/visionapi/pkg/vision"
+)
+
+// translatingProvider translates the labels and text of the results of a
+// provider (see vision.TranslateResults) into a language, for --translate-to.
+// Results are cached untranslated, so changing the language does not require
+// annotating images again.
+type translatingProvider struct {
+	vision.Provider
+	translator vision.Translator
+	target     string
+
+	mu    sync.Mutex
+	known map[string]string // Translations, by original.
+}
+
+// newTranslator returns the translator named by --translator: google, or the
+// name of a plugin.
+func newTranslator(ctx context.Context, name string, cfg providerFlags) (vision.Translator, error) {
+	if name == "google" {
+		return vision.NewGoogleTranslator(ctx, cfg.google)
+	}
+	if t, err := vision.NewTranslatorPlugin(name); err == nil {
+		return t, nil
+	}
+	return nil, fmt.Errorf("invalid --translator(%s), must be 'google' or the name of a plugin (%s%s in $PATH)", name, vision.TranslatorPluginPrefix, name)
+}
+
+func newTranslatingProvider(p vision.Provider, t vision.Translator, target string) *translatingProvider {
+	return &translatingProvider{Provider: p, translator: t, target: target, known: make(map[string]string)}
+}
+
+// Annotate fails the images whose results could not be translated.
+func (p *translatingProvider) Annotate(ctx context.Context, images []vision.Image, opts vision.Options) ([]vision.Result, error) {
+	results, err := p.Provider.Annotate(ctx, images, opts)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := vision.TranslateResults(ctx, p.translator, results, p.target, p.known); err != nil {
+		err = fmt.Errorf("unable to translate into %s with %s: %v", p.target, p.translator.Name(), err)
+		for i := range results {
+			if results[i].Err == nil {
+				results[i] = vision.Result{Name: results[i].Name, Err: err}
+			}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asimshankar/visionapi/pkg/preprocess"
+	"github.com/asimshankar/visionapi/pkg/vision"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// videoExtensions are the extensions of video files, whose frames are sampled
+// (with ffmpeg) and annotated as images.
+var videoExtensions = map[string]bool{
+	".m4v": true,
+	".mov": true,
+	".mp4": true,
+}
+
+func isVideo(filename string) bool {
+	return !isURL(filename) && videoExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// videoOptions control the sampling of the frames of videos.
+type videoOptions struct {
+	// interval between the frames of videos.
+	interval time.Duration
+	// gifFrames, if positive, is the number of frames between those of
+	// animated GIFs that are annotated, which are otherwise annotated as
+	// images (i.e. by their first frame).
+	gifFrames int
+}
+
+// splitVideos separates local video files (and GIFs, if vo.gifFrames is set)
+// from images.
+func splitVideos(filenames []string, vo videoOptions) (images, videos []string) {
+	for _, f := range filenames {
+		if isVideo(f) || vo.gifFrames > 0 && !isURL(f) && strings.EqualFold(filepath.Ext(f), ".gif") {
+			videos = append(videos, f)
+		} else {
+			images = append(images, f)
+		}
+	}
+	return images, videos
+}
+
+// sampleFrames extracts a frame every interval of the video in filename, as
+// JPEGs, using ffmpeg (which must be in $PATH).
+func sampleFrames(ctx context.Context, filename string, interval time.Duration) ([][]byte, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("videos require ffmpeg: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "visionapi-frames")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, "-nostdin", "-v", "error", "-i", filename,
+		"-vf", fmt.Sprintf("fps=1/%g", interval.Seconds()), "-q:v", "2", filepath.Join(dir, "%06d.jpg"))
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+			return nil, fmt.Errorf("ffmpeg failed: %v: %s", err, msg)
+		}
+		return nil, fmt.Errorf("ffmpeg failed: %v", err)
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*.jpg"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	frames := make([][]byte, len(names))
+	for i, name := range names {
+		if frames[i], err = ioutil.ReadFile(name); err != nil {
+			return nil, err
+		}
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no frames in video")
+	}
+	return frames, nil
+}
+
+// sampleGIFFrames returns every nth frame of the GIF in filename, as PNGs,
+// and their offsets in seconds.
+func sampleGIFFrames(filename string, n int) ([][]byte, []float64, error) {
+	byts, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read failed: %v", err)
+	}
+	decoded, seconds, err := preprocess.GIFFrames(byts, n)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	frames := make([][]byte, len(decoded))
+	for i, img := range decoded {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, nil, err
+		}
+		frames[i] = buf.Bytes()
+	}
+	return frames, seconds, nil
+}
+
+// annotateVideo annotates frames of the video (or animated GIF) in filename,
+// as per vo, returning a result with the labels of each frame and their merge.
+// Frames are loaded as images, as per lo. The video fails if all of its
+// frames do.
+func annotateVideo(ctx context.Context, p vision.Provider, filename string, vo videoOptions, lo loadOptions, opts vision.Options) (_ vision.Result, err error) {
+	ctx, span := tracer.Start(ctx, "Video", trace.WithAttributes(attribute.String("file", filename)))
+	defer func() {
+		spanError(span, err)
+		span.End()
+	}()
+	result := vision.Result{Name: filename}
+	var (
+		frames  [][]byte
+		offsets []float64
+	)
+	if isVideo(filename) {
+		frames, err = sampleFrames(ctx, filename, vo.interval)
+		for i := range frames {
+			offsets = append(offsets, (time.Duration(i) * vo.interval).Seconds())
+		}
+	} else {
+		frames, offsets, err = sampleGIFFrames(filename, vo.gifFrames)
+	}
+	if err != nil {
+		result.Err = fmt.Errorf("unable to load: %v", err)
+		return result, nil
+	}
+	span.SetAttributes(attribute.Int("frames", len(frames)))
+	var (
+		images  []vision.Image
+		seconds []float64
+		lastErr error
+	)
+	for i, frame := range frames {
+		offset := time.Duration(offsets[i] * float64(time.Second))
+		name := fmt.Sprintf("%s@%v", filename, offset)
+		content, err := prepareImage(ctx, name, frame, lo)
+		if err != nil {
+			slog.Error("Unable to annotate", "file", name, "err", err)
+			lastErr = err
+			continue
+		}
+		images = append(images, vision.Image{Name: name, Content: content})
+		seconds = append(seconds, offsets[i])
+	}
+	var results []vision.Result
+	if len(images) > 0 {
+		if results, err = p.Annotate(ctx, images, opts); err != nil {
+			return result, err
+		}
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			slog.Error("Unable to annotate", "file", r.Name, "err", r.Err)
+			lastErr = r.Err
+			continue
+		}
+		result.Frames = append(result.Frames, vision.Frame{Seconds: seconds[i], Labels: r.Labels})
+	}
+	if len(result.Frames) == 0 {
+		result.Err = fmt.Errorf("none of the %d frames could be annotated: %v", len(frames), lastErr)
+		return result, nil
+	}
+	result.Labels = vision.MergeFrames(result.Frames)
+	return result, nil
+}
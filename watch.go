@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchSettle is how long a file must go unchanged before it is annotated, so
+// that files being copied or written are only annotated once complete.
+const watchSettle = time.Second
+
+// watcher reports the image files created or modified in directories.
+type watcher struct {
+	w         *fsnotify.Watcher
+	recursive bool
+	exclude   []string
+	changed   map[string]time.Time // by file, the time it last changed
+	// annotated maps files to their modification time when last annotated,
+	// so that changes made by annotating them (e.g., with --write-metadata)
+	// do not cause them to be annotated again.
+	annotated map[string]time.Time
+}
+
+// newWatcher watches dirs (and, if recursive, their subdirectories) except for
+// the files and directories matching exclude.
+func newWatcher(dirs []string, recursive bool, exclude []string) (*watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &watcher{
+		w:         fw,
+		recursive: recursive,
+		exclude:   exclude,
+		changed:   make(map[string]time.Time),
+		annotated: make(map[string]time.Time),
+	}
+	for _, dir := range dirs {
+		if err := w.add(dir, false); err != nil {
+			fw.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// add watches dir and, if recursive, its subdirectories. If existing is true
+// (for directories that appeared while watching, e.g. when moved into a
+// watched directory) the images already in them are reported as changed.
+func (w *watcher) add(dir string, existing bool) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if isExcluded(path, w.exclude) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			if existing && imageExtensions[strings.ToLower(filepath.Ext(path))] {
+				w.changed[path] = time.Now()
+			}
+			return nil
+		}
+		if path != dir && !w.recursive {
+			return filepath.SkipDir
+		}
+		if err := w.w.Add(path); err != nil {
+			return err
+		}
+		slog.Debug("Watching", "dir", path)
+		return nil
+	})
+}
+
+// run calls annotate with the files that were created or modified, in batches,
+// until ctx is done.
+func (w *watcher) run(ctx context.Context, annotate func(filenames []string)) error {
+	defer w.w.Close()
+	ticker := time.NewTicker(watchSettle / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-w.w.Errors:
+			slog.Error("Unable to watch", "err", err)
+		case ev := <-w.w.Events:
+			w.handle(ev)
+		case now := <-ticker.C:
+			if ready := w.settled(now); len(ready) > 0 {
+				annotate(ready)
+				for _, f := range ready {
+					if info, err := os.Stat(f); err == nil {
+						w.annotated[f] = info.ModTime()
+					}
+				}
+			}
+		}
+	}
+}
+
+func (w *watcher) handle(ev fsnotify.Event) {
+	if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Write) {
+		return
+	}
+	if isExcluded(ev.Name, w.exclude) {
+		return
+	}
+	info, err := os.Stat(ev.Name)
+	if err != nil {
+		// E.g., a temporary file that was already renamed.
+		return
+	}
+	if info.IsDir() {
+		if w.recursive && ev.Has(fsnotify.Create) {
+			if err := w.add(ev.Name, true); err != nil {
+				slog.Error("Unable to watch", "dir", ev.Name, "err", err)
+			}
+		}
+		return
+	}
+	if imageExtensions[strings.ToLower(filepath.Ext(ev.Name))] {
+		w.changed[ev.Name] = time.Now()
+	}
+}
+
+// settled returns the changed files that have been unchanged for watchSettle,
+// and that were not already annotated as they are.
+func (w *watcher) settled(now time.Time) []string {
+	var ready []string
+	for f, t := range w.changed {
+		if now.Sub(t) < watchSettle {
+			continue
+		}
+		delete(w.changed, f)
+		info, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if t, ok := w.annotated[f]; ok && t.Equal(info.ModTime()) {
+			continue
+		}
+		ready = append(ready, f)
+	}
+	sort.Strings(ready)
+	return ready
+}